@@ -39,7 +39,7 @@ func main() {
 	// 3. 创建子代理工厂
 	factory := func(ctx context.Context, spec middleware.SubAgentSpec) (middleware.SubAgent, error) {
 		// 这里使用 SimpleSubAgent 作为演示
-		// 实际应用中应该创建真正的 Agent 实例
+		// 实际应用中使用 agent.NewSubAgentFactory 创建真正的 Agent 实例
 		execFn := func(ctx context.Context, description string, parentContext map[string]interface{}) (string, error) {
 			return fmt.Sprintf("[%s] 已执行任务: %s\n系统提示: %s\n上下文: %v",
 				spec.Name, description, spec.Prompt, parentContext), nil
@@ -2,9 +2,11 @@ package agent
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"log"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -13,6 +15,7 @@ import (
 	"github.com/wordflowlab/agentsdk/pkg/commands"
 	"github.com/wordflowlab/agentsdk/pkg/events"
 	"github.com/wordflowlab/agentsdk/pkg/middleware"
+	"github.com/wordflowlab/agentsdk/pkg/permission"
 	"github.com/wordflowlab/agentsdk/pkg/provider"
 	"github.com/wordflowlab/agentsdk/pkg/sandbox"
 	"github.com/wordflowlab/agentsdk/pkg/skills"
@@ -51,11 +54,36 @@ type Agent struct {
 	stepCount    int
 	lastSfpIndex int
 	lastBookmark *types.Bookmark
+	sfpMarks     []sfpMark // 安全分叉点,用于按书签回退
+	lineage      []string  // 祖先 Agent ID,从最早的祖先到直接父 Agent
 	createdAt    time.Time
 
 	// 权限管理
+	permissions        *permission.Manager
 	pendingPermissions map[string]chan string // callID -> decision channel
 
+	// 当前 Stream 调用的事件出口(没有 Stream 调用时为 nil)
+	stream *streamSink
+	budget *runBudget // 当前执行的预算计数
+
+	// 执行控制
+	runCancel   context.CancelFunc // 取消当前一轮执行(没有执行时为 nil)
+	runDone     chan struct{}      // 当前一轮执行结束时关闭
+	interrupted bool               // 当前一轮执行被 Interrupt 取消
+	turnStart   int                // 当前一轮执行开始时的消息数,之后的 assistant 消息属于本轮
+	resumeCh    chan struct{}      // Pause 后非空,Resume 时关闭
+
+	// 收件箱: 执行期间收到的消息
+	inbox    []queuedMessage // 当前轮结束后按顺序处理
+	steering []queuedMessage // 在当前轮的下一次模型调用前注入
+
+	// 系统提醒: 在下一次模型调用前合并到最后一条用户消息
+	reminders []pendingReminder
+	todoSteps int // 距上次更新任务列表的模型调用次数
+
+	// 文件追踪: 工具读写过的文件(沙箱内的绝对路径 -> 状态)
+	files map[string]*fileState
+
 	// 控制信号
 	stopCh chan struct{}
 }
@@ -68,10 +96,16 @@ func Create(ctx context.Context, config *types.AgentConfig, deps *Dependencies)
 	}
 
 	// 获取模板
-	template, err := deps.TemplateRegistry.Get(config.TemplateID)
+	template, err := deps.TemplateRegistry.GetVersion(config.TemplateID, config.TemplateVersion)
 	if err != nil {
 		return nil, fmt.Errorf("get template: %w", err)
 	}
+	// 模板在 Agent 之间共享,复制一份,工具手册按 Agent 的工具列表写入系统提示词
+	templateCopy := *template
+	template = &templateCopy
+	if config.SystemPrompt != "" {
+		template.SystemPrompt = config.SystemPrompt
+	}
 
 	// 创建Provider
 	modelConfig := config.ModelConfig
@@ -90,6 +124,24 @@ func Create(ctx context.Context, config *types.AgentConfig, deps *Dependencies)
 		return nil, fmt.Errorf("create provider: %w", err)
 	}
 
+	// 模型调用遇到限流、服务端错误或连接中断时自动重试,每次重试发出告警事件
+	eventBus := events.NewEventBus()
+	retryProvider := provider.NewRetryProvider(prov, modelConfig.Retry)
+	retryProvider.SetRetryHandler(func(info provider.RetryInfo) {
+		eventBus.EmitMonitor(&types.MonitorErrorEvent{
+			Severity: "warn",
+			Phase:    "model",
+			Message:  fmt.Sprintf("model call failed, retrying in %v: %v", info.Delay, info.Err),
+			Detail: map[string]interface{}{
+				"attempt":      info.Attempt,
+				"max_attempts": info.MaxAttempts,
+				"delay_ms":     info.Delay.Milliseconds(),
+				"status_code":  info.StatusCode,
+			},
+		})
+	})
+	prov = retryProvider
+
 	// 创建Sandbox
 	sandboxConfig := config.Sandbox
 	if sandboxConfig == nil {
@@ -104,11 +156,13 @@ func Create(ctx context.Context, config *types.AgentConfig, deps *Dependencies)
 		return nil, fmt.Errorf("create sandbox: %w", err)
 	}
 
-	// 创建工具执行器
-	executor := tools.NewExecutor(tools.ExecutorConfig{
-		MaxConcurrency: 3,
-		DefaultTimeout: 60 * time.Second,
-	})
+	// 创建工具执行器(并发数与超时来自模板运行时配置,未配置时使用执行器默认值)
+	executorConfig := tools.ExecutorConfig{}
+	if template.Runtime != nil {
+		executorConfig.MaxConcurrency = template.Runtime.MaxToolConcurrency
+		executorConfig.DefaultTimeout = time.Duration(template.Runtime.ToolTimeoutMs) * time.Millisecond
+	}
+	executor := tools.NewExecutor(executorConfig)
 
 	// 解析工具列表
 	toolNames := config.Tools
@@ -194,9 +248,17 @@ func Create(ctx context.Context, config *types.AgentConfig, deps *Dependencies)
 
 	// 初始化 Middleware Stack (Phase 6C)
 	var middlewareStack *middleware.Stack
-	if len(config.Middlewares) > 0 {
-		middlewareList := make([]middleware.Middleware, 0, len(config.Middlewares))
-		for _, name := range config.Middlewares {
+	middlewareNames := config.Middlewares
+	if middlewareNames == nil {
+		middlewareNames = template.Middlewares // 使用模板的中间件列表
+	}
+	subAgentMiddleware, err := newSubAgentMiddleware(config, template, deps)
+	if err != nil {
+		return nil, fmt.Errorf("create subagent middleware: %w", err)
+	}
+	if len(middlewareNames) > 0 || subAgentMiddleware != nil {
+		middlewareList := make([]middleware.Middleware, 0, len(middlewareNames)+1)
+		for _, name := range middlewareNames {
 			mw, err := middleware.DefaultRegistry.Create(name, &middleware.MiddlewareFactoryConfig{
 				Provider: prov,
 				AgentID:  config.AgentID,
@@ -209,19 +271,41 @@ func Create(ctx context.Context, config *types.AgentConfig, deps *Dependencies)
 			middlewareList = append(middlewareList, mw)
 			log.Printf("[Agent Create] Middleware loaded: %s (priority: %d)", name, mw.Priority())
 		}
+		// 模板配置了 Runtime.SubAgents 时提供 task 工具
+		if subAgentMiddleware != nil {
+			middlewareList = append(middlewareList, subAgentMiddleware)
+		}
 		if len(middlewareList) > 0 {
 			middlewareStack = middleware.NewStack(middlewareList)
 			log.Printf("[Agent Create] Middleware stack created with %d middlewares", len(middlewareList))
 		}
 	}
 
+	// 合并中间件提供的工具(write_todos、task、fs_* 等),与配置的工具同名时保留配置的工具
+	if middlewareStack != nil {
+		for _, tool := range middlewareStack.Tools() {
+			if _, exists := toolMap[tool.Name()]; exists {
+				log.Printf("[Agent Create] Middleware tool %s shadowed by configured tool", tool.Name())
+				continue
+			}
+			toolMap[tool.Name()] = tool
+			log.Printf("[Agent Create] Middleware tool loaded: %s", tool.Name())
+		}
+	}
+
+	// 创建权限管理器(覆盖配置优先于模板配置)
+	permissionConfig := template.Permission
+	if config.Overrides != nil && config.Overrides.Permission != nil {
+		permissionConfig = config.Overrides.Permission
+	}
+
 	// 创建Agent
 	agent := &Agent{
 		id:                 config.AgentID,
 		template:           template,
 		config:             config,
 		deps:               deps,
-		eventBus:           events.NewEventBus(),
+		eventBus:           eventBus,
 		provider:           prov,
 		sandbox:            sb,
 		executor:           executor,
@@ -233,6 +317,8 @@ func Create(ctx context.Context, config *types.AgentConfig, deps *Dependencies)
 		breakpoint:         types.BreakpointReady,
 		messages:           []types.Message{},
 		toolRecords:        make(map[string]*types.ToolCallRecord),
+		files:              make(map[string]*fileState),
+		permissions:        permission.FromConfig(permissionConfig),
 		pendingPermissions: make(map[string]chan string),
 		createdAt:          time.Now(),
 		stopCh:             make(chan struct{}),
@@ -245,6 +331,7 @@ func Create(ctx context.Context, config *types.AgentConfig, deps *Dependencies)
 	if err := agent.initialize(ctx); err != nil {
 		return nil, fmt.Errorf("initialize agent: %w", err)
 	}
+	agent.remindTodoOnStart()
 
 	return agent, nil
 }
@@ -263,10 +350,11 @@ func (a *Agent) initialize(ctx context.Context) error {
 			a.toolRecords[record.ID] = &record
 		}
 	}
+	a.loadFileActivity()
 
 	// 注意：工具手册已在 Agent 创建时注入，这里不再重复注入
 
-	// 保存Agent信息
+	// 保存Agent信息(恢复或分叉的 Agent 保留创建时间和血缘)
 	info := types.AgentInfo{
 		AgentID:       a.id,
 		TemplateID:    a.template.ID,
@@ -275,6 +363,17 @@ func (a *Agent) initialize(ctx context.Context) error {
 		ConfigVersion: "v1.0.0",
 		MessageCount:  len(a.messages),
 	}
+	if existing, err := a.deps.Store.LoadInfo(ctx, a.id); err == nil && existing != nil && existing.AgentID != "" {
+		a.createdAt = existing.CreatedAt
+		info.CreatedAt = existing.CreatedAt
+		info.Metadata = existing.Metadata
+		if existing.Lineage != nil {
+			info.Lineage = existing.Lineage
+		}
+	}
+	a.lineage = info.Lineage
+	a.lastSfpIndex = safeForkCount(a.messages) - 1
+	a.sfpMarks = []sfpMark{{seq: a.eventBus.GetCursor(), count: safeForkCount(a.messages)}}
 
 	if err := a.deps.Store.SaveInfo(ctx, a.id, info); err != nil {
 		return err
@@ -291,10 +390,17 @@ func (a *Agent) initialize(ctx context.Context) error {
 }
 
 // injectToolManual 注入工具手册到系统提示词
+// 已有的工具手册会被替换;没有工具或工具都没有手册时只移除旧的手册
 func (a *Agent) injectToolManual() {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	// 移除旧的工具手册
+	if strings.Contains(a.template.SystemPrompt, "### Tools Manual") {
+		parts := strings.Split(a.template.SystemPrompt, "### Tools Manual")
+		a.template.SystemPrompt = strings.TrimSpace(parts[0])
+	}
+
 	if len(a.toolMap) == 0 {
 		log.Printf("[injectToolManual] Agent %s: No tools in toolMap, skipping", a.id)
 		return
@@ -302,7 +408,8 @@ func (a *Agent) injectToolManual() {
 
 	// 收集工具手册
 	var sections []string
-	for _, tool := range a.toolMap {
+	for _, name := range a.sortedToolNames() {
+		tool := a.toolMap[name]
 		if prompt := tool.Prompt(); prompt != "" {
 			sections = append(sections, fmt.Sprintf("**%s**\n%s", tool.Name(), prompt))
 			log.Printf("[injectToolManual] Agent %s: Added manual for tool %s", a.id, tool.Name())
@@ -320,21 +427,48 @@ func (a *Agent) injectToolManual() {
 	manualSection := fmt.Sprintf("\n\n### Tools Manual\n\nThe following tools are available for your use. Please read their usage guidance carefully:\n\n%s",
 		strings.Join(sections, "\n\n"))
 
-	// 检查系统提示词是否已包含工具手册
-	if strings.Contains(a.template.SystemPrompt, "### Tools Manual") {
-		// 移除旧的工具手册
-		parts := strings.Split(a.template.SystemPrompt, "### Tools Manual")
-		if len(parts) > 0 {
-			a.template.SystemPrompt = strings.TrimSpace(parts[0])
-		}
-	}
-
 	// 追加新的工具手册
 	oldLength := len(a.template.SystemPrompt)
 	a.template.SystemPrompt += manualSection
 	log.Printf("[injectToolManual] Agent %s: Injected manual, system prompt length: %d -> %d", a.id, oldLength, len(a.template.SystemPrompt))
 }
 
+// sortedToolNames 返回按名称排序的工具列表,保证工具手册和工具 Schema 的顺序稳定
+// 调用方需持有锁
+func (a *Agent) sortedToolNames() []string {
+	names := make([]string, 0, len(a.toolMap))
+	for name := range a.toolMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// exposeThinking 是否向 Progress 通道推送模型的思考内容
+func (a *Agent) exposeThinking() bool {
+	if a.config != nil && a.config.ExposeThinking {
+		return true
+	}
+	return a.template != nil && a.template.Runtime != nil && a.template.Runtime.ExposeThinking
+}
+
+// promptCacheConfig 返回模板的提示词缓存配置
+// 模板未配置时缓存系统提示词、工具定义和对话前缀;显式关闭时返回 nil
+func (a *Agent) promptCacheConfig() *types.PromptCacheConfig {
+	if a.template == nil || a.template.Runtime == nil || a.template.Runtime.PromptCache == nil {
+		return &types.PromptCacheConfig{Enabled: true, System: true, Tools: true, Messages: true}
+	}
+
+	cache := *a.template.Runtime.PromptCache
+	if !cache.Enabled {
+		return nil
+	}
+	if !cache.System && !cache.Tools && !cache.Messages {
+		cache.System, cache.Tools, cache.Messages = true, true, true
+	}
+	return &cache
+}
+
 // ID 返回AgentID
 func (a *Agent) ID() string {
 	return a.id
@@ -342,14 +476,54 @@ func (a *Agent) ID() string {
 
 // Send 发送消息
 func (a *Agent) Send(ctx context.Context, text string) error {
+	return a.SendWithAttachments(ctx, text)
+}
+
+// SendWithAttachments 发送带附件的消息
+// 附件为 *types.ImageBlock 或 *types.DocumentBlock;只设置了 Path 的附件从沙箱读取
+// Agent 正在执行或暂停时消息进入收件箱,当前轮结束后按顺序处理
+// ctx 只用于准备消息,执行不随 ctx 取消;使用 Interrupt 中止执行
+func (a *Agent) SendWithAttachments(ctx context.Context, text string, attachments ...types.ContentBlock) error {
 	a.mu.Lock()
-	defer a.mu.Unlock()
+	message, err := a.prepareUserMessage(ctx, text, attachments)
+	if err != nil {
+		a.mu.Unlock()
+		return err
+	}
+
+	if a.state != types.AgentStateReady {
+		event := a.enqueue(ctx, message)
+		a.mu.Unlock()
+		a.emitMonitor(event)
+		return nil
+	}
+
+	if err := a.appendUserMessage(ctx, message); err != nil {
+		a.mu.Unlock()
+		return err
+	}
+	a.state = types.AgentStateWorking
+	a.mu.Unlock()
 
+	// 触发处理: 执行在后台进行,不随 ctx 取消(与 Stream 一致),使用 Interrupt 中止
+	go a.runTurn(context.WithoutCancel(ctx))
+
+	return nil
+}
+
+// prepareUserMessage 构建用户消息: 执行 slash command、应用 Skills 增强并加载附件
+// 调用方需持有 a.mu
+func (a *Agent) prepareUserMessage(ctx context.Context, text string, attachments []types.ContentBlock) (types.Message, error) {
 	// 检测 slash command
-	if strings.HasPrefix(text, "/") {
+	if strings.HasPrefix(text, "/") && len(attachments) == 0 {
 		return a.handleSlashCommand(ctx, text)
 	}
 
+	resolved, err := a.resolveAttachments(ctx, attachments)
+	if err != nil {
+		return types.Message{}, err
+	}
+
 	// 准备消息内容
 	messageText := text
 
@@ -377,13 +551,26 @@ func (a *Agent) Send(ctx context.Context, text string) error {
 	}
 
 	// 创建用户消息
-	message := types.Message{
-		Role: types.MessageRoleUser,
-		Content: []types.ContentBlock{
-			&types.TextBlock{Text: messageText},
-		},
-	}
+	content := make([]types.ContentBlock, 0, len(resolved)+1)
+	if messageText != "" || len(resolved) == 0 {
+		content = append(content, &types.TextBlock{Text: messageText})
+	}
+	return types.Message{
+		Role:    types.MessageRoleUser,
+		Content: append(content, resolved...),
+	}, nil
+}
+
+// copyMessages 复制当前消息列表,供释放锁之后读取或持久化
+// 执行期间其它 goroutine 会修改 a.messages(引导消息、系统提醒、上下文压缩)
+// 调用方需持有 a.mu
+func (a *Agent) copyMessages() []types.Message {
+	return append([]types.Message(nil), a.messages...)
+}
 
+// appendUserMessage 追加用户消息并持久化
+// 调用方需持有 a.mu
+func (a *Agent) appendUserMessage(ctx context.Context, message types.Message) error {
 	a.messages = append(a.messages, message)
 	a.stepCount++
 
@@ -391,56 +578,106 @@ func (a *Agent) Send(ctx context.Context, text string) error {
 	if err := a.deps.Store.SaveMessages(ctx, a.id, a.messages); err != nil {
 		return fmt.Errorf("save messages: %w", err)
 	}
-
-	// 触发处理
-	go a.processMessages(ctx)
-
 	return nil
 }
 
-// Chat 同步对话(阻塞式)
-func (a *Agent) Chat(ctx context.Context, text string) (*types.CompleteResult, error) {
-	// 发送消息
-	if err := a.Send(ctx, text); err != nil {
-		return nil, err
-	}
-
-	// 等待完成
-	for {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-time.After(100 * time.Millisecond):
-			a.mu.RLock()
-			state := a.state
-			a.mu.RUnlock()
-
-			if state == types.AgentStateReady {
-				// 提取最后的助手回复
-				a.mu.RLock()
-				defer a.mu.RUnlock()
-
-				var text string
-				for i := len(a.messages) - 1; i >= 0; i-- {
-					if a.messages[i].Role == types.MessageRoleAssistant {
-						for _, block := range a.messages[i].Content {
-							if tb, ok := block.(*types.TextBlock); ok {
-								text = tb.Text
-								break
-							}
-						}
-						break
-					}
+// resolveAttachments 校验附件并从沙箱读取只有 Path 的附件
+func (a *Agent) resolveAttachments(ctx context.Context, attachments []types.ContentBlock) ([]types.ContentBlock, error) {
+	if len(attachments) == 0 {
+		return nil, nil
+	}
+
+	vision := a.provider.Capabilities().SupportVision
+	resolved := make([]types.ContentBlock, 0, len(attachments))
+	for i, attachment := range attachments {
+		switch b := attachment.(type) {
+		case *types.ImageBlock:
+			image := *b
+			if image.Data == "" && image.URL == "" {
+				data, mediaType, err := a.readAttachment(ctx, image.Path, image.MediaType)
+				if err != nil {
+					return nil, err
 				}
-
-				return &types.CompleteResult{
-					Status: "ok",
-					Text:   text,
-					Last:   a.lastBookmark,
-				}, nil
+				image.Data, image.MediaType = data, mediaType
 			}
+			if !types.IsImageMediaType(image.MediaType) && image.URL == "" {
+				return nil, fmt.Errorf("attachment %d: unsupported image type %q", i, image.MediaType)
+			}
+			if !vision {
+				return nil, fmt.Errorf("attachment %d: model %s does not support image input", i, a.provider.Config().Model)
+			}
+			resolved = append(resolved, &image)
+
+		case *types.DocumentBlock:
+			doc := *b
+			if doc.Data == "" && doc.URL == "" {
+				data, mediaType, err := a.readAttachment(ctx, doc.Path, doc.MediaType)
+				if err != nil {
+					return nil, err
+				}
+				doc.Data, doc.MediaType = data, mediaType
+			}
+			// 纯文本文档会以文本形式发送,PDF 需要模型支持视觉
+			if !types.IsTextMediaType(doc.MediaType) && !vision {
+				return nil, fmt.Errorf("attachment %d: model %s does not support document input", i, a.provider.Config().Model)
+			}
+			resolved = append(resolved, &doc)
+
+		default:
+			return nil, fmt.Errorf("attachment %d: unsupported block type %T", i, attachment)
+		}
+	}
+
+	return resolved, nil
+}
+
+// readAttachment 从沙箱读取附件,返回 base64 数据和媒体类型
+func (a *Agent) readAttachment(ctx context.Context, path, mediaType string) (string, string, error) {
+	if path == "" {
+		return "", "", fmt.Errorf("attachment requires data, url or path")
+	}
+	if mediaType == "" {
+		mediaType = types.MediaTypeFromPath(path)
+	}
+	if mediaType == "" {
+		return "", "", fmt.Errorf("unknown media type for attachment %s", path)
+	}
+
+	content, err := a.sandbox.FS().Read(ctx, path)
+	if err != nil {
+		return "", "", fmt.Errorf("read attachment %s: %w", path, err)
+	}
+	return base64.StdEncoding.EncodeToString([]byte(content)), mediaType, nil
+}
+
+// Chat 同步对话(阻塞式)
+// 基于 Stream 实现,收集整轮执行后返回最终回复;opts 与 Stream 相同(如 WithBudget)
+// 工具调用需要审批时立即返回 Status 为 "paused" 的结果,PermissionIDs 为待审批的调用
+func (a *Agent) Chat(ctx context.Context, text string, opts ...Option) (*types.CompleteResult, error) {
+	result := &types.CompleteResult{Status: "ok"}
+	streamOpts := append([]Option{}, opts...)
+	streamOpts = append(streamOpts, func(c *streamConfig) { c.detachOnPause = true })
+
+	for event, err := range a.Stream(ctx, text, streamOpts...) {
+		if err != nil {
+			return nil, err
+		}
+		switch StreamEventKind(event) {
+		case StreamEventFinal:
+			result.Text = assistantText(event.Content)
+			result.Reason, _ = event.Metadata["reason"].(string)
+		case StreamEventPaused:
+			// 等待审批: 返回待审批的调用,Decide 后在后台继续执行
+			result.Status = "paused"
+			result.PermissionIDs, _ = event.Metadata["permission_ids"].([]string)
 		}
 	}
+
+	a.mu.RLock()
+	result.Last = a.lastBookmark
+	a.mu.RUnlock()
+
+	return result, nil
 }
 
 // Subscribe 订阅事件
@@ -461,6 +698,7 @@ func (a *Agent) Status() *types.AgentStatus {
 		LastBookmark: a.lastBookmark,
 		Cursor:       a.eventBus.GetCursor(),
 		Breakpoint:   a.breakpoint,
+		RecentFiles:  a.getRecentFiles(),
 	}
 }
 
@@ -468,6 +706,16 @@ func (a *Agent) Status() *types.AgentStatus {
 func (a *Agent) Close() error {
 	close(a.stopCh)
 
+	// 取消正在进行的执行
+	a.mu.RLock()
+	cancel := a.runCancel
+	a.mu.RUnlock()
+	if cancel != nil {
+		cancel()
+	}
+
+	a.unwatchFiles()
+
 	// 通知 Middleware Agent 停止 (Phase 6C)
 	if a.middlewareStack != nil {
 		ctx := context.Background()
@@ -483,11 +731,11 @@ func (a *Agent) Close() error {
 	return a.provider.Close()
 }
 
-// handleSlashCommand 处理 slash command
-func (a *Agent) handleSlashCommand(ctx context.Context, text string) error {
+// handleSlashCommand 处理 slash command,返回命令生成的用户消息
+func (a *Agent) handleSlashCommand(ctx context.Context, text string) (types.Message, error) {
 	if a.commandExecutor == nil {
 		log.Printf("[Command] ERROR: Slash commands not enabled for agent %s", a.id)
-		return fmt.Errorf("slash commands not enabled")
+		return types.Message{}, fmt.Errorf("slash commands not enabled")
 	}
 
 	// 解析命令和参数
@@ -505,40 +753,18 @@ func (a *Agent) handleSlashCommand(ctx context.Context, text string) error {
 	message, err := a.commandExecutor.Execute(ctx, commandName, args)
 	if err != nil {
 		log.Printf("[Command] ERROR: Agent %s failed to execute /%s: %v", a.id, commandName, err)
-		return fmt.Errorf("execute command: %w", err)
+		return types.Message{}, fmt.Errorf("execute command: %w", err)
 	}
 
 	log.Printf("[Command] Agent %s: Command /%s executed successfully, generated message length: %d", a.id, commandName, len(message))
 
 	// 将命令消息作为用户消息发送
-	userMessage := types.Message{
+	return types.Message{
 		Role: types.MessageRoleUser,
 		Content: []types.ContentBlock{
 			&types.TextBlock{Text: message},
 		},
-	}
-
-	a.messages = append(a.messages, userMessage)
-	a.stepCount++
-
-	// 持久化
-	if err := a.deps.Store.SaveMessages(ctx, a.id, a.messages); err != nil {
-		return fmt.Errorf("save messages: %w", err)
-	}
-
-	log.Printf("[Command] Agent %s: Command /%s processing started", a.id, commandName)
-
-	// 触发处理
-	go a.processMessages(ctx)
-
-	return nil
-}
-
-// getRecentFiles 获取最近访问的文件列表
-func (a *Agent) getRecentFiles() []string {
-	// TODO: 实现文件追踪逻辑
-	// 可以从 toolRecords 中提取最近读写的文件
-	return []string{}
+	}, nil
 }
 
 // generateAgentID 生成AgentID
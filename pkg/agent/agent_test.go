@@ -2,6 +2,7 @@ package agent
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
@@ -166,3 +167,280 @@ func setupTestDeps(t *testing.T) *Dependencies {
 		TemplateRegistry: templateRegistry,
 	}
 }
+
+// newMockAgent 使用模拟提供商创建测试 Agent
+func newMockAgent(t *testing.T, mp *provider.MockProvider, configure ...func(*types.AgentConfig)) *Agent {
+	t.Helper()
+
+	deps := setupTestDeps(t)
+	deps.ProviderFactory = mp
+
+	config := &types.AgentConfig{
+		TemplateID:  "test-template",
+		ModelConfig: &types.ModelConfig{Provider: "mock", Model: "mock-model"},
+		Sandbox: &types.SandboxConfig{
+			Kind:    types.SandboxKindMock,
+			WorkDir: "/tmp/test",
+		},
+	}
+	for _, fn := range configure {
+		fn(config)
+	}
+
+	ag, err := Create(context.Background(), config, deps)
+	if err != nil {
+		t.Fatalf("Failed to create agent: %v", err)
+	}
+	t.Cleanup(func() { ag.Close() })
+	return ag
+}
+
+func TestAgentChat_ToolLoopWithMockProvider(t *testing.T) {
+	mp := provider.NewMockProvider(
+		provider.MockToolStep(provider.MockToolCall{
+			ID:          "call_write",
+			Name:        "fs_write",
+			PartialJSON: []string{`{"path":"hello.txt",`, `"content":"hi"}`},
+		}),
+		provider.MockStep{Text: "File written.", Usage: &provider.TokenUsage{InputTokens: 30, OutputTokens: 4}},
+	)
+	ag := newMockAgent(t, mp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := ag.Chat(ctx, "write hello.txt")
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if result.Text != "File written." {
+		t.Errorf("Expected final text 'File written.', got %q", result.Text)
+	}
+
+	// 第二次模型调用应看到工具结果
+	second, ok := mp.Request(1)
+	if !ok {
+		t.Fatalf("Expected a second model call, got %d", len(mp.Requests()))
+	}
+	last := second.Messages[len(second.Messages)-1]
+	tr, ok := last.Content[0].(*types.ToolResultBlock)
+	if !ok || tr.ToolUseID != "call_write" || tr.IsError {
+		t.Errorf("Expected successful tool result for call_write, got %+v", last.Content[0])
+	}
+
+	if content, err := ag.sandbox.FS().Read(ctx, "hello.txt"); err != nil || content != "hi" {
+		t.Errorf("Expected hello.txt to contain 'hi', got %q (%v)", content, err)
+	}
+	if mp.Remaining() != 0 {
+		t.Errorf("Expected all scripted steps to be consumed, %d left", mp.Remaining())
+	}
+}
+
+func TestAgentChat_ExposeThinking(t *testing.T) {
+	mp := provider.NewMockProvider(
+		provider.MockStep{
+			Thinking: "Need to create the file first.",
+			ToolCalls: []provider.MockToolCall{{
+				ID:    "call_write",
+				Name:  "fs_write",
+				Input: map[string]interface{}{"path": "a.txt", "content": "a"},
+			}},
+		},
+		provider.MockTextStep("Done."),
+	)
+	ag := newMockAgent(t, mp, func(config *types.AgentConfig) {
+		config.ExposeThinking = true
+	})
+
+	eventCh := ag.Subscribe([]types.AgentChannel{types.ChannelProgress}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := ag.Chat(ctx, "create a.txt"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	// 收集思考事件
+	var starts, ends int
+	thinking := ""
+	for {
+		select {
+		case envelope := <-eventCh:
+			switch evt := envelope.Event.(type) {
+			case *types.ProgressThinkChunkStartEvent:
+				starts++
+			case *types.ProgressThinkChunkEvent:
+				thinking += evt.Delta
+			case *types.ProgressThinkChunkEndEvent:
+				ends++
+			}
+			continue
+		default:
+		}
+		break
+	}
+	if starts != 1 || ends != 1 || thinking != "Need to create the file first." {
+		t.Errorf("Expected one think chunk with full text, got starts=%d ends=%d text=%q", starts, ends, thinking)
+	}
+
+	// 工具调用续写时思考块(含签名)应随 assistant 消息回传
+	second, ok := mp.Request(1)
+	if !ok {
+		t.Fatalf("Expected a second model call")
+	}
+	var assistant *types.Message
+	for i := range second.Messages {
+		if second.Messages[i].Role == types.MessageRoleAssistant {
+			assistant = &second.Messages[i]
+		}
+	}
+	if assistant == nil || len(assistant.Content) == 0 {
+		t.Fatalf("Expected assistant message in second request")
+	}
+	tb, ok := assistant.Content[0].(*types.ThinkingBlock)
+	if !ok || tb.Thinking != "Need to create the file first." || tb.Signature == "" {
+		t.Errorf("Expected signed thinking block first, got %+v", assistant.Content[0])
+	}
+}
+
+func TestAgentChat_ThinkingHiddenByDefault(t *testing.T) {
+	mp := provider.NewMockProvider(provider.MockStep{Thinking: "secret", Text: "ok"})
+	ag := newMockAgent(t, mp)
+
+	eventCh := ag.Subscribe([]types.AgentChannel{types.ChannelProgress}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := ag.Chat(ctx, "hi")
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if result.Text != "ok" {
+		t.Errorf("Expected text 'ok', got %q", result.Text)
+	}
+
+	for {
+		select {
+		case envelope := <-eventCh:
+			switch envelope.Event.(type) {
+			case *types.ProgressThinkChunkStartEvent, *types.ProgressThinkChunkEvent, *types.ProgressThinkChunkEndEvent:
+				t.Errorf("Expected no think events without ExposeThinking, got %T", envelope.Event)
+			}
+			continue
+		default:
+		}
+		break
+	}
+}
+
+func TestAgentPromptCacheConfig(t *testing.T) {
+	mp := provider.NewMockProvider(provider.MockTextStep("ok"))
+	ag := newMockAgent(t, mp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := ag.Chat(ctx, "hi"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	// 模板未配置时默认全部缓存
+	req, ok := mp.Request(0)
+	if !ok {
+		t.Fatalf("Expected a model call")
+	}
+	cache := req.Options.PromptCache
+	if cache == nil || !cache.Enabled || !cache.System || !cache.Tools || !cache.Messages {
+		t.Errorf("Expected default prompt cache on everything, got %+v", cache)
+	}
+
+	// 只开启时缓存全部
+	ag.template.Runtime = &types.AgentTemplateRuntime{PromptCache: &types.PromptCacheConfig{Enabled: true, TTL: "1h"}}
+	if cache := ag.promptCacheConfig(); cache == nil || !cache.System || !cache.Tools || !cache.Messages || cache.TTL != "1h" {
+		t.Errorf("Expected enabled config to cache everything, got %+v", cache)
+	}
+
+	// 指定部分时只缓存指定部分
+	ag.template.Runtime.PromptCache = &types.PromptCacheConfig{Enabled: true, System: true}
+	if cache := ag.promptCacheConfig(); cache == nil || !cache.System || cache.Tools || cache.Messages {
+		t.Errorf("Expected only system prompt to be cached, got %+v", cache)
+	}
+
+	// 显式关闭
+	ag.template.Runtime.PromptCache = &types.PromptCacheConfig{Enabled: false}
+	if cache := ag.promptCacheConfig(); cache != nil {
+		t.Errorf("Expected prompt cache to be disabled, got %+v", cache)
+	}
+}
+
+func TestAgentCreate_SystemPromptOverride(t *testing.T) {
+	// Metadata 由调用方使用,其中的 system_prompt 不影响系统提示词
+	ag := newMockAgent(t, provider.NewMockProvider(), func(config *types.AgentConfig) {
+		config.Metadata = map[string]interface{}{"system_prompt": "from metadata"}
+	})
+	if !strings.HasPrefix(ag.template.SystemPrompt, "You are a test assistant.") {
+		t.Errorf("Expected template prompt, got %q", ag.template.SystemPrompt)
+	}
+
+	ag = newMockAgent(t, provider.NewMockProvider(), func(config *types.AgentConfig) {
+		config.SystemPrompt = "You are an override."
+	})
+	if !strings.HasPrefix(ag.template.SystemPrompt, "You are an override.") {
+		t.Errorf("Expected overridden prompt, got %q", ag.template.SystemPrompt)
+	}
+}
+
+func TestAgentSendWithAttachments(t *testing.T) {
+	mp := provider.NewMockProvider(provider.MockTextStep("A login form."))
+	caps := mp.Capabilities()
+	caps.SupportVision = true
+	mp.SetCapabilities(caps)
+	ag := newMockAgent(t, mp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := ag.sandbox.FS().Write(ctx, "shot.png", "\x89PNG fake"); err != nil {
+		t.Fatalf("Failed to write screenshot: %v", err)
+	}
+	if err := ag.SendWithAttachments(ctx, "what is this?", &types.ImageBlock{Path: "shot.png"}); err != nil {
+		t.Fatalf("SendWithAttachments failed: %v", err)
+	}
+
+	// 等待模型调用
+	for len(mp.Requests()) == 0 {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("Timed out waiting for model call")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	req, _ := mp.Request(0)
+	content := req.Messages[len(req.Messages)-1].Content
+	if len(content) != 2 {
+		t.Fatalf("Expected text and image blocks, got %d", len(content))
+	}
+	image, ok := content[1].(*types.ImageBlock)
+	if !ok || image.MediaType != "image/png" || image.Data == "" || image.Path != "shot.png" {
+		t.Errorf("Expected image loaded from sandbox, got %+v", content[1])
+	}
+}
+
+func TestAgentSendWithAttachments_RequiresVision(t *testing.T) {
+	mp := provider.NewMockProvider()
+	ag := newMockAgent(t, mp)
+
+	ctx := context.Background()
+	err := ag.SendWithAttachments(ctx, "what is this?", &types.ImageBlock{MediaType: "image/png", Data: "aGk="})
+	if err == nil {
+		t.Fatalf("Expected error for image input without vision support")
+	}
+
+	// 纯文本文档不需要视觉能力
+	if err := ag.SendWithAttachments(ctx, "summarize", &types.DocumentBlock{MediaType: "text/plain", Data: "aGk="}); err != nil {
+		t.Errorf("Expected text document to be accepted, got %v", err)
+	}
+}
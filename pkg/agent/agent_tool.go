@@ -0,0 +1,184 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/wordflowlab/agentsdk/pkg/tools"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// AgentToolConfig 把 Agent 作为工具使用的配置
+// Agent 与 Config 二选一:Agent 复用同一个 Agent(对话历史在多次调用间保留),
+// Config 每次调用按配置创建新的 Agent,执行完毕后关闭
+type AgentToolConfig struct {
+	Name        string
+	Description string
+
+	// Agent 使用已有的 Agent
+	Agent *Agent
+
+	// Config 子 Agent 配置,AgentID 每次调用重新生成
+	Config *types.AgentConfig
+	// Deps 创建子 Agent 的依赖
+	Deps *Dependencies
+	// SystemPrompt 覆盖模板的系统提示词(可选)
+	SystemPrompt string
+}
+
+// AgentToolResult Agent 工具的执行结果
+type AgentToolResult struct {
+	AgentID string
+	Text    string
+	Usage   types.MonitorTokenUsageEvent // 各次模型调用的用量之和
+}
+
+// AgentTool 把 Agent 包装为工具,输入任务和上下文,运行 Agent 直到完成并返回最终回复
+type AgentTool struct {
+	config AgentToolConfig
+}
+
+// NewAgentTool 创建 Agent 工具
+func NewAgentTool(config *AgentToolConfig) (*AgentTool, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("agent tool name is required")
+	}
+	if config.Agent == nil && (config.Config == nil || config.Deps == nil) {
+		return nil, fmt.Errorf("agent tool %s requires an agent or a config with dependencies", config.Name)
+	}
+	return &AgentTool{config: *config}, nil
+}
+
+func (t *AgentTool) Name() string {
+	return t.config.Name
+}
+
+func (t *AgentTool) Description() string {
+	if t.config.Description != "" {
+		return t.config.Description
+	}
+	return "Delegate a task to another agent and return its final answer"
+}
+
+func (t *AgentTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"task": map[string]interface{}{
+				"type":        "string",
+				"description": "Complete, self-contained description of the task for the agent",
+			},
+			"context": map[string]interface{}{
+				"type":        "object",
+				"description": "Optional structured context the agent needs to complete the task",
+			},
+		},
+		"required": []string{"task"},
+	}
+}
+
+func (t *AgentTool) Execute(ctx context.Context, input map[string]interface{}, tc *tools.ToolContext) (interface{}, error) {
+	task, ok := input["task"].(string)
+	if !ok || task == "" {
+		return nil, fmt.Errorf("task must be a non-empty string")
+	}
+	taskContext, _ := input["context"].(map[string]interface{})
+
+	result, err := t.Run(ctx, task, taskContext)
+	if err != nil {
+		return map[string]interface{}{
+			"ok":    false,
+			"error": err.Error(),
+		}, nil
+	}
+
+	return map[string]interface{}{
+		"ok":       true,
+		"agent_id": result.AgentID,
+		"text":     result.Text,
+		"usage": map[string]interface{}{
+			"input_tokens":  result.Usage.InputTokens,
+			"output_tokens": result.Usage.OutputTokens,
+			"total_tokens":  result.Usage.TotalTokens,
+		},
+	}, nil
+}
+
+func (t *AgentTool) Prompt() string {
+	return fmt.Sprintf(`Use %s to hand off a self-contained task to another agent.
+
+- The agent does not see this conversation. Put everything it needs in "task" and "context".
+- Only the agent's final answer is returned, not its intermediate steps.`, t.config.Name)
+}
+
+// Run 运行 Agent 完成任务
+func (t *AgentTool) Run(ctx context.Context, task string, taskContext map[string]interface{}) (*AgentToolResult, error) {
+	ag := t.config.Agent
+	if ag == nil {
+		var err error
+		if ag, err = t.newAgent(ctx); err != nil {
+			return nil, err
+		}
+		defer ag.Close()
+	}
+
+	message, err := taskMessage(task, taskContext)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[AgentTool] %s delegating to agent %s: %s", t.config.Name, ag.ID(), truncate(task, 50))
+
+	result := &AgentToolResult{AgentID: ag.ID()}
+	streamOpts := []Option{func(c *streamConfig) { c.detachOnPause = true }}
+	for event, err := range ag.Stream(ctx, message, streamOpts...) {
+		if err != nil {
+			return nil, fmt.Errorf("agent %s: %w", ag.ID(), err)
+		}
+		switch StreamEventKind(event) {
+		case StreamEventUsage:
+			if usage, ok := event.Metadata["usage"].(*types.MonitorTokenUsageEvent); ok {
+				result.Usage.InputTokens += usage.InputTokens
+				result.Usage.OutputTokens += usage.OutputTokens
+				result.Usage.CacheCreationInputTokens += usage.CacheCreationInputTokens
+				result.Usage.CacheReadInputTokens += usage.CacheReadInputTokens
+				result.Usage.TotalTokens += usage.TotalTokens
+			}
+		case StreamEventFinal:
+			result.Text = assistantText(event.Content)
+		case StreamEventPaused:
+			return nil, fmt.Errorf("agent %s paused waiting for tool approval", ag.ID())
+		}
+	}
+
+	return result, nil
+}
+
+// newAgent 按配置创建子 Agent,SystemPrompt 非空时覆盖模板的系统提示词
+func (t *AgentTool) newAgent(ctx context.Context) (*Agent, error) {
+	config := *t.config.Config
+	config.AgentID = ""
+	if t.config.SystemPrompt != "" {
+		config.SystemPrompt = t.config.SystemPrompt
+	}
+
+	ag, err := Create(ctx, &config, t.config.Deps)
+	if err != nil {
+		return nil, fmt.Errorf("create agent: %w", err)
+	}
+	return ag, nil
+}
+
+// taskMessage 把任务和上下文组合为发送给 Agent 的消息
+func taskMessage(task string, taskContext map[string]interface{}) (string, error) {
+	if len(taskContext) == 0 {
+		return task, nil
+	}
+	data, err := json.MarshalIndent(taskContext, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encode task context: %w", err)
+	}
+	return fmt.Sprintf("%s\n\n<context>\n%s\n</context>", task, data), nil
+}
@@ -0,0 +1,99 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/provider"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+func TestAgentTool_RunsChildAgent(t *testing.T) {
+	mp := provider.NewMockProvider(provider.MockStep{
+		Text:  "child done",
+		Usage: &provider.TokenUsage{InputTokens: 10, OutputTokens: 5},
+	})
+	deps := setupTestDeps(t)
+	deps.ProviderFactory = mp
+
+	tool, err := NewAgentTool(&AgentToolConfig{
+		Name: "helper",
+		Config: &types.AgentConfig{
+			TemplateID:  "test-template",
+			ModelConfig: &types.ModelConfig{Provider: "mock", Model: "mock-model"},
+			Sandbox:     &types.SandboxConfig{Kind: types.SandboxKindMock, WorkDir: "/tmp/test"},
+		},
+		Deps:         deps,
+		SystemPrompt: "You are a helper.",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create agent tool: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	output, err := tool.Execute(ctx, map[string]interface{}{
+		"task":    "summarize the repo",
+		"context": map[string]interface{}{"branch": "main"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	result := output.(map[string]interface{})
+	if result["ok"] != true || result["text"] != "child done" {
+		t.Fatalf("Unexpected result: %+v", result)
+	}
+	if usage := result["usage"].(map[string]interface{}); usage["total_tokens"] != int64(15) {
+		t.Errorf("Expected usage of the child agent, got %+v", usage)
+	}
+
+	req, _ := mp.Request(0)
+	if !strings.HasPrefix(req.System, "You are a helper.") {
+		t.Errorf("Expected overridden system prompt, got %q", req.System)
+	}
+	if len(req.Messages) != 1 {
+		t.Fatalf("Expected isolated history with one message, got %d", len(req.Messages))
+	}
+	text := req.Messages[0].Content[0].(*types.TextBlock).Text
+	if !strings.Contains(text, "summarize the repo") || !strings.Contains(text, `"branch": "main"`) {
+		t.Errorf("Expected task and context in message, got %q", text)
+	}
+
+	// 缺少 task 时报错
+	if _, err := tool.Execute(ctx, map[string]interface{}{}, nil); err == nil {
+		t.Error("Expected error for missing task")
+	}
+}
+
+func TestAgentTool_ExistingAgentKeepsHistory(t *testing.T) {
+	mp := provider.NewMockProvider(provider.MockTextStep("first"), provider.MockTextStep("second"))
+	ag := newMockAgent(t, mp)
+
+	tool, err := NewAgentTool(&AgentToolConfig{Name: "assistant", Agent: ag})
+	if err != nil {
+		t.Fatalf("Failed to create agent tool: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, expected := range []string{"first", "second"} {
+		result, err := tool.Run(ctx, "task "+expected, nil)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if result.Text != expected || result.AgentID != ag.ID() {
+			t.Errorf("Expected %q from %s, got %+v", expected, ag.ID(), result)
+		}
+	}
+	if req, _ := mp.Request(1); len(req.Messages) != 3 {
+		t.Errorf("Expected the agent to keep its history, got %d messages", len(req.Messages))
+	}
+
+	if _, err := NewAgentTool(&AgentToolConfig{Name: "broken"}); err == nil {
+		t.Error("Expected error without agent or config")
+	}
+}
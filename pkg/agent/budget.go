@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// wrapUpPrompt 预算耗尽时追加给模型的收尾指令
+const wrapUpPrompt = "The run budget for this request has been reached (%s). Do not call any more tools. Using only the information gathered so far, give your best final answer now, and mention briefly what is left unfinished."
+
+// errMaxDuration 墙钟时间预算耗尽时取消执行的原因
+var errMaxDuration = errors.New("run budget: max duration exceeded")
+
+// runBudget 单轮执行的预算计数
+type runBudget struct {
+	mu           sync.Mutex
+	limits       types.RunBudget
+	startedAt    time.Time
+	steps        int
+	toolCalls    int
+	inputTokens  int
+	outputTokens int
+	toolsDenied  bool // 有工具调用因超出预算被拒绝
+}
+
+// newRunBudget 创建预算计数,limits 为空时不做任何限制
+func newRunBudget(limits *types.RunBudget) *runBudget {
+	b := &runBudget{startedAt: time.Now()}
+	if limits != nil {
+		b.limits = *limits
+	}
+	return b
+}
+
+// addStep 记录一次模型调用
+func (b *runBudget) addStep() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.steps++
+}
+
+// addUsage 记录一次模型调用的 Token 用量
+func (b *runBudget) addUsage(inputTokens, outputTokens int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.inputTokens += inputTokens
+	b.outputTokens += outputTokens
+}
+
+// reserveToolCalls 申请 n 次工具调用,返回预算内允许执行的次数
+func (b *runBudget) reserveToolCalls(n int) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	allowed := n
+	if b.limits.MaxToolCalls > 0 {
+		if remaining := b.limits.MaxToolCalls - b.toolCalls; remaining < allowed {
+			allowed = max(remaining, 0)
+		}
+	}
+	b.toolCalls += allowed
+	if allowed < n {
+		b.toolsDenied = true
+	}
+	return allowed
+}
+
+// exceeded 返回已耗尽的预算(DoneReason),未耗尽时返回空字符串
+// 在每次模型调用前检查;墙钟时间预算另由 withDeadline 打断进行中的调用
+func (b *runBudget) exceeded() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	l := b.limits
+	switch {
+	case l.MaxDurationMs > 0 && time.Since(b.startedAt) >= time.Duration(l.MaxDurationMs)*time.Millisecond:
+		return types.DoneReasonMaxDuration
+	case l.MaxSteps > 0 && b.steps >= l.MaxSteps:
+		return types.DoneReasonMaxSteps
+	case b.toolsDenied:
+		return types.DoneReasonMaxToolCalls
+	case l.MaxInputTokens > 0 && b.inputTokens >= l.MaxInputTokens:
+		return types.DoneReasonMaxInputTokens
+	case l.MaxOutputTokens > 0 && b.outputTokens >= l.MaxOutputTokens:
+		return types.DoneReasonMaxOutputTokens
+	}
+	return ""
+}
+
+// withDeadline 按墙钟时间预算为 ctx 设置截止时间,超时的 Cause 为 errMaxDuration
+// 没有墙钟时间预算时原样返回 ctx
+func (b *runBudget) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if b.limits.MaxDurationMs <= 0 {
+		return ctx, func() {}
+	}
+	deadline := b.startedAt.Add(time.Duration(b.limits.MaxDurationMs) * time.Millisecond)
+	return context.WithDeadlineCause(ctx, deadline, errMaxDuration)
+}
+
+// runBudgetLimits 本次调用生效的预算: 调用时指定的预算优先,否则使用模板配置
+func (a *Agent) runBudgetLimits(override *types.RunBudget) *types.RunBudget {
+	if override != nil {
+		return override
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.template != nil && a.template.Runtime != nil {
+		return a.template.Runtime.Budget
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/provider"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// listStep 调用一次 fs_read 的脚本步骤
+func listStep(id string) provider.MockStep {
+	return provider.MockToolStep(provider.MockToolCall{
+		ID:          id,
+		Name:        "fs_read",
+		PartialJSON: []string{`{"path":"missing.txt"}`},
+	})
+}
+
+func TestAgentBudget_MaxSteps(t *testing.T) {
+	mp := provider.NewMockProvider(listStep("call_1"), listStep("call_2"), listStep("call_3"))
+	ag := newMockAgent(t, mp)
+	ag.template.Runtime = &types.AgentTemplateRuntime{Budget: &types.RunBudget{MaxSteps: 2}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := ag.Chat(ctx, "loop")
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if result.Reason != types.DoneReasonMaxSteps {
+		t.Errorf("Expected reason %s, got %q", types.DoneReasonMaxSteps, result.Reason)
+	}
+	if len(mp.Requests()) != 2 || mp.Remaining() != 1 {
+		t.Errorf("Expected exactly 2 model calls, got %d (%d left)", len(mp.Requests()), mp.Remaining())
+	}
+	if ag.Status().State != types.AgentStateReady {
+		t.Errorf("Expected agent to be Ready, got %s", ag.Status().State)
+	}
+}
+
+func TestAgentBudget_MaxToolCallsWithWrapUp(t *testing.T) {
+	mp := provider.NewMockProvider(
+		provider.MockToolStep(
+			provider.MockToolCall{ID: "call_a", Name: "fs_read", PartialJSON: []string{`{"path":"a.txt"}`}},
+			provider.MockToolCall{ID: "call_b", Name: "fs_read", PartialJSON: []string{`{"path":"b.txt"}`}},
+		),
+		provider.MockStep{Text: "Partial summary.", ToolCalls: []provider.MockToolCall{
+			{ID: "call_c", Name: "fs_read", PartialJSON: []string{`{"path":"c.txt"}`}},
+		}},
+	)
+	ag := newMockAgent(t, mp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := ag.Chat(ctx, "read both", WithBudget(&types.RunBudget{MaxToolCalls: 1, WrapUp: true}))
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if result.Reason != types.DoneReasonMaxToolCalls || result.Text != "Partial summary." {
+		t.Errorf("Expected wrap-up answer with reason %s, got %q / %q", types.DoneReasonMaxToolCalls, result.Reason, result.Text)
+	}
+
+	// 收尾请求: 第二个工具被拒绝,最后附加收尾指令
+	wrapUp, ok := mp.Request(1)
+	if !ok {
+		t.Fatalf("Expected a wrap-up model call")
+	}
+	last := wrapUp.Messages[len(wrapUp.Messages)-1]
+	if len(last.Content) != 3 {
+		t.Fatalf("Expected 2 tool results and the wrap-up prompt, got %d blocks", len(last.Content))
+	}
+	if tr := last.Content[1].(*types.ToolResultBlock); tr.ToolUseID != "call_b" || !tr.IsError {
+		t.Errorf("Expected call_b to be rejected, got %+v", tr)
+	}
+	if tb, ok := last.Content[2].(*types.TextBlock); !ok || !strings.Contains(tb.Text, types.DoneReasonMaxToolCalls) {
+		t.Errorf("Expected wrap-up prompt, got %+v", last.Content[2])
+	}
+
+	// 收尾指令不持久化,收尾回复中的工具调用被丢弃
+	ag.mu.RLock()
+	defer ag.mu.RUnlock()
+	if n := len(ag.messages[len(ag.messages)-2].Content); n != 2 {
+		t.Errorf("Expected wrap-up prompt not to be persisted, got %d blocks", n)
+	}
+	for _, block := range ag.messages[len(ag.messages)-1].Content {
+		if _, ok := block.(*types.ToolUseBlock); ok {
+			t.Errorf("Expected tool calls to be dropped from the wrap-up answer")
+		}
+	}
+}
+
+func TestAgentBudget_MaxOutputTokens(t *testing.T) {
+	step := listStep("call_1")
+	step.Usage = &provider.TokenUsage{InputTokens: 100, OutputTokens: 50}
+	mp := provider.NewMockProvider(step, provider.MockTextStep("never"))
+	ag := newMockAgent(t, mp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := ag.Chat(ctx, "go", WithBudget(&types.RunBudget{MaxOutputTokens: 50}))
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if result.Reason != types.DoneReasonMaxOutputTokens {
+		t.Errorf("Expected reason %s, got %q", types.DoneReasonMaxOutputTokens, result.Reason)
+	}
+	if mp.Remaining() != 1 {
+		t.Errorf("Expected the run to stop before the second model call")
+	}
+}
+
+func TestAgentBudget_MaxDurationInterruptsModelCall(t *testing.T) {
+	mp := provider.NewMockProvider(
+		provider.MockStep{Text: "too late", Delay: 5 * time.Second},
+		provider.MockTextStep("Best effort answer."),
+	)
+	ag := newMockAgent(t, mp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 进行中的模型调用在墙钟时间预算耗尽时被打断,收尾调用不受截止时间限制
+	start := time.Now()
+	result, err := ag.Chat(ctx, "slow", WithBudget(&types.RunBudget{MaxDurationMs: 100, WrapUp: true}))
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the run to stop near the deadline, took %v", elapsed)
+	}
+	if result.Reason != types.DoneReasonMaxDuration || result.Text != "Best effort answer." {
+		t.Errorf("Expected wrap-up answer with reason %s, got %q / %q", types.DoneReasonMaxDuration, result.Reason, result.Text)
+	}
+	if ag.Status().State != types.AgentStateReady {
+		t.Errorf("Expected agent to be Ready, got %s", ag.Status().State)
+	}
+}
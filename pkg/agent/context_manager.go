@@ -0,0 +1,239 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/wordflowlab/agentsdk/pkg/provider"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+const (
+	compressionSystemPrompt = "You compress conversation histories. Summarize the conversation below so that another assistant can continue the work without it: " +
+		"keep the user's goals and constraints, decisions made, files and commands involved, tool results that still matter and any unfinished work. Be concise and factual."
+	compressionSummaryPrefix = "## Previous conversation summary"
+
+	// compressionResultChars 渲染待压缩的对话时,每个工具结果保留的最大字符数
+	compressionResultChars = 2000
+)
+
+// contextLimits 返回触发压缩的 token 上限和压缩目标,未启用压缩时返回 0
+// 上限取 ContextManagerOptions.MaxTokens 与模型上下文窗口(ProviderCapabilities.ContextWindow)中较小的一个,
+// 目标未配置时为上限的一半
+func (a *Agent) contextLimits() (limit, target int) {
+	opts := a.config.Context
+	if opts == nil || !opts.EnableCompression {
+		return 0, 0
+	}
+
+	limit = a.provider.Capabilities().ContextWindow
+	if opts.MaxTokens > 0 && (limit <= 0 || opts.MaxTokens < limit) {
+		limit = opts.MaxTokens
+	}
+	if limit <= 0 {
+		return 0, 0
+	}
+
+	target = opts.CompressToTokens
+	if target <= 0 || target >= limit {
+		target = limit / 2
+	}
+	return limit, target
+}
+
+// manageContext 在模型调用前估算提示词大小,超过上限时把较早的对话压缩为摘要并持久化
+// 压缩失败时发出告警,保留原始对话继续执行
+func (a *Agent) manageContext(ctx context.Context) {
+	limit, target := a.contextLimits()
+	if limit == 0 {
+		return
+	}
+
+	a.mu.RLock()
+	messages := a.copyMessages()
+	overhead := estimateTokens(a.template.SystemPrompt)
+	a.mu.RUnlock()
+	overhead += estimateTokens(toolSchemasOf(a.currentTools()))
+
+	before := overhead + estimateTokens(messages)
+	if before <= limit {
+		return
+	}
+
+	split := compressionSplit(messages, target-overhead)
+	if split == 0 {
+		log.Printf("[Agent] Agent %s: context has %d tokens (limit %d) but no safe point to compress", a.id, before, limit)
+		return
+	}
+
+	log.Printf("[Agent] Agent %s: compressing %d of %d messages (%d tokens, limit %d)", a.id, split, len(messages), before, limit)
+	a.emitMonitor(&types.MonitorContextCompressionEvent{
+		Phase:          "start",
+		TokensBefore:   before,
+		MessagesBefore: len(messages),
+	})
+
+	summary, err := a.summarize(ctx, messages[:split])
+	if err != nil {
+		a.emitMonitor(&types.MonitorErrorEvent{
+			Severity: "warn",
+			Phase:    "context",
+			Message:  fmt.Sprintf("context compression failed: %v", err),
+		})
+		return
+	}
+	compressed := withSummary(summary, messages[split:])
+
+	a.mu.Lock()
+	// 压缩期间只有本轮执行会修改对话,把之后追加的消息接上
+	a.turnStart = max(a.turnStart-(len(messages)-len(compressed)), 0)
+	compressed = append(compressed, a.messages[len(messages):]...)
+	a.messages = compressed
+	count := safeForkCount(compressed)
+	a.lastSfpIndex = count - 1
+	a.sfpMarks = []sfpMark{{seq: a.eventBus.GetCursor(), count: count}}
+	a.mu.Unlock()
+
+	if err := a.deps.Store.SaveMessages(ctx, a.id, compressed); err != nil {
+		log.Printf("[Agent] Failed to save compressed messages: %v", err)
+	}
+
+	after := overhead + estimateTokens(compressed)
+	a.emitMonitor(&types.MonitorContextCompressionEvent{
+		Phase:          "end",
+		Summary:        summary,
+		Ratio:          float64(after) / float64(before),
+		TokensBefore:   before,
+		TokensAfter:    after,
+		MessagesBefore: len(messages),
+		MessagesAfter:  len(compressed),
+	})
+}
+
+// summarize 使用压缩模型(未配置时使用 Agent 的模型)生成对话摘要
+func (a *Agent) summarize(ctx context.Context, messages []types.Message) (string, error) {
+	prov := a.provider
+	modelConfig := types.ModelConfig{}
+	if config := prov.Config(); config != nil {
+		modelConfig = *config
+	}
+	if model := a.config.Context.CompressionModel; model != "" && model != modelConfig.Model {
+		modelConfig.Model = model
+		compressor, err := a.deps.ProviderFactory.Create(&modelConfig)
+		if err != nil {
+			return "", fmt.Errorf("create compression provider: %w", err)
+		}
+		defer compressor.Close()
+		prov = compressor
+	}
+
+	resp, err := prov.Complete(ctx, []types.Message{{
+		Role:    types.MessageRoleUser,
+		Content: []types.ContentBlock{&types.TextBlock{Text: renderTranscript(messages)}},
+	}}, &provider.StreamOptions{System: compressionSystemPrompt})
+	if err != nil {
+		return "", fmt.Errorf("summarize: %w", err)
+	}
+
+	summary := strings.TrimSpace(assistantText(resp.Message))
+	if summary == "" {
+		return "", fmt.Errorf("summarize: empty summary")
+	}
+	return summary, nil
+}
+
+// compressionSplit 选择压缩的分界点,分界点之前的消息压缩为摘要
+// 分界点之前不能有未完成的工具调用(tool_use 与 tool_result 不被拆开);
+// 在此前提下尽量保留估算不超过 budget 的最近消息,都超过时尽量多压缩。返回 0 表示无法压缩
+func compressionSplit(messages []types.Message, budget int) int {
+	if len(messages) < 2 {
+		return 0
+	}
+
+	// suffix[i] 为 messages[i:] 的估算 token 数
+	suffix := make([]int, len(messages)+1)
+	for i := len(messages) - 1; i >= 0; i-- {
+		suffix[i] = suffix[i+1] + estimateTokens(messages[i:i+1])
+	}
+
+	open := make(map[string]bool)
+	split := 0
+	for i, msg := range messages[:len(messages)-1] {
+		for _, block := range msg.Content {
+			switch b := block.(type) {
+			case *types.ToolUseBlock:
+				open[b.ID] = true
+			case *types.ToolResultBlock:
+				delete(open, b.ToolUseID)
+			}
+		}
+		if len(open) > 0 {
+			continue
+		}
+		split = i + 1
+		if suffix[split] <= budget {
+			break
+		}
+	}
+	return split
+}
+
+// withSummary 把摘要放在保留的消息前面
+// 保留的第一条是用户消息时合并到该消息中,保持角色交替
+func withSummary(summary string, kept []types.Message) []types.Message {
+	text := &types.TextBlock{Text: fmt.Sprintf("%s\n\n%s", compressionSummaryPrefix, summary)}
+	result := make([]types.Message, 0, len(kept)+1)
+	if len(kept) > 0 && kept[0].Role == types.MessageRoleUser {
+		first := kept[0]
+		first.Content = append([]types.ContentBlock{text}, first.Content...)
+		return append(append(result, first), kept[1:]...)
+	}
+	result = append(result, types.Message{
+		Role:    types.MessageRoleUser,
+		Content: []types.ContentBlock{text},
+	})
+	return append(result, kept...)
+}
+
+// renderTranscript 把待压缩的对话渲染为文本
+func renderTranscript(messages []types.Message) string {
+	var sb strings.Builder
+	for _, msg := range messages {
+		for _, block := range msg.Content {
+			switch b := block.(type) {
+			case *types.TextBlock:
+				fmt.Fprintf(&sb, "[%s] %s\n", msg.Role, b.Text)
+			case *types.ToolUseBlock:
+				input, _ := json.Marshal(b.Input)
+				fmt.Fprintf(&sb, "[%s] called %s %s\n", msg.Role, b.Name, input)
+			case *types.ToolResultBlock:
+				content, ok := b.Content.(string)
+				if !ok {
+					data, _ := json.Marshal(b.Content)
+					content = string(data)
+				}
+				fmt.Fprintf(&sb, "[tool result] %s\n", truncate(content, compressionResultChars))
+			}
+		}
+	}
+	return sb.String()
+}
+
+// estimateTokens 粗略估算 token 数(约 4 个字符一个 token)
+func estimateTokens(v interface{}) int {
+	var n int
+	switch val := v.(type) {
+	case string:
+		n = len(val)
+	case []types.Message:
+		data, _ := json.Marshal(types.EncodeMessages(val))
+		n = len(data)
+	default:
+		data, _ := json.Marshal(val)
+		n = len(data)
+	}
+	return (n + 3) / 4
+}
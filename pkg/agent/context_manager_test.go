@@ -0,0 +1,121 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/provider"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+func TestAgentContextCompression(t *testing.T) {
+	mp := provider.NewMockProvider(
+		provider.MockTextStep("one"),
+		provider.MockTextStep("The user asked about the first topic."),
+		provider.MockTextStep("two"),
+	)
+	ag := newMockAgent(t, mp, func(config *types.AgentConfig) {
+		config.Tools = []string{}
+		config.Context = &types.ContextManagerOptions{
+			MaxTokens:         300,
+			CompressToTokens:  150,
+			CompressionModel:  "mock-small",
+			EnableCompression: true,
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	long := strings.Repeat("x ", 400)
+	if _, err := ag.Chat(ctx, "first "+long); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if _, err := ag.Chat(ctx, "second "+long); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	// 第二轮超过上限: 先用压缩模型生成摘要,再带着摘要调用模型
+	summaryReq, _ := mp.Request(1)
+	if summaryReq.Stream || summaryReq.System != compressionSystemPrompt {
+		t.Fatalf("Expected a compression request, got %+v", summaryReq.Options)
+	}
+	if !strings.Contains(assistantText(summaryReq.Messages[0]), "[user] first") {
+		t.Errorf("Expected transcript of the first turn in compression request")
+	}
+
+	req, _ := mp.Request(2)
+	if len(req.Messages) != 1 || len(req.Messages[0].Content) != 2 {
+		t.Fatalf("Expected summary merged into the latest user message, got %+v", req.Messages)
+	}
+	if text := assistantText(req.Messages[0]); !strings.HasPrefix(text, compressionSummaryPrefix) || !strings.Contains(text, "first topic") {
+		t.Errorf("Unexpected summary block: %q", text)
+	}
+
+	stored, err := ag.deps.Store.LoadMessages(ctx, ag.ID())
+	if err != nil {
+		t.Fatalf("Failed to load messages: %v", err)
+	}
+	if len(stored) != 2 {
+		t.Errorf("Expected compressed history to be persisted, got %d messages", len(stored))
+	}
+
+	var phases []string
+	for _, envelope := range ag.eventBus.GetTimeline() {
+		if e, ok := envelope.Event.(*types.MonitorContextCompressionEvent); ok {
+			phases = append(phases, e.Phase)
+			if e.Phase == "end" && (e.TokensAfter >= e.TokensBefore || e.MessagesBefore != 3 || e.MessagesAfter != 1) {
+				t.Errorf("Unexpected compression sizes: %+v", e)
+			}
+		}
+	}
+	if len(phases) != 2 || phases[0] != "start" || phases[1] != "end" {
+		t.Errorf("Expected start and end compression events, got %v", phases)
+	}
+}
+
+func TestAgentContextLimits(t *testing.T) {
+	mp := provider.NewMockProvider()
+	ag := newMockAgent(t, mp, func(config *types.AgentConfig) {
+		config.Context = &types.ContextManagerOptions{EnableCompression: true}
+	})
+
+	// MaxTokens 是输出上限,不作为上下文大小
+	mp.SetCapabilities(provider.ProviderCapabilities{MaxTokens: 8192})
+	if limit, _ := ag.contextLimits(); limit != 0 {
+		t.Errorf("Expected no limit without context window, got %d", limit)
+	}
+
+	mp.SetCapabilities(provider.ProviderCapabilities{MaxTokens: 8192, ContextWindow: 100000})
+	if limit, target := ag.contextLimits(); limit != 100000 || target != 50000 {
+		t.Errorf("Expected limit from context window, got %d/%d", limit, target)
+	}
+
+	ag.config.Context.MaxTokens = 30000
+	if limit, _ := ag.contextLimits(); limit != 30000 {
+		t.Errorf("Expected configured MaxTokens below context window, got %d", limit)
+	}
+}
+
+func TestCompressionSplit_KeepsToolPairs(t *testing.T) {
+	messages := []types.Message{
+		{Role: types.MessageRoleUser, Content: []types.ContentBlock{&types.TextBlock{Text: strings.Repeat("read a.txt ", 50)}}},
+		{Role: types.MessageRoleAssistant, Content: []types.ContentBlock{&types.ToolUseBlock{ID: "call_1", Name: "fs_read"}}},
+		{Role: types.MessageRoleUser, Content: []types.ContentBlock{&types.ToolResultBlock{ToolUseID: "call_1", Content: "contents"}}},
+		{Role: types.MessageRoleAssistant, Content: []types.ContentBlock{&types.TextBlock{Text: "done"}}},
+		{Role: types.MessageRoleUser, Content: []types.ContentBlock{&types.TextBlock{Text: "thanks"}}},
+	}
+
+	// 工具调用与结果之间不能作为分界点
+	if got := compressionSplit(messages, estimateTokens(messages[3:4])+estimateTokens(messages[4:])); got != 3 {
+		t.Errorf("Expected split at 3, got %d", got)
+	}
+	if got := compressionSplit(messages, 0); got != 4 {
+		t.Errorf("Expected split at 4 when nothing fits, got %d", got)
+	}
+	if got := compressionSplit(messages[:1], 0); got != 0 {
+		t.Errorf("Expected no split for a single message, got %d", got)
+	}
+}
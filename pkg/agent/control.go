@@ -0,0 +1,163 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// Interrupt 中断正在进行的一轮执行
+// 取消模型的流式输出和正在执行的工具(ToolContext.Signal 随之取消),已输出的文本保存为助手消息,
+// 未完成的工具调用返回错误结果,对话历史保持完整;等待执行结束或 ctx 取消后返回
+func (a *Agent) Interrupt(ctx context.Context) error {
+	a.mu.Lock()
+	cancel, done := a.runCancel, a.runDone
+	if cancel == nil {
+		a.mu.Unlock()
+		return nil // 没有正在进行的执行
+	}
+	a.interrupted = true
+	// 中断同时解除暂停,结束后回到 Ready
+	if a.resumeCh != nil {
+		close(a.resumeCh)
+		a.resumeCh = nil
+	}
+	a.mu.Unlock()
+
+	log.Printf("[Agent] Interrupting run of agent %s", a.id)
+	cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Pause 暂停 Agent
+// 正在执行时在当前步骤(模型调用或一批工具调用)完成后暂停;空闲时不再开始新的执行,
+// 暂停期间发送的消息会保留,Resume 后继续处理
+func (a *Agent) Pause() {
+	a.mu.Lock()
+	if a.resumeCh != nil {
+		a.mu.Unlock()
+		return
+	}
+	a.resumeCh = make(chan struct{})
+	idle := a.state == types.AgentStateReady
+	if idle {
+		a.state = types.AgentStatePaused
+	}
+	a.mu.Unlock()
+
+	if idle {
+		a.emitMonitor(&types.MonitorStateChangedEvent{
+			State:  types.AgentStatePaused,
+			Reason: types.PauseReasonUser,
+		})
+	}
+}
+
+// Resume 解除 Pause 造成的暂停
+// 执行中暂停的 Agent 从下一步继续;空闲时暂停的 Agent 如果有未处理的消息,使用 ctx 开始新的执行
+func (a *Agent) Resume(ctx context.Context) error {
+	a.mu.Lock()
+	ch := a.resumeCh
+	if ch == nil {
+		a.mu.Unlock()
+		return fmt.Errorf("agent is not paused")
+	}
+	a.resumeCh = nil
+	close(ch)
+
+	// 空闲时在同一次加锁中开始下一轮,避免期间收到的新消息插队
+	idle := a.runCancel == nil && a.state == types.AgentStatePaused
+	pending, handoff := false, false
+	var next queuedMessage
+	if idle {
+		a.state = types.AgentStateReady
+		if n := len(a.messages); n > 0 && a.messages[n-1].Role == types.MessageRoleUser {
+			pending = true
+			a.state = types.AgentStateWorking
+		} else {
+			next, handoff = a.dequeueLocked()
+		}
+	}
+	step := a.stepCount
+	a.mu.Unlock()
+
+	if idle {
+		a.emitMonitor(&types.MonitorStateChangedEvent{
+			State: types.AgentStateReady,
+		})
+		if pending {
+			go a.runTurn(context.WithoutCancel(ctx))
+		} else if handoff {
+			a.consumed(next, step)
+		}
+	}
+	return nil
+}
+
+// waitIfPaused 在步骤之间检查暂停请求,暂停时等待 Resume;返回 ctx 的错误
+func (a *Agent) waitIfPaused(ctx context.Context) error {
+	a.mu.Lock()
+	ch := a.resumeCh
+	if ch != nil {
+		a.state = types.AgentStatePaused
+	}
+	a.mu.Unlock()
+	if ch == nil {
+		return ctx.Err()
+	}
+
+	log.Printf("[Agent] Agent %s paused between steps", a.id)
+	a.emitMonitor(&types.MonitorStateChangedEvent{
+		State:  types.AgentStatePaused,
+		Reason: types.PauseReasonUser,
+	})
+
+	select {
+	case <-ch:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	a.mu.Lock()
+	a.state = types.AgentStateWorking
+	a.mu.Unlock()
+
+	a.emitMonitor(&types.MonitorStateChangedEvent{
+		State: types.AgentStateWorking,
+	})
+	return ctx.Err()
+}
+
+// savePartialResponse 保存被中断的模型回复中已输出的文本
+// 未完成的工具调用和思考块不保存,避免历史中出现没有结果的调用
+func (a *Agent) savePartialResponse(ctx context.Context, message types.Message) {
+	content := make([]types.ContentBlock, 0, len(message.Content))
+	for _, block := range message.Content {
+		if tb, ok := block.(*types.TextBlock); ok && tb.Text != "" {
+			content = append(content, tb)
+		}
+	}
+	if len(content) == 0 {
+		return
+	}
+
+	a.mu.Lock()
+	a.messages = append(a.messages, types.Message{
+		Role:    types.MessageRoleAssistant,
+		Content: content,
+	})
+	messages := a.copyMessages()
+	a.mu.Unlock()
+
+	if err := a.deps.Store.SaveMessages(context.WithoutCancel(ctx), a.id, messages); err != nil {
+		log.Printf("[Agent] Failed to save partial response: %v", err)
+	}
+}
@@ -0,0 +1,305 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/provider"
+	"github.com/wordflowlab/agentsdk/pkg/tools"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// blockTool 阻塞到 release 关闭或调用被取消的测试工具
+type blockTool struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockTool() *blockTool {
+	return &blockTool{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (t *blockTool) Name() string        { return "block" }
+func (t *blockTool) Description() string { return "block until released" }
+func (t *blockTool) Prompt() string      { return "" }
+func (t *blockTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "object"}
+}
+
+func (t *blockTool) Execute(ctx context.Context, input map[string]interface{}, tc *tools.ToolContext) (interface{}, error) {
+	close(t.started)
+	select {
+	case <-t.release:
+		return map[string]interface{}{"ok": true}, nil
+	case <-tc.Signal.Done():
+		return nil, tc.Signal.Err()
+	}
+}
+
+// blockStep 调用 block 工具的脚本步骤
+func blockStep() provider.MockStep {
+	return provider.MockToolStep(provider.MockToolCall{ID: "call_block", Name: "block"})
+}
+
+// waitForSteps 等待模拟提供商的脚本步骤全部消费
+func waitForSteps(t *testing.T, mp *provider.MockProvider) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for mp.Remaining() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the run, %d steps left", mp.Remaining())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAgentInterrupt_KeepsPartialText(t *testing.T) {
+	mp := provider.NewMockProvider(provider.MockStep{
+		TextChunks: []string{"Hello", " there,", " this", " is", " long"},
+		ChunkDelay: 200 * time.Millisecond,
+	})
+	ag := newMockAgent(t, mp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	progress := ag.Subscribe([]types.AgentChannel{types.ChannelProgress}, nil)
+	results := make(chan *types.CompleteResult, 1)
+	go func() {
+		result, err := ag.Chat(ctx, "say something long")
+		if err != nil {
+			t.Errorf("Chat failed: %v", err)
+		}
+		results <- result
+	}()
+
+	// 收到第一段文本后中断
+	for envelope := range progress {
+		if _, ok := envelope.Event.(*types.ProgressTextChunkEvent); ok {
+			break
+		}
+	}
+	if err := ag.Interrupt(ctx); err != nil {
+		t.Fatalf("Interrupt failed: %v", err)
+	}
+
+	result := <-results
+	if result == nil || result.Reason != types.DoneReasonInterrupted {
+		t.Fatalf("Expected interrupted result, got %+v", result)
+	}
+	if !strings.HasPrefix(result.Text, "Hello") || result.Text == "Hello there, this is long" {
+		t.Errorf("Expected partial text, got %q", result.Text)
+	}
+	if ag.Status().State != types.AgentStateReady {
+		t.Errorf("Expected agent to be ready after interrupt, got %s", ag.Status().State)
+	}
+
+	stored, err := ag.deps.Store.LoadMessages(ctx, ag.ID())
+	if err != nil {
+		t.Fatalf("Failed to load messages: %v", err)
+	}
+	if last := stored[len(stored)-1]; last.Role != types.MessageRoleAssistant || assistantText(last) != result.Text {
+		t.Errorf("Expected partial reply to be persisted, got %+v", last)
+	}
+}
+
+func TestAgentInterrupt_BeforeReplyReturnsNoText(t *testing.T) {
+	mp := provider.NewMockProvider(
+		provider.MockTextStep("first reply"),
+		provider.MockStep{Text: "never sent", Delay: 5 * time.Second},
+	)
+	ag := newMockAgent(t, mp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := ag.Chat(ctx, "first"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	results := make(chan *types.CompleteResult, 1)
+	go func() {
+		result, err := ag.Chat(ctx, "second")
+		if err != nil {
+			t.Errorf("Chat failed: %v", err)
+		}
+		results <- result
+	}()
+
+	// 模型还没有回复时中断,不返回上一轮的回复
+	waitForSteps(t, mp)
+	if err := ag.Interrupt(ctx); err != nil {
+		t.Fatalf("Interrupt failed: %v", err)
+	}
+	result := <-results
+	if result == nil {
+		t.Fatalf("Expected chat result")
+	}
+	if result.Text != "" {
+		t.Errorf("Expected no text for the interrupted turn, got %q", result.Text)
+	}
+}
+
+func TestAgentInterrupt_CancelsRunningTool(t *testing.T) {
+	mp := provider.NewMockProvider(blockStep(), provider.MockTextStep("never reached"))
+	ag := newMockAgent(t, mp)
+	tool := newBlockTool()
+	ag.toolMap["block"] = tool
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := ag.Send(ctx, "block"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	<-tool.started
+	if err := ag.Interrupt(ctx); err != nil {
+		t.Fatalf("Interrupt failed: %v", err)
+	}
+
+	if ag.Status().State != types.AgentStateReady {
+		t.Errorf("Expected agent to be ready after interrupt, got %s", ag.Status().State)
+	}
+	if mp.Remaining() != 1 {
+		t.Errorf("Expected no model call after interrupt, %d steps left", mp.Remaining())
+	}
+
+	// 每个工具调用都有结果,历史可以继续使用
+	last := ag.messages[len(ag.messages)-1]
+	tr, ok := last.Content[0].(*types.ToolResultBlock)
+	if !ok || tr.ToolUseID != "call_block" || !tr.IsError {
+		t.Fatalf("Expected error result for interrupted tool, got %+v", last)
+	}
+	if ag.toolRecords["call_block"].State != types.ToolCallStateFailed {
+		t.Errorf("Expected record state FAILED, got %s", ag.toolRecords["call_block"].State)
+	}
+
+	if err := ag.Interrupt(ctx); err != nil {
+		t.Errorf("Interrupt on an idle agent should be a no-op, got %v", err)
+	}
+}
+
+func TestAgentPause_BetweenSteps(t *testing.T) {
+	mp := provider.NewMockProvider(blockStep(), provider.MockTextStep("done"))
+	ag := newMockAgent(t, mp)
+	tool := newBlockTool()
+	ag.toolMap["block"] = tool
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := ag.Send(ctx, "block"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	<-tool.started
+	ag.Pause()
+	close(tool.release)
+
+	// 工具完成后暂停,不再调用模型
+	waitForState(t, ag, types.AgentStatePaused)
+	time.Sleep(50 * time.Millisecond)
+	if mp.Remaining() != 1 {
+		t.Fatalf("Expected run to pause before the next model call, %d steps left", mp.Remaining())
+	}
+
+	if err := ag.Resume(ctx); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	waitForSteps(t, mp)
+	waitForState(t, ag, types.AgentStateReady)
+
+	if err := ag.Resume(ctx); err == nil {
+		t.Errorf("Expected error when resuming an agent that is not paused")
+	}
+}
+
+func TestAgentPause_WhileIdle(t *testing.T) {
+	mp := provider.NewMockProvider(provider.MockTextStep("hello"))
+	ag := newMockAgent(t, mp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ag.Pause()
+	if err := ag.Send(ctx, "hi"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if mp.Remaining() != 1 || ag.Status().State != types.AgentStatePaused {
+		t.Fatalf("Expected paused agent to hold the message, state %s", ag.Status().State)
+	}
+
+	// Resume 后处理暂停期间收到的消息
+	if err := ag.Resume(ctx); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	waitForSteps(t, mp)
+	waitForState(t, ag, types.AgentStateReady)
+}
+
+func TestAgentPause_DuringChat(t *testing.T) {
+	mp := provider.NewMockProvider(blockStep(), provider.MockTextStep("done"))
+	ag := newMockAgent(t, mp)
+	tool := newBlockTool()
+	ag.toolMap["block"] = tool
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	type chatResult struct {
+		result *types.CompleteResult
+		err    error
+	}
+	resultCh := make(chan chatResult, 1)
+	go func() {
+		result, err := ag.Chat(ctx, "block")
+		resultCh <- chatResult{result, err}
+	}()
+
+	<-tool.started
+	ag.Pause()
+	close(tool.release)
+	waitForState(t, ag, types.AgentStatePaused)
+
+	// Pause 不是等待审批,Chat 继续等待 Resume
+	select {
+	case r := <-resultCh:
+		t.Fatalf("Chat returned while paused by Pause: %+v, %v", r.result, r.err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := ag.Resume(ctx); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	r := <-resultCh
+	if r.err != nil {
+		t.Fatalf("Chat failed: %v", r.err)
+	}
+	if r.result.Status != "ok" || r.result.Text != "done" {
+		t.Errorf("Expected completed result after Resume, got %+v", r.result)
+	}
+}
+
+func TestAgentSend_OutlivesCallerContext(t *testing.T) {
+	mp := provider.NewMockProvider(provider.MockStep{Text: "hello", Delay: 100 * time.Millisecond})
+	ag := newMockAgent(t, mp)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := ag.Send(ctx, "hi"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	// 请求级 ctx 在 Send 返回后取消,不影响后台执行
+	cancel()
+
+	waitForSteps(t, mp)
+	waitForState(t, ag, types.AgentStateReady)
+
+	ag.mu.RLock()
+	final := lastAssistantMessage(ag.messages)
+	ag.mu.RUnlock()
+	if final == nil || assistantText(*final) != "hello" {
+		t.Errorf("Expected the turn to complete after ctx cancellation, got %+v", final)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"github.com/wordflowlab/agentsdk/pkg/sandbox"
 	"github.com/wordflowlab/agentsdk/pkg/store"
 	"github.com/wordflowlab/agentsdk/pkg/tools"
-	"github.com/wordflowlab/agentsdk/pkg/types"
 )
 
 // Dependencies Agent依赖
@@ -16,47 +15,3 @@ type Dependencies struct {
 	ProviderFactory provider.Factory
 	TemplateRegistry *TemplateRegistry
 }
-
-// TemplateRegistry 模板注册表
-type TemplateRegistry struct {
-	templates map[string]*types.AgentTemplateDefinition
-}
-
-// NewTemplateRegistry 创建模板注册表
-func NewTemplateRegistry() *TemplateRegistry {
-	return &TemplateRegistry{
-		templates: make(map[string]*types.AgentTemplateDefinition),
-	}
-}
-
-// Register 注册模板
-func (tr *TemplateRegistry) Register(template *types.AgentTemplateDefinition) {
-	tr.templates[template.ID] = template
-}
-
-// Get 获取模板
-func (tr *TemplateRegistry) Get(id string) (*types.AgentTemplateDefinition, error) {
-	template, ok := tr.templates[id]
-	if !ok {
-		return nil, &TemplateNotFoundError{ID: id}
-	}
-	return template, nil
-}
-
-// List 列出所有模板
-func (tr *TemplateRegistry) List() []*types.AgentTemplateDefinition {
-	templates := make([]*types.AgentTemplateDefinition, 0, len(tr.templates))
-	for _, t := range tr.templates {
-		templates = append(templates, t)
-	}
-	return templates
-}
-
-// TemplateNotFoundError 模板未找到错误
-type TemplateNotFoundError struct {
-	ID string
-}
-
-func (e *TemplateNotFoundError) Error() string {
-	return "template not found: " + e.ID
-}
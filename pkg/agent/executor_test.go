@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/provider"
+	"github.com/wordflowlab/agentsdk/pkg/tools"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// sleepTool 记录最大并发数的测试工具
+type sleepTool struct {
+	mu        sync.Mutex
+	running   int
+	maxActive int
+	exclusive bool
+}
+
+func (t *sleepTool) Name() string        { return "sleep" }
+func (t *sleepTool) Description() string { return "sleep for a while" }
+func (t *sleepTool) Prompt() string      { return "" }
+func (t *sleepTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "object"}
+}
+
+func (t *sleepTool) ConcurrencyKey(input map[string]interface{}) string {
+	if t.exclusive {
+		return tools.ExclusiveConcurrencyKey
+	}
+	return ""
+}
+
+func (t *sleepTool) Execute(ctx context.Context, input map[string]interface{}, tc *tools.ToolContext) (interface{}, error) {
+	t.mu.Lock()
+	t.running++
+	t.maxActive = max(t.maxActive, t.running)
+	t.mu.Unlock()
+
+	time.Sleep(50 * time.Millisecond)
+
+	t.mu.Lock()
+	t.running--
+	t.mu.Unlock()
+	return map[string]interface{}{"ok": true, "id": input["id"]}, nil
+}
+
+// sleepCalls 生成 n 个 sleep 工具调用
+func sleepCalls(n int) provider.MockStep {
+	calls := make([]provider.MockToolCall, n)
+	for i := range calls {
+		calls[i] = provider.MockToolCall{
+			ID:          fmt.Sprintf("call_%d", i),
+			Name:        "sleep",
+			PartialJSON: []string{fmt.Sprintf(`{"id":%d}`, i)},
+		}
+	}
+	return provider.MockToolStep(calls...)
+}
+
+func TestAgentExecuteTools_Parallel(t *testing.T) {
+	mp := provider.NewMockProvider(sleepCalls(5), provider.MockTextStep("done"))
+	ag := newMockAgent(t, mp)
+	tool := &sleepTool{}
+	ag.toolMap["sleep"] = tool
+	ag.executor = tools.NewExecutor(tools.ExecutorConfig{MaxConcurrency: 2})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := ag.Chat(ctx, "sleep"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if tool.maxActive != 2 {
+		t.Errorf("Expected 2 tools to run concurrently, got %d", tool.maxActive)
+	}
+
+	// 工具结果保持调用顺序
+	second, _ := mp.Request(1)
+	results := second.Messages[len(second.Messages)-1].Content
+	if len(results) != 5 {
+		t.Fatalf("Expected 5 tool results, got %d", len(results))
+	}
+	for i, block := range results {
+		if tr := block.(*types.ToolResultBlock); tr.ToolUseID != fmt.Sprintf("call_%d", i) || tr.IsError {
+			t.Errorf("Unexpected result at %d: %+v", i, tr)
+		}
+	}
+}
+
+func TestAgentExecuteTools_Exclusive(t *testing.T) {
+	mp := provider.NewMockProvider(sleepCalls(3), provider.MockTextStep("done"))
+	ag := newMockAgent(t, mp)
+	tool := &sleepTool{exclusive: true}
+	ag.toolMap["sleep"] = tool
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := ag.Chat(ctx, "sleep"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if tool.maxActive != 1 {
+		t.Errorf("Expected exclusive tool calls to run one at a time, got %d", tool.maxActive)
+	}
+}
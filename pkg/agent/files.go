@@ -0,0 +1,250 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/sandbox"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// 文件操作类型
+const (
+	fileOpRead  = "read"
+	fileOpWrite = "write"
+)
+
+// recentFilesLimit Status 和 Skills 中的最近文件数
+const recentFilesLimit = 10
+
+const fileChangedReminder = "The file %s was modified outside of this conversation after you last read it. Read it again before relying on its previous contents."
+
+// fileToolOps 访问文件的工具及其操作
+var fileToolOps = map[string]string{
+	"fs_read":  fileOpRead,
+	"fs_write": fileOpWrite,
+	"fs_edit":  fileOpWrite,
+}
+
+// fileState 文件的访问记录和监听状态
+type fileState struct {
+	activity types.FileActivity
+	writing  int       // 正在执行的写操作数,期间忽略变更通知
+	notified time.Time // 最近一次通知的修改时间
+	watchID  string
+}
+
+// Files 返回 Agent 通过工具读写过的文件,最近访问的在前
+func (a *Agent) Files() []types.FileActivity {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.sortedFiles()
+}
+
+// sortedFiles 按最近访问时间排序的文件列表
+// 调用方需持有锁
+func (a *Agent) sortedFiles() []types.FileActivity {
+	files := make([]types.FileActivity, 0, len(a.files))
+	for _, state := range a.files {
+		files = append(files, state.activity)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].LastAccessAt.After(files[j].LastAccessAt)
+	})
+	return files
+}
+
+// getRecentFiles 获取最近访问的文件列表
+// 调用方需持有锁
+func (a *Agent) getRecentFiles() []string {
+	files := a.sortedFiles()
+	if len(files) > recentFilesLimit {
+		files = files[:recentFilesLimit]
+	}
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = file.Path
+	}
+	return paths
+}
+
+// fileAccessOf 返回工具调用访问的文件(沙箱内的绝对路径)和操作,不访问文件时 op 为空
+func (a *Agent) fileAccessOf(name string, input map[string]interface{}) (path, op string) {
+	op = fileToolOps[name]
+	if op == "" {
+		return "", ""
+	}
+	path, _ = input["path"].(string)
+	if path == "" {
+		path, _ = input["file_path"].(string)
+	}
+	if path == "" {
+		return "", ""
+	}
+	return a.sandbox.FS().Resolve(path), op
+}
+
+// beginFileTool 文件工具开始执行
+func (a *Agent) beginFileTool(path, op string) {
+	if op != fileOpWrite {
+		return
+	}
+	a.mu.Lock()
+	a.trackedFile(path).writing++
+	a.mu.Unlock()
+}
+
+// endFileTool 文件工具执行结束,成功时记录访问;读取的文件在启用 WatchFiles 时开始监听
+func (a *Agent) endFileTool(path, op string, success bool) {
+	a.mu.Lock()
+	state := a.trackedFile(path)
+	if op == fileOpWrite {
+		state.writing--
+	}
+	if success {
+		recordFileAccess(state, op, time.Now())
+	}
+	a.mu.Unlock()
+
+	if success && op == fileOpRead {
+		a.watchFile(path)
+	}
+}
+
+// trackedFile 返回文件状态,不存在时创建
+// 调用方需持有 a.mu
+func (a *Agent) trackedFile(path string) *fileState {
+	state, ok := a.files[path]
+	if !ok {
+		state = &fileState{activity: types.FileActivity{Path: path}}
+		a.files[path] = state
+	}
+	return state
+}
+
+// recordFileAccess 记录一次文件访问
+func recordFileAccess(state *fileState, op string, at time.Time) {
+	switch op {
+	case fileOpRead:
+		state.activity.Reads++
+		state.activity.LastReadAt = at
+	case fileOpWrite:
+		state.activity.Writes++
+		state.activity.LastWriteAt = at
+	}
+	if at.After(state.activity.LastAccessAt) {
+		state.activity.LastAccessAt = at
+	}
+}
+
+// loadFileActivity 从已完成的工具调用记录恢复文件访问记录
+func (a *Agent) loadFileActivity() {
+	var read []string
+	a.mu.Lock()
+	for _, record := range a.toolRecords {
+		if record.State != types.ToolCallStateCompleted {
+			continue
+		}
+		path, op := a.fileAccessOf(record.Name, record.Input)
+		if op == "" {
+			continue
+		}
+		at := record.UpdatedAt
+		if record.CompletedAt != nil {
+			at = *record.CompletedAt
+		}
+		recordFileAccess(a.trackedFile(path), op, at)
+		if op == fileOpRead {
+			read = append(read, path)
+		}
+	}
+	a.mu.Unlock()
+
+	for _, path := range read {
+		a.watchFile(path)
+	}
+}
+
+// resetFileActivity 取消监听并按当前的工具调用记录重建文件访问记录
+func (a *Agent) resetFileActivity() {
+	a.unwatchFiles()
+	a.mu.Lock()
+	a.files = make(map[string]*fileState)
+	a.mu.Unlock()
+	a.loadFileActivity()
+}
+
+// watchFile 启用 SandboxConfig.WatchFiles 时监听文件在 Agent 之外的修改
+func (a *Agent) watchFile(path string) {
+	if a.config.Sandbox == nil || !a.config.Sandbox.WatchFiles {
+		return
+	}
+
+	a.mu.Lock()
+	state := a.trackedFile(path)
+	if state.watchID != "" {
+		a.mu.Unlock()
+		return
+	}
+	state.watchID = "pending"
+	a.mu.Unlock()
+
+	watchID, err := a.sandbox.Watch([]string{path}, a.onFileChanged)
+	if err != nil {
+		log.Printf("[Agent] Failed to watch %s: %v", path, err)
+		watchID = ""
+	}
+
+	a.mu.Lock()
+	state.watchID = watchID
+	a.mu.Unlock()
+}
+
+// onFileChanged 读过的文件在 Agent 之外被修改时发出 MonitorFileChangedEvent 并提醒模型
+// Agent 自己写入时的变更,以及不晚于最近一次读写的修改不通知
+func (a *Agent) onFileChanged(event sandbox.FileChangeEvent) {
+	a.mu.Lock()
+	state, ok := a.files[event.Path]
+	if !ok || state.activity.Reads == 0 || state.writing > 0 {
+		a.mu.Unlock()
+		return
+	}
+	last := state.activity.LastReadAt
+	if state.activity.LastWriteAt.After(last) {
+		last = state.activity.LastWriteAt
+	}
+	if !event.Mtime.After(last) || !event.Mtime.After(state.notified) {
+		a.mu.Unlock()
+		return
+	}
+	state.notified = event.Mtime
+	a.mu.Unlock()
+
+	log.Printf("[Agent] File %s changed outside agent %s", event.Path, a.id)
+	a.emitMonitor(&types.MonitorFileChangedEvent{
+		Path:  event.Path,
+		Mtime: event.Mtime,
+	})
+	a.Remind(fmt.Sprintf(fileChangedReminder, event.Path), &types.ReminderOptions{Category: "file"})
+}
+
+// unwatchFiles 取消所有文件监听
+func (a *Agent) unwatchFiles() {
+	a.mu.Lock()
+	var watchIDs []string
+	for _, state := range a.files {
+		if state.watchID != "" && state.watchID != "pending" {
+			watchIDs = append(watchIDs, state.watchID)
+		}
+		state.watchID = ""
+	}
+	a.mu.Unlock()
+
+	for _, watchID := range watchIDs {
+		if err := a.sandbox.Unwatch(watchID); err != nil {
+			log.Printf("[Agent] Failed to unwatch %s: %v", watchID, err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/provider"
+	"github.com/wordflowlab/agentsdk/pkg/sandbox"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// readStep 读取 hello.txt 的脚本步骤
+func readStep(id string) provider.MockStep {
+	return provider.MockToolStep(provider.MockToolCall{
+		ID:          id,
+		Name:        "fs_read",
+		PartialJSON: []string{`{"path":"hello.txt"}`},
+	})
+}
+
+func TestAgentFiles_TracksToolAccess(t *testing.T) {
+	mp := provider.NewMockProvider(writeStep(), readStep("call_read"), provider.MockTextStep("done"))
+	ag := newMockAgent(t, mp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := ag.Chat(ctx, "write and read hello.txt"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	files := ag.Files()
+	if len(files) != 1 || files[0].Path != "hello.txt" || files[0].Reads != 1 || files[0].Writes != 1 {
+		t.Fatalf("Unexpected file activity: %+v", files)
+	}
+	if recent := ag.getRecentFiles(); len(recent) != 1 || recent[0] != "hello.txt" {
+		t.Errorf("Expected recent files [hello.txt], got %v", recent)
+	}
+	if status := ag.Status(); len(status.RecentFiles) != 1 || status.RecentFiles[0] != "hello.txt" {
+		t.Errorf("Expected recent files in status, got %v", status.RecentFiles)
+	}
+
+	// 恢复时从工具调用记录重建
+	ag.files = make(map[string]*fileState)
+	ag.loadFileActivity()
+	if files := ag.Files(); len(files) != 1 || files[0].Reads != 1 || files[0].Writes != 1 {
+		t.Errorf("Expected file activity rebuilt from tool records, got %+v", files)
+	}
+}
+
+func TestAgentFiles_ExternalChange(t *testing.T) {
+	mp := provider.NewMockProvider(readStep("call_read"), provider.MockTextStep("read it"))
+	ag := newMockAgent(t, mp, func(config *types.AgentConfig) {
+		config.Sandbox.WatchFiles = true
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := ag.sandbox.FS().Write(ctx, "hello.txt", "v1"); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := ag.Chat(ctx, "read hello.txt"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if state := ag.files["hello.txt"]; state == nil || state.watchID == "" {
+		t.Fatalf("Expected hello.txt to be watched")
+	}
+
+	// 读取之前的修改不通知,之后的修改通知一次
+	readAt := ag.Files()[0].LastReadAt
+	ag.onFileChanged(sandbox.FileChangeEvent{Path: "hello.txt", Mtime: readAt.Add(-time.Second)})
+	ag.onFileChanged(sandbox.FileChangeEvent{Path: "hello.txt", Mtime: readAt.Add(time.Second)})
+	ag.onFileChanged(sandbox.FileChangeEvent{Path: "hello.txt", Mtime: readAt.Add(time.Second)})
+	ag.onFileChanged(sandbox.FileChangeEvent{Path: "other.txt", Mtime: readAt.Add(time.Second)})
+
+	var changed []string
+	for _, envelope := range ag.eventBus.GetTimeline() {
+		if e, ok := envelope.Event.(*types.MonitorFileChangedEvent); ok {
+			changed = append(changed, e.Path)
+		}
+	}
+	if len(changed) != 1 || changed[0] != "hello.txt" {
+		t.Errorf("Expected one change notification for hello.txt, got %v", changed)
+	}
+	if len(ag.reminders) != 1 || ag.reminders[0].category != "file" {
+		t.Errorf("Expected a file reminder for the model, got %+v", ag.reminders)
+	}
+}
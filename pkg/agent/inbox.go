@@ -0,0 +1,173 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// 收件箱消息类型
+const (
+	inboxKindQueued = "queued" // 当前轮结束后作为新一轮处理
+	inboxKindSteer  = "steer"  // 在当前轮的下一次模型调用前注入
+)
+
+// queuedMessage 执行期间收到、尚未加入对话的用户消息
+type queuedMessage struct {
+	id       string
+	kind     string
+	ctx      context.Context // 发送方的上下文,处理该消息的执行使用它
+	message  types.Message
+	queuedAt time.Time
+}
+
+// Steer 向正在执行的一轮发送引导消息,在下一次模型调用前作为用户消息加入对话
+// Agent 空闲时等同于 Send
+func (a *Agent) Steer(ctx context.Context, text string) error {
+	if text == "" {
+		return fmt.Errorf("message cannot be empty")
+	}
+
+	a.mu.Lock()
+	if a.state == types.AgentStateReady {
+		a.mu.Unlock()
+		return a.Send(ctx, text)
+	}
+	queued := newQueuedMessage(ctx, inboxKindSteer, types.Message{
+		Role:    types.MessageRoleUser,
+		Content: []types.ContentBlock{&types.TextBlock{Text: text}},
+	})
+	a.steering = append(a.steering, queued)
+	pending := len(a.steering)
+	a.mu.Unlock()
+
+	a.emitMonitor(&types.MonitorMessageQueuedEvent{
+		MessageID: queued.id,
+		Kind:      queued.kind,
+		Pending:   pending,
+	})
+	return nil
+}
+
+// PendingMessages 返回收件箱中等待处理的消息数(含尚未注入的引导消息)
+func (a *Agent) PendingMessages() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.inbox) + len(a.steering)
+}
+
+func newQueuedMessage(ctx context.Context, kind string, message types.Message) queuedMessage {
+	return queuedMessage{
+		id:       "msg_" + uuid.New().String(),
+		kind:     kind,
+		ctx:      context.WithoutCancel(ctx), // 消息在之后的轮次处理,不随发送方的 ctx 取消
+		message:  message,
+		queuedAt: time.Now(),
+	}
+}
+
+// enqueue 把消息放入收件箱,当前轮结束后按顺序处理
+// 调用方需持有 a.mu
+func (a *Agent) enqueue(ctx context.Context, message types.Message) *types.MonitorMessageQueuedEvent {
+	queued := newQueuedMessage(ctx, inboxKindQueued, message)
+	a.inbox = append(a.inbox, queued)
+	return &types.MonitorMessageQueuedEvent{
+		MessageID: queued.id,
+		Kind:      queued.kind,
+		Pending:   len(a.inbox),
+	}
+}
+
+// runTurn 执行一轮处理,收件箱中的消息由 runMessages 结束时依次接力处理
+// 调用前需通过 beginRun 切换状态
+func (a *Agent) runTurn(ctx context.Context) {
+	a.runMessages(ctx, nil)
+}
+
+// dequeueLocked 取出收件箱中的下一条消息加入对话,并切换为 Working 状态
+// 上一轮没来得及注入的引导消息排在最前面;在释放锁之前调用,保证排队的消息先于新消息处理
+// 调用方需持有 a.mu,并在释放锁后调用 consumed 发出事件
+func (a *Agent) dequeueLocked() (queuedMessage, bool) {
+	if len(a.steering) > 0 {
+		a.inbox = append(a.steering, a.inbox...)
+		a.steering = nil
+	}
+	for len(a.inbox) > 0 {
+		next := a.inbox[0]
+		a.inbox = a.inbox[1:]
+		if err := a.appendUserMessage(next.ctx, next.message); err != nil {
+			log.Printf("[Agent] Failed to append queued message %s: %v", next.id, err)
+			continue
+		}
+		a.state = types.AgentStateWorking
+		return next, true
+	}
+	return queuedMessage{}, false
+}
+
+// consumed 发出收件箱消息被处理的事件,并在后台执行它的一轮
+func (a *Agent) consumed(next queuedMessage, step int) {
+	a.emitMonitor(&types.MonitorMessageConsumedEvent{
+		MessageID: next.id,
+		Kind:      next.kind,
+		Step:      step,
+	})
+	go a.runTurn(next.ctx)
+}
+
+// injectSteering 把执行期间收到的引导消息加入对话
+func (a *Agent) injectSteering(ctx context.Context) {
+	a.mu.Lock()
+	pending := a.steering
+	a.steering = nil
+	if len(pending) == 0 {
+		a.mu.Unlock()
+		return
+	}
+
+	var blocks []types.ContentBlock
+	for _, queued := range pending {
+		blocks = append(blocks, queued.message.Content...)
+	}
+	a.mergeUserBlocks(blocks)
+	messages := a.copyMessages()
+	step := a.stepCount
+	a.mu.Unlock()
+
+	if err := a.deps.Store.SaveMessages(ctx, a.id, messages); err != nil {
+		log.Printf("[Agent] Failed to save steering messages: %v", err)
+	}
+	for _, queued := range pending {
+		a.emitMonitor(&types.MonitorMessageConsumedEvent{
+			MessageID: queued.id,
+			Kind:      queued.kind,
+			Step:      step,
+		})
+	}
+}
+
+// mergeUserBlocks 把内容块合并到最后一条用户消息(如工具结果)中,保持角色交替
+// 最后一条不是用户消息时追加新的用户消息;调用方需持有 a.mu
+func (a *Agent) mergeUserBlocks(blocks []types.ContentBlock) {
+	if n := len(a.messages); n > 0 && a.messages[n-1].Role == types.MessageRoleUser {
+		last := a.messages[n-1]
+		last.Content = append(append([]types.ContentBlock{}, last.Content...), blocks...)
+		a.messages[n-1] = last
+		return
+	}
+	a.messages = append(a.messages, types.Message{
+		Role:    types.MessageRoleUser,
+		Content: blocks,
+	})
+}
+
+// hasSteering 是否有尚未注入的引导消息
+func (a *Agent) hasSteering() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.steering) > 0
+}
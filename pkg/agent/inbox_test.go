@@ -0,0 +1,227 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/provider"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// inboxEvents 从事件时间线中取出收件箱事件的类型
+func inboxEvents(ag *Agent) []string {
+	var kinds []string
+	for _, envelope := range ag.eventBus.GetTimeline() {
+		switch e := envelope.Event.(type) {
+		case *types.MonitorMessageQueuedEvent:
+			kinds = append(kinds, "queued:"+e.Kind)
+		case *types.MonitorMessageConsumedEvent:
+			kinds = append(kinds, "consumed:"+e.Kind)
+		}
+	}
+	return kinds
+}
+
+func TestAgentInbox_QueuedWhileWorking(t *testing.T) {
+	mp := provider.NewMockProvider(blockStep(), provider.MockTextStep("first done"), provider.MockTextStep("second done"))
+	ag := newMockAgent(t, mp)
+	tool := newBlockTool()
+	ag.toolMap["block"] = tool
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := ag.Send(ctx, "first"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	<-tool.started
+
+	// 执行中发送的消息进入收件箱,不会插到工具调用与结果之间
+	if err := ag.Send(ctx, "second"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if ag.PendingMessages() != 1 {
+		t.Fatalf("Expected 1 queued message, got %d", ag.PendingMessages())
+	}
+	close(tool.release)
+
+	waitForSteps(t, mp)
+	waitForState(t, ag, types.AgentStateReady)
+
+	var texts []string
+	for _, msg := range ag.messages {
+		if text := assistantText(msg); text != "" {
+			texts = append(texts, string(msg.Role)+":"+text)
+		}
+	}
+	expected := []string{"user:first", "assistant:first done", "user:second", "assistant:second done"}
+	if len(texts) != len(expected) {
+		t.Fatalf("Expected history %v, got %v", expected, texts)
+	}
+	for i := range expected {
+		if texts[i] != expected[i] {
+			t.Fatalf("Expected history %v, got %v", expected, texts)
+		}
+	}
+
+	events := inboxEvents(ag)
+	if len(events) != 2 || events[0] != "queued:queued" || events[1] != "consumed:queued" {
+		t.Errorf("Expected queued and consumed events, got %v", events)
+	}
+}
+
+func TestAgentInbox_HandoffKeepsOrder(t *testing.T) {
+	mp := provider.NewMockProvider(
+		blockStep(),
+		provider.MockTextStep("first done"),
+		provider.MockStep{Text: "second done", Delay: 200 * time.Millisecond},
+		provider.MockTextStep("third done"),
+	)
+	ag := newMockAgent(t, mp)
+	tool := newBlockTool()
+	ag.toolMap["block"] = tool
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := ag.Send(ctx, "first"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	<-tool.started
+	if err := ag.Send(ctx, "second"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	ag.mu.RLock()
+	done := ag.runDone
+	ag.mu.RUnlock()
+	close(tool.release)
+
+	// 一轮结束时直接接力处理收件箱,新消息不会插到排队的消息之前
+	<-done
+	if state := ag.Status().State; state != types.AgentStateWorking {
+		t.Errorf("Expected queued message to be handed off without going idle, got %s", state)
+	}
+	if err := ag.Send(ctx, "third"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	waitForSteps(t, mp)
+	waitForState(t, ag, types.AgentStateReady)
+
+	var users []string
+	for _, msg := range ag.messages {
+		if text := assistantText(msg); msg.Role == types.MessageRoleUser && text != "" {
+			users = append(users, text)
+		}
+	}
+	if len(users) != 3 || users[0] != "first" || users[1] != "second" || users[2] != "third" {
+		t.Errorf("Expected user messages in send order, got %v", users)
+	}
+}
+
+func TestAgentInbox_SteerBetweenSteps(t *testing.T) {
+	mp := provider.NewMockProvider(blockStep(), provider.MockTextStep("Switched to b.txt."))
+	ag := newMockAgent(t, mp)
+	tool := newBlockTool()
+	ag.toolMap["block"] = tool
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := ag.Send(ctx, "edit a.txt"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	<-tool.started
+	if err := ag.Steer(ctx, "actually, use b.txt"); err != nil {
+		t.Fatalf("Steer failed: %v", err)
+	}
+	close(tool.release)
+
+	waitForSteps(t, mp)
+	waitForState(t, ag, types.AgentStateReady)
+
+	// 引导消息合并在工具结果之后,随下一次模型调用发出
+	req, _ := mp.Request(1)
+	last := req.Messages[len(req.Messages)-1]
+	if last.Role != types.MessageRoleUser || len(last.Content) != 2 {
+		t.Fatalf("Expected tool result and steering text in the last message, got %+v", last)
+	}
+	if _, ok := last.Content[0].(*types.ToolResultBlock); !ok {
+		t.Errorf("Expected tool result first, got %T", last.Content[0])
+	}
+	if text, ok := last.Content[1].(*types.TextBlock); !ok || text.Text != "actually, use b.txt" {
+		t.Errorf("Expected steering text, got %+v", last.Content[1])
+	}
+
+	events := inboxEvents(ag)
+	if len(events) != 2 || events[0] != "queued:steer" || events[1] != "consumed:steer" {
+		t.Errorf("Expected steer queued and consumed events, got %v", events)
+	}
+}
+
+func TestAgentInbox_SteerDuringFinalReply(t *testing.T) {
+	mp := provider.NewMockProvider(
+		provider.MockStep{TextChunks: []string{"Writing", " the", " summary"}, ChunkDelay: 50 * time.Millisecond},
+		provider.MockTextStep("Shorter summary."),
+	)
+	ag := newMockAgent(t, mp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	progress := ag.Subscribe([]types.AgentChannel{types.ChannelProgress}, nil)
+	if err := ag.Send(ctx, "summarize"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	for envelope := range progress {
+		if _, ok := envelope.Event.(*types.ProgressTextChunkEvent); ok {
+			break
+		}
+	}
+	if err := ag.Steer(ctx, "keep it short"); err != nil {
+		t.Fatalf("Steer failed: %v", err)
+	}
+
+	// 最终回复期间收到的引导消息让本轮继续
+	waitForSteps(t, mp)
+	waitForState(t, ag, types.AgentStateReady)
+
+	req, _ := mp.Request(1)
+	last := req.Messages[len(req.Messages)-1]
+	if last.Role != types.MessageRoleUser || assistantText(last) != "keep it short" {
+		t.Errorf("Expected steering message before the second call, got %+v", last)
+	}
+	if ag.PendingMessages() != 0 {
+		t.Errorf("Expected empty inbox, got %d", ag.PendingMessages())
+	}
+}
+
+func TestAgentInbox_ChatReturnsOwnTurn(t *testing.T) {
+	mp := provider.NewMockProvider(blockStep(), provider.MockTextStep("first done"), provider.MockTextStep("second done"))
+	ag := newMockAgent(t, mp)
+	tool := newBlockTool()
+	ag.toolMap["block"] = tool
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	go func() {
+		<-tool.started
+		// Chat 执行期间排队的消息在其结束后立即开始下一轮
+		if err := ag.Send(ctx, "second"); err != nil {
+			t.Errorf("Send failed: %v", err)
+		}
+		close(tool.release)
+	}()
+
+	result, err := ag.Chat(ctx, "first")
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if result.Text != "first done" {
+		t.Errorf("Expected Chat to return its own turn's reply, got %q", result.Text)
+	}
+
+	waitForSteps(t, mp)
+	waitForState(t, ag, types.AgentStateReady)
+}
@@ -0,0 +1,165 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+const (
+	// defaultMaxToolResultChars 模板未配置时,工具结果写入文件的阈值
+	defaultMaxToolResultChars = 50000
+
+	// toolResultPreviewChars 写入文件的工具结果在对话中保留的开头字符数,结尾保留一半
+	toolResultPreviewChars = 2000
+
+	// toolResultDir 大工具结果在沙箱中的目录(相对工作目录)
+	toolResultDir = ".agentsdk/tool_results"
+)
+
+// maxToolResultChars 返回工具结果写入文件的阈值,0 表示不限制
+func (a *Agent) maxToolResultChars() int {
+	if a.template.Runtime == nil || a.template.Runtime.MaxToolResultChars == 0 {
+		return defaultMaxToolResultChars
+	}
+	if a.template.Runtime.MaxToolResultChars < 0 {
+		return 0
+	}
+	return a.template.Runtime.MaxToolResultChars
+}
+
+// toolResultTextFields 结构化工具结果中按顺序优先写入文件的文本字段
+var toolResultTextFields = []string{"output", "content", "body", "text", "stdout"}
+
+// offloadToolResult 工具结果超过阈值时把完整结果写入沙箱文件,
+// 对话中只保留首尾预览和文件路径,模型可以用 fs_read 分页读取;写入失败时返回原结果
+// 结构化结果只把其中的文本字段原样写入文件,其余字段(与预览信息同名的除外)保留在对话中
+func (a *Agent) offloadToolResult(ctx context.Context, tu *types.ToolUseBlock, output interface{}) interface{} {
+	limit := a.maxToolResultChars()
+	if limit == 0 || a.readsToolResult(tu) {
+		return output
+	}
+	text, fields, ok := toolResultText(output)
+	if !ok || len(text) <= limit {
+		return output
+	}
+
+	file := path.Join(toolResultDir, tu.ID+".txt")
+	if err := a.sandbox.FS().Write(ctx, file, text); err != nil {
+		log.Printf("[Agent] Failed to offload result of tool %s (%s): %v", tu.Name, tu.ID, err)
+		return output
+	}
+	totalLines := strings.Count(text, "\n") + 1
+	log.Printf("[Agent] Offloaded result of tool %s (%d chars, %d lines) to %s", tu.Name, len(text), totalLines, file)
+
+	result := map[string]interface{}{"ok": true}
+	for key, value := range fields {
+		result[key] = value
+	}
+	result["offloaded"] = true
+	result["path"] = file
+	result["size"] = len(text)
+	result["totalLines"] = totalLines
+	result["head"] = previewHead(text, toolResultPreviewChars)
+	result["tail"] = previewTail(text, toolResultPreviewChars/2)
+	result["message"] = fmt.Sprintf("The result of %s was too large (%d chars, %d lines) and was saved to %s. "+
+		"Only the beginning and end are shown; use fs_read with offset/limit to read the rest.", tu.Name, len(text), totalLines, file)
+	return result
+}
+
+// readsToolResult 是否为 fs_read 分页读取已写入文件的工具结果,这类结果不再写入文件
+func (a *Agent) readsToolResult(tu *types.ToolUseBlock) bool {
+	file, op := a.fileAccessOf(tu.Name, tu.Input)
+	if op != fileOpRead {
+		return false
+	}
+	dir := a.sandbox.FS().Resolve(toolResultDir)
+	return strings.HasPrefix(file, dir+"/")
+}
+
+// toolResultText 把工具结果转换为写入文件的文本,fields 为结构化结果中未写入文件的其余字段
+// 结构化结果写入其中的文本字段(见 toolResultTextFields,否则为最长的字符串字段),保留换行;
+// 没有字符串字段时写入缩进的 JSON。内容块(图片、文档)保持原样,返回 false
+func toolResultText(output interface{}) (text string, fields map[string]interface{}, ok bool) {
+	switch v := output.(type) {
+	case string:
+		return v, nil, true
+	case []types.ContentBlock:
+		return "", nil, false
+	}
+
+	data, err := json.Marshal(output)
+	if err != nil {
+		return "", nil, false
+	}
+	var object map[string]interface{}
+	if json.Unmarshal(data, &object) == nil && object != nil {
+		if key := toolResultTextField(object); key != "" {
+			fields = make(map[string]interface{}, len(object)-1)
+			for k, value := range object {
+				if k != key {
+					fields[k] = value
+				}
+			}
+			return object[key].(string), fields, true
+		}
+	}
+
+	data, err = json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return "", nil, false
+	}
+	return string(data), nil, true
+}
+
+// toolResultTextField 返回结构化结果中写入文件的字符串字段,没有时返回空
+func toolResultTextField(object map[string]interface{}) string {
+	for _, key := range toolResultTextFields {
+		if _, ok := object[key].(string); ok {
+			return key
+		}
+	}
+	longest, size := "", 0
+	for key, value := range object {
+		if text, ok := value.(string); ok && len(text) > size {
+			longest, size = key, len(text)
+		}
+	}
+	return longest
+}
+
+// previewHead 返回 text 开头不超过 n 个字符的部分,尽量在行尾截断
+func previewHead(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	head := text[:n]
+	if i := strings.LastIndexByte(head, '\n'); i > 0 {
+		return head[:i]
+	}
+	for len(head) > 0 && !utf8.ValidString(head) {
+		head = head[:len(head)-1]
+	}
+	return head
+}
+
+// previewTail 返回 text 结尾不超过 n 个字符的部分,尽量从行首开始
+func previewTail(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	tail := text[len(text)-n:]
+	if i := strings.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+		return tail[i+1:]
+	}
+	for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+		tail = tail[1:]
+	}
+	return tail
+}
@@ -0,0 +1,163 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/provider"
+	"github.com/wordflowlab/agentsdk/pkg/tools"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// bigTool 返回多行大输出的测试工具
+type bigTool struct{}
+
+func (t *bigTool) Name() string        { return "big" }
+func (t *bigTool) Description() string { return "produce a large output" }
+func (t *bigTool) Prompt() string      { return "" }
+func (t *bigTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "object"}
+}
+
+func (t *bigTool) Execute(ctx context.Context, input map[string]interface{}, tc *tools.ToolContext) (interface{}, error) {
+	return buildLog(), nil
+}
+
+// bigMapTool 以结构化结果返回大输出的测试工具
+type bigMapTool struct{ bigTool }
+
+func (t *bigMapTool) Name() string { return "big_map" }
+
+func (t *bigMapTool) Execute(ctx context.Context, input map[string]interface{}, tc *tools.ToolContext) (interface{}, error) {
+	return map[string]interface{}{"ok": false, "code": 2, "output": buildLog()}, nil
+}
+
+// buildLog 100 行的测试输出
+func buildLog() string {
+	lines := make([]string, 100)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %03d of the build log", i)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestAgentOffloadLargeToolResult(t *testing.T) {
+	mp := provider.NewMockProvider(
+		provider.MockToolStep(provider.MockToolCall{ID: "call_big", Name: "big"}),
+		provider.MockToolStep(provider.MockToolCall{
+			ID:          "call_page",
+			Name:        "fs_read",
+			PartialJSON: []string{`{"path":".agentsdk/tool_results/call_big.txt","offset":50,"limit":2}`},
+		}),
+		provider.MockTextStep("Build looks fine."),
+	)
+	ag := newMockAgent(t, mp)
+	ag.template.Runtime = &types.AgentTemplateRuntime{MaxToolResultChars: 500}
+	ag.AddTool(&bigTool{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := ag.Chat(ctx, "run the build"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	// 对话中只保留首尾预览和文件路径
+	req, _ := mp.Request(1)
+	result, ok := req.Messages[len(req.Messages)-1].Content[0].(*types.ToolResultBlock)
+	if !ok {
+		t.Fatalf("Expected tool result block")
+	}
+	preview, ok := result.Content.(map[string]interface{})
+	if !ok || preview["offloaded"] != true || preview["path"] != ".agentsdk/tool_results/call_big.txt" {
+		t.Fatalf("Expected offloaded result, got %+v", result.Content)
+	}
+	head, _ := preview["head"].(string)
+	tail, _ := preview["tail"].(string)
+	if !strings.HasPrefix(head, "line 000") || !strings.HasSuffix(tail, "line 099 of the build log") || len(head) > toolResultPreviewChars {
+		t.Errorf("Unexpected preview head=%q tail=%q", head, tail)
+	}
+
+	// 完整结果可以用 fs_read 分页读取
+	req, _ = mp.Request(2)
+	page, ok := req.Messages[len(req.Messages)-1].Content[0].(*types.ToolResultBlock)
+	if !ok {
+		t.Fatalf("Expected fs_read result block")
+	}
+	content, _ := page.Content.(map[string]interface{})["content"].(string)
+	if content != "line 050 of the build log\nline 051 of the build log" {
+		t.Errorf("Unexpected page content: %q", content)
+	}
+}
+
+func TestAgentOffloadStructuredToolResult(t *testing.T) {
+	mp := provider.NewMockProvider(
+		provider.MockToolStep(provider.MockToolCall{ID: "call_big", Name: "big_map"}),
+		provider.MockToolStep(provider.MockToolCall{
+			ID:          "call_page",
+			Name:        "fs_read",
+			PartialJSON: []string{`{"path":".agentsdk/tool_results/call_big.txt","offset":50,"limit":2}`},
+		}),
+		provider.MockToolStep(provider.MockToolCall{
+			ID:          "call_all",
+			Name:        "fs_read",
+			PartialJSON: []string{`{"path":".agentsdk/tool_results/call_big.txt"}`},
+		}),
+		provider.MockTextStep("The build failed."),
+	)
+	ag := newMockAgent(t, mp)
+	ag.template.Runtime = &types.AgentTemplateRuntime{MaxToolResultChars: 500}
+	ag.AddTool(&bigMapTool{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := ag.Chat(ctx, "run the build"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	// 文本字段写入文件,其余字段保留在对话中
+	req, _ := mp.Request(1)
+	result := req.Messages[len(req.Messages)-1].Content[0].(*types.ToolResultBlock)
+	preview, ok := result.Content.(map[string]interface{})
+	if !ok || preview["offloaded"] != true || preview["ok"] != false || preview["code"] != float64(2) || preview["totalLines"] != 100 {
+		t.Fatalf("Expected offloaded result with original fields, got %+v", result.Content)
+	}
+	if _, ok := preview["output"]; ok {
+		t.Errorf("Expected output field to be offloaded")
+	}
+
+	// 文件保留原始换行,可以按行分页
+	req, _ = mp.Request(2)
+	page := req.Messages[len(req.Messages)-1].Content[0].(*types.ToolResultBlock)
+	content, _ := page.Content.(map[string]interface{})["content"].(string)
+	if content != "line 050 of the build log\nline 051 of the build log" {
+		t.Errorf("Unexpected page content: %q", content)
+	}
+
+	// 读取已写入文件的结果不再写入文件
+	req, _ = mp.Request(3)
+	all := req.Messages[len(req.Messages)-1].Content[0].(*types.ToolResultBlock)
+	if output, _ := all.Content.(map[string]interface{}); output["offloaded"] == true {
+		t.Errorf("Expected fs_read of an offloaded result to be returned as is, got %+v", output)
+	}
+}
+
+func TestToolResultPreview(t *testing.T) {
+	text := "alpha\nbeta\ngamma\ndelta"
+	if got := previewHead(text, 12); got != "alpha\nbeta" {
+		t.Errorf("Expected head cut at line end, got %q", got)
+	}
+	if got := previewTail(text, 9); got != "delta" {
+		t.Errorf("Expected tail cut at line start, got %q", got)
+	}
+	if got := previewHead("日志日志", 4); got != "日" {
+		t.Errorf("Expected head cut at rune boundary, got %q", got)
+	}
+	if _, _, ok := toolResultText([]types.ContentBlock{&types.TextBlock{Text: "x"}}); ok {
+		t.Errorf("Content blocks should not be offloaded")
+	}
+}
@@ -0,0 +1,193 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/permission"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// Permissions 返回工具权限管理器,可在运行时调整规则
+func (a *Agent) Permissions() *permission.Manager {
+	return a.permissions
+}
+
+// PendingPermissions 返回等待审批的工具调用 ID
+func (a *Agent) PendingPermissions() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	ids := make([]string, 0, len(a.pendingPermissions))
+	for id := range a.pendingPermissions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Decide 对等待审批的工具调用作出决定,decision 为 "allow" 或 "deny"
+// 本批次的审批全部有决定后,暂停的执行继续进行
+func (a *Agent) Decide(callID, decision, note string) error {
+	d := permission.Decision(decision)
+	if d != permission.Allow && d != permission.Deny {
+		return fmt.Errorf("invalid decision %q: must be allow or deny", decision)
+	}
+
+	a.mu.Lock()
+	ch, ok := a.pendingPermissions[callID]
+	if !ok {
+		a.mu.Unlock()
+		return fmt.Errorf("no pending permission for call: %s", callID)
+	}
+	delete(a.pendingPermissions, callID)
+
+	if record, ok := a.toolRecords[callID]; ok {
+		now := time.Now()
+		record.Approval.Decision = decision
+		record.Approval.DecidedBy = "user"
+		record.Approval.DecidedAt = &now
+		record.Approval.Note = note
+	}
+	a.mu.Unlock()
+
+	a.emitControl(&types.ControlPermissionDecidedEvent{
+		CallID:    callID,
+		Decision:  decision,
+		DecidedBy: "user",
+		Note:      note,
+	})
+
+	ch <- decision
+	return nil
+}
+
+// authorizeToolCalls 执行前检查工具权限
+// 被拒绝的调用直接生成错误结果(按下标返回);需要审批的调用会暂停 Agent,等待 Decide
+func (a *Agent) authorizeToolCalls(ctx context.Context, toolUses []*types.ToolUseBlock) map[int]types.ContentBlock {
+	results := make(map[int]types.ContentBlock)
+	pending := make(map[string]int)
+
+	for i, tu := range toolUses {
+		if _, ok := a.lookupTool(tu.Name); !ok {
+			continue // 工具不存在,执行时返回错误
+		}
+
+		record := a.ensureToolRecord(tu)
+		decision, reason, err := a.permissions.Check(ctx, record)
+		if err != nil {
+			decision, reason = permission.Deny, err.Error()
+		}
+
+		switch decision {
+		case permission.Deny:
+			results[i] = a.failToolCall(tu, types.ToolCallStateDenied, fmt.Sprintf("permission denied: %s", reason))
+		case permission.Ask:
+			pending[tu.ID] = i
+		}
+	}
+
+	if len(pending) == 0 {
+		return results
+	}
+
+	decisions := a.awaitApproval(ctx, toolUses, pending)
+	for id, i := range pending {
+		tu := toolUses[i]
+		switch decisions[id] {
+		case string(permission.Allow):
+			a.updateToolRecord(id, types.ToolCallStateApproved, "")
+		case string(permission.Deny):
+			errorMsg := "permission denied by user"
+			a.mu.RLock()
+			if note := a.toolRecords[id].Approval.Note; note != "" {
+				errorMsg += ": " + note
+			}
+			a.mu.RUnlock()
+			results[i] = a.failToolCall(tu, types.ToolCallStateDenied, errorMsg)
+		default:
+			results[i] = a.failToolCall(tu, types.ToolCallStateFailed, "interrupted while awaiting approval")
+		}
+	}
+
+	return results
+}
+
+// awaitApproval 暂停 Agent 并等待待审批调用的决定,ctx 取消时未决定的调用返回空字符串
+func (a *Agent) awaitApproval(ctx context.Context, toolUses []*types.ToolUseBlock, pending map[string]int) map[string]string {
+	channels := make(map[string]chan string, len(pending))
+
+	a.mu.Lock()
+	for id := range pending {
+		ch := make(chan string, 1)
+		channels[id] = ch
+		a.pendingPermissions[id] = ch
+		if record, ok := a.toolRecords[id]; ok {
+			record.Approval.Required = true
+		}
+	}
+	a.state = types.AgentStatePaused
+	a.mu.Unlock()
+
+	for id := range pending {
+		a.updateToolRecord(id, types.ToolCallStateApprovalRequired, "")
+	}
+	a.setBreakpoint(types.BreakpointAwaitingApproval)
+	if err := a.saveToolRecords(ctx); err != nil {
+		log.Printf("[Agent] Failed to save tool records: %v", err)
+	}
+
+	// 按调用顺序发出审批请求
+	ids := make([]string, 0, len(pending))
+	for _, tu := range toolUses {
+		if _, ok := pending[tu.ID]; ok {
+			ids = append(ids, tu.ID)
+		}
+	}
+	for _, id := range ids {
+		tu := toolUses[pending[id]]
+		callID := id
+		a.emitControl(&types.ControlPermissionRequiredEvent{
+			Call: types.ToolCallSnapshot{
+				ID:        tu.ID,
+				Name:      tu.Name,
+				State:     types.ToolCallStateApprovalRequired,
+				Arguments: tu.Input,
+			},
+			Respond: func(decision string, note string) error {
+				return a.Decide(callID, decision, note)
+			},
+		})
+	}
+	a.emitMonitor(&types.MonitorStateChangedEvent{
+		State:  types.AgentStatePaused,
+		Reason: types.PauseReasonPermission,
+	})
+
+	decisions := make(map[string]string, len(ids))
+	for _, id := range ids {
+		select {
+		case decision := <-channels[id]:
+			decisions[id] = decision
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	a.mu.Lock()
+	for id := range channels {
+		delete(a.pendingPermissions, id)
+	}
+	a.state = types.AgentStateWorking
+	a.mu.Unlock()
+
+	a.emitMonitor(&types.MonitorStateChangedEvent{
+		State: types.AgentStateWorking,
+	})
+	return decisions
+}
@@ -0,0 +1,142 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/provider"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// writeStep 调用 fs_write 写入 hello.txt 的脚本步骤
+func writeStep() provider.MockStep {
+	return provider.MockToolStep(provider.MockToolCall{
+		ID:          "call_write",
+		Name:        "fs_write",
+		PartialJSON: []string{`{"path":"hello.txt","content":"hi"}`},
+	})
+}
+
+// withPermission 设置 Agent 的权限配置
+func withPermission(permission *types.PermissionConfig) func(*types.AgentConfig) {
+	return func(config *types.AgentConfig) {
+		config.Overrides = &types.AgentConfigOverrides{Permission: permission}
+	}
+}
+
+// waitForState 等待 Agent 进入指定状态
+func waitForState(t *testing.T, ag *Agent, state types.AgentRuntimeState) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for ag.Status().State != state {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for state %s, got %s", state, ag.Status().State)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAgentPermission_PauseAndAllow(t *testing.T) {
+	mp := provider.NewMockProvider(writeStep(), provider.MockTextStep("File written."))
+	ag := newMockAgent(t, mp, withPermission(&types.PermissionConfig{
+		Mode: types.PermissionModeAuto,
+		Ask:  []string{"fs_write"},
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := ag.Chat(ctx, "write hello.txt")
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if result.Status != "paused" || len(result.PermissionIDs) != 1 || result.PermissionIDs[0] != "call_write" {
+		t.Fatalf("Expected paused result for call_write, got %+v", result)
+	}
+	if ag.Status().State != types.AgentStatePaused {
+		t.Errorf("Expected agent to be paused, got %s", ag.Status().State)
+	}
+	if _, err := ag.sandbox.FS().Read(ctx, "hello.txt"); err == nil {
+		t.Fatalf("Tool should not run before approval")
+	}
+
+	decided := ag.eventBus.Subscribe([]types.AgentChannel{types.ChannelControl}, nil)
+	if err := ag.Decide("call_write", "allow", "looks fine"); err != nil {
+		t.Fatalf("Decide failed: %v", err)
+	}
+	envelope := <-decided
+	if e, ok := envelope.Event.(*types.ControlPermissionDecidedEvent); !ok || e.CallID != "call_write" || e.Decision != "allow" {
+		t.Errorf("Expected permission decided event, got %+v", envelope.Event)
+	}
+
+	waitForState(t, ag, types.AgentStateReady)
+	if content, err := ag.sandbox.FS().Read(ctx, "hello.txt"); err != nil || content != "hi" {
+		t.Errorf("Expected hello.txt to be written after approval, got %q (%v)", content, err)
+	}
+	if mp.Remaining() != 0 {
+		t.Errorf("Expected the run to continue after approval, %d steps left", mp.Remaining())
+	}
+	if err := ag.Decide("call_write", "allow", ""); err == nil {
+		t.Errorf("Expected error when deciding a call that is no longer pending")
+	}
+}
+
+func TestAgentPermission_DenyByUser(t *testing.T) {
+	mp := provider.NewMockProvider(writeStep(), provider.MockTextStep("Okay, skipped."))
+	ag := newMockAgent(t, mp, withPermission(&types.PermissionConfig{Mode: types.PermissionModeApproval}))
+
+	// 通过事件中的 Respond 回调审批
+	ag.eventBus.OnControl("permission_required", func(event interface{}) {
+		event.(*types.ControlPermissionRequiredEvent).Respond("deny", "not in this repo")
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var final string
+	for event, err := range ag.Stream(ctx, "write hello.txt") {
+		if err != nil {
+			t.Fatalf("Stream failed: %v", err)
+		}
+		if StreamEventKind(event) == StreamEventFinal {
+			final = assistantText(event.Content)
+		}
+	}
+	if final != "Okay, skipped." {
+		t.Errorf("Expected stream to continue after decision, got %q", final)
+	}
+
+	second, _ := mp.Request(1)
+	tr := second.Messages[len(second.Messages)-1].Content[0].(*types.ToolResultBlock)
+	errorMsg, _ := tr.Content.(map[string]interface{})["error"].(string)
+	if !tr.IsError || !strings.Contains(errorMsg, "not in this repo") {
+		t.Errorf("Expected denied tool result with note, got %+v", tr)
+	}
+	if ag.toolRecords["call_write"].State != types.ToolCallStateDenied {
+		t.Errorf("Expected record state DENIED, got %s", ag.toolRecords["call_write"].State)
+	}
+}
+
+func TestAgentPermission_DenyList(t *testing.T) {
+	mp := provider.NewMockProvider(writeStep(), provider.MockTextStep("Cannot write."))
+	ag := newMockAgent(t, mp, withPermission(&types.PermissionConfig{
+		Mode: types.PermissionModeAllow,
+		Deny: []string{"fs_write"},
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := ag.Chat(ctx, "write hello.txt")
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if result.Status != "ok" || result.Text != "Cannot write." {
+		t.Errorf("Expected run to complete without pausing, got %+v", result)
+	}
+	if _, err := ag.sandbox.FS().Read(ctx, "hello.txt"); err == nil {
+		t.Errorf("Denied tool should not run")
+	}
+}
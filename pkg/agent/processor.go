@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/wordflowlab/agentsdk/pkg/middleware"
@@ -16,62 +17,182 @@ import (
 
 // processMessages 处理消息队列
 func (a *Agent) processMessages(ctx context.Context) {
+	if !a.beginRun() {
+		return // 已经在处理中
+	}
+	a.runTurn(ctx)
+}
+
+// beginRun 把状态从 Ready 切换为 Working,已经在处理中时返回 false
+func (a *Agent) beginRun() bool {
 	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	if a.state != types.AgentStateReady {
-		a.mu.Unlock()
-		return // 已经在处理中
+		return false
 	}
 	a.state = types.AgentStateWorking
+	return true
+}
+
+// runMessages 执行一轮处理(模型调用与工具循环),结束后恢复 Ready 状态
+// 调用前需通过 beginRun 切换状态;limits 为本次调用指定的预算,为空时使用模板配置;返回模型调用错误
+func (a *Agent) runMessages(ctx context.Context, limits *types.RunBudget) error {
+	budget := newRunBudget(a.runBudgetLimits(limits))
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	a.mu.Lock()
+	a.budget = budget
+	a.runCancel = cancel
+	a.runDone = done
+	a.interrupted = false
+	a.turnStart = len(a.messages)
 	a.mu.Unlock()
 
+	// 结束后回到空闲状态(执行期间收到 Pause 时保持暂停)
+	idleState := types.AgentStateReady
+	// 收件箱中有消息时在同一次加锁中接力处理,期间收到的新消息排在它们之后
 	defer func() {
+		cancel()
 		a.mu.Lock()
-		a.state = types.AgentStateReady
+		a.state = idleState
+		a.budget = nil
+		a.runCancel = nil
+		a.runDone = nil
+		if a.stream != nil {
+			a.stream.final = lastAssistantMessage(a.messages[a.turnStart:])
+			a.stream.step = a.stepCount
+		}
+		a.stream = nil // Stream 出口只属于本轮执行
+		var next queuedMessage
+		handoff := false
+		if idleState == types.AgentStateReady {
+			next, handoff = a.dequeueLocked()
+		}
+		step := a.stepCount
 		a.mu.Unlock()
+		close(done)
+
+		if handoff {
+			a.consumed(next, step)
+		}
 	}()
 
 	// 发送状态变更事件
-	a.eventBus.EmitMonitor(&types.MonitorStateChangedEvent{
+	a.emitMonitor(&types.MonitorStateChangedEvent{
 		State: types.AgentStateWorking,
 	})
 
 	// 设置断点
 	a.setBreakpoint(types.BreakpointPreModel)
 
-	// 调用模型
-	if err := a.runModelStep(ctx); err != nil {
-		a.eventBus.EmitMonitor(&types.MonitorErrorEvent{
+	// 模型与工具循环
+	reason, err := a.runLoop(ctx, budget)
+	a.mu.RLock()
+	interrupted := a.interrupted
+	if a.resumeCh != nil {
+		idleState = types.AgentStatePaused
+	}
+	a.mu.RUnlock()
+
+	if interrupted {
+		// 主动中断不作为错误返回
+		reason, err = types.DoneReasonInterrupted, nil
+	} else if err != nil {
+		if ctx.Err() != nil {
+			reason = types.DoneReasonInterrupted
+		}
+		a.emitMonitor(&types.MonitorErrorEvent{
 			Severity: "error",
 			Phase:    "model",
 			Message:  err.Error(),
 		})
 	}
 
+	// 一轮结束后所有工具调用都有结果,记录安全分叉点
+	a.markSafeForkPoint()
+
 	// 发送完成事件
-	a.eventBus.EmitProgress(&types.ProgressDoneEvent{
+	a.emitProgress(&types.ProgressDoneEvent{
 		Step:   a.stepCount,
-		Reason: "completed",
+		Reason: reason,
 	})
 
 	// 发送状态变更事件
-	a.eventBus.EmitMonitor(&types.MonitorStateChangedEvent{
-		State: types.AgentStateReady,
+	a.emitMonitor(&types.MonitorStateChangedEvent{
+		State: idleState,
 	})
+
+	return err
+}
+
+// runLoop 依次调用模型并执行其请求的工具,直到模型给出最终回复或预算耗尽
+// 返回 ProgressDoneEvent 的 Reason
+func (a *Agent) runLoop(ctx context.Context, budget *runBudget) (string, error) {
+	// 墙钟时间预算同时限制进行中的模型调用和工具执行,收尾调用不受其限制
+	stepCtx, stop := budget.withDeadline(ctx)
+	defer stop()
+	failed := func(reason string, err error) (string, error) {
+		if context.Cause(stepCtx) == errMaxDuration {
+			return a.finishOverBudget(ctx, budget, types.DoneReasonMaxDuration)
+		}
+		return reason, err
+	}
+
+	for {
+		// 每一步开始前检查暂停与中断
+		if err := a.waitIfPaused(stepCtx); err != nil {
+			return failed(types.DoneReasonInterrupted, err)
+		}
+
+		if reason := budget.exceeded(); reason != "" {
+			return a.finishOverBudget(ctx, budget, reason)
+		}
+
+		// 执行期间收到的引导消息在模型调用前加入对话
+		a.injectSteering(stepCtx)
+		a.injectReminders(stepCtx)
+
+		// 提示词超过上下文上限时压缩较早的对话
+		a.manageContext(stepCtx)
+
+		budget.addStep()
+		toolUses, err := a.runModelStep(stepCtx, "")
+		if err != nil {
+			return failed(types.DoneReasonError, err)
+		}
+		a.trackTodoSteps(toolUses)
+		if len(toolUses) == 0 {
+			if a.hasSteering() {
+				continue // 模型回复期间收到引导消息,继续下一步
+			}
+			return types.DoneReasonCompleted, nil
+		}
+		if err := a.executeTools(stepCtx, toolUses, budget); err != nil {
+			return failed(types.DoneReasonError, err)
+		}
+	}
 }
 
-// runModelStep 运行模型步骤
-func (a *Agent) runModelStep(ctx context.Context) error {
+// finishOverBudget 预算耗尽时结束本轮,配置了 WrapUp 时先让模型根据已有信息收尾
+func (a *Agent) finishOverBudget(ctx context.Context, budget *runBudget, reason string) (string, error) {
+	log.Printf("[runLoop] Agent %s: run budget exhausted: %s", a.id, reason)
+	if budget.limits.WrapUp {
+		if _, err := a.runModelStep(ctx, fmt.Sprintf(wrapUpPrompt, reason)); err != nil {
+			return types.DoneReasonError, err
+		}
+	}
+	return reason, nil
+}
+
+// runModelStep 运行模型步骤,返回模型请求的工具调用
+// wrapUp 非空时作为收尾指令附加在最后一条用户消息后(不持久化),并丢弃回复中的工具调用
+func (a *Agent) runModelStep(ctx context.Context, wrapUp string) ([]*types.ToolUseBlock, error) {
 	a.setBreakpoint(types.BreakpointStreamingModel)
 
 	// 准备工具Schema
-	toolSchemas := make([]provider.ToolSchema, 0, len(a.toolMap))
-	for _, tool := range a.toolMap {
-		toolSchemas = append(toolSchemas, provider.ToolSchema{
-			Name:        tool.Name(),
-			Description: tool.Description(),
-			InputSchema: tool.InputSchema(),
-		})
-	}
+	toolList := a.currentTools()
+	toolSchemas := toolSchemasOf(toolList)
 	toolNames := make([]string, len(toolSchemas))
 	for i, ts := range toolSchemas {
 		toolNames[i] = ts.Name
@@ -82,9 +203,9 @@ func (a *Agent) runModelStep(ctx context.Context) error {
 	// 确保系统提示词包含工具手册（如果还没有注入）
 	a.mu.RLock()
 	hasManual := strings.Contains(a.template.SystemPrompt, "### Tools Manual")
-	toolMapSize := len(a.toolMap)
+	toolMapSize := len(toolList)
 	currentSystemPrompt := a.template.SystemPrompt
-	messages := a.messages // 复制当前消息列表
+	messages := a.copyMessages()
 	a.mu.RUnlock()
 
 	if !hasManual && toolMapSize > 0 {
@@ -101,6 +222,10 @@ func (a *Agent) runModelStep(ctx context.Context) error {
 
 	log.Printf("[runModelStep] Agent %s: Final system prompt length: %d, contains manual: %v", a.id, len(currentSystemPrompt), strings.Contains(currentSystemPrompt, "### Tools Manual"))
 
+	if wrapUp != "" {
+		messages = withWrapUpPrompt(messages, wrapUp)
+	}
+
 	// 通过 Middleware Stack 调用模型 (Phase 6C)
 	var assistantMessage types.Message
 	var modelErr error
@@ -110,17 +235,14 @@ func (a *Agent) runModelStep(ctx context.Context) error {
 		req := &middleware.ModelRequest{
 			Messages:     messages,
 			SystemPrompt: currentSystemPrompt,
-			Tools:        nil, // TODO: 转换 toolMap 为 []tools.Tool
+			Tools:        toolList,
 			Metadata:     make(map[string]interface{}),
 		}
 
 		// 定义 finalHandler: 实际调用 Provider
 		finalHandler := func(ctx context.Context, req *middleware.ModelRequest) (*middleware.ModelResponse, error) {
-			streamOpts := &provider.StreamOptions{
-				Tools:     toolSchemas,
-				MaxTokens: 4096,
-				System:    req.SystemPrompt,
-			}
+			// 中间件可能调整了工具列表,以请求中的工具为准
+			streamOpts := a.streamOptions(req.SystemPrompt, toolSchemasOf(req.Tools))
 
 			stream, err := a.provider.Stream(ctx, req.Messages, streamOpts)
 			if err != nil {
@@ -148,11 +270,7 @@ func (a *Agent) runModelStep(ctx context.Context) error {
 		}
 	} else {
 		// 没有 middleware, 直接调用
-		streamOpts := &provider.StreamOptions{
-			Tools:     toolSchemas,
-			MaxTokens: 4096,
-			System:    currentSystemPrompt,
-		}
+		streamOpts := a.streamOptions(currentSystemPrompt, toolSchemas)
 
 		stream, err := a.provider.Stream(ctx, messages, streamOpts)
 		if err != nil {
@@ -165,19 +283,37 @@ func (a *Agent) runModelStep(ctx context.Context) error {
 		}
 	}
 
+	// 被中断时保留已输出的文本,丢弃未完成的工具调用
+	if ctx.Err() != nil {
+		a.savePartialResponse(ctx, assistantMessage)
+		return nil, ctx.Err()
+	}
+
 	// 处理模型调用错误
 	if modelErr != nil {
-		return fmt.Errorf("model call: %w", modelErr)
+		return nil, fmt.Errorf("model call: %w", modelErr)
+	}
+
+	// 收尾回复不再执行工具,去掉工具调用以保证历史中不会有未完成的调用
+	if wrapUp != "" {
+		content := make([]types.ContentBlock, 0, len(assistantMessage.Content))
+		for _, block := range assistantMessage.Content {
+			if _, ok := block.(*types.ToolUseBlock); !ok {
+				content = append(content, block)
+			}
+		}
+		assistantMessage.Content = content
 	}
 
 	// 保存助手消息
 	a.mu.Lock()
 	a.messages = append(a.messages, assistantMessage)
+	messages = a.copyMessages()
 	a.mu.Unlock()
 
 	// 持久化
-	if err := a.deps.Store.SaveMessages(ctx, a.id, a.messages); err != nil {
-		return fmt.Errorf("save messages: %w", err)
+	if err := a.deps.Store.SaveMessages(ctx, a.id, messages); err != nil {
+		return nil, fmt.Errorf("save messages: %w", err)
 	}
 
 	// 检查是否有工具调用
@@ -194,21 +330,68 @@ func (a *Agent) runModelStep(ctx context.Context) error {
 			log.Printf("[runModelStep] Agent %s: Tool use - Name: %s, ID: %s, Input: %v", a.id, tu.Name, tu.ID, tu.Input)
 		}
 		a.setBreakpoint(types.BreakpointToolPending)
-		return a.executeTools(ctx, toolUses)
 	} else {
 		log.Printf("[runModelStep] Agent %s: No tool uses found, only text response", a.id)
 	}
 
-	return nil
+	return toolUses, nil
+}
+
+// toolSchemasOf 把工具转换为模型请求中的工具 Schema
+func toolSchemasOf(toolList []tools.Tool) []provider.ToolSchema {
+	toolSchemas := make([]provider.ToolSchema, 0, len(toolList))
+	for _, tool := range toolList {
+		toolSchemas = append(toolSchemas, provider.ToolSchema{
+			Name:        tool.Name(),
+			Description: tool.Description(),
+			InputSchema: tool.InputSchema(),
+		})
+	}
+	return toolSchemas
 }
 
-// executeTools 执行工具
-func (a *Agent) executeTools(ctx context.Context, toolUses []*types.ToolUseBlock) error {
-	toolResults := make([]types.ContentBlock, 0, len(toolUses))
+// executeTools 执行工具,超出工具调用预算的调用不执行,直接返回错误结果
+// 互不冲突的调用并发执行(不超过 MaxToolConcurrency),工具结果保持模型给出的顺序
+func (a *Agent) executeTools(ctx context.Context, toolUses []*types.ToolUseBlock, budget *runBudget) error {
+	toolResults := make([]types.ContentBlock, len(toolUses))
+	allowed := budget.reserveToolCalls(len(toolUses))
 
-	for _, tu := range toolUses {
-		result := a.executeSingleTool(ctx, tu)
-		toolResults = append(toolResults, result)
+	// 权限检查: 被拒绝的调用不执行,需要审批时在这里暂停
+	for i, result := range a.authorizeToolCalls(ctx, toolUses[:allowed]) {
+		toolResults[i] = result
+	}
+
+	// 按资源键划分批次: 批次之间依次执行,批次内并发执行
+	keys := make([]string, allowed)
+	for i, tu := range toolUses[:allowed] {
+		if tool, ok := a.lookupTool(tu.Name); ok {
+			keys[i] = tools.ConcurrencyKeyOf(tool, tu.Input)
+		}
+	}
+	slots := make(chan struct{}, a.executor.MaxConcurrency())
+	for _, batch := range tools.PlanBatches(keys) {
+		var wg sync.WaitGroup
+		for _, i := range batch {
+			if toolResults[i] != nil {
+				continue
+			}
+			if ctx.Err() != nil {
+				toolResults[i] = a.failToolCall(toolUses[i], types.ToolCallStateFailed, "interrupted before execution")
+				continue
+			}
+			slots <- struct{}{}
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				defer func() { <-slots }()
+				toolResults[i] = a.executeSingleTool(ctx, toolUses[i])
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	for i := allowed; i < len(toolUses); i++ {
+		toolResults[i] = a.rejectToolCall(toolUses[i], "tool call budget exhausted for this run")
 	}
 
 	// 保存工具结果
@@ -218,65 +401,72 @@ func (a *Agent) executeTools(ctx context.Context, toolUses []*types.ToolUseBlock
 		Content: toolResults,
 	})
 	a.stepCount++
+	messages := a.copyMessages()
 	a.mu.Unlock()
 
-	// 持久化
-	if err := a.deps.Store.SaveMessages(ctx, a.id, a.messages); err != nil {
+	// 持久化(中断后也要保存,保证每个工具调用都有结果)
+	saveCtx := context.WithoutCancel(ctx)
+	if err := a.deps.Store.SaveMessages(saveCtx, a.id, messages); err != nil {
 		return fmt.Errorf("save messages: %w", err)
 	}
 
+	a.markSafeForkPoint()
+
 	// 持久化工具记录
+	return a.saveToolRecords(saveCtx)
+}
+
+// saveToolRecords 持久化工具调用记录
+func (a *Agent) saveToolRecords(ctx context.Context) error {
+	a.mu.RLock()
 	records := make([]types.ToolCallRecord, 0, len(a.toolRecords))
 	for _, record := range a.toolRecords {
 		records = append(records, *record)
 	}
+	a.mu.RUnlock()
+
 	if err := a.deps.Store.SaveToolCallRecords(ctx, a.id, records); err != nil {
 		return fmt.Errorf("save tool records: %w", err)
 	}
+	return nil
+}
+
+// ensureToolRecord 返回工具调用记录,不存在时创建
+func (a *Agent) ensureToolRecord(tu *types.ToolUseBlock) *types.ToolCallRecord {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-	// 继续处理
-	return a.runModelStep(ctx)
+	record, ok := a.toolRecords[tu.ID]
+	if !ok {
+		record = tools.NewToolCallRecord(tu.ID, tu.Name, tu.Input).Build()
+		a.toolRecords[tu.ID] = record
+	}
+	return record
 }
 
 // executeSingleTool 执行单个工具
 func (a *Agent) executeSingleTool(ctx context.Context, tu *types.ToolUseBlock) types.ContentBlock {
-	// 创建工具调用记录
-	record := tools.NewToolCallRecord(tu.ID, tu.Name, tu.Input).Build()
-	a.mu.Lock()
-	a.toolRecords[tu.ID] = record
-	a.mu.Unlock()
+	// 创建工具调用记录(权限检查时可能已创建)
+	record := a.ensureToolRecord(tu)
+	a.mu.RLock()
+	startCall := types.ToolCallSnapshot{
+		ID:        record.ID,
+		Name:      record.Name,
+		State:     record.State,
+		Arguments: tu.Input,
+	}
+	a.mu.RUnlock()
 
 	// 发送工具开始事件
-	a.eventBus.EmitProgress(&types.ProgressToolStartEvent{
-		Call: types.ToolCallSnapshot{
-			ID:    record.ID,
-			Name:  record.Name,
-			State: record.State,
-		},
+	a.emitProgress(&types.ProgressToolStartEvent{
+		Call: startCall,
 	})
 
 	// 获取工具
-	tool, ok := a.toolMap[tu.Name]
+	tool, ok := a.lookupTool(tu.Name)
 	if !ok {
 		// 工具未找到
-		errorMsg := fmt.Sprintf("tool not found: %s", tu.Name)
-		a.updateToolRecord(tu.ID, types.ToolCallStateFailed, errorMsg)
-		a.eventBus.EmitProgress(&types.ProgressToolErrorEvent{
-			Call: types.ToolCallSnapshot{
-				ID:    tu.ID,
-				Name:  tu.Name,
-				State: types.ToolCallStateFailed,
-			},
-			Error: errorMsg,
-		})
-		return &types.ToolResultBlock{
-			ToolUseID: tu.ID,
-			Content: map[string]interface{}{
-				"ok":    false,
-				"error": errorMsg,
-			},
-			IsError: true,
-		}
+		return a.failToolCall(tu, types.ToolCallStateFailed, fmt.Sprintf("tool not found: %s", tu.Name))
 	}
 
 	// 设置断点
@@ -288,6 +478,12 @@ func (a *Agent) executeSingleTool(ctx context.Context, tu *types.ToolUseBlock) t
 
 	startTime := time.Now()
 
+	// 追踪工具读写的文件
+	filePath, fileOp := a.fileAccessOf(tu.Name, tu.Input)
+	if fileOp != "" {
+		a.beginFileTool(filePath, fileOp)
+	}
+
 	toolCtx := &tools.ToolContext{
 		AgentID: a.id,
 		Sandbox: a.sandbox,
@@ -313,7 +509,6 @@ func (a *Agent) executeSingleTool(ctx context.Context, tu *types.ToolUseBlock) t
 				Tool:    req.Tool,
 				Input:   req.ToolInput,
 				Context: req.Context,
-				Timeout: 60 * time.Second,
 			})
 
 			return &middleware.ToolCallResponse{
@@ -339,11 +534,13 @@ func (a *Agent) executeSingleTool(ctx context.Context, tu *types.ToolUseBlock) t
 			Tool:    tool,
 			Input:   tu.Input,
 			Context: toolCtx,
-			Timeout: 60 * time.Second,
 		})
 	}
 
 	endTime := time.Now()
+	if fileOp != "" {
+		a.endFileTool(filePath, fileOp, toolSucceeded(execResult))
+	}
 
 	// 更新记录
 	if execResult.Success {
@@ -364,12 +561,18 @@ func (a *Agent) executeSingleTool(ctx context.Context, tu *types.ToolUseBlock) t
 	}
 
 	// 发送工具结束事件
-	a.eventBus.EmitProgress(&types.ProgressToolEndEvent{
-		Call: types.ToolCallSnapshot{
-			ID:    tu.ID,
-			Name:  tu.Name,
-			State: a.toolRecords[tu.ID].State,
-		},
+	a.mu.RLock()
+	endRecord := a.toolRecords[tu.ID]
+	endCall := types.ToolCallSnapshot{
+		ID:     tu.ID,
+		Name:   tu.Name,
+		State:  endRecord.State,
+		Result: endRecord.Result,
+		Error:  endRecord.Error,
+	}
+	a.mu.RUnlock()
+	a.emitProgress(&types.ProgressToolEndEvent{
+		Call: endCall,
 	})
 
 	// 设置断点
@@ -379,7 +582,7 @@ func (a *Agent) executeSingleTool(ctx context.Context, tu *types.ToolUseBlock) t
 	if execResult.Success {
 		return &types.ToolResultBlock{
 			ToolUseID: tu.ID,
-			Content:   execResult.Output,
+			Content:   a.offloadToolResult(ctx, tu, execResult.Output),
 			IsError:   false,
 		}
 	} else {
@@ -398,6 +601,62 @@ func (a *Agent) executeSingleTool(ctx context.Context, tu *types.ToolUseBlock) t
 	}
 }
 
+// toolSucceeded 工具是否执行成功;工具返回 {"ok": false} 时视为失败
+func toolSucceeded(result *tools.ExecuteResult) bool {
+	if !result.Success {
+		return false
+	}
+	if output, ok := result.Output.(map[string]interface{}); ok && output["ok"] == false {
+		return false
+	}
+	return true
+}
+
+// rejectToolCall 不执行工具,记录调用并直接返回错误结果
+func (a *Agent) rejectToolCall(tu *types.ToolUseBlock, errorMsg string) types.ContentBlock {
+	a.ensureToolRecord(tu)
+	return a.failToolCall(tu, types.ToolCallStateFailed, errorMsg)
+}
+
+// failToolCall 把工具调用标记为失败(或被拒绝)并返回错误结果
+func (a *Agent) failToolCall(tu *types.ToolUseBlock, state types.ToolCallState, errorMsg string) types.ContentBlock {
+	a.updateToolRecord(tu.ID, state, errorMsg)
+	a.emitProgress(&types.ProgressToolErrorEvent{
+		Call: types.ToolCallSnapshot{
+			ID:    tu.ID,
+			Name:  tu.Name,
+			State: state,
+		},
+		Error: errorMsg,
+	})
+	return &types.ToolResultBlock{
+		ToolUseID: tu.ID,
+		Content: map[string]interface{}{
+			"ok":    false,
+			"error": errorMsg,
+		},
+		IsError: true,
+	}
+}
+
+// withWrapUpPrompt 返回在最后一条用户消息后附加收尾指令的消息副本
+func withWrapUpPrompt(messages []types.Message, prompt string) []types.Message {
+	result := make([]types.Message, len(messages), len(messages)+1)
+	copy(result, messages)
+
+	text := &types.TextBlock{Text: prompt}
+	if n := len(result); n > 0 && result[n-1].Role == types.MessageRoleUser {
+		last := result[n-1]
+		last.Content = append(append([]types.ContentBlock{}, last.Content...), text)
+		result[n-1] = last
+		return result
+	}
+	return append(result, types.Message{
+		Role:    types.MessageRoleUser,
+		Content: []types.ContentBlock{text},
+	})
+}
+
 // setBreakpoint 设置断点
 func (a *Agent) setBreakpoint(state types.BreakpointState) {
 	a.mu.Lock()
@@ -405,7 +664,7 @@ func (a *Agent) setBreakpoint(state types.BreakpointState) {
 	a.breakpoint = state
 	a.mu.Unlock()
 
-	a.eventBus.EmitMonitor(&types.MonitorBreakpointChangedEvent{
+	a.emitMonitor(&types.MonitorBreakpointChangedEvent{
 		Previous:  previous,
 		Current:   state,
 		Timestamp: time.Now(),
@@ -437,12 +696,32 @@ func (a *Agent) updateToolRecord(id string, state types.ToolCallState, errorMsg
 	})
 }
 
+// streamOptions 构建模型调用选项
+func (a *Agent) streamOptions(system string, toolSchemas []provider.ToolSchema) *provider.StreamOptions {
+	// 结构化输出格式只属于指定它的那一轮执行
+	var responseFormat *provider.ResponseFormat
+	a.mu.RLock()
+	if a.stream != nil {
+		responseFormat = a.stream.responseFormat
+	}
+	a.mu.RUnlock()
+
+	return &provider.StreamOptions{
+		Tools:          toolSchemas,
+		MaxTokens:      4096,
+		System:         system,
+		PromptCache:    a.promptCacheConfig(),
+		ResponseFormat: responseFormat,
+	}
+}
+
 // handleStreamResponse 处理流式响应(Phase 6C - 提取为独立方法以支持Middleware)
 func (a *Agent) handleStreamResponse(ctx context.Context, stream <-chan provider.StreamChunk) (types.Message, error) {
 	assistantContent := make([]types.ContentBlock, 0)
 	currentBlockIndex := -1
 	textBuffers := make(map[int]string)
 	inputJSONBuffers := make(map[int]string)
+	exposeThinking := a.exposeThinking()
 
 	for chunk := range stream {
 		switch chunk.Type {
@@ -452,7 +731,7 @@ func (a *Agent) handleStreamResponse(ctx context.Context, stream <-chan provider
 				blockType, _ := delta["type"].(string)
 				if blockType == "text" {
 					// 发送文本开始事件
-					a.eventBus.EmitProgress(&types.ProgressTextChunkStartEvent{
+					a.emitProgress(&types.ProgressTextChunkStartEvent{
 						Step: a.stepCount,
 					})
 					// 初始化文本块
@@ -486,6 +765,22 @@ func (a *Agent) handleStreamResponse(ctx context.Context, stream <-chan provider
 						Name:  toolName,
 						Input: make(map[string]interface{}),
 					}
+				} else if blockType == "thinking" || blockType == "redacted_thinking" {
+					// 初始化思考块(需要保留签名以便工具调用续写时回传)
+					for len(assistantContent) <= currentBlockIndex {
+						assistantContent = append(assistantContent, nil)
+					}
+					block := &types.ThinkingBlock{}
+					block.Thinking, _ = delta["thinking"].(string)
+					block.Signature, _ = delta["signature"].(string)
+					block.Data, _ = delta["data"].(string)
+					assistantContent[currentBlockIndex] = block
+
+					if exposeThinking && !block.Redacted() {
+						a.emitProgress(&types.ProgressThinkChunkStartEvent{
+							Step: a.stepCount,
+						})
+					}
 				} else {
 					log.Printf("[handleStreamResponse] Unknown block type: %s", blockType)
 				}
@@ -513,10 +808,28 @@ func (a *Agent) handleStreamResponse(ctx context.Context, stream <-chan provider
 						}
 					}
 					// 发送文本增量事件
-					a.eventBus.EmitProgress(&types.ProgressTextChunkEvent{
+					a.emitProgress(&types.ProgressTextChunkEvent{
 						Step:  a.stepCount,
 						Delta: text,
 					})
+				} else if deltaType == "thinking_delta" || deltaType == "signature_delta" {
+					if currentBlockIndex >= 0 && currentBlockIndex < len(assistantContent) {
+						if block, ok := assistantContent[currentBlockIndex].(*types.ThinkingBlock); ok {
+							if deltaType == "signature_delta" {
+								signature, _ := delta["signature"].(string)
+								block.Signature += signature
+							} else {
+								thinking, _ := delta["thinking"].(string)
+								block.Thinking += thinking
+								if exposeThinking {
+									a.emitProgress(&types.ProgressThinkChunkEvent{
+										Step:  a.stepCount,
+										Delta: thinking,
+									})
+								}
+							}
+						}
+					}
 				} else if deltaType == "input_json_delta" {
 					partialJSON, _ := delta["partial_json"].(string)
 					if currentBlockIndex >= 0 {
@@ -543,10 +856,16 @@ func (a *Agent) handleStreamResponse(ctx context.Context, stream <-chan provider
 		case "content_block_stop":
 			if currentBlockIndex >= 0 && currentBlockIndex < len(assistantContent) {
 				if block, ok := assistantContent[currentBlockIndex].(*types.TextBlock); ok {
-					a.eventBus.EmitProgress(&types.ProgressTextChunkEndEvent{
+					a.emitProgress(&types.ProgressTextChunkEndEvent{
 						Step: a.stepCount,
 						Text: block.Text,
 					})
+				} else if block, ok := assistantContent[currentBlockIndex].(*types.ThinkingBlock); ok {
+					if exposeThinking && !block.Redacted() {
+						a.emitProgress(&types.ProgressThinkChunkEndEvent{
+							Step: a.stepCount,
+						})
+					}
 				} else if block, ok := assistantContent[currentBlockIndex].(*types.ToolUseBlock); ok {
 					if jsonStr, exists := inputJSONBuffers[currentBlockIndex]; exists && jsonStr != "" {
 						var input map[string]interface{}
@@ -561,11 +880,33 @@ func (a *Agent) handleStreamResponse(ctx context.Context, stream <-chan provider
 
 		case "message_delta":
 			if chunk.Usage != nil {
-				a.eventBus.EmitMonitor(&types.MonitorTokenUsageEvent{
-					InputTokens:  chunk.Usage.InputTokens,
-					OutputTokens: chunk.Usage.OutputTokens,
-					TotalTokens:  chunk.Usage.InputTokens + chunk.Usage.OutputTokens,
-				})
+				usageEvent := &types.MonitorTokenUsageEvent{
+					InputTokens:              chunk.Usage.InputTokens,
+					OutputTokens:             chunk.Usage.OutputTokens,
+					CacheCreationInputTokens: chunk.Usage.CacheCreationInputTokens,
+					CacheReadInputTokens:     chunk.Usage.CacheReadInputTokens,
+					TotalTokens:              chunk.Usage.TotalTokens(),
+				}
+				// 记录实际处理请求的后端(故障转移时可能不是主模型)
+				if cfg := a.provider.Config(); cfg != nil {
+					usageEvent.Provider = cfg.Provider
+					usageEvent.Model = cfg.Model
+				}
+				if p, ok := chunk.Metadata[provider.MetadataProvider].(string); ok {
+					usageEvent.Provider = p
+				}
+				if m, ok := chunk.Metadata[provider.MetadataModel].(string); ok {
+					usageEvent.Model = m
+				}
+				usageEvent.Backend, _ = chunk.Metadata[provider.MetadataBackend].(string)
+				a.emitMonitor(usageEvent)
+
+				a.mu.RLock()
+				budget := a.budget
+				a.mu.RUnlock()
+				if budget != nil {
+					budget.addUsage(int(usageEvent.InputTokens+usageEvent.CacheCreationInputTokens+usageEvent.CacheReadInputTokens), int(usageEvent.OutputTokens))
+				}
 			}
 		}
 	}
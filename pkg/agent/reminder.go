@@ -0,0 +1,184 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+const (
+	sendKindUser     = "user"
+	sendKindReminder = "reminder"
+
+	// todoToolName 任务列表工具(由 todolist 中间件提供)
+	todoToolName = "write_todos"
+
+	reminderStandardEnding = "This is a system reminder. DO NOT respond to this message directly and DO NOT mention it to the user."
+
+	todoStartReminder    = "If the task ahead has multiple steps, use the write_todos tool to plan it and keep the todo list updated as you make progress."
+	todoIntervalReminder = "The todo list has not been updated in the last %d steps. If you are working on a multi-step task, use the write_todos tool to mark finished items and keep the list current."
+)
+
+// pendingReminder 等待发送给模型的系统提醒
+type pendingReminder struct {
+	content  string
+	priority string
+	category string
+	ending   bool
+}
+
+// SendWithOptions 按 opts 发送消息
+// Kind 为 "reminder" 时作为系统提醒发送(见 Remind),为空或 "user" 时等同于 Send
+func (a *Agent) SendWithOptions(ctx context.Context, text string, opts *types.SendOptions) error {
+	if opts == nil || opts.Kind == "" || opts.Kind == sendKindUser {
+		return a.Send(ctx, text)
+	}
+	if opts.Kind != sendKindReminder {
+		return fmt.Errorf("unknown send kind: %s", opts.Kind)
+	}
+	return a.Remind(text, opts.Reminder)
+}
+
+// Remind 向模型发送系统提醒
+// 提醒包装在 <system-reminder> 中,在下一次模型调用前合并到最后一条用户消息,不形成新的用户轮次;
+// Agent 空闲时不会开始新的执行,随下一轮一起发送。高优先级的提醒排在前面
+func (a *Agent) Remind(text string, opts *types.ReminderOptions) error {
+	if text == "" {
+		return fmt.Errorf("reminder cannot be empty")
+	}
+
+	reminder := pendingReminder{content: text, priority: "medium", category: "general", ending: true}
+	if opts != nil {
+		if opts.Priority != "" {
+			reminder.priority = opts.Priority
+		}
+		if opts.Category != "" {
+			reminder.category = opts.Category
+		}
+		reminder.ending = !opts.SkipStandardEnding
+	}
+	if _, ok := reminderPriorities[reminder.priority]; !ok {
+		return fmt.Errorf("unknown reminder priority: %s", reminder.priority)
+	}
+
+	a.mu.Lock()
+	a.reminders = append(a.reminders, reminder)
+	a.mu.Unlock()
+	return nil
+}
+
+// reminderPriorities 提醒优先级,数值小的先发送
+var reminderPriorities = map[string]int{"high": 0, "medium": 1, "low": 2}
+
+// injectReminders 把待发送的提醒加入对话,并发出 MonitorReminderSentEvent
+func (a *Agent) injectReminders(ctx context.Context) {
+	a.mu.Lock()
+	pending := a.reminders
+	a.reminders = nil
+	if len(pending) == 0 {
+		a.mu.Unlock()
+		return
+	}
+
+	sort.SliceStable(pending, func(i, j int) bool {
+		return reminderPriorities[pending[i].priority] < reminderPriorities[pending[j].priority]
+	})
+	blocks := make([]types.ContentBlock, 0, len(pending))
+	for _, reminder := range pending {
+		blocks = append(blocks, &types.TextBlock{Text: reminder.wrap()})
+	}
+	a.mergeUserBlocks(blocks)
+	messages := a.copyMessages()
+	a.mu.Unlock()
+
+	if err := a.deps.Store.SaveMessages(ctx, a.id, messages); err != nil {
+		log.Printf("[Agent] Failed to save reminders: %v", err)
+	}
+	for _, reminder := range pending {
+		a.emitMonitor(&types.MonitorReminderSentEvent{
+			Category: reminder.category,
+			Content:  reminder.content,
+		})
+	}
+}
+
+// wrap 返回发送给模型的提醒文本
+func (r pendingReminder) wrap() string {
+	var sb strings.Builder
+	sb.WriteString("<system-reminder>\n")
+	if r.priority == "high" {
+		sb.WriteString("IMPORTANT: ")
+	}
+	sb.WriteString(r.content)
+	if r.ending {
+		sb.WriteString("\n\n")
+		sb.WriteString(reminderStandardEnding)
+	}
+	sb.WriteString("\n</system-reminder>")
+	return sb.String()
+}
+
+// todoConfig 返回生效的任务列表配置(覆盖配置优先于模板配置)
+// 未启用或 Agent 没有任务列表工具时返回 nil
+func (a *Agent) todoConfig() *types.TodoConfig {
+	var config *types.TodoConfig
+	if a.config.Overrides != nil && a.config.Overrides.Todo != nil {
+		config = a.config.Overrides.Todo
+	} else if a.template.Runtime != nil {
+		config = a.template.Runtime.Todo
+	}
+	if config == nil || !config.Enabled {
+		return nil
+	}
+	if _, ok := a.lookupTool(todoToolName); !ok {
+		return nil
+	}
+	return config
+}
+
+// remindTodoOnStart 新对话开始时提醒模型使用任务列表(TodoConfig.ReminderOnStart)
+// 恢复或分叉的 Agent 已有对话历史,不再提醒
+func (a *Agent) remindTodoOnStart() {
+	a.mu.RLock()
+	started := len(a.messages) > 0
+	a.mu.RUnlock()
+	if started {
+		return
+	}
+	if config := a.todoConfig(); config != nil && config.ReminderOnStart {
+		a.Remind(todoStartReminder, &types.ReminderOptions{Category: "todo", Priority: "low"})
+	}
+}
+
+// trackTodoSteps 每次模型调用后计数,任务列表连续 RemindIntervalSteps 步没有更新时提醒模型
+func (a *Agent) trackTodoSteps(toolUses []*types.ToolUseBlock) {
+	config := a.todoConfig()
+	if config == nil || config.RemindIntervalSteps <= 0 {
+		return
+	}
+
+	for _, tu := range toolUses {
+		if tu.Name == todoToolName {
+			a.mu.Lock()
+			a.todoSteps = 0
+			a.mu.Unlock()
+			return
+		}
+	}
+
+	a.mu.Lock()
+	a.todoSteps++
+	due := a.todoSteps >= config.RemindIntervalSteps
+	if due {
+		a.todoSteps = 0
+	}
+	a.mu.Unlock()
+
+	if due {
+		a.Remind(fmt.Sprintf(todoIntervalReminder, config.RemindIntervalSteps), &types.ReminderOptions{Category: "todo"})
+	}
+}
@@ -0,0 +1,119 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/provider"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// reminderTexts 返回消息中的系统提醒文本
+func reminderTexts(message types.Message) []string {
+	var texts []string
+	for _, block := range message.Content {
+		if tb, ok := block.(*types.TextBlock); ok && strings.HasPrefix(tb.Text, "<system-reminder>") {
+			texts = append(texts, tb.Text)
+		}
+	}
+	return texts
+}
+
+func TestAgentRemind_MergedIntoUserMessage(t *testing.T) {
+	mp := provider.NewMockProvider(provider.MockTextStep("ok"))
+	ag := newMockAgent(t, mp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := ag.SendWithOptions(ctx, "disk is almost full", &types.SendOptions{
+		Kind:     "reminder",
+		Reminder: &types.ReminderOptions{Category: "performance", Priority: "low"},
+	}); err != nil {
+		t.Fatalf("SendWithOptions failed: %v", err)
+	}
+	if err := ag.Remind("never touch secrets/", &types.ReminderOptions{Category: "security", Priority: "high", SkipStandardEnding: true}); err != nil {
+		t.Fatalf("Remind failed: %v", err)
+	}
+	if err := ag.SendWithOptions(ctx, "x", &types.SendOptions{Kind: "note"}); err == nil {
+		t.Errorf("Expected error for unknown send kind")
+	}
+
+	if _, err := ag.Chat(ctx, "hi"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	// 提醒合并到用户消息中,不形成新的用户轮次;高优先级在前
+	req, _ := mp.Request(0)
+	if len(req.Messages) != 1 {
+		t.Fatalf("Expected a single user message, got %d", len(req.Messages))
+	}
+	reminders := reminderTexts(req.Messages[0])
+	if len(reminders) != 2 {
+		t.Fatalf("Expected 2 reminders, got %v", reminders)
+	}
+	if !strings.Contains(reminders[0], "IMPORTANT: never touch secrets/") || strings.Contains(reminders[0], reminderStandardEnding) {
+		t.Errorf("Unexpected high priority reminder: %q", reminders[0])
+	}
+	if !strings.Contains(reminders[1], "disk is almost full") || !strings.Contains(reminders[1], reminderStandardEnding) {
+		t.Errorf("Unexpected low priority reminder: %q", reminders[1])
+	}
+
+	var categories []string
+	for _, envelope := range ag.eventBus.GetTimeline() {
+		if e, ok := envelope.Event.(*types.MonitorReminderSentEvent); ok {
+			categories = append(categories, e.Category)
+		}
+	}
+	if len(categories) != 2 || categories[0] != "security" || categories[1] != "performance" {
+		t.Errorf("Expected reminder events [security performance], got %v", categories)
+	}
+}
+
+func TestAgentTodoReminders(t *testing.T) {
+	readStep := func(id string) provider.MockStep {
+		return provider.MockToolStep(provider.MockToolCall{ID: id, Name: "fs_read", PartialJSON: []string{`{"path":"a.txt"}`}})
+	}
+	mp := provider.NewMockProvider(readStep("call_1"), readStep("call_2"), provider.MockTextStep("done"))
+	ag := newMockAgent(t, mp, func(config *types.AgentConfig) {
+		config.Middlewares = []string{"todolist"}
+		config.Overrides = &types.AgentConfigOverrides{
+			Todo: &types.TodoConfig{Enabled: true, ReminderOnStart: true, RemindIntervalSteps: 2},
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := ag.Chat(ctx, "refactor the module"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	// 启动提醒随第一次模型调用发出
+	first, _ := mp.Request(0)
+	if reminders := reminderTexts(first.Messages[0]); len(reminders) != 1 || !strings.Contains(reminders[0], "write_todos") {
+		t.Errorf("Expected todo reminder on start, got %v", reminders)
+	}
+
+	// 连续两步没有更新任务列表,第三次调用前提醒
+	second, _ := mp.Request(1)
+	if reminders := reminderTexts(second.Messages[len(second.Messages)-1]); len(reminders) != 0 {
+		t.Errorf("Unexpected reminder before the interval, got %v", reminders)
+	}
+	third, _ := mp.Request(2)
+	if reminders := reminderTexts(third.Messages[len(third.Messages)-1]); len(reminders) != 1 || !strings.Contains(reminders[0], "last 2 steps") {
+		t.Errorf("Expected todo interval reminder, got %v", reminders)
+	}
+
+	// 恢复已有对话的 Agent 不再发出启动提醒
+	resumed, err := Resume(ctx, ag.Config(), ag.deps, nil)
+	if err != nil {
+		t.Fatalf("Failed to resume agent: %v", err)
+	}
+	defer resumed.Close()
+	if len(resumed.reminders) != 0 {
+		t.Errorf("Expected no start reminder on resume, got %+v", resumed.reminders)
+	}
+}
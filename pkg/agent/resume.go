@@ -0,0 +1,165 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/tools"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// sealedToolCallError 封口工具调用时写入的错误结果
+const sealedToolCallError = "tool call was interrupted because the agent process stopped before it finished; the outcome is unknown, check the current state before retrying"
+
+// Resume 从 Store 恢复 Agent
+// crash 策略(默认)会封口上次进程中断时没有结果的工具调用;AutoRun 时继续执行未完成的一轮
+func Resume(ctx context.Context, config *types.AgentConfig, deps *Dependencies, opts *types.ResumeOptions) (*Agent, error) {
+	if opts == nil {
+		opts = &types.ResumeOptions{}
+	}
+	if opts.Overrides != nil {
+		overrides := *opts.Overrides
+		overrides.AgentID = config.AgentID
+		config = &overrides
+	}
+
+	strategy := opts.Strategy
+	if strategy == "" {
+		strategy = types.ResumeStrategyCrash
+	}
+
+	ag, err := Create(ctx, config, deps)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := []types.ToolCallSnapshot{}
+	if strategy == types.ResumeStrategyCrash {
+		sealed, err = ag.sealInterruptedToolCalls(ctx)
+		if err != nil {
+			ag.Close()
+			return nil, fmt.Errorf("seal tool calls: %w", err)
+		}
+	}
+
+	ag.emitMonitor(&types.MonitorAgentResumedEvent{
+		Strategy: string(strategy),
+		Sealed:   sealed,
+	})
+	if len(sealed) > 0 {
+		log.Printf("[Agent Resume] Agent %s: sealed %d interrupted tool calls", ag.id, len(sealed))
+	}
+
+	// 与 Send 一样,恢复的执行不随调用方的 ctx 取消,用 Interrupt 取消
+	if opts.AutoRun && ag.hasPendingTurn() {
+		go ag.processMessages(context.WithoutCancel(ctx))
+	}
+
+	return ag, nil
+}
+
+// sealInterruptedToolCalls 为没有结果的工具调用补上错误结果,并把记录标记为 SEALED
+// 结果插入在对应的助手消息之后(已有后续用户消息时合并到该消息开头)
+func (a *Agent) sealInterruptedToolCalls(ctx context.Context) ([]types.ToolCallSnapshot, error) {
+	a.mu.Lock()
+
+	answered := make(map[string]bool)
+	for _, msg := range a.messages {
+		for _, block := range msg.Content {
+			if tr, ok := block.(*types.ToolResultBlock); ok {
+				answered[tr.ToolUseID] = true
+			}
+		}
+	}
+
+	sealed := []types.ToolCallSnapshot{}
+	messages := make([]types.Message, 0, len(a.messages)+1)
+	var pending []types.ContentBlock
+
+	for _, msg := range a.messages {
+		if pending != nil {
+			if msg.Role == types.MessageRoleUser {
+				msg.Content = append(pending, msg.Content...)
+			} else {
+				messages = append(messages, types.Message{Role: types.MessageRoleUser, Content: pending})
+			}
+			pending = nil
+		}
+		messages = append(messages, msg)
+
+		if msg.Role != types.MessageRoleAssistant {
+			continue
+		}
+		for _, block := range msg.Content {
+			tu, ok := block.(*types.ToolUseBlock)
+			if !ok || answered[tu.ID] {
+				continue
+			}
+			pending = append(pending, &types.ToolResultBlock{
+				ToolUseID: tu.ID,
+				Content: map[string]interface{}{
+					"ok":    false,
+					"error": sealedToolCallError,
+				},
+				IsError: true,
+			})
+			a.sealToolRecord(tu)
+			sealed = append(sealed, types.ToolCallSnapshot{
+				ID:        tu.ID,
+				Name:      tu.Name,
+				State:     types.ToolCallStateSealed,
+				Arguments: tu.Input,
+				Error:     sealedToolCallError,
+			})
+		}
+	}
+	if pending != nil {
+		messages = append(messages, types.Message{Role: types.MessageRoleUser, Content: pending})
+	}
+
+	if len(sealed) == 0 {
+		a.mu.Unlock()
+		return sealed, nil
+	}
+	a.messages = messages
+	a.mu.Unlock()
+
+	// 持久化
+	if err := a.deps.Store.SaveMessages(ctx, a.id, messages); err != nil {
+		return nil, fmt.Errorf("save messages: %w", err)
+	}
+	if err := a.saveToolRecords(ctx); err != nil {
+		return nil, err
+	}
+	return sealed, nil
+}
+
+// sealToolRecord 把工具调用记录标记为 SEALED,记录不存在时创建;调用方需持有 a.mu
+func (a *Agent) sealToolRecord(tu *types.ToolUseBlock) {
+	record, ok := a.toolRecords[tu.ID]
+	if !ok {
+		record = tools.NewToolCallRecord(tu.ID, tu.Name, tu.Input).Build()
+		a.toolRecords[tu.ID] = record
+	}
+
+	now := time.Now()
+	record.State = types.ToolCallStateSealed
+	record.Error = sealedToolCallError
+	record.IsError = true
+	record.UpdatedAt = now
+	record.AuditTrail = append(record.AuditTrail, types.ToolCallAuditEntry{
+		State:     types.ToolCallStateSealed,
+		Timestamp: now,
+	})
+}
+
+// hasPendingTurn 最后一条消息来自用户(模型尚未回复)时返回 true
+func (a *Agent) hasPendingTurn() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	n := len(a.messages)
+	return n > 0 && a.messages[n-1].Role == types.MessageRoleUser
+}
@@ -0,0 +1,198 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/provider"
+	"github.com/wordflowlab/agentsdk/pkg/tools"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// saveCrashedState 模拟进程在执行 fs_write 时退出后 Store 中留下的状态
+func saveCrashedState(t *testing.T, deps *Dependencies, agentID string) {
+	t.Helper()
+	ctx := context.Background()
+
+	messages := []types.Message{
+		{
+			Role:    types.MessageRoleUser,
+			Content: []types.ContentBlock{&types.TextBlock{Text: "write hello.txt"}},
+		},
+		{
+			Role: types.MessageRoleAssistant,
+			Content: []types.ContentBlock{
+				&types.TextBlock{Text: "Writing the file."},
+				&types.ToolUseBlock{ID: "call_write", Name: "fs_write", Input: map[string]interface{}{"path": "hello.txt", "content": "hi"}},
+			},
+		},
+	}
+	if err := deps.Store.SaveMessages(ctx, agentID, messages); err != nil {
+		t.Fatalf("Failed to save messages: %v", err)
+	}
+
+	record := tools.NewToolCallRecord("call_write", "fs_write", nil).Build()
+	record.State = types.ToolCallStateExecuting
+	if err := deps.Store.SaveToolCallRecords(ctx, agentID, []types.ToolCallRecord{*record}); err != nil {
+		t.Fatalf("Failed to save tool records: %v", err)
+	}
+}
+
+// resumeConfig 恢复测试使用的 Agent 配置
+func resumeConfig(agentID string) *types.AgentConfig {
+	return &types.AgentConfig{
+		AgentID:     agentID,
+		TemplateID:  "test-template",
+		ModelConfig: &types.ModelConfig{Provider: "mock", Model: "mock-model"},
+		Sandbox:     &types.SandboxConfig{Kind: types.SandboxKindMock, WorkDir: "/tmp/test"},
+	}
+}
+
+// resumedEvent 从事件时间线中取出恢复事件
+func resumedEvent(ag *Agent) *types.MonitorAgentResumedEvent {
+	for _, envelope := range ag.eventBus.GetTimeline() {
+		if e, ok := envelope.Event.(*types.MonitorAgentResumedEvent); ok {
+			return e
+		}
+	}
+	return nil
+}
+
+func TestResume_SealsInterruptedToolCalls(t *testing.T) {
+	mp := provider.NewMockProvider(provider.MockTextStep("The write may not have finished, let me check."))
+	deps := setupTestDeps(t)
+	deps.ProviderFactory = mp
+	saveCrashedState(t, deps, "crashed-agent")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ag, err := Resume(ctx, resumeConfig("crashed-agent"), deps, &types.ResumeOptions{AutoRun: true})
+	if err != nil {
+		t.Fatalf("Failed to resume agent: %v", err)
+	}
+	defer ag.Close()
+
+	event := resumedEvent(ag)
+	if event == nil || event.Strategy != "crash" || len(event.Sealed) != 1 || event.Sealed[0].ID != "call_write" {
+		t.Fatalf("Expected resumed event sealing call_write, got %+v", event)
+	}
+	if ag.toolRecords["call_write"].State != types.ToolCallStateSealed {
+		t.Errorf("Expected record state SEALED, got %s", ag.toolRecords["call_write"].State)
+	}
+
+	// AutoRun 继续执行,模型看到封口后的错误结果
+	deadline := time.Now().Add(5 * time.Second)
+	for mp.Remaining() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for auto run")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	waitForState(t, ag, types.AgentStateReady)
+
+	req, _ := mp.Request(0)
+	last := req.Messages[len(req.Messages)-1]
+	tr, ok := last.Content[0].(*types.ToolResultBlock)
+	if last.Role != types.MessageRoleUser || !ok || tr.ToolUseID != "call_write" || !tr.IsError {
+		t.Fatalf("Expected sealed tool result as last message, got %+v", last)
+	}
+
+	// 封口结果已持久化,重新加载后内容块类型不变
+	stored, err := deps.Store.LoadMessages(ctx, "crashed-agent")
+	if err != nil {
+		t.Fatalf("Failed to load messages: %v", err)
+	}
+	if len(stored) != 4 {
+		t.Fatalf("Expected 4 stored messages, got %d", len(stored))
+	}
+	if _, ok := stored[1].Content[1].(*types.ToolUseBlock); !ok {
+		t.Errorf("Expected tool use block after reload, got %T", stored[1].Content[1])
+	}
+	if _, ok := stored[2].Content[0].(*types.ToolResultBlock); !ok {
+		t.Errorf("Expected tool result block after reload, got %T", stored[2].Content[0])
+	}
+}
+
+func TestResume_MergesIntoFollowingUserMessage(t *testing.T) {
+	deps := setupTestDeps(t)
+	deps.ProviderFactory = provider.NewMockProvider()
+	saveCrashedState(t, deps, "crashed-agent")
+
+	// 崩溃后用户又发送了一条消息
+	ctx := context.Background()
+	messages, _ := deps.Store.LoadMessages(ctx, "crashed-agent")
+	messages = append(messages, types.Message{
+		Role:    types.MessageRoleUser,
+		Content: []types.ContentBlock{&types.TextBlock{Text: "are you done?"}},
+	})
+	if err := deps.Store.SaveMessages(ctx, "crashed-agent", messages); err != nil {
+		t.Fatalf("Failed to save messages: %v", err)
+	}
+
+	ag, err := Resume(ctx, resumeConfig("crashed-agent"), deps, nil)
+	if err != nil {
+		t.Fatalf("Failed to resume agent: %v", err)
+	}
+	defer ag.Close()
+
+	if len(ag.messages) != 3 {
+		t.Fatalf("Expected 3 messages after sealing, got %d", len(ag.messages))
+	}
+	content := ag.messages[2].Content
+	if _, ok := content[0].(*types.ToolResultBlock); !ok || len(content) != 2 {
+		t.Errorf("Expected sealed result before the user text, got %+v", content)
+	}
+}
+
+func TestResume_ManualStrategy(t *testing.T) {
+	deps := setupTestDeps(t)
+	deps.ProviderFactory = provider.NewMockProvider()
+	saveCrashedState(t, deps, "crashed-agent")
+
+	ag, err := Resume(context.Background(), resumeConfig("crashed-agent"), deps, &types.ResumeOptions{
+		Strategy: types.ResumeStrategyManual,
+	})
+	if err != nil {
+		t.Fatalf("Failed to resume agent: %v", err)
+	}
+	defer ag.Close()
+
+	event := resumedEvent(ag)
+	if event == nil || event.Strategy != "manual" || len(event.Sealed) != 0 {
+		t.Errorf("Expected manual resumed event without sealed calls, got %+v", event)
+	}
+	if len(ag.messages) != 2 || ag.toolRecords["call_write"].State != types.ToolCallStateExecuting {
+		t.Errorf("Manual strategy should leave the history untouched")
+	}
+}
+
+func TestResume_AutoRunOutlivesCallerContext(t *testing.T) {
+	mp := provider.NewMockProvider(provider.MockStep{Text: "Hello again.", Delay: 50 * time.Millisecond})
+	deps := setupTestDeps(t)
+	deps.ProviderFactory = mp
+	if err := deps.Store.SaveMessages(context.Background(), "pending-agent", []types.Message{{
+		Role:    types.MessageRoleUser,
+		Content: []types.ContentBlock{&types.TextBlock{Text: "hello"}},
+	}}); err != nil {
+		t.Fatalf("Failed to save messages: %v", err)
+	}
+
+	// 调用方(如请求处理函数)返回后取消 ctx,恢复的执行继续完成
+	ctx, cancel := context.WithCancel(context.Background())
+	ag, err := Resume(ctx, resumeConfig("pending-agent"), deps, &types.ResumeOptions{AutoRun: true})
+	if err != nil {
+		t.Fatalf("Failed to resume agent: %v", err)
+	}
+	defer ag.Close()
+	cancel()
+
+	waitForSteps(t, mp)
+	waitForState(t, ag, types.AgentStateReady)
+	ag.mu.RLock()
+	defer ag.mu.RUnlock()
+	if len(ag.messages) != 2 || assistantText(ag.messages[1]) != "Hello again." {
+		t.Errorf("Expected resumed turn to complete, got %d messages", len(ag.messages))
+	}
+}
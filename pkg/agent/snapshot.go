@@ -0,0 +1,245 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// sfpMark 安全分叉点: 事件游标 seq 处对话的前 count 条消息没有未完成的工具调用
+type sfpMark struct {
+	seq   int64
+	count int
+	step  int // 当时的 stepCount
+}
+
+// Snapshot 保存当前对话到最近安全分叉点的快照,label 记录在 Metadata["label"]
+// 执行中调用时,未完成的工具调用及其后的消息不包含在快照中
+func (a *Agent) Snapshot(ctx context.Context, label string) (*types.Snapshot, error) {
+	a.mu.RLock()
+	count := safeForkCount(a.messages)
+	messages := append([]types.Message(nil), a.messages[:count]...)
+	stepCount := a.stepCount
+	a.mu.RUnlock()
+
+	snapshot := types.Snapshot{
+		ID:           "snap_" + uuid.New().String(),
+		Messages:     messages,
+		LastSfpIndex: count - 1,
+		LastBookmark: a.eventBus.GetLastBookmark(),
+		CreatedAt:    time.Now(),
+		Metadata: map[string]interface{}{
+			"label":      label,
+			"step_count": stepCount,
+		},
+	}
+	if err := a.deps.Store.SaveSnapshot(ctx, a.id, snapshot); err != nil {
+		return nil, fmt.Errorf("save snapshot: %w", err)
+	}
+
+	log.Printf("[Agent] Snapshot %s (%q) saved for agent %s with %d messages", snapshot.ID, label, a.id, count)
+	return &snapshot, nil
+}
+
+// Rewind 把对话回退到快照处,快照之后的消息和工具记录被删除
+// 只能在 Agent 空闲时调用
+func (a *Agent) Rewind(ctx context.Context, snapshotID string) error {
+	snapshot, err := a.deps.Store.LoadSnapshot(ctx, a.id, snapshotID)
+	if err != nil {
+		return fmt.Errorf("load snapshot: %w", err)
+	}
+	if snapshot == nil || snapshot.ID == "" {
+		return fmt.Errorf("snapshot not found: %s", snapshotID)
+	}
+	return a.rewindTo(ctx, snapshot.Messages, snapshotStepCount(snapshot))
+}
+
+// RewindToBookmark 把对话回退到书签(如 CompleteResult.Last)时的最近安全分叉点
+// 书签来自事件游标,只在当前进程内有效;跨进程回退使用 Snapshot/Rewind
+func (a *Agent) RewindToBookmark(ctx context.Context, bookmark types.Bookmark) error {
+	a.mu.RLock()
+	count, step := -1, 0
+	for _, mark := range a.sfpMarks {
+		if mark.seq > bookmark.Seq {
+			break
+		}
+		count, step = mark.count, mark.step
+	}
+	if count < 0 || count > len(a.messages) {
+		a.mu.RUnlock()
+		return fmt.Errorf("no safe fork point at or before bookmark %d", bookmark.Seq)
+	}
+	messages := append([]types.Message(nil), a.messages[:count]...)
+	a.mu.RUnlock()
+
+	return a.rewindTo(ctx, messages, step)
+}
+
+// rewindTo 用 messages 替换对话历史,删除不再被引用的工具记录,
+// 并把步数和文件访问记录恢复到 step 步时的状态
+func (a *Agent) rewindTo(ctx context.Context, messages []types.Message, step int) error {
+	a.mu.Lock()
+	if a.state != types.AgentStateReady {
+		state := a.state
+		a.mu.Unlock()
+		return fmt.Errorf("cannot rewind while agent is %s", state)
+	}
+
+	a.messages = messages
+	a.toolRecords = toolRecordsFor(messages, a.toolRecords)
+	a.lastSfpIndex = len(messages) - 1
+	a.stepCount = step
+	marks := a.sfpMarks[:0]
+	for _, mark := range a.sfpMarks {
+		if mark.count <= len(messages) {
+			marks = append(marks, mark)
+		}
+	}
+	a.sfpMarks = marks
+	a.mu.Unlock()
+
+	a.resetFileActivity()
+
+	if err := a.deps.Store.SaveMessages(ctx, a.id, messages); err != nil {
+		return fmt.Errorf("save messages: %w", err)
+	}
+	return a.saveToolRecords(ctx)
+}
+
+// markSafeForkPoint 在一轮执行或一批工具调用结束后记录安全分叉点
+func (a *Agent) markSafeForkPoint() {
+	bookmark := a.eventBus.GetLastBookmark()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	count := safeForkCount(a.messages)
+	a.lastSfpIndex = count - 1
+	if bookmark != nil {
+		a.lastBookmark = bookmark
+		a.sfpMarks = append(a.sfpMarks, sfpMark{seq: bookmark.Seq, count: count, step: a.stepCount})
+	}
+}
+
+// Lineage 返回祖先 Agent ID(从最早的祖先到直接父 Agent),非分叉创建的 Agent 为空
+func (a *Agent) Lineage() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return append([]string(nil), a.lineage...)
+}
+
+// Config 返回创建 Agent 时使用的配置副本
+func (a *Agent) Config() *types.AgentConfig {
+	config := *a.config
+	return &config
+}
+
+// Fork 从 parentID 的快照创建新 Agent
+// 新 Agent 复制快照中的对话及相关工具记录,血缘记录为父 Agent 的血缘加上 parentID
+func Fork(ctx context.Context, config *types.AgentConfig, deps *Dependencies, parentID string, snapshot *types.Snapshot) (*Agent, error) {
+	if snapshot == nil {
+		return nil, fmt.Errorf("snapshot is required")
+	}
+	if config.AgentID == "" {
+		config.AgentID = generateAgentID()
+	}
+	if config.AgentID == parentID {
+		return nil, fmt.Errorf("fork must use a new agent id")
+	}
+
+	parentInfo, err := deps.Store.LoadInfo(ctx, parentID)
+	if err != nil {
+		return nil, fmt.Errorf("load parent info: %w", err)
+	}
+	lineage := []string{parentID}
+	if parentInfo != nil && len(parentInfo.Lineage) > 0 {
+		lineage = append(append([]string{}, parentInfo.Lineage...), parentID)
+	}
+
+	parentRecords, err := deps.Store.LoadToolCallRecords(ctx, parentID)
+	if err != nil {
+		return nil, fmt.Errorf("load parent tool records: %w", err)
+	}
+	recordMap := make(map[string]*types.ToolCallRecord, len(parentRecords))
+	for i := range parentRecords {
+		recordMap[parentRecords[i].ID] = &parentRecords[i]
+	}
+	records := make([]types.ToolCallRecord, 0, len(recordMap))
+	for _, record := range toolRecordsFor(snapshot.Messages, recordMap) {
+		records = append(records, *record)
+	}
+
+	// 先写入存储,Create 时作为已有状态加载
+	if err := deps.Store.SaveMessages(ctx, config.AgentID, snapshot.Messages); err != nil {
+		return nil, fmt.Errorf("save messages: %w", err)
+	}
+	if err := deps.Store.SaveToolCallRecords(ctx, config.AgentID, records); err != nil {
+		return nil, fmt.Errorf("save tool records: %w", err)
+	}
+	if err := deps.Store.SaveInfo(ctx, config.AgentID, types.AgentInfo{
+		AgentID:       config.AgentID,
+		TemplateID:    config.TemplateID,
+		CreatedAt:     time.Now(),
+		Lineage:       lineage,
+		ConfigVersion: "v1.0.0",
+		MessageCount:  len(snapshot.Messages),
+		Metadata: map[string]interface{}{
+			"forked_from": parentID,
+			"snapshot_id": snapshot.ID,
+		},
+	}); err != nil {
+		return nil, fmt.Errorf("save info: %w", err)
+	}
+
+	return Create(ctx, config, deps)
+}
+
+// snapshotStepCount 返回快照记录的步数
+func snapshotStepCount(snapshot *types.Snapshot) int {
+	switch step := snapshot.Metadata["step_count"].(type) {
+	case int:
+		return step
+	case float64: // 从 JSON 恢复的快照
+		return int(step)
+	}
+	return 0
+}
+
+// safeForkCount 返回最长的、没有未完成工具调用的消息前缀长度
+func safeForkCount(messages []types.Message) int {
+	open := make(map[string]bool)
+	safe := 0
+	for i, msg := range messages {
+		for _, block := range msg.Content {
+			switch b := block.(type) {
+			case *types.ToolUseBlock:
+				open[b.ID] = true
+			case *types.ToolResultBlock:
+				delete(open, b.ToolUseID)
+			}
+		}
+		if len(open) == 0 {
+			safe = i + 1
+		}
+	}
+	return safe
+}
+
+// toolRecordsFor 返回 messages 中的工具调用对应的记录
+func toolRecordsFor(messages []types.Message, records map[string]*types.ToolCallRecord) map[string]*types.ToolCallRecord {
+	kept := make(map[string]*types.ToolCallRecord)
+	for _, msg := range messages {
+		for _, block := range msg.Content {
+			if tu, ok := block.(*types.ToolUseBlock); ok {
+				if record, ok := records[tu.ID]; ok {
+					kept[tu.ID] = record
+				}
+			}
+		}
+	}
+	return kept
+}
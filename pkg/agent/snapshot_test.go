@@ -0,0 +1,154 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/provider"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+func TestAgentSnapshot_Rewind(t *testing.T) {
+	mp := provider.NewMockProvider(provider.MockTextStep("one"), provider.MockTextStep("two"))
+	ag := newMockAgent(t, mp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := ag.Chat(ctx, "first"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	snapshot, err := ag.Snapshot(ctx, "after-first")
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if len(snapshot.Messages) != 2 || snapshot.Metadata["label"] != "after-first" {
+		t.Fatalf("Unexpected snapshot: %+v", snapshot)
+	}
+	step := ag.Status().StepCount
+
+	if _, err := ag.Chat(ctx, "second"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if err := ag.Rewind(ctx, snapshot.ID); err != nil {
+		t.Fatalf("Rewind failed: %v", err)
+	}
+
+	stored, err := ag.deps.Store.LoadMessages(ctx, ag.ID())
+	if err != nil {
+		t.Fatalf("Failed to load messages: %v", err)
+	}
+	if len(ag.messages) != 2 || len(stored) != 2 || assistantText(stored[1]) != "one" {
+		t.Errorf("Expected history rewound to the first reply, got %d messages (%d stored)", len(ag.messages), len(stored))
+	}
+	if got := ag.Status().StepCount; got != step {
+		t.Errorf("Expected step count %d after rewind, got %d", step, got)
+	}
+
+	if err := ag.Rewind(ctx, "snap_missing"); err == nil {
+		t.Errorf("Expected error for unknown snapshot")
+	}
+}
+
+func TestAgentRewindToBookmark_DropsToolRecords(t *testing.T) {
+	mp := provider.NewMockProvider(provider.MockTextStep("hello"), writeStep(), provider.MockTextStep("written"))
+	ag := newMockAgent(t, mp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	first, err := ag.Chat(ctx, "hi")
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if first.Last == nil {
+		t.Fatalf("Expected bookmark in chat result")
+	}
+	step := ag.Status().StepCount
+	if _, err := ag.Chat(ctx, "write hello.txt"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if _, ok := ag.toolRecords["call_write"]; !ok {
+		t.Fatalf("Expected tool record for call_write")
+	}
+	if len(ag.Files()) != 1 {
+		t.Fatalf("Expected written file to be tracked, got %+v", ag.Files())
+	}
+
+	if err := ag.RewindToBookmark(ctx, *first.Last); err != nil {
+		t.Fatalf("RewindToBookmark failed: %v", err)
+	}
+	if len(ag.messages) != 2 || len(ag.toolRecords) != 0 {
+		t.Errorf("Expected 2 messages and no tool records, got %d messages and %d records", len(ag.messages), len(ag.toolRecords))
+	}
+	// 步数和文件访问记录回到书签时的状态
+	if got := ag.Status().StepCount; got != step {
+		t.Errorf("Expected step count %d after rewind, got %d", step, got)
+	}
+	if files := ag.Files(); len(files) != 0 {
+		t.Errorf("Expected no tracked files after rewind, got %+v", files)
+	}
+	records, _ := ag.deps.Store.LoadToolCallRecords(ctx, ag.ID())
+	if len(records) != 0 {
+		t.Errorf("Expected stored tool records to be removed, got %d", len(records))
+	}
+}
+
+func TestAgentFork_CopiesHistoryAndLineage(t *testing.T) {
+	mp := provider.NewMockProvider(provider.MockTextStep("one"), provider.MockTextStep("branch"))
+	parent := newMockAgent(t, mp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := parent.Chat(ctx, "first"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	snapshot, err := parent.Snapshot(ctx, "")
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	child, err := Fork(ctx, parent.Config(), parent.deps, parent.ID(), snapshot)
+	if err == nil {
+		child.Close()
+		t.Fatalf("Expected error when forking with the parent's agent id")
+	}
+
+	config := parent.Config()
+	config.AgentID = ""
+	child, err = Fork(ctx, config, parent.deps, parent.ID(), snapshot)
+	if err != nil {
+		t.Fatalf("Fork failed: %v", err)
+	}
+	defer child.Close()
+
+	if lineage := child.Lineage(); len(lineage) != 1 || lineage[0] != parent.ID() {
+		t.Errorf("Expected lineage [%s], got %v", parent.ID(), lineage)
+	}
+	if _, err := child.Chat(ctx, "try another way"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	// 分叉后的调用带着父 Agent 的历史,父 Agent 不受影响
+	req, _ := mp.Request(1)
+	if len(req.Messages) != 3 || assistantText(req.Messages[1]) != "one" {
+		t.Errorf("Expected forked history in request, got %d messages", len(req.Messages))
+	}
+	if len(parent.messages) != 2 {
+		t.Errorf("Parent history should be unchanged, got %d messages", len(parent.messages))
+	}
+}
+
+func TestSafeForkCount(t *testing.T) {
+	messages := []types.Message{
+		{Role: types.MessageRoleUser, Content: []types.ContentBlock{&types.TextBlock{Text: "go"}}},
+		{Role: types.MessageRoleAssistant, Content: []types.ContentBlock{&types.ToolUseBlock{ID: "call_1", Name: "fs_read"}}},
+		{Role: types.MessageRoleUser, Content: []types.ContentBlock{&types.ToolResultBlock{ToolUseID: "call_1"}}},
+		{Role: types.MessageRoleAssistant, Content: []types.ContentBlock{&types.ToolUseBlock{ID: "call_2", Name: "fs_read"}}},
+	}
+	if got := safeForkCount(messages); got != 3 {
+		t.Errorf("Expected safe prefix of 3 messages, got %d", got)
+	}
+}
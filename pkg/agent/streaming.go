@@ -2,15 +2,39 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"iter"
 	"log"
+	"sync"
+	"time"
 
-	"github.com/wordflowlab/agentsdk/pkg/events"
+	"github.com/google/uuid"
+	"github.com/wordflowlab/agentsdk/pkg/provider"
 	"github.com/wordflowlab/agentsdk/pkg/session"
 	"github.com/wordflowlab/agentsdk/pkg/types"
 )
 
+// ErrAgentBusy Agent 正在处理上一轮消息
+var ErrAgentBusy = errors.New("agent is busy")
+
+// Stream 事件类型,保存在 session.Event.Metadata[StreamEventKindKey]
+const (
+	StreamEventKindKey = "kind"
+
+	StreamEventTextDelta  = "text_delta"  // 文本增量
+	StreamEventThinkDelta = "think_delta" // 思考内容增量(需开启 ExposeThinking)
+	StreamEventToolStart  = "tool_start"  // 工具开始执行
+	StreamEventToolEnd    = "tool_end"    // 工具执行结束
+	StreamEventToolError  = "tool_error"  // 工具调用失败(如工具不存在)
+	StreamEventUsage      = "usage"       // 一次模型调用的 Token 用量
+	StreamEventFinal      = "final"       // 最终回复(完整的 assistant 消息)
+
+	StreamEventPermissionRequired = "permission_required" // 工具调用需要审批
+	StreamEventPermissionDecided  = "permission_decided"  // 审批已有决定
+	StreamEventPaused             = "paused"              // 等待审批而暂停,Metadata["permission_ids"] 为待审批的调用
+)
+
 // StreamingAgent 扩展接口 - 支持流式执行
 // 参考 Google ADK-Go 的 Agent.Run() iter.Seq2 设计
 //
@@ -33,10 +57,10 @@ type StreamingAgent interface {
 }
 
 // Stream 实现流式执行接口
-// 返回 Go 1.23 的 iter.Seq2 迭代器，支持：
-// - 流式生成事件
-// - 客户端控制的取消（yield 返回 false）
-// - 与 LLM 流式 API 无缝集成
+// 与 Send/Chat 共用同一套执行循环(Middleware、工具记录、断点、持久化),
+// 按顺序产出文本增量、工具开始/结束、用量和最终回复事件;
+// 工具需要审批时产出 permission_required 与 paused 事件,调用 Decide 后继续;
+// yield 返回 false 时取消本轮执行,并等待 Agent 回到 Ready 状态后返回
 func (a *Agent) Stream(ctx context.Context, message string, opts ...Option) iter.Seq2[*session.Event, error] {
 	return func(yield func(*session.Event, error) bool) {
 		// 应用选项
@@ -48,75 +72,81 @@ func (a *Agent) Stream(ctx context.Context, message string, opts ...Option) iter
 		log.Printf("[Agent Stream] Starting stream for message: %s", truncate(message, 50))
 
 		// 1. 前置验证
-		if err := a.validateMessage(message); err != nil {
+		if err := a.validateMessage(message, config.attachments); err != nil {
 			yield(nil, fmt.Errorf("validate message: %w", err))
 			return
 		}
 
-		// 2. 创建用户消息
-		userMsg := types.Message{
-			Role:    types.RoleUser,
-			Content: message,
+		// 2. 占用 Agent,同一时间只能有一轮执行
+		if !a.beginRun() {
+			yield(nil, ErrAgentBusy)
+			return
 		}
 
-		// 3. 检查 Slash Commands
-		if a.commandExecutor != nil {
-			if handled, err := a.handleSlashCommandForStream(ctx, &userMsg, yield); handled {
-				if err != nil {
-					yield(nil, fmt.Errorf("slash command: %w", err))
-				}
-				return
-			}
+		// 3. 构建并持久化用户消息(slash command、Skills 增强、附件)
+		a.mu.Lock()
+		userMsg, err := a.prepareUserMessage(ctx, message, config.attachments)
+		if err == nil {
+			err = a.appendUserMessage(ctx, userMsg)
 		}
-
-		// 4. 应用 Skills 增强
-		if a.skillInjector != nil {
-			if err := a.skillInjector.Inject(ctx, &userMsg); err != nil {
-				log.Printf("[Agent Stream] Skill injection failed: %v", err)
-			}
+		if err != nil {
+			a.state = types.AgentStateReady
+			a.mu.Unlock()
+			yield(nil, err)
+			return
 		}
-
-		// 5. 入队消息
-		a.mu.Lock()
-		a.messages = append(a.messages, userMsg)
+		sink := newStreamSink(a.id)
+		sink.responseFormat = config.responseFormat
+		a.stream = sink
 		a.mu.Unlock()
 
-		// 6. 持久化消息
-		if err := a.persistMessage(ctx, &userMsg); err != nil {
-			log.Printf("[Agent Stream] Failed to persist message: %v", err)
-		}
-
-		// 7. 流式执行模型步骤
-		for {
-			// 检查上下文是否已取消
-			select {
-			case <-ctx.Done():
-				yield(nil, ctx.Err())
+		// 4. 在后台执行,事件经 sink 按顺序交给调用方
+		// 执行上下文不直接继承 ctx,以便 Chat 在暂停时脱离后执行循环仍可继续
+		runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		stopPropagation := context.AfterFunc(ctx, cancel)
+		defer stopPropagation()
+
+		var runErr error
+		go func() {
+			defer close(sink.events)
+			defer cancel()
+			// 执行期间收件箱收到的消息由 runMessages 在后台继续处理
+			runErr = a.runMessages(runCtx, config.budget)
+		}()
+
+		for event := range sink.events {
+			if !yield(event, nil) {
+				log.Printf("[Agent Stream] Client cancelled stream")
+				sink.stop()
+				cancel()
+				for range sink.events {
+					// 等待执行循环退出
+				}
 				return
-			default:
 			}
-
-			// 执行一步模型推理
-			event, done, err := a.runModelStepStreaming(ctx)
-			if err != nil {
-				yield(nil, fmt.Errorf("model step: %w", err))
+			if config.detachOnPause && StreamEventKind(event) == StreamEventPaused {
+				// 调用方不再等待,审批后执行循环在后台继续
+				stopPropagation()
+				sink.stop()
 				return
 			}
+		}
 
-			// Yield 事件，如果客户端返回 false 则中断
-			if event != nil {
-				if !yield(event, nil) {
-					log.Printf("[Agent Stream] Client cancelled stream")
-					return
-				}
-			}
+		// sink.events 关闭后 runErr 已写入
+		if runErr != nil {
+			yield(nil, runErr)
+			return
+		}
 
-			// 检查是否完成
-			if done {
-				log.Printf("[Agent Stream] Stream completed")
-				return
-			}
+		// 5. 最终回复(执行循环结束时记录在 sink 中)
+		final, step := sink.final, sink.step
+		if final != nil {
+			event := sink.newEvent("assistant", StreamEventFinal, *final)
+			event.Metadata["step"] = step
+			event.Metadata["reason"] = sink.reason
+			yield(event, nil)
 		}
+		log.Printf("[Agent Stream] Stream completed")
 	}
 }
 
@@ -194,202 +224,245 @@ func StreamFilter(stream iter.Seq2[*session.Event, error], predicate func(*sessi
 	}
 }
 
+// StreamEventKind 返回 Stream 事件的类型
+func StreamEventKind(event *session.Event) string {
+	if event == nil {
+		return ""
+	}
+	kind, _ := event.Metadata[StreamEventKindKey].(string)
+	return kind
+}
+
 // streamConfig 流式执行配置
 type streamConfig struct {
-	// 未来可扩展的配置项
+	attachments   []types.ContentBlock
+	budget        *types.RunBudget
+	detachOnPause bool // 暂停等待审批时结束迭代(Chat 使用)
+
+	responseFormat *provider.ResponseFormat // 本轮模型调用的结构化输出格式(ChatStructured 使用)
 }
 
 // Option 流式执行选项
 type Option func(*streamConfig)
 
+// WithAttachments 随消息发送图片/文档附件
+func WithAttachments(attachments ...types.ContentBlock) Option {
+	return func(c *streamConfig) {
+		c.attachments = append(c.attachments, attachments...)
+	}
+}
+
+// WithBudget 为本次调用指定执行预算,覆盖模板中的 Runtime.Budget
+func WithBudget(budget *types.RunBudget) Option {
+	return func(c *streamConfig) {
+		c.budget = budget
+	}
+}
+
 // validateMessage 验证消息
-func (a *Agent) validateMessage(message string) error {
-	if message == "" {
+func (a *Agent) validateMessage(message string, attachments []types.ContentBlock) error {
+	if message == "" && len(attachments) == 0 {
 		return fmt.Errorf("message cannot be empty")
 	}
 	return nil
 }
 
-// handleSlashCommandForStream 处理 Slash Command（流式版本）
-func (a *Agent) handleSlashCommandForStream(ctx context.Context, msg *types.Message, yield func(*session.Event, error) bool) (bool, error) {
-	// 检查是否为 slash command
-	if !a.commandExecutor.IsSlashCommand(msg.Content) {
-		return false, nil
-	}
+// streamSink 一次 Stream 调用的事件出口
+// 执行循环在发送时阻塞,直到调用方取走事件或停止迭代
+type streamSink struct {
+	agentID      string
+	invocationID string
+	events       chan *session.Event
+	stopped      chan struct{}
+	stopOnce     sync.Once
+	reason       string // ProgressDoneEvent.Reason,由执行循环写入
+
+	// 本轮结束时的最终回复和步数,由执行循环在回到空闲状态前写入,
+	// 之后收件箱中的下一轮可能已经开始,不能再从 a.messages 读取
+	final *types.Message
+	step  int
+
+	responseFormat *provider.ResponseFormat // 本轮执行的结构化输出格式
+}
 
-	// 执行 slash command
-	result, err := a.commandExecutor.Execute(ctx, msg.Content)
-	if err != nil {
-		return true, err
+func newStreamSink(agentID string) *streamSink {
+	return &streamSink{
+		agentID:      agentID,
+		invocationID: "inv_" + uuid.New().String(),
+		events:       make(chan *session.Event, 16),
+		stopped:      make(chan struct{}),
 	}
+}
 
-	// 生成响应事件
-	event := &session.Event{
-		ID:        generateEventID(),
-		Timestamp: a.createdAt,
-		AgentID:   a.id,
-		Author:    "system",
-		Content: types.Message{
-			Role:    types.RoleAssistant,
-			Content: result,
-		},
+// send 发送事件;调用方已停止迭代时丢弃
+func (s *streamSink) send(event *session.Event) {
+	select {
+	case <-s.stopped:
+		return
+	default:
+	}
+	select {
+	case s.events <- event:
+	case <-s.stopped:
 	}
-
-	// Yield 事件
-	yield(event, nil)
-	return true, nil
 }
 
-// runModelStepStreaming 流式执行模型步骤
-// 返回: (event, done, error)
-func (a *Agent) runModelStepStreaming(ctx context.Context) (*session.Event, bool, error) {
-	// 1. 准备消息
-	a.mu.RLock()
-	messages := make([]types.Message, len(a.messages))
-	copy(messages, a.messages)
-	a.mu.RUnlock()
-
-	// 2. 通过 Middleware 调用 LLM
-	var resp *types.Response
-	var err error
-
-	if a.middlewareStack != nil {
-		// 使用 Middleware Stack
-		req := &types.Request{
-			Messages: messages,
-			Tools:    a.getToolsForProvider(),
-			MaxTokens: a.getMaxTokens(),
-		}
-
-		resp, err = a.middlewareStack.Handle(ctx, req, a.provider.SendMessage)
-	} else {
-		// 直接调用 Provider
-		resp, err = a.provider.SendMessage(ctx, &types.Request{
-			Messages: messages,
-			Tools:    a.getToolsForProvider(),
-			MaxTokens: a.getMaxTokens(),
-		})
-	}
+// stop 停止接收事件
+func (s *streamSink) stop() {
+	s.stopOnce.Do(func() { close(s.stopped) })
+}
 
-	if err != nil {
-		return nil, false, err
+// newEvent 创建属于本次调用的事件
+func (s *streamSink) newEvent(author, kind string, content types.Message) *session.Event {
+	return &session.Event{
+		ID:           generateEventID(),
+		Timestamp:    time.Now(),
+		InvocationID: s.invocationID,
+		AgentID:      s.agentID,
+		Author:       author,
+		Content:      content,
+		Metadata:     map[string]interface{}{StreamEventKindKey: kind},
 	}
+}
 
-	// 3. 处理响应
-	a.mu.Lock()
-	a.messages = append(a.messages, resp.Message)
-	a.mu.Unlock()
+// emitProgress 发送 Progress 事件,并转发给当前的 Stream 调用
+func (a *Agent) emitProgress(event interface{}) {
+	a.eventBus.EmitProgress(event)
+	a.forwardToStream(event)
+}
 
-	// 4. 检查是否有工具调用
-	if len(resp.Message.ToolCalls) > 0 {
-		// 执行工具调用
-		if err := a.executeToolCalls(ctx, resp.Message.ToolCalls); err != nil {
-			return nil, false, err
-		}
-		// 继续循环
-		return nil, false, nil
-	}
+// emitControl 发送 Control 事件,并转发给当前的 Stream 调用
+func (a *Agent) emitControl(event interface{}) {
+	a.eventBus.EmitControl(event)
+	a.forwardToStream(event)
+}
 
-	// 5. 生成事件
-	event := &session.Event{
-		ID:        generateEventID(),
-		Timestamp: a.createdAt,
-		AgentID:   a.id,
-		Author:    "assistant",
-		Content:   resp.Message,
-		Actions:   session.EventActions{},
-	}
+// emitMonitor 发送 Monitor 事件,并转发给当前的 Stream 调用
+func (a *Agent) emitMonitor(event interface{}) {
+	a.eventBus.EmitMonitor(event)
+	a.forwardToStream(event)
+}
 
-	// 6. 持久化事件
-	if err := a.persistEvent(ctx, event); err != nil {
-		log.Printf("[Agent Stream] Failed to persist event: %v", err)
+// forwardToStream 把 EventBus 事件转换为 session.Event 交给 Stream 调用方
+func (a *Agent) forwardToStream(event interface{}) {
+	a.mu.RLock()
+	sink := a.stream
+	a.mu.RUnlock()
+	if sink == nil {
+		return
 	}
 
-	// 7. 发布事件到 EventBus
-	if a.eventBus != nil {
-		a.eventBus.Publish(events.Event{
-			Type:      events.EventTypeMessageReceived,
-			AgentID:   a.id,
-			Timestamp: event.Timestamp,
-			Data:      event,
+	var out *session.Event
+	switch e := event.(type) {
+	case *types.ProgressTextChunkEvent:
+		out = sink.newEvent("assistant", StreamEventTextDelta, types.Message{
+			Role:    types.MessageRoleAssistant,
+			Content: []types.ContentBlock{&types.TextBlock{Text: e.Delta}},
 		})
-	}
+		out.Metadata["step"] = e.Step
 
-	return event, true, nil
-}
+	case *types.ProgressThinkChunkEvent:
+		out = sink.newEvent("assistant", StreamEventThinkDelta, types.Message{
+			Role:    types.MessageRoleAssistant,
+			Content: []types.ContentBlock{&types.ThinkingBlock{Thinking: e.Delta}},
+		})
+		out.Metadata["step"] = e.Step
+
+	case *types.ProgressToolStartEvent:
+		out = sink.newEvent("assistant", StreamEventToolStart, types.Message{
+			Role: types.MessageRoleAssistant,
+			Content: []types.ContentBlock{&types.ToolUseBlock{
+				ID:    e.Call.ID,
+				Name:  e.Call.Name,
+				Input: e.Call.Arguments,
+			}},
+		})
+		out.Metadata["call"] = e.Call
 
-// executeToolCalls 执行工具调用
-func (a *Agent) executeToolCalls(ctx context.Context, toolCalls []types.ToolCall) error {
-	results := make([]types.Message, len(toolCalls))
-
-	for i, call := range toolCalls {
-		tool, ok := a.toolMap[call.Name]
-		if !ok {
-			results[i] = types.Message{
-				Role:       types.RoleToolResult,
-				ToolCallID: call.ID,
-				Content:    fmt.Sprintf("Error: tool '%s' not found", call.Name),
-			}
-			continue
+	case *types.ProgressToolEndEvent:
+		result := e.Call.Result
+		if e.Call.Error != "" {
+			result = map[string]interface{}{"ok": false, "error": e.Call.Error}
 		}
+		out = sink.newEvent("tool", StreamEventToolEnd, types.Message{
+			Role: types.MessageRoleUser,
+			Content: []types.ContentBlock{&types.ToolResultBlock{
+				ToolUseID: e.Call.ID,
+				Content:   result,
+				IsError:   e.Call.Error != "",
+			}},
+		})
+		out.Metadata["call"] = e.Call
+
+	case *types.ProgressToolErrorEvent:
+		out = sink.newEvent("tool", StreamEventToolError, types.Message{
+			Role: types.MessageRoleUser,
+			Content: []types.ContentBlock{&types.ToolResultBlock{
+				ToolUseID: e.Call.ID,
+				Content:   map[string]interface{}{"ok": false, "error": e.Error},
+				IsError:   true,
+			}},
+		})
+		out.Metadata["call"] = e.Call
+
+	case *types.MonitorTokenUsageEvent:
+		out = sink.newEvent("system", StreamEventUsage, types.Message{})
+		out.Metadata["usage"] = e
+
+	case *types.ControlPermissionRequiredEvent:
+		out = sink.newEvent("system", StreamEventPermissionRequired, types.Message{
+			Role: types.MessageRoleAssistant,
+			Content: []types.ContentBlock{&types.ToolUseBlock{
+				ID:    e.Call.ID,
+				Name:  e.Call.Name,
+				Input: e.Call.Arguments,
+			}},
+		})
+		out.Metadata["call"] = e.Call
 
-		// 执行工具
-		result, err := a.executor.Execute(ctx, tool, call.Arguments)
-		if err != nil {
-			results[i] = types.Message{
-				Role:       types.RoleToolResult,
-				ToolCallID: call.ID,
-				Content:    fmt.Sprintf("Error: %v", err),
-			}
-			continue
-		}
+	case *types.ControlPermissionDecidedEvent:
+		out = sink.newEvent("system", StreamEventPermissionDecided, types.Message{})
+		out.Metadata["decision"] = e
 
-		results[i] = types.Message{
-			Role:       types.RoleToolResult,
-			ToolCallID: call.ID,
-			Content:    fmt.Sprint(result),
+	case *types.MonitorStateChangedEvent:
+		// 只有等待审批的暂停需要调用方处理;Pause 造成的暂停在 Resume 后继续执行
+		if e.State != types.AgentStatePaused || e.Reason != types.PauseReasonPermission {
+			return
 		}
-	}
+		out = sink.newEvent("system", StreamEventPaused, types.Message{})
+		out.Metadata["permission_ids"] = a.PendingPermissions()
 
-	// 追加工具结果到消息历史
-	a.mu.Lock()
-	a.messages = append(a.messages, results...)
-	a.mu.Unlock()
+	case *types.ProgressDoneEvent:
+		// 结束原因随最终回复一起返回
+		sink.reason = e.Reason
+		return
 
-	return nil
-}
-
-// getToolsForProvider 获取 Provider 格式的工具定义
-func (a *Agent) getToolsForProvider() []types.ToolDefinition {
-	tools := make([]types.ToolDefinition, 0, len(a.toolMap))
-	for _, tool := range a.toolMap {
-		tools = append(tools, types.ToolDefinition{
-			Name:        tool.Name(),
-			Description: tool.Description(),
-			InputSchema: tool.InputSchema(),
-		})
+	default:
+		return
 	}
-	return tools
-}
 
-// getMaxTokens 获取最大 token 数
-func (a *Agent) getMaxTokens() int {
-	if a.config.ModelConfig != nil && a.config.ModelConfig.MaxTokens > 0 {
-		return a.config.ModelConfig.MaxTokens
-	}
-	return 4096 // 默认值
+	sink.send(out)
 }
 
-// persistMessage 持久化消息
-func (a *Agent) persistMessage(ctx context.Context, msg *types.Message) error {
-	// TODO: 实现消息持久化
+// lastAssistantMessage 返回最后一条 assistant 消息
+func lastAssistantMessage(messages []types.Message) *types.Message {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == types.MessageRoleAssistant {
+			msg := messages[i]
+			return &msg
+		}
+	}
 	return nil
 }
 
-// persistEvent 持久化事件
-func (a *Agent) persistEvent(ctx context.Context, event *session.Event) error {
-	// TODO: 实现事件持久化到 SessionService
-	return nil
+// assistantText 返回消息中的第一个文本块
+func assistantText(message types.Message) string {
+	for _, block := range message.Content {
+		if tb, ok := block.(*types.TextBlock); ok {
+			return tb.Text
+		}
+	}
+	return ""
 }
 
 // truncate 截断字符串
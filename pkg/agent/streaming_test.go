@@ -0,0 +1,132 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"testing"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/provider"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+func TestAgentStream_ToolLoopEvents(t *testing.T) {
+	mp := provider.NewMockProvider(
+		provider.MockToolStep(provider.MockToolCall{
+			ID:          "call_write",
+			Name:        "fs_write",
+			PartialJSON: []string{`{"path":"hello.txt","content":"hi"}`},
+		}),
+		provider.MockStep{TextChunks: []string{"File ", "written."}, Usage: &provider.TokenUsage{InputTokens: 30, OutputTokens: 4}},
+	)
+	ag := newMockAgent(t, mp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events, err := StreamCollect(ag.Stream(ctx, "write hello.txt"))
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+
+	var kinds []string
+	text := ""
+	for _, event := range events {
+		kinds = append(kinds, StreamEventKind(event))
+		if StreamEventKind(event) == StreamEventTextDelta {
+			text += assistantText(event.Content)
+		}
+	}
+	if text != "File written." {
+		t.Errorf("Expected text deltas to form 'File written.', got %q", text)
+	}
+
+	// 工具开始 -> 工具结束 -> 文本 -> 最终回复
+	order := []string{StreamEventToolStart, StreamEventToolEnd, StreamEventTextDelta, StreamEventUsage, StreamEventFinal}
+	next := 0
+	for _, kind := range kinds {
+		if next < len(order) && kind == order[next] {
+			next++
+		}
+	}
+	if next != len(order) {
+		t.Fatalf("Expected events in order %v, got %v", order, kinds)
+	}
+
+	end := events[indexOfKind(kinds, StreamEventToolEnd)]
+	tr, ok := end.Content.Content[0].(*types.ToolResultBlock)
+	if !ok || tr.ToolUseID != "call_write" || tr.IsError {
+		t.Errorf("Unexpected tool_end content: %+v", end.Content.Content)
+	}
+
+	final := events[len(events)-1]
+	if StreamEventKind(final) != StreamEventFinal || assistantText(final.Content) != "File written." {
+		t.Errorf("Expected final answer last, got %s %q", StreamEventKind(final), assistantText(final.Content))
+	}
+	if ag.Status().State != types.AgentStateReady {
+		t.Errorf("Expected agent to be Ready after stream, got %s", ag.Status().State)
+	}
+}
+
+func TestAgentStream_BreakCancelsRun(t *testing.T) {
+	mp := provider.NewMockProvider(
+		provider.MockStep{TextChunks: []string{"one ", "two ", "three"}, ChunkDelay: 200 * time.Millisecond},
+		provider.MockTextStep("again"),
+	)
+	ag := newMockAgent(t, mp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for event, err := range ag.Stream(ctx, "count") {
+		if err != nil {
+			t.Fatalf("Stream failed: %v", err)
+		}
+		if StreamEventKind(event) == StreamEventTextDelta {
+			break
+		}
+	}
+
+	// 中断后 Agent 应立即可用
+	if ag.Status().State != types.AgentStateReady {
+		t.Fatalf("Expected agent to be Ready after break, got %s", ag.Status().State)
+	}
+	result, err := ag.Chat(ctx, "again")
+	if err != nil {
+		t.Fatalf("Chat after break failed: %v", err)
+	}
+	if result.Text != "again" {
+		t.Errorf("Expected 'again', got %q", result.Text)
+	}
+}
+
+func TestAgentStream_Busy(t *testing.T) {
+	mp := provider.NewMockProvider(
+		provider.MockStep{TextChunks: []string{"slow ", "answer"}, ChunkDelay: 200 * time.Millisecond},
+	)
+	ag := newMockAgent(t, mp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	next, stop := iter.Pull2(ag.Stream(ctx, "first"))
+	defer stop()
+	if _, err, ok := next(); !ok || err != nil {
+		t.Fatalf("Expected first event, got ok=%v err=%v", ok, err)
+	}
+
+	_, err := StreamFirst(ag.Stream(ctx, "second"))
+	if !errors.Is(err, ErrAgentBusy) {
+		t.Errorf("Expected ErrAgentBusy, got %v", err)
+	}
+}
+
+func indexOfKind(kinds []string, kind string) int {
+	for i, k := range kinds {
+		if k == kind {
+			return i
+		}
+	}
+	return -1
+}
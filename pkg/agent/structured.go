@@ -0,0 +1,171 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/wordflowlab/agentsdk/pkg/provider"
+	"github.com/wordflowlab/agentsdk/pkg/schema"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// DefaultStructuredRepairs 结构化输出校验失败后让模型修复的最大次数
+const DefaultStructuredRepairs = 2
+
+// structuredResultKey schema 根节点不是 object 时的包装字段
+const structuredResultKey = "result"
+
+// ChatStructured 对话并返回符合 JSON Schema 的结构化数据
+// 模型的最终回复会按 schema 校验,不通过时把校验错误反馈给模型重新作答,
+// 最多修复 DefaultStructuredRepairs 次;仍不通过时返回 *schema.ValidationError
+func (a *Agent) ChatStructured(ctx context.Context, text string, outputSchema map[string]interface{}) (*types.StructuredResult, error) {
+	if outputSchema == nil {
+		return nil, fmt.Errorf("structured output schema is required")
+	}
+
+	// 各提供商都要求根节点为 object,其他类型包装一层
+	requestSchema, wrapped := wrapStructuredSchema(outputSchema)
+
+	// 格式随每次 Chat 传给它占用的那一轮执行,不修改 Agent 的共享状态
+	format := func(c *streamConfig) {
+		c.responseFormat = &provider.ResponseFormat{
+			Type:   provider.ResponseFormatJSONSchema,
+			Schema: requestSchema,
+		}
+	}
+
+	prompt := text
+	var lastErr error
+	for attempt := 1; attempt <= DefaultStructuredRepairs+1; attempt++ {
+		result, err := a.Chat(ctx, prompt, format)
+		if err != nil {
+			return nil, err
+		}
+		if result.Status != "ok" {
+			return &types.StructuredResult{CompleteResult: *result, Attempts: attempt},
+				fmt.Errorf("structured output unavailable: agent status %s", result.Status)
+		}
+
+		raw := extractJSON(result.Text)
+		value, err := schema.ValidateJSON(requestSchema, []byte(raw))
+		if err == nil {
+			if wrapped {
+				value = value.(map[string]interface{})[structuredResultKey]
+			}
+			data, err := json.Marshal(value)
+			if err != nil {
+				return nil, fmt.Errorf("marshal structured output: %w", err)
+			}
+			return &types.StructuredResult{
+				CompleteResult: *result,
+				Data:           data,
+				Attempts:       attempt,
+			}, nil
+		}
+
+		lastErr = err
+		log.Printf("[ChatStructured] Agent %s: attempt %d produced invalid output: %v", a.id, attempt, err)
+		a.emitMonitor(&types.MonitorErrorEvent{
+			Severity: "warn",
+			Phase:    "structured_output",
+			Message:  fmt.Sprintf("structured output failed validation: %v", err),
+			Detail: map[string]interface{}{
+				"attempt":      attempt,
+				"max_attempts": DefaultStructuredRepairs + 1,
+			},
+		})
+
+		if attempt > DefaultStructuredRepairs {
+			return &types.StructuredResult{CompleteResult: *result, Attempts: attempt},
+				fmt.Errorf("structured output invalid after %d attempts: %w", attempt, lastErr)
+		}
+		prompt = structuredRepairPrompt(err)
+	}
+
+	return nil, lastErr
+}
+
+// ChatInto 对话并把结构化结果解码到 T
+// schema 为空时根据 T 的字段和 json tag 生成
+func ChatInto[T any](ctx context.Context, a *Agent, text string, outputSchema map[string]interface{}) (T, error) {
+	var out T
+	if outputSchema == nil {
+		outputSchema = schema.For[T]()
+	}
+
+	result, err := a.ChatStructured(ctx, text, outputSchema)
+	if err != nil {
+		return out, err
+	}
+	if err := json.Unmarshal(result.Data, &out); err != nil {
+		return out, fmt.Errorf("decode structured output: %w", err)
+	}
+	return out, nil
+}
+
+// wrapStructuredSchema 根节点不是 object 时包装为 {"result": ...}
+func wrapStructuredSchema(s map[string]interface{}) (map[string]interface{}, bool) {
+	if t, _ := s["type"].(string); t == "object" {
+		return s, false
+	}
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			structuredResultKey: s,
+		},
+		"required":             []interface{}{structuredResultKey},
+		"additionalProperties": false,
+	}, true
+}
+
+// structuredRepairPrompt 构建修复提示
+func structuredRepairPrompt(err error) string {
+	var b strings.Builder
+	b.WriteString("Your previous answer did not match the required JSON schema.\n")
+
+	var validationErr *schema.ValidationError
+	if errors.As(err, &validationErr) {
+		b.WriteString("Problems:\n")
+		for _, e := range validationErr.Errors {
+			b.WriteString("- " + e + "\n")
+		}
+	} else {
+		b.WriteString("Problem: " + err.Error() + "\n")
+	}
+
+	b.WriteString("Reply again with the complete, corrected JSON only.")
+	return b.String()
+}
+
+// extractJSON 从模型回复中提取 JSON(去掉 Markdown 代码块和前后的说明文字)
+func extractJSON(text string) string {
+	text = strings.TrimSpace(text)
+	if json.Valid([]byte(text)) {
+		return text
+	}
+
+	if start := strings.Index(text, "```"); start >= 0 {
+		body := text[start+3:]
+		if nl := strings.Index(body, "\n"); nl >= 0 {
+			body = body[nl+1:]
+		}
+		if end := strings.Index(body, "```"); end >= 0 {
+			body = strings.TrimSpace(body[:end])
+		}
+		if json.Valid([]byte(body)) {
+			return body
+		}
+	}
+
+	start := strings.IndexAny(text, "{[")
+	end := strings.LastIndexAny(text, "}]")
+	if start >= 0 && end > start && json.Valid([]byte(text[start:end+1])) {
+		return text[start : end+1]
+	}
+
+	return text
+}
@@ -0,0 +1,164 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/provider"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+var citySchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"city":       map[string]interface{}{"type": "string"},
+		"population": map[string]interface{}{"type": "integer", "minimum": 0},
+	},
+	"required":             []interface{}{"city", "population"},
+	"additionalProperties": false,
+}
+
+func TestAgentChatStructured_RepairsInvalidOutput(t *testing.T) {
+	mp := provider.NewMockProvider(
+		provider.MockTextStep(`{"city": "Paris"}`),
+		provider.MockTextStep(`{"city": "Paris", "population": 2100000}`),
+		provider.MockTextStep("Paris is the largest city."),
+	)
+	ag := newMockAgent(t, mp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := ag.ChatStructured(ctx, "largest city in France?", citySchema)
+	if err != nil {
+		t.Fatalf("Failed to chat structured: %v", err)
+	}
+	if result.Attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", result.Attempts)
+	}
+	if string(result.Data) != `{"city":"Paris","population":2100000}` {
+		t.Errorf("Unexpected data: %s", result.Data)
+	}
+
+	// 第二次请求应包含修复提示
+	req, ok := mp.Request(1)
+	if !ok {
+		t.Fatalf("Expected a second request")
+	}
+	if req.Options.ResponseFormat == nil || req.Options.ResponseFormat.Schema == nil {
+		t.Errorf("Expected response format on request")
+	}
+	last := req.Messages[len(req.Messages)-1]
+	text := last.Content[0].(*types.TextBlock).Text
+	if !strings.Contains(text, `missing required property "population"`) {
+		t.Errorf("Expected repair prompt with validation error, got %q", text)
+	}
+
+	// 调用结束后恢复普通对话
+	if _, err := ag.Chat(ctx, "tell me about it"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if req, _ := mp.Request(2); req.Options.ResponseFormat != nil {
+		t.Errorf("Expected no response format after ChatStructured, got %+v", req.Options.ResponseFormat)
+	}
+}
+
+func TestAgentChatStructured_BusyLeavesRunningTurn(t *testing.T) {
+	mp := provider.NewMockProvider(blockStep(), provider.MockTextStep("done"))
+	ag := newMockAgent(t, mp)
+	tool := newBlockTool()
+	ag.toolMap["block"] = tool
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := ag.Send(ctx, "block"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	<-tool.started
+
+	// Agent 忙时返回 ErrAgentBusy,不影响正在执行的一轮
+	if _, err := ag.ChatStructured(ctx, "largest city in France?", citySchema); !errors.Is(err, ErrAgentBusy) {
+		t.Fatalf("Expected ErrAgentBusy, got %v", err)
+	}
+	close(tool.release)
+	waitForSteps(t, mp)
+	waitForState(t, ag, types.AgentStateReady)
+
+	if req, _ := mp.Request(1); req.Options.ResponseFormat != nil {
+		t.Errorf("Expected running turn without response format, got %+v", req.Options.ResponseFormat)
+	}
+}
+
+func TestAgentChatStructured_GivesUpAfterRepairs(t *testing.T) {
+	steps := make([]provider.MockStep, 0, DefaultStructuredRepairs+1)
+	for i := 0; i <= DefaultStructuredRepairs; i++ {
+		steps = append(steps, provider.MockTextStep("not json"))
+	}
+	ag := newMockAgent(t, provider.NewMockProvider(steps...))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := ag.ChatStructured(ctx, "largest city in France?", citySchema)
+	if err == nil {
+		t.Fatalf("Expected error for invalid output")
+	}
+	if result == nil || result.Attempts != DefaultStructuredRepairs+1 || result.Data != nil {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+}
+
+func TestChatInto_DecodesFencedJSON(t *testing.T) {
+	type city struct {
+		City       string   `json:"city"`
+		Population int      `json:"population"`
+		Tags       []string `json:"tags,omitempty"`
+	}
+
+	mp := provider.NewMockProvider(
+		provider.MockTextStep("Here you go:\n```json\n{\"city\": \"Lyon\", \"population\": 500000}\n```"),
+	)
+	ag := newMockAgent(t, mp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	out, err := ChatInto[city](ctx, ag, "second city?", nil)
+	if err != nil {
+		t.Fatalf("Failed to chat into struct: %v", err)
+	}
+	if out.City != "Lyon" || out.Population != 500000 {
+		t.Errorf("Unexpected output: %+v", out)
+	}
+
+	req, _ := mp.Request(0)
+	if req.Options.ResponseFormat == nil {
+		t.Fatalf("Expected response format on request")
+	}
+	if _, ok := req.Options.ResponseFormat.Schema["properties"].(map[string]interface{})["population"]; !ok {
+		t.Errorf("Expected schema generated from struct, got %v", req.Options.ResponseFormat.Schema)
+	}
+}
+
+func TestChatStructured_WrapsNonObjectSchema(t *testing.T) {
+	mp := provider.NewMockProvider(provider.MockTextStep(`{"result": ["a", "b"]}`))
+	ag := newMockAgent(t, mp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := ag.ChatStructured(ctx, "list", map[string]interface{}{
+		"type":  "array",
+		"items": map[string]interface{}{"type": "string"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to chat structured: %v", err)
+	}
+	if string(result.Data) != `["a","b"]` {
+		t.Errorf("Expected unwrapped array, got %s", result.Data)
+	}
+}
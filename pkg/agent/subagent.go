@@ -0,0 +1,184 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wordflowlab/agentsdk/pkg/middleware"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// 子 Agent 在 AgentConfig.Metadata 中的标记
+const (
+	MetadataSubAgentDepth = "subagent_depth"  // 嵌套层级,顶层 Agent 为 0
+	MetadataParentAgentID = "parent_agent_id" // 创建它的父 Agent
+)
+
+// defaultSubAgentDepth SubAgentConfig.Depth 未设置时允许的嵌套层级
+const defaultSubAgentDepth = 1
+
+// NewSubAgentFactory 创建子 Agent 工厂,供 SubAgentMiddleware 使用
+// 每次执行任务都按 spec 创建新的 Agent(独立的对话历史),完成后关闭:
+//   - spec.Config["template"] 或与 cfg.Templates 同名的 spec.Name 指定模板,
+//     否则使用父 Agent 的模板并以 spec.Prompt 作为系统提示词
+//   - cfg.Templates 非空时只允许其中的模板(父 Agent 的模板除外)
+//   - 父 Agent 的嵌套层级达到 cfg.Depth 时不再创建子 Agent
+func NewSubAgentFactory(parent *types.AgentConfig, deps *Dependencies, cfg *types.SubAgentConfig) middleware.SubAgentFactory {
+	if cfg == nil {
+		cfg = &types.SubAgentConfig{}
+	}
+	return func(ctx context.Context, spec middleware.SubAgentSpec) (middleware.SubAgent, error) {
+		depth := subAgentDepth(parent)
+		if depth >= maxSubAgentDepth(cfg) {
+			return nil, fmt.Errorf("subagent %s: depth limit %d reached", spec.Name, maxSubAgentDepth(cfg))
+		}
+
+		templateID, systemPrompt, err := subAgentTemplate(parent, cfg, spec)
+		if err != nil {
+			return nil, err
+		}
+		config := &types.AgentConfig{
+			TemplateID:  templateID,
+			ModelConfig: parent.ModelConfig,
+			Sandbox:     parent.Sandbox,
+			Tools:       spec.Tools,
+			Metadata: map[string]interface{}{
+				MetadataSubAgentDepth: depth + 1,
+				MetadataParentAgentID: parent.AgentID,
+			},
+		}
+		if templateID == parent.TemplateID {
+			config.TemplateVersion = parent.TemplateVersion
+		}
+		template, err := deps.TemplateRegistry.GetVersion(config.TemplateID, config.TemplateVersion)
+		if err != nil {
+			return nil, fmt.Errorf("subagent %s: %w", spec.Name, err)
+		}
+		// 模板指定了模型时使用模板的模型,其余模型配置(提供商、密钥等)沿用父 Agent
+		if parent.ModelConfig != nil && template.Model != "" && template.Model != parent.ModelConfig.Model {
+			modelConfig := *parent.ModelConfig
+			modelConfig.Model = template.Model
+			config.ModelConfig = &modelConfig
+		}
+		if cfg.InheritConfig {
+			config.ExposeThinking = parent.ExposeThinking
+			config.Overrides = parent.Overrides
+			config.Context = parent.Context
+			config.SkillsPackage = parent.SkillsPackage
+		}
+		if cfg.Overrides != nil {
+			config.Overrides = cfg.Overrides
+		}
+		if spec.InheritMiddlewares {
+			config.Middlewares = parent.Middlewares
+		}
+
+		tool, err := NewAgentTool(&AgentToolConfig{
+			Name:         spec.Name,
+			Description:  spec.Description,
+			Config:       config,
+			Deps:         deps,
+			SystemPrompt: systemPrompt,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &subAgent{tool: tool}, nil
+	}
+}
+
+// subAgentTemplate 返回子 Agent 使用的模板,以及需要覆盖的系统提示词
+func subAgentTemplate(parent *types.AgentConfig, cfg *types.SubAgentConfig, spec middleware.SubAgentSpec) (string, string, error) {
+	templateID, _ := spec.Config["template"].(string)
+	if templateID == "" {
+		for _, id := range cfg.Templates {
+			if id == spec.Name {
+				templateID = id
+				break
+			}
+		}
+	}
+	if templateID == "" {
+		return parent.TemplateID, spec.Prompt, nil
+	}
+
+	if templateID != parent.TemplateID && len(cfg.Templates) > 0 {
+		allowed := false
+		for _, id := range cfg.Templates {
+			if id == templateID {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return "", "", fmt.Errorf("subagent %s: template %s is not allowed", spec.Name, templateID)
+		}
+	}
+	return templateID, "", nil
+}
+
+// subAgentDepth 返回 Agent 的嵌套层级
+func subAgentDepth(config *types.AgentConfig) int {
+	switch depth := config.Metadata[MetadataSubAgentDepth].(type) {
+	case int:
+		return depth
+	case float64: // 从 JSON 恢复的配置
+		return int(depth)
+	}
+	return 0
+}
+
+// maxSubAgentDepth 允许的嵌套层级
+func maxSubAgentDepth(cfg *types.SubAgentConfig) int {
+	if cfg.Depth > 0 {
+		return cfg.Depth
+	}
+	return defaultSubAgentDepth
+}
+
+// newSubAgentMiddleware 按模板的 Runtime.SubAgents 创建子 Agent 中间件,提供 task 工具
+// 未配置或已达到嵌套层级时返回 nil
+func newSubAgentMiddleware(config *types.AgentConfig, template *types.AgentTemplateDefinition, deps *Dependencies) (*middleware.SubAgentMiddleware, error) {
+	if template.Runtime == nil || template.Runtime.SubAgents == nil {
+		return nil, nil
+	}
+	cfg := template.Runtime.SubAgents
+	if subAgentDepth(config) >= maxSubAgentDepth(cfg) {
+		return nil, nil
+	}
+
+	specs := make([]middleware.SubAgentSpec, 0, len(cfg.Templates))
+	for _, id := range cfg.Templates {
+		specs = append(specs, middleware.SubAgentSpec{
+			Name:        id,
+			Description: fmt.Sprintf("Agent based on template %s", id),
+		})
+	}
+	return middleware.NewSubAgentMiddleware(&middleware.SubAgentMiddlewareConfig{
+		Specs:                specs,
+		Factory:              NewSubAgentFactory(config, deps, cfg),
+		EnableGeneralPurpose: true,
+	})
+}
+
+// subAgent 基于 AgentTool 的子代理
+type subAgent struct {
+	tool *AgentTool
+}
+
+func (s *subAgent) Name() string {
+	return s.tool.Name()
+}
+
+func (s *subAgent) Execute(ctx context.Context, description string, parentContext map[string]interface{}) (string, error) {
+	result, err := s.tool.Run(ctx, description, parentContext)
+	if err != nil {
+		return "", err
+	}
+	return result.Text, nil
+}
+
+// Close 每次执行的 Agent 在执行结束时已关闭
+func (s *subAgent) Close() error {
+	return nil
+}
@@ -0,0 +1,173 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/middleware"
+	"github.com/wordflowlab/agentsdk/pkg/provider"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// registerSubAgentTemplates 注册可以委派子 Agent 的模板和 reviewer 模板
+func registerSubAgentTemplates(deps *Dependencies, depth int) {
+	deps.TemplateRegistry.Register(&types.AgentTemplateDefinition{
+		ID:           "lead",
+		SystemPrompt: "You are the lead.",
+		Tools:        []interface{}{"fs_read"},
+		Runtime: &types.AgentTemplateRuntime{
+			SubAgents: &types.SubAgentConfig{Depth: depth, Templates: []string{"reviewer"}},
+		},
+	})
+	deps.TemplateRegistry.Register(&types.AgentTemplateDefinition{
+		ID:           "reviewer",
+		SystemPrompt: "You are a reviewer.",
+		Tools:        []interface{}{"fs_read"},
+	})
+}
+
+func TestSubAgent_TaskToolSpawnsAgent(t *testing.T) {
+	mp := provider.NewMockProvider(
+		provider.MockToolStep(provider.MockToolCall{
+			ID:          "call_task",
+			Name:        "task",
+			PartialJSON: []string{`{"description":"review hello.txt","subagent_type":"reviewer"}`},
+		}),
+		provider.MockTextStep("looks good"),
+		provider.MockTextStep("review finished"),
+	)
+	deps := setupTestDeps(t)
+	deps.ProviderFactory = mp
+	registerSubAgentTemplates(deps, 1)
+
+	ag, err := Create(context.Background(), &types.AgentConfig{
+		TemplateID:  "lead",
+		ModelConfig: &types.ModelConfig{Provider: "mock", Model: "mock-model"},
+		Sandbox:     &types.SandboxConfig{Kind: types.SandboxKindMock, WorkDir: "/tmp/test"},
+	}, deps)
+	if err != nil {
+		t.Fatalf("Failed to create agent: %v", err)
+	}
+	defer ag.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := ag.Chat(ctx, "please review hello.txt")
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if result.Text != "review finished" {
+		t.Errorf("Expected final text from parent, got %q", result.Text)
+	}
+
+	if !containsName(offeredTools(t, mp, 0), "task") {
+		t.Errorf("Expected task tool offered to the parent")
+	}
+	child, _ := mp.Request(1)
+	if !strings.HasPrefix(child.System, "You are a reviewer.") || len(child.Messages) != 1 {
+		t.Errorf("Expected reviewer agent with isolated history, got system %q and %d messages", child.System, len(child.Messages))
+	}
+	// Depth 为 1 时子 Agent 不能继续委派
+	if containsName(offeredTools(t, mp, 1), "task") {
+		t.Errorf("Expected no task tool for the subagent at depth limit")
+	}
+
+	parent, _ := mp.Request(2)
+	raw, _ := json.Marshal(parent.Messages[len(parent.Messages)-1].Content)
+	if !strings.Contains(string(raw), "looks good") {
+		t.Errorf("Expected subagent answer in tool result, got %s", raw)
+	}
+}
+
+func TestSubAgent_NestedDelegation(t *testing.T) {
+	mp := provider.NewMockProvider(
+		provider.MockToolStep(provider.MockToolCall{
+			ID:          "call_general",
+			Name:        "task",
+			PartialJSON: []string{`{"description":"investigate hello.txt","subagent_type":"general-purpose"}`},
+		}),
+		provider.MockToolStep(provider.MockToolCall{
+			ID:          "call_review",
+			Name:        "task",
+			PartialJSON: []string{`{"description":"review hello.txt","subagent_type":"reviewer"}`},
+		}),
+		provider.MockTextStep("looks good"),
+		provider.MockTextStep("investigated"),
+		provider.MockTextStep("all done"),
+	)
+	deps := setupTestDeps(t)
+	deps.ProviderFactory = mp
+	registerSubAgentTemplates(deps, 2)
+
+	ag, err := Create(context.Background(), &types.AgentConfig{
+		TemplateID:  "lead",
+		ModelConfig: &types.ModelConfig{Provider: "mock", Model: "mock-model"},
+		Sandbox:     &types.SandboxConfig{Kind: types.SandboxKindMock, WorkDir: "/tmp/test"},
+	}, deps)
+	if err != nil {
+		t.Fatalf("Failed to create agent: %v", err)
+	}
+	defer ag.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := ag.Chat(ctx, "please investigate hello.txt")
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if result.Text != "all done" {
+		t.Errorf("Expected final text from parent, got %q", result.Text)
+	}
+
+	// general-purpose 子 Agent 使用覆盖的提示词,并且可以继续委派给 reviewer
+	general, _ := mp.Request(1)
+	if strings.HasPrefix(general.System, "You are the lead.") || !containsName(offeredTools(t, mp, 1), "task") {
+		t.Errorf("Expected general-purpose agent with its own prompt and task tool, got system %q", general.System)
+	}
+	reviewer, _ := mp.Request(2)
+	if !strings.HasPrefix(reviewer.System, "You are a reviewer.") {
+		t.Errorf("Expected reviewer agent at depth 2, got system %q", reviewer.System)
+	}
+	if containsName(offeredTools(t, mp, 2), "task") {
+		t.Errorf("Expected no task tool at depth limit")
+	}
+
+	if lead, _ := deps.TemplateRegistry.Get("lead"); lead.SystemPrompt != "You are the lead." {
+		t.Errorf("Expected shared template unchanged, got %q", lead.SystemPrompt)
+	}
+}
+
+func TestSubAgentFactory_Limits(t *testing.T) {
+	deps := setupTestDeps(t)
+	deps.ProviderFactory = provider.NewMockProvider()
+	registerSubAgentTemplates(deps, 2)
+
+	parent := &types.AgentConfig{
+		AgentID:     "agt:parent",
+		TemplateID:  "lead",
+		ModelConfig: &types.ModelConfig{Provider: "mock", Model: "mock-model"},
+	}
+	cfg := &types.SubAgentConfig{Depth: 2, Templates: []string{"reviewer"}}
+	factory := NewSubAgentFactory(parent, deps, cfg)
+
+	if _, err := factory(context.Background(), middleware.SubAgentSpec{Name: "reviewer"}); err != nil {
+		t.Errorf("Expected reviewer template to be allowed: %v", err)
+	}
+	if _, err := factory(context.Background(), middleware.SubAgentSpec{Name: "general-purpose", Prompt: "p"}); err != nil {
+		t.Errorf("Expected parent template to be allowed: %v", err)
+	}
+	spec := middleware.SubAgentSpec{Name: "other", Config: map[string]interface{}{"template": "test-template"}}
+	if _, err := factory(context.Background(), spec); err == nil {
+		t.Error("Expected error for template outside SubAgentConfig.Templates")
+	}
+
+	parent.Metadata = map[string]interface{}{MetadataSubAgentDepth: 2}
+	if _, err := factory(context.Background(), middleware.SubAgentSpec{Name: "reviewer"}); err == nil {
+		t.Error("Expected error at depth limit")
+	}
+}
@@ -0,0 +1,474 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+
+	"github.com/wordflowlab/agentsdk/pkg/middleware"
+	"github.com/wordflowlab/agentsdk/pkg/tools"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// templateReloadDelay 文件变更后重新加载前的等待时间,合并编辑器的连续写入
+const templateReloadDelay = 100 * time.Millisecond
+
+// TemplateLoadOptions 从目录加载模板的选项
+type TemplateLoadOptions struct {
+	// ToolRegistry 非空时校验模板的工具均已注册
+	ToolRegistry *tools.Registry
+	// MiddlewareRegistry 非空时校验模板的中间件均已注册
+	MiddlewareRegistry *middleware.Registry
+	// Watch 监听目录变更并自动重新加载
+	Watch bool
+}
+
+// templateFile 从文件读取的模板原始数据
+type templateFile struct {
+	file    string
+	id      string
+	version string
+	extends string
+	data    map[string]interface{}
+}
+
+// NewFileTemplateRegistry 创建从目录加载模板的注册表
+// 目录下每个 .yaml/.yml/.json 文件定义一个模板,字段与 AgentTemplateDefinition 相同,
+// 另外可以用 extends: "<id>" 或 "<id>@<version>" 继承其他模板
+func NewFileTemplateRegistry(dir string, opts *TemplateLoadOptions) (*TemplateRegistry, error) {
+	if opts == nil {
+		opts = &TemplateLoadOptions{}
+	}
+	tr := NewTemplateRegistry()
+	tr.dir = dir
+	tr.opts = opts
+
+	if err := tr.Reload(); err != nil {
+		return nil, err
+	}
+	if opts.Watch {
+		if err := tr.watch(); err != nil {
+			return nil, err
+		}
+	}
+	return tr, nil
+}
+
+// Reload 重新加载目录下的模板
+// 任一模板无效,或与代码注册的模板 ID 和版本相同时返回错误并保留已加载的模板;代码注册的模板不受影响
+func (tr *TemplateRegistry) Reload() error {
+	if tr.dir == "" {
+		return nil
+	}
+
+	files, err := readTemplateFiles(tr.dir)
+	if err != nil {
+		return err
+	}
+
+	byKey := make(map[string]*templateFile, len(files))
+	var errs []error
+	for _, f := range files {
+		key := templateKey(f.id, f.version)
+		if prev, ok := byKey[key]; ok {
+			errs = append(errs, fmt.Errorf("template %s defined in both %s and %s", key, prev.file, f.file))
+			continue
+		}
+		byKey[key] = f
+	}
+
+	entries := make([]*templateEntry, 0, len(files))
+	for _, f := range files {
+		if byKey[templateKey(f.id, f.version)] != f {
+			continue
+		}
+		template, err := tr.resolveTemplateFile(f, files, nil)
+		if err == nil {
+			err = tr.validateTemplate(template)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.file, err))
+			continue
+		}
+		entries = append(entries, &templateEntry{template: template, file: f.file})
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("load templates from %s: %w", tr.dir, errors.Join(errs...))
+	}
+
+	tr.mu.Lock()
+	for _, entry := range entries {
+		if existing := tr.templates[entry.template.ID][entry.template.Version]; existing != nil && existing.file == "" {
+			errs = append(errs, fmt.Errorf("%s: template %s is already registered in code", entry.file, templateKey(entry.template.ID, entry.template.Version)))
+		}
+	}
+	if len(errs) > 0 {
+		tr.mu.Unlock()
+		return fmt.Errorf("load templates from %s: %w", tr.dir, errors.Join(errs...))
+	}
+	for id, versions := range tr.templates {
+		for version, entry := range versions {
+			if entry.file != "" {
+				delete(versions, version)
+			}
+		}
+		if len(versions) == 0 {
+			delete(tr.templates, id)
+		}
+	}
+	for _, entry := range entries {
+		tr.put(entry)
+	}
+	tr.mu.Unlock()
+
+	log.Printf("[TemplateRegistry] Loaded %d templates from %s", len(entries), tr.dir)
+	return nil
+}
+
+// readTemplateFiles 读取目录下的模板文件
+func readTemplateFiles(dir string) ([]*templateFile, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read template dir: %w", err)
+	}
+
+	var files []*templateFile
+	var errs []error
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || !isTemplateFile(dirEntry.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, dirEntry.Name())
+		f, err := parseTemplateFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		files = append(files, f)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("load templates from %s: %w", dir, errors.Join(errs...))
+	}
+	return files, nil
+}
+
+// isTemplateFile 是否是模板文件
+func isTemplateFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// parseTemplateFile 解析模板文件
+func parseTemplateFile(path string) (*templateFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+
+	var data map[string]interface{}
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		err = json.Unmarshal(content, &data)
+	} else {
+		err = yaml.Unmarshal(content, &data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+	if data == nil {
+		return nil, fmt.Errorf("empty template")
+	}
+
+	f := &templateFile{file: path, data: data}
+	var ok bool
+	if f.id, ok = data["id"].(string); !ok || f.id == "" {
+		return nil, fmt.Errorf("template id is required")
+	}
+	if version, exists := data["version"]; exists {
+		// YAML 中未加引号的版本号(如 1.2)会被解析为数字
+		f.version = fmt.Sprint(version)
+		data["version"] = f.version
+	}
+	if extends, exists := data["extends"]; exists {
+		if f.extends, ok = extends.(string); !ok {
+			return nil, fmt.Errorf("extends must be a string")
+		}
+		delete(data, "extends")
+	}
+	return f, nil
+}
+
+// resolveTemplateFile 合并继承链并解码为模板定义
+func (tr *TemplateRegistry) resolveTemplateFile(f *templateFile, files []*templateFile, chain []string) (*types.AgentTemplateDefinition, error) {
+	data, err := tr.resolveTemplateData(f, files, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("encode template: %w", err)
+	}
+	var template types.AgentTemplateDefinition
+	if err := json.Unmarshal(raw, &template); err != nil {
+		return nil, fmt.Errorf("decode template: %w", err)
+	}
+	if template.SystemPrompt == "" {
+		return nil, fmt.Errorf("template %s: system_prompt is required", f.id)
+	}
+	return &template, nil
+}
+
+// resolveTemplateData 返回合并了基础模板的数据,子模板的字段覆盖基础模板
+func (tr *TemplateRegistry) resolveTemplateData(f *templateFile, files []*templateFile, chain []string) (map[string]interface{}, error) {
+	key := templateKey(f.id, f.version)
+	for _, k := range chain {
+		if k == key {
+			return nil, fmt.Errorf("circular extends: %s -> %s", strings.Join(chain, " -> "), key)
+		}
+	}
+	if f.extends == "" {
+		return f.data, nil
+	}
+	chain = append(chain, key)
+
+	baseID, baseVersion, _ := strings.Cut(f.extends, "@")
+	var base map[string]interface{}
+	if baseFile := findTemplateFile(files, baseID, baseVersion); baseFile != nil {
+		data, err := tr.resolveTemplateData(baseFile, files, chain)
+		if err != nil {
+			return nil, err
+		}
+		base = data
+	} else {
+		template, err := tr.registeredTemplate(baseID, baseVersion)
+		if err != nil {
+			return nil, fmt.Errorf("extends %s: %w", f.extends, err)
+		}
+		if base, err = templateToMap(template); err != nil {
+			return nil, fmt.Errorf("extends %s: %w", f.extends, err)
+		}
+	}
+
+	merged := mergeTemplateData(base, f.data)
+	merged["id"] = f.id
+	if f.version != "" {
+		merged["version"] = f.version
+	} else {
+		delete(merged, "version")
+	}
+	return merged, nil
+}
+
+// findTemplateFile 在模板文件中查找模板,version 为空或 "latest" 时取最高版本
+func findTemplateFile(files []*templateFile, id, version string) *templateFile {
+	var found *templateFile
+	for _, f := range files {
+		if f.id != id {
+			continue
+		}
+		if version != "" && version != TemplateVersionLatest {
+			if f.version == version {
+				return f
+			}
+			continue
+		}
+		if found == nil || compareVersions(f.version, found.version) > 0 {
+			found = f
+		}
+	}
+	return found
+}
+
+// registeredTemplate 查找代码注册的模板
+func (tr *TemplateRegistry) registeredTemplate(id, version string) (*types.AgentTemplateDefinition, error) {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+
+	versions := tr.templates[id]
+	var found *templateEntry
+	for v, entry := range versions {
+		if entry.file != "" {
+			continue
+		}
+		if version != "" && version != TemplateVersionLatest {
+			if v == version {
+				found = entry
+				break
+			}
+			continue
+		}
+		if found == nil || compareVersions(v, found.template.Version) > 0 {
+			found = entry
+		}
+	}
+	if found == nil {
+		return nil, &TemplateNotFoundError{ID: id, Version: version}
+	}
+	return found.template, nil
+}
+
+// templateToMap 将模板转换为与模板文件相同结构的数据
+func templateToMap(template *types.AgentTemplateDefinition) (map[string]interface{}, error) {
+	raw, err := json.Marshal(template)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// mergeTemplateData 深度合并模板数据,对象逐字段合并,其他值(包括列表)由 override 替换
+func mergeTemplateData(base, override map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		baseMap, baseOK := merged[k].(map[string]interface{})
+		overrideMap, overrideOK := v.(map[string]interface{})
+		if baseOK && overrideOK {
+			merged[k] = mergeTemplateData(baseMap, overrideMap)
+			continue
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// validateTemplate 校验模板引用的工具和中间件均已注册
+func (tr *TemplateRegistry) validateTemplate(template *types.AgentTemplateDefinition) error {
+	var errs []error
+	if tr.opts.ToolRegistry != nil {
+		names, err := templateToolNames(template.Tools)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, name := range names {
+			if !tr.opts.ToolRegistry.Has(name) {
+				errs = append(errs, fmt.Errorf("unknown tool: %s", name))
+			}
+		}
+	}
+	if tr.opts.MiddlewareRegistry != nil {
+		for _, name := range template.Middlewares {
+			if !tr.opts.MiddlewareRegistry.Has(name) {
+				errs = append(errs, fmt.Errorf("unknown middleware: %s", name))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("template %s: %w", templateKey(template.ID, template.Version), errors.Join(errs...))
+	}
+	return nil
+}
+
+// templateToolNames 返回模板声明的工具名,"*" 表示全部工具,不需要校验
+func templateToolNames(toolsDef interface{}) ([]string, error) {
+	switch v := toolsDef.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if v == "*" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("tools must be a list or \"*\", got %q", v)
+	case []string:
+		return v, nil
+	case []interface{}:
+		names := make([]string, 0, len(v))
+		for _, item := range v {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("tool name must be a string, got %v", item)
+			}
+			names = append(names, name)
+		}
+		return names, nil
+	}
+	return nil, fmt.Errorf("tools must be a list or \"*\"")
+}
+
+// templateKey 模板的 id@version 标识
+func templateKey(id, version string) string {
+	if version == "" {
+		return id
+	}
+	return id + "@" + version
+}
+
+// watch 监听模板目录,文件变更后重新加载
+func (tr *TemplateRegistry) watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("create template watcher: %w", err)
+	}
+	if err := watcher.Add(tr.dir); err != nil {
+		watcher.Close()
+		return fmt.Errorf("watch template dir: %w", err)
+	}
+	tr.watcher = watcher
+	tr.done = make(chan struct{})
+
+	go tr.watchLoop(watcher, tr.done)
+	return nil
+}
+
+// watchLoop 模板目录监听循环
+func (tr *TemplateRegistry) watchLoop(watcher *fsnotify.Watcher, done chan struct{}) {
+	defer watcher.Close()
+
+	timer := time.NewTimer(templateReloadDelay)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if !isTemplateFile(event.Name) || event.Op == fsnotify.Chmod {
+				continue
+			}
+			timer.Reset(templateReloadDelay)
+		case <-timer.C:
+			if err := tr.Reload(); err != nil {
+				log.Printf("[TemplateRegistry] Reload failed, keeping previous templates: %v", err)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("[TemplateRegistry] Watch error: %v", err)
+		case <-done:
+			return
+		}
+	}
+}
+
+// Close 停止监听模板目录
+func (tr *TemplateRegistry) Close() error {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	if tr.done != nil {
+		close(tr.done)
+		tr.done = nil
+		tr.watcher = nil
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package agent
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// TemplateVersionLatest 取最高版本的模板
+const TemplateVersionLatest = "latest"
+
+// TemplateRegistry 模板注册表
+// 同一 ID 可以注册多个版本(AgentTemplateDefinition.Version);从目录加载的模板见 NewFileTemplateRegistry
+type TemplateRegistry struct {
+	mu        sync.RWMutex
+	templates map[string]map[string]*templateEntry // id -> version -> 模板
+
+	// 从目录加载(NewFileTemplateRegistry)
+	dir     string
+	opts    *TemplateLoadOptions
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+// templateEntry 注册的模板及其来源
+type templateEntry struct {
+	template *types.AgentTemplateDefinition
+	file     string // 从目录加载时的文件路径,代码注册时为空
+}
+
+// NewTemplateRegistry 创建模板注册表
+func NewTemplateRegistry() *TemplateRegistry {
+	return &TemplateRegistry{
+		templates: make(map[string]map[string]*templateEntry),
+	}
+}
+
+// Register 注册模板,同一 ID 和版本的模板会被替换
+func (tr *TemplateRegistry) Register(template *types.AgentTemplateDefinition) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.put(&templateEntry{template: template})
+}
+
+// put 添加模板
+// 调用方需持有锁
+func (tr *TemplateRegistry) put(entry *templateEntry) {
+	versions, ok := tr.templates[entry.template.ID]
+	if !ok {
+		versions = make(map[string]*templateEntry)
+		tr.templates[entry.template.ID] = versions
+	}
+	versions[entry.template.Version] = entry
+}
+
+// Get 获取模板的最高版本
+func (tr *TemplateRegistry) Get(id string) (*types.AgentTemplateDefinition, error) {
+	return tr.GetVersion(id, TemplateVersionLatest)
+}
+
+// GetVersion 获取模板的指定版本,version 为空或 "latest" 时取最高版本
+func (tr *TemplateRegistry) GetVersion(id, version string) (*types.AgentTemplateDefinition, error) {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+
+	entry := tr.lookup(id, version)
+	if entry == nil {
+		return nil, &TemplateNotFoundError{ID: id, Version: version}
+	}
+	return entry.template, nil
+}
+
+// lookup 查找模板
+// 调用方需持有锁
+func (tr *TemplateRegistry) lookup(id, version string) *templateEntry {
+	versions := tr.templates[id]
+	if version != "" && version != TemplateVersionLatest {
+		return versions[version]
+	}
+	var latest *templateEntry
+	for v, entry := range versions {
+		if latest == nil || compareVersions(v, latest.template.Version) > 0 {
+			latest = entry
+		}
+	}
+	return latest
+}
+
+// Versions 返回模板已注册的版本,从低到高排列
+func (tr *TemplateRegistry) Versions(id string) []string {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+
+	versions := make([]string, 0, len(tr.templates[id]))
+	for v := range tr.templates[id] {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return compareVersions(versions[i], versions[j]) < 0
+	})
+	return versions
+}
+
+// List 列出所有模板(每个 ID 的最高版本)
+func (tr *TemplateRegistry) List() []*types.AgentTemplateDefinition {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+
+	templates := make([]*types.AgentTemplateDefinition, 0, len(tr.templates))
+	for id := range tr.templates {
+		templates = append(templates, tr.lookup(id, TemplateVersionLatest).template)
+	}
+	return templates
+}
+
+// compareVersions 比较版本号,按点分隔的各段依次比较(数字段按数值),忽略前缀 "v"
+// 空版本最低
+func compareVersions(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return -1
+	}
+	if b == "" {
+		return 1
+	}
+
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		if i >= len(as) {
+			return -1
+		}
+		if i >= len(bs) {
+			return 1
+		}
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case as[i] != bs[i]:
+			if as[i] < bs[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// TemplateNotFoundError 模板未找到错误
+type TemplateNotFoundError struct {
+	ID      string
+	Version string
+}
+
+func (e *TemplateNotFoundError) Error() string {
+	if e.Version != "" && e.Version != TemplateVersionLatest {
+		return "template not found: " + e.ID + "@" + e.Version
+	}
+	return "template not found: " + e.ID
+}
@@ -0,0 +1,253 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/middleware"
+	"github.com/wordflowlab/agentsdk/pkg/provider"
+	"github.com/wordflowlab/agentsdk/pkg/tools"
+	"github.com/wordflowlab/agentsdk/pkg/tools/builtin"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// writeTemplateFile 写入模板文件
+func writeTemplateFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write template file: %v", err)
+	}
+}
+
+func TestTemplateRegistry_Versions(t *testing.T) {
+	tr := NewTemplateRegistry()
+	for _, version := range []string{"1.2", "1.10", "", "1.9"} {
+		tr.Register(&types.AgentTemplateDefinition{ID: "coder", Version: version, SystemPrompt: "v" + version})
+	}
+
+	if versions := tr.Versions("coder"); strings.Join(versions, ",") != ",1.2,1.9,1.10" {
+		t.Errorf("Unexpected version order: %v", versions)
+	}
+
+	latest, err := tr.Get("coder")
+	if err != nil {
+		t.Fatalf("Failed to get template: %v", err)
+	}
+	if latest.Version != "1.10" {
+		t.Errorf("Expected latest version 1.10, got %q", latest.Version)
+	}
+
+	exact, err := tr.GetVersion("coder", "1.2")
+	if err != nil {
+		t.Fatalf("Failed to get template version: %v", err)
+	}
+	if exact.SystemPrompt != "v1.2" {
+		t.Errorf("Expected version 1.2, got %+v", exact)
+	}
+
+	if _, err := tr.GetVersion("coder", "2.0"); err == nil {
+		t.Error("Expected error for unknown version")
+	}
+	if len(tr.List()) != 1 {
+		t.Errorf("Expected one template in list, got %d", len(tr.List()))
+	}
+}
+
+func TestFileTemplateRegistry_LoadAndExtends(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplateFile(t, dir, "base.yaml", `
+id: base
+version: "1.0"
+system_prompt: You are a helpful assistant.
+model: claude-sonnet-4-5
+tools: [fs_read]
+runtime:
+  todo:
+    enabled: true
+    remind_interval_steps: 5
+`)
+	writeTemplateFile(t, dir, "coder.json", `{
+  "id": "coder",
+  "version": "2.0",
+  "extends": "base",
+  "system_prompt": "You are a coding assistant.",
+  "tools": ["fs_read", "fs_write"],
+  "middlewares": ["todolist"],
+  "runtime": {"todo": {"remind_interval_steps": 10}}
+}`)
+	writeTemplateFile(t, dir, "README.md", "not a template")
+
+	tr, err := NewFileTemplateRegistry(dir, &TemplateLoadOptions{
+		ToolRegistry:       newBuiltinToolRegistry(),
+		MiddlewareRegistry: middleware.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+
+	coder, err := tr.GetVersion("coder", "2.0")
+	if err != nil {
+		t.Fatalf("Failed to get template: %v", err)
+	}
+	if coder.SystemPrompt != "You are a coding assistant." || coder.Model != "claude-sonnet-4-5" {
+		t.Errorf("Expected prompt overridden and model inherited, got %+v", coder)
+	}
+	if len(coder.Middlewares) != 1 || coder.Middlewares[0] != "todolist" {
+		t.Errorf("Expected middlewares [todolist], got %v", coder.Middlewares)
+	}
+	if todo := coder.Runtime.Todo; todo == nil || !todo.Enabled || todo.RemindIntervalSteps != 10 {
+		t.Errorf("Expected runtime merged with base, got %+v", coder.Runtime.Todo)
+	}
+	if base, _ := tr.Get("base"); base.Version != "1.0" || base.Model != "claude-sonnet-4-5" {
+		t.Errorf("Expected base template unchanged, got %+v", base)
+	}
+}
+
+func TestFileTemplateRegistry_Validation(t *testing.T) {
+	opts := &TemplateLoadOptions{
+		ToolRegistry:       newBuiltinToolRegistry(),
+		MiddlewareRegistry: middleware.NewRegistry(),
+	}
+
+	cases := map[string]string{
+		"unknown tool":       "id: a\nsystem_prompt: p\ntools: [no_such_tool]\n",
+		"unknown middleware": "id: a\nsystem_prompt: p\nmiddlewares: [no_such_middleware]\n",
+		"missing prompt":     "id: a\n",
+		"missing base":       "id: a\nextends: nope\nsystem_prompt: p\n",
+		"circular extends":   "id: a\nextends: a\nsystem_prompt: p\n",
+	}
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTemplateFile(t, dir, "a.yaml", content)
+			if _, err := NewFileTemplateRegistry(dir, opts); err == nil {
+				t.Errorf("Expected error for %s", name)
+			}
+		})
+	}
+}
+
+func TestFileTemplateRegistry_HotReload(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplateFile(t, dir, "assistant.yaml", "id: assistant\nsystem_prompt: v1\n")
+
+	tr, err := NewFileTemplateRegistry(dir, &TemplateLoadOptions{Watch: true})
+	if err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+	defer tr.Close()
+
+	tr.Register(&types.AgentTemplateDefinition{ID: "code", SystemPrompt: "registered in code"})
+
+	// 无效的修改不影响已加载的模板
+	writeTemplateFile(t, dir, "assistant.yaml", "id: assistant\n")
+	time.Sleep(5 * templateReloadDelay)
+	if template, _ := tr.Get("assistant"); template == nil || template.SystemPrompt != "v1" {
+		t.Fatalf("Expected previous template to be kept, got %+v", template)
+	}
+
+	writeTemplateFile(t, dir, "assistant.yaml", "id: assistant\nsystem_prompt: v2\n")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		template, _ := tr.Get("assistant")
+		if template != nil && template.SystemPrompt == "v2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Template was not reloaded, got %+v", template)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if _, err := tr.Get("code"); err != nil {
+		t.Errorf("Expected code-registered template to survive reload: %v", err)
+	}
+}
+
+func TestFileTemplateRegistry_CodeCollision(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplateFile(t, dir, "assistant.yaml", "id: assistant\nversion: \"1.0\"\nsystem_prompt: from file\n")
+
+	tr, err := NewFileTemplateRegistry(dir, nil)
+	if err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+	tr.Register(&types.AgentTemplateDefinition{ID: "coder", Version: "1.0", SystemPrompt: "registered in code"})
+
+	// 与代码注册的模板同 ID 同版本的文件被拒绝,代码注册的模板保持不变
+	writeTemplateFile(t, dir, "coder.yaml", "id: coder\nversion: \"1.0\"\nsystem_prompt: from file\n")
+	if err := tr.Reload(); err == nil || !strings.Contains(err.Error(), "already registered in code") {
+		t.Fatalf("Expected collision error, got %v", err)
+	}
+	if template, _ := tr.GetVersion("coder", "1.0"); template == nil || template.SystemPrompt != "registered in code" {
+		t.Errorf("Expected code-registered template to be kept, got %+v", template)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "coder.yaml")); err != nil {
+		t.Fatalf("Failed to remove template file: %v", err)
+	}
+	if err := tr.Reload(); err != nil {
+		t.Fatalf("Failed to reload templates: %v", err)
+	}
+	if template, _ := tr.GetVersion("coder", "1.0"); template == nil || template.SystemPrompt != "registered in code" {
+		t.Errorf("Expected code-registered template to survive reload, got %+v", template)
+	}
+	if _, err := tr.Get("assistant"); err != nil {
+		t.Errorf("Expected file template to be loaded: %v", err)
+	}
+}
+
+func TestAgentCreate_TemplateVersion(t *testing.T) {
+	deps := setupTestDeps(t)
+	deps.ProviderFactory = provider.NewMockProvider()
+	for _, version := range []string{"1.0", "2.0"} {
+		deps.TemplateRegistry.Register(&types.AgentTemplateDefinition{
+			ID:           "versioned",
+			Version:      version,
+			SystemPrompt: "You are version " + version,
+			Tools:        []interface{}{"fs_read"},
+		})
+	}
+
+	create := func(version string) (*Agent, error) {
+		ag, err := Create(context.Background(), &types.AgentConfig{
+			TemplateID:      "versioned",
+			TemplateVersion: version,
+			ModelConfig:     &types.ModelConfig{Provider: "mock", Model: "mock-model"},
+			Sandbox:         &types.SandboxConfig{Kind: types.SandboxKindMock, WorkDir: "/tmp/test"},
+		}, deps)
+		if err == nil {
+			t.Cleanup(func() { ag.Close() })
+		}
+		return ag, err
+	}
+
+	for version, expected := range map[string]string{
+		"":                    "2.0",
+		TemplateVersionLatest: "2.0",
+		"1.0":                 "1.0",
+	} {
+		ag, err := create(version)
+		if err != nil {
+			t.Fatalf("Failed to create agent: %v", err)
+		}
+		if ag.template.Version != expected || !strings.HasPrefix(ag.template.SystemPrompt, "You are version "+expected) {
+			t.Errorf("TemplateVersion %q: expected version %s, got %s", version, expected, ag.template.Version)
+		}
+	}
+
+	if _, err := create("9.9"); err == nil {
+		t.Error("Expected error for unknown template version")
+	}
+}
+
+// newBuiltinToolRegistry 注册了内置工具的工具注册表
+func newBuiltinToolRegistry() *tools.Registry {
+	toolRegistry := tools.NewRegistry()
+	builtin.RegisterAll(toolRegistry)
+	return toolRegistry
+}
@@ -0,0 +1,92 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/tools"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// AddTool 向 Agent 添加工具,同名工具会被替换
+// 执行中调用时从下一次模型调用开始生效
+func (a *Agent) AddTool(tool tools.Tool) {
+	a.mu.Lock()
+	a.toolMap[tool.Name()] = tool
+	a.mu.Unlock()
+
+	log.Printf("[Agent] Tool %s added to agent %s", tool.Name(), a.id)
+	a.refreshToolManual()
+}
+
+// RemoveTool 从 Agent 移除工具
+func (a *Agent) RemoveTool(name string) error {
+	a.mu.Lock()
+	if _, ok := a.toolMap[name]; !ok {
+		a.mu.Unlock()
+		return fmt.Errorf("tool not found: %s", name)
+	}
+	delete(a.toolMap, name)
+	a.mu.Unlock()
+
+	log.Printf("[Agent] Tool %s removed from agent %s", name, a.id)
+	a.refreshToolManual()
+	return nil
+}
+
+// SetTools 用 toolList 替换 Agent 的全部工具
+// 中间件提供的工具保留,与 toolList 同名时使用 toolList 中的工具
+func (a *Agent) SetTools(toolList []tools.Tool) {
+	toolMap := make(map[string]tools.Tool, len(toolList))
+	if a.middlewareStack != nil {
+		for _, tool := range a.middlewareStack.Tools() {
+			toolMap[tool.Name()] = tool
+		}
+	}
+	for _, tool := range toolList {
+		toolMap[tool.Name()] = tool
+	}
+
+	a.mu.Lock()
+	a.toolMap = toolMap
+	a.mu.Unlock()
+
+	log.Printf("[Agent] Tools of agent %s replaced (%d tools)", a.id, len(toolMap))
+	a.refreshToolManual()
+}
+
+// ToolNames 返回 Agent 当前可用的工具名,按名称排序
+func (a *Agent) ToolNames() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.sortedToolNames()
+}
+
+// refreshToolManual 工具变化后重新生成系统提示词中的工具手册,并发出事件
+func (a *Agent) refreshToolManual() {
+	a.injectToolManual()
+	a.emitMonitor(&types.MonitorToolManualUpdatedEvent{
+		Tools:     a.ToolNames(),
+		Timestamp: time.Now(),
+	})
+}
+
+// lookupTool 按名称查找工具
+func (a *Agent) lookupTool(name string) (tools.Tool, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	tool, ok := a.toolMap[name]
+	return tool, ok
+}
+
+// currentTools 返回按名称排序的工具列表
+func (a *Agent) currentTools() []tools.Tool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	list := make([]tools.Tool, 0, len(a.toolMap))
+	for _, name := range a.sortedToolNames() {
+		list = append(list, a.toolMap[name])
+	}
+	return list
+}
@@ -0,0 +1,140 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/provider"
+	"github.com/wordflowlab/agentsdk/pkg/tools"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// echoTool 带工具手册的测试工具
+type echoTool struct{}
+
+func (t *echoTool) Name() string        { return "echo" }
+func (t *echoTool) Description() string { return "echo the input" }
+func (t *echoTool) Prompt() string      { return "Use echo to repeat text." }
+func (t *echoTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "object"}
+}
+
+func (t *echoTool) Execute(ctx context.Context, input map[string]interface{}, tc *tools.ToolContext) (interface{}, error) {
+	return input, nil
+}
+
+// offeredTools 返回第 i 次模型请求中的工具名
+func offeredTools(t *testing.T, mp *provider.MockProvider, i int) []string {
+	t.Helper()
+	req, ok := mp.Request(i)
+	if !ok {
+		t.Fatalf("Missing request %d", i)
+	}
+	names := make([]string, 0, len(req.Options.Tools))
+	for _, schema := range req.Options.Tools {
+		names = append(names, schema.Name)
+	}
+	return names
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAgentAddRemoveTool(t *testing.T) {
+	mp := provider.NewMockProvider(provider.MockTextStep("one"), provider.MockTextStep("two"), provider.MockTextStep("three"))
+	ag := newMockAgent(t, mp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := ag.Chat(ctx, "first"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if containsName(offeredTools(t, mp, 0), "echo") {
+		t.Fatalf("echo should not be offered before AddTool")
+	}
+
+	ag.AddTool(&echoTool{})
+	if _, err := ag.Chat(ctx, "second"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if !containsName(offeredTools(t, mp, 1), "echo") {
+		t.Errorf("Expected echo in tool schemas after AddTool")
+	}
+	req, _ := mp.Request(1)
+	if !strings.Contains(req.System, "**echo**") {
+		t.Errorf("Expected echo manual in system prompt")
+	}
+
+	if err := ag.RemoveTool("echo"); err != nil {
+		t.Fatalf("RemoveTool failed: %v", err)
+	}
+	if err := ag.RemoveTool("echo"); err == nil {
+		t.Errorf("Expected error when removing a missing tool")
+	}
+	if _, err := ag.Chat(ctx, "third"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	req, _ = mp.Request(2)
+	if containsName(offeredTools(t, mp, 2), "echo") || strings.Contains(req.System, "**echo**") {
+		t.Errorf("echo should be gone after RemoveTool")
+	}
+
+	var updates int
+	for _, envelope := range ag.eventBus.GetTimeline() {
+		if e, ok := envelope.Event.(*types.MonitorToolManualUpdatedEvent); ok {
+			updates++
+			if updates == 1 && !containsName(e.Tools, "echo") {
+				t.Errorf("Expected echo in first manual update, got %v", e.Tools)
+			}
+		}
+	}
+	if updates != 2 {
+		t.Errorf("Expected 2 tool manual updates, got %d", updates)
+	}
+}
+
+func TestAgentSetTools_KeepsMiddlewareTools(t *testing.T) {
+	mp := provider.NewMockProvider(provider.MockTextStep("one"), provider.MockTextStep("two"))
+	ag := newMockAgent(t, mp, func(config *types.AgentConfig) {
+		config.Middlewares = []string{"todolist"}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 中间件提供的工具与配置的工具一起提供给模型
+	if _, err := ag.Chat(ctx, "plan"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	names := offeredTools(t, mp, 0)
+	if !containsName(names, "write_todos") || !containsName(names, "fs_read") {
+		t.Fatalf("Expected write_todos and fs_read to be offered, got %v", names)
+	}
+
+	ag.SetTools([]tools.Tool{&echoTool{}})
+	if _, err := ag.Chat(ctx, "again"); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	names = offeredTools(t, mp, 1)
+	if len(names) != 2 || names[0] != "echo" || names[1] != "write_todos" {
+		t.Errorf("Expected [echo write_todos], got %v", names)
+	}
+
+	// 工具手册只写入本 Agent 的模板副本
+	template, err := ag.deps.TemplateRegistry.Get("test-template")
+	if err != nil {
+		t.Fatalf("Failed to get template: %v", err)
+	}
+	if strings.Contains(template.SystemPrompt, "### Tools Manual") {
+		t.Errorf("Registry template should not be modified")
+	}
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
@@ -100,8 +101,14 @@ func (p *Pool) Status(agentID string) (*types.AgentStatus, error) {
 	return ag.Status(), nil
 }
 
-// Resume 从存储中恢复 Agent
+// Resume 从存储恢复 Agent,使用默认恢复选项(crash 策略,不自动继续执行)
 func (p *Pool) Resume(ctx context.Context, agentID string, config *types.AgentConfig) (*agent.Agent, error) {
+	return p.ResumeWithOptions(ctx, agentID, config, nil)
+}
+
+// ResumeWithOptions 按恢复选项从存储恢复 Agent
+// crash 策略会封口进程中断时未完成的工具调用,AutoRun 时继续执行未完成的一轮
+func (p *Pool) ResumeWithOptions(ctx context.Context, agentID string, config *types.AgentConfig, opts *types.ResumeOptions) (*agent.Agent, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -116,16 +123,16 @@ func (p *Pool) Resume(ctx context.Context, agentID string, config *types.AgentCo
 	}
 
 	// 3. 检查存储中是否存在
-	_, err := p.deps.Store.LoadMessages(ctx, agentID)
-	if err != nil {
+	info, err := p.deps.Store.LoadInfo(ctx, agentID)
+	if err != nil || info == nil || info.AgentID == "" {
 		return nil, fmt.Errorf("agent not found in store: %s", agentID)
 	}
 
 	// 4. 设置 AgentID
 	config.AgentID = agentID
 
-	// 5. 创建 Agent (会自动加载状态)
-	ag, err := agent.Create(ctx, config, p.deps)
+	// 5. 创建 Agent (会自动加载状态并封口未完成的工具调用)
+	ag, err := agent.Resume(ctx, config, p.deps, opts)
 	if err != nil {
 		return nil, fmt.Errorf("resume agent: %w", err)
 	}
@@ -135,15 +142,75 @@ func (p *Pool) Resume(ctx context.Context, agentID string, config *types.AgentCo
 	return ag, nil
 }
 
-// ResumeAll 恢复所有存储的 Agent
+// ResumeAll 恢复所有存储的 Agent,使用默认恢复选项
+// configFactory 为每个 Agent 提供配置,返回 nil 时跳过该 Agent;单个 Agent 恢复失败不影响其它 Agent
 func (p *Pool) ResumeAll(ctx context.Context, configFactory func(agentID string) *types.AgentConfig) ([]*agent.Agent, error) {
-	// 获取所有 Agent ID (需要 Store 实现 List 方法)
-	// 这里简化实现,假设外部提供 ID 列表
-	// 实际应该从 Store.ListAgents() 获取
+	return p.ResumeAllWithOptions(ctx, configFactory, nil)
+}
+
+// ResumeAllWithOptions 按恢复选项恢复所有存储的 Agent
+func (p *Pool) ResumeAllWithOptions(ctx context.Context, configFactory func(agentID string) *types.AgentConfig, opts *types.ResumeOptions) ([]*agent.Agent, error) {
+	agentIDs, err := p.deps.Store.ListAgents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list agents: %w", err)
+	}
+
+	resumed := make([]*agent.Agent, 0, len(agentIDs))
+	var errs []error
+	for _, agentID := range agentIDs {
+		config := configFactory(agentID)
+		if config == nil {
+			continue
+		}
+
+		ag, err := p.ResumeWithOptions(ctx, agentID, config, opts)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("resume %s: %w", agentID, err))
+			continue
+		}
+		resumed = append(resumed, ag)
+	}
+
+	return resumed, errors.Join(errs...)
+}
+
+// Fork 从池中 Agent 的快照分叉出新 Agent 并加入池
+// snapshotID 为空时先对源 Agent 的当前对话创建快照;新 Agent 使用源 Agent 的配置,血缘中记录源 Agent
+func (p *Pool) Fork(ctx context.Context, agentID, snapshotID string) (*agent.Agent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-	resumed := make([]*agent.Agent, 0)
-	// TODO: 实现 Store.ListAgents() 方法
-	return resumed, fmt.Errorf("resumeAll not fully implemented: need Store.ListAgents()")
+	source, exists := p.agents[agentID]
+	if !exists {
+		return nil, fmt.Errorf("agent not found: %s", agentID)
+	}
+	if len(p.agents) >= p.maxAgents {
+		return nil, fmt.Errorf("pool is full (max %d agents)", p.maxAgents)
+	}
+
+	var snapshot *types.Snapshot
+	var err error
+	if snapshotID == "" {
+		snapshot, err = source.Snapshot(ctx, "fork")
+	} else {
+		snapshot, err = p.deps.Store.LoadSnapshot(ctx, agentID, snapshotID)
+		if err == nil && (snapshot == nil || snapshot.ID == "") {
+			err = fmt.Errorf("snapshot not found: %s", snapshotID)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fork agent: %w", err)
+	}
+
+	config := source.Config()
+	config.AgentID = ""
+	ag, err := agent.Fork(ctx, config, p.deps, agentID, snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("fork agent: %w", err)
+	}
+
+	p.agents[ag.ID()] = ag
+	return ag, nil
 }
 
 // Remove 从池中移除 Agent (不删除存储)
@@ -392,9 +392,7 @@ func TestPool_ForEach(t *testing.T) {
 }
 
 // TestPool_Resume 测试恢复 Agent
-// 注意: 这个测试依赖于 Agent 实际保存消息到 Store,在单元测试环境中可能会失败
 func TestPool_Resume(t *testing.T) {
-	t.Skip("Skipping Resume test - requires real agent message persistence")
 	deps := createTestDeps(t)
 	ctx := context.Background()
 
@@ -405,6 +403,8 @@ func TestPool_Resume(t *testing.T) {
 	})
 
 	config := createTestConfig("persistent-agent")
+	config.ModelConfig = &types.ModelConfig{Provider: "mock", Model: "mock-model"}
+	deps.ProviderFactory = provider.NewMockProvider(provider.MockTextStep("Hello!"))
 
 	// 创建 Agent
 	ag1, err := pool1.Create(ctx, config)
@@ -413,13 +413,18 @@ func TestPool_Resume(t *testing.T) {
 	}
 
 	// 发送消息以保存状态
-	err = ag1.Send(ctx, "Test message")
-	if err != nil {
-		t.Logf("Send message warning: %v", err)
+	if err := ag1.Send(ctx, "Test message"); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
 	}
 
-	// 等待保存完成
-	time.Sleep(100 * time.Millisecond)
+	// 等待执行完成
+	deadline := time.Now().Add(5 * time.Second)
+	for ag1.Status().State != types.AgentStateReady || ag1.Status().StepCount == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for agent, state %s", ag1.Status().State)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
 
 	// 关闭第一个池
 	pool1.Shutdown()
@@ -446,4 +451,108 @@ func TestPool_Resume(t *testing.T) {
 	if !exists {
 		t.Error("Resumed agent not found in pool")
 	}
+
+	// 验证消息历史已恢复
+	snapshot, err := ag2.Snapshot(ctx, "resumed")
+	if err != nil {
+		t.Fatalf("Failed to snapshot resumed agent: %v", err)
+	}
+	if len(snapshot.Messages) != 2 {
+		t.Fatalf("Expected 2 resumed messages, got %d", len(snapshot.Messages))
+	}
+	user, _ := snapshot.Messages[0].Content[0].(*types.TextBlock)
+	reply, _ := snapshot.Messages[1].Content[0].(*types.TextBlock)
+	if user == nil || user.Text != "Test message" || reply == nil || reply.Text != "Hello!" {
+		t.Errorf("Unexpected resumed history: %+v", snapshot.Messages)
+	}
+}
+
+// TestPool_ResumeNotFound 测试恢复不存在的 Agent
+func TestPool_ResumeNotFound(t *testing.T) {
+	pool := NewPool(&PoolOptions{
+		Dependencies: createTestDeps(t),
+		MaxAgents:    10,
+	})
+	defer pool.Shutdown()
+
+	if _, err := pool.Resume(context.Background(), "missing-agent", createTestConfig("missing-agent")); err == nil {
+		t.Error("Expected error when resuming an agent that is not in the store")
+	}
+}
+
+// TestPool_ResumeAll 测试恢复存储中的所有 Agent
+func TestPool_ResumeAll(t *testing.T) {
+	deps := createTestDeps(t)
+	ctx := context.Background()
+
+	pool1 := NewPool(&PoolOptions{
+		Dependencies: deps,
+		MaxAgents:    10,
+	})
+	for _, id := range []string{"agent-a", "agent-b", "agent-c"} {
+		if _, err := pool1.Create(ctx, createTestConfig(id)); err != nil {
+			t.Fatalf("Failed to create agent %s: %v", id, err)
+		}
+	}
+	pool1.Shutdown()
+
+	pool2 := NewPool(&PoolOptions{
+		Dependencies: deps,
+		MaxAgents:    10,
+	})
+	defer pool2.Shutdown()
+
+	// agent-c 不提供配置,跳过恢复
+	resumed, err := pool2.ResumeAll(ctx, func(agentID string) *types.AgentConfig {
+		if agentID == "agent-c" {
+			return nil
+		}
+		return createTestConfig(agentID)
+	})
+	if err != nil {
+		t.Fatalf("Failed to resume agents: %v", err)
+	}
+
+	if len(resumed) != 2 || pool2.Size() != 2 {
+		t.Fatalf("Expected 2 resumed agents, got %d (pool size %d)", len(resumed), pool2.Size())
+	}
+	if _, exists := pool2.Get("agent-c"); exists {
+		t.Error("agent-c should not be resumed")
+	}
+}
+
+// TestPool_Fork 测试从池中 Agent 分叉
+func TestPool_Fork(t *testing.T) {
+	deps := createTestDeps(t)
+	ctx := context.Background()
+
+	pool := NewPool(&PoolOptions{
+		Dependencies: deps,
+		MaxAgents:    10,
+	})
+	defer pool.Shutdown()
+
+	if _, err := pool.Create(ctx, createTestConfig("source-agent")); err != nil {
+		t.Fatalf("Failed to create agent: %v", err)
+	}
+
+	forked, err := pool.Fork(ctx, "source-agent", "")
+	if err != nil {
+		t.Fatalf("Failed to fork agent: %v", err)
+	}
+	if forked.ID() == "source-agent" || pool.Size() != 2 {
+		t.Fatalf("Expected a new agent in the pool, got %s (pool size %d)", forked.ID(), pool.Size())
+	}
+
+	info, err := deps.Store.LoadInfo(ctx, forked.ID())
+	if err != nil {
+		t.Fatalf("Failed to load info: %v", err)
+	}
+	if len(info.Lineage) != 1 || info.Lineage[0] != "source-agent" {
+		t.Errorf("Expected lineage [source-agent], got %v", info.Lineage)
+	}
+
+	if _, err := pool.Fork(ctx, "missing-agent", ""); err == nil {
+		t.Error("Expected error when forking an agent that is not in the pool")
+	}
 }
@@ -409,3 +409,18 @@ func BenchmarkPathValidation_Disabled(b *testing.B) {
 		middleware.validatePath(path)
 	}
 }
+
+// TestFilesystemTools_ConcurrencyKey 测试写类工具对同一路径按顺序执行
+func TestFilesystemTools_ConcurrencyKey(t *testing.T) {
+	m := NewFilesystemMiddleware(&FilesystemMiddlewareConfig{Backend: backends.NewStateBackend()})
+
+	input := map[string]interface{}{"path": "./a.txt"}
+	for _, tool := range m.Tools() {
+		switch tool.Name() {
+		case "fs_write", "fs_edit":
+			if key := tools.ConcurrencyKeyOf(tool, input); key != "path:a.txt" {
+				t.Errorf("Expected %s to serialize on path:a.txt, got %q", tool.Name(), key)
+			}
+		}
+	}
+}
@@ -140,6 +140,11 @@ func (t *FsEditTool) InputSchema() map[string]interface{} {
 	}
 }
 
+// ConcurrencyKey 同一路径的读写按顺序执行
+func (t *FsEditTool) ConcurrencyKey(input map[string]interface{}) string {
+	return tools.PathConcurrencyKey(input)
+}
+
 func (t *FsEditTool) Execute(ctx context.Context, input map[string]interface{}, tc *tools.ToolContext) (interface{}, error) {
 	path, _ := input["path"].(string)
 	oldStr, _ := input["old_string"].(string)
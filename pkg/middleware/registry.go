@@ -75,6 +75,14 @@ func (r *Registry) List() []string {
 	return names
 }
 
+// Has 检查中间件是否已注册
+func (r *Registry) Has(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.factories[name]
+	return ok
+}
+
 // registerBuiltin 注册内置中间件
 func (r *Registry) registerBuiltin() {
 	// Summarization Middleware
@@ -111,6 +119,11 @@ func (r *Registry) registerBuiltin() {
 		})
 	})
 
+	// TodoList Middleware
+	r.Register("todolist", func(config *MiddlewareFactoryConfig) (Middleware, error) {
+		return NewTodoListMiddleware(nil), nil
+	})
+
 	log.Printf("[MiddlewareRegistry] Built-in middlewares registered: %v", r.List())
 }
 
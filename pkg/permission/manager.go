@@ -0,0 +1,421 @@
+// Package permission 工具调用权限管理(白名单/黑名单/审批)
+package permission
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// Decision 权限决策
+type Decision string
+
+const (
+	Allow Decision = "allow" // 允许
+	Deny  Decision = "deny"  // 拒绝
+	Ask   Decision = "ask"   // 需要询问
+)
+
+// Rule 工具权限规则
+type Rule struct {
+	ToolName string
+	Decision Decision
+	Reason   string // 规则说明
+}
+
+// ApprovalFunc 审批函数
+// 返回 (decision, reason, error)
+type ApprovalFunc func(ctx context.Context, call *types.ToolCallRecord) (Decision, string, error)
+
+// Hook 权限钩子
+type Hook struct {
+	PreToolUse  PreToolUseHook  // 工具执行前
+	PostToolUse PostToolUseHook // 工具执行后
+}
+
+// PreToolUseHook 工具执行前钩子
+// 返回 (modified_call, error)
+// 可以修改工具调用参数或返回错误来阻止执行
+type PreToolUseHook func(ctx context.Context, call *types.ToolCallRecord) (*types.ToolCallRecord, error)
+
+// PostToolUseHook 工具执行后钩子
+// 可以检查结果或执行清理工作
+type PostToolUseHook func(ctx context.Context, call *types.ToolCallRecord, result interface{}, err error) error
+
+// Manager 权限管理器
+type Manager struct {
+	mu sync.RWMutex
+
+	// 全局模式
+	defaultMode types.PermissionMode
+
+	// 工具规则
+	rules map[string]*Rule
+
+	// 白名单/黑名单
+	allowList map[string]bool
+	denyList  map[string]bool
+	askList   map[string]bool
+
+	// 审批函数
+	approvalFunc ApprovalFunc
+
+	// Hook
+	hooks []Hook
+
+	// 统计
+	stats Stats
+}
+
+// Stats 权限统计
+type Stats struct {
+	TotalChecks    int64
+	AllowedCount   int64
+	DeniedCount    int64
+	ApprovalCount  int64
+	HookErrorCount int64
+}
+
+// Options 权限管理器配置
+type Options struct {
+	DefaultMode  types.PermissionMode
+	AllowList    []string
+	DenyList     []string
+	AskList      []string
+	ApprovalFunc ApprovalFunc
+}
+
+// NewManager 创建权限管理器
+func NewManager(opts *Options) *Manager {
+	if opts == nil {
+		opts = &Options{
+			DefaultMode: types.PermissionModeAuto,
+		}
+	}
+
+	pm := &Manager{
+		defaultMode:  opts.DefaultMode,
+		rules:        make(map[string]*Rule),
+		allowList:    make(map[string]bool),
+		denyList:     make(map[string]bool),
+		askList:      make(map[string]bool),
+		approvalFunc: opts.ApprovalFunc,
+		hooks:        make([]Hook, 0),
+	}
+
+	// 设置白名单
+	for _, tool := range opts.AllowList {
+		pm.allowList[tool] = true
+	}
+
+	// 设置黑名单
+	for _, tool := range opts.DenyList {
+		pm.denyList[tool] = true
+	}
+
+	// 设置审批列表
+	for _, tool := range opts.AskList {
+		pm.askList[tool] = true
+	}
+
+	return pm
+}
+
+// FromConfig 根据模板或覆盖配置中的权限配置创建权限管理器,config 为空时使用 auto 模式
+func FromConfig(config *types.PermissionConfig) *Manager {
+	if config == nil {
+		return NewManager(nil)
+	}
+
+	mode := config.Mode
+	if mode == "" {
+		mode = types.PermissionModeAuto
+	}
+	return NewManager(&Options{
+		DefaultMode: mode,
+		AllowList:   config.Allow,
+		DenyList:    config.Deny,
+		AskList:     config.Ask,
+	})
+}
+
+// SetRule 设置工具规则
+func (pm *Manager) SetRule(toolName string, decision Decision, reason string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	pm.rules[toolName] = &Rule{
+		ToolName: toolName,
+		Decision: decision,
+		Reason:   reason,
+	}
+}
+
+// RemoveRule 移除工具规则
+func (pm *Manager) RemoveRule(toolName string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	delete(pm.rules, toolName)
+}
+
+// AddHook 添加权限钩子
+func (pm *Manager) AddHook(hook Hook) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	pm.hooks = append(pm.hooks, hook)
+}
+
+// Check 检查工具权限
+func (pm *Manager) Check(ctx context.Context, call *types.ToolCallRecord) (Decision, string, error) {
+	pm.mu.Lock()
+	pm.stats.TotalChecks++
+	pm.mu.Unlock()
+
+	toolName := call.Name
+
+	// 1. 检查黑名单 (优先级最高)
+	pm.mu.RLock()
+	if pm.denyList[toolName] {
+		pm.mu.RUnlock()
+		pm.mu.Lock()
+		pm.stats.DeniedCount++
+		pm.mu.Unlock()
+		return Deny, "tool is in deny list", nil
+	}
+	pm.mu.RUnlock()
+
+	// 2. 检查白名单
+	pm.mu.RLock()
+	if pm.allowList[toolName] {
+		pm.mu.RUnlock()
+		pm.mu.Lock()
+		pm.stats.AllowedCount++
+		pm.mu.Unlock()
+		return Allow, "tool is in allow list", nil
+	}
+	pm.mu.RUnlock()
+
+	// 3. 检查审批列表
+	pm.mu.RLock()
+	if pm.askList[toolName] {
+		pm.mu.RUnlock()
+		pm.mu.Lock()
+		pm.stats.ApprovalCount++
+		pm.mu.Unlock()
+		return Ask, "tool requires approval", nil
+	}
+	pm.mu.RUnlock()
+
+	// 4. 检查工具规则
+	pm.mu.RLock()
+	if rule, exists := pm.rules[toolName]; exists {
+		reason := rule.Reason
+		pm.mu.RUnlock()
+
+		pm.mu.Lock()
+		switch rule.Decision {
+		case Allow:
+			pm.stats.AllowedCount++
+		case Deny:
+			pm.stats.DeniedCount++
+		case Ask:
+			pm.stats.ApprovalCount++
+		}
+		pm.mu.Unlock()
+
+		return rule.Decision, reason, nil
+	}
+	pm.mu.RUnlock()
+
+	// 5. 应用全局模式
+	pm.mu.RLock()
+	mode := pm.defaultMode
+	pm.mu.RUnlock()
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	switch mode {
+	case types.PermissionModeAllow:
+		pm.stats.AllowedCount++
+		return Allow, "default mode: allow", nil
+	case types.PermissionModeApproval:
+		pm.stats.ApprovalCount++
+		return Ask, "default mode: approval", nil
+	case types.PermissionModeAuto:
+		// Auto 模式: 默认允许,但可以通过规则覆盖
+		pm.stats.AllowedCount++
+		return Allow, "default mode: auto (allow)", nil
+	default:
+		pm.stats.AllowedCount++
+		return Allow, "default: allow", nil
+	}
+}
+
+// RequestApproval 请求审批
+func (pm *Manager) RequestApproval(ctx context.Context, call *types.ToolCallRecord) (Decision, string, error) {
+	pm.mu.RLock()
+	approvalFunc := pm.approvalFunc
+	pm.mu.RUnlock()
+
+	if approvalFunc == nil {
+		// 没有审批函数,默认拒绝
+		return Deny, "no approval function configured", nil
+	}
+
+	decision, reason, err := approvalFunc(ctx, call)
+	if err != nil {
+		return Deny, fmt.Sprintf("approval error: %v", err), err
+	}
+
+	return decision, reason, nil
+}
+
+// RunPreHooks 运行前置钩子
+func (pm *Manager) RunPreHooks(ctx context.Context, call *types.ToolCallRecord) (*types.ToolCallRecord, error) {
+	pm.mu.RLock()
+	hooks := make([]Hook, len(pm.hooks))
+	copy(hooks, pm.hooks)
+	pm.mu.RUnlock()
+
+	modifiedCall := call
+	for _, hook := range hooks {
+		if hook.PreToolUse == nil {
+			continue
+		}
+
+		newCall, err := hook.PreToolUse(ctx, modifiedCall)
+		if err != nil {
+			pm.mu.Lock()
+			pm.stats.HookErrorCount++
+			pm.mu.Unlock()
+			return nil, fmt.Errorf("pre-hook error: %w", err)
+		}
+
+		if newCall != nil {
+			modifiedCall = newCall
+		}
+	}
+
+	return modifiedCall, nil
+}
+
+// RunPostHooks 运行后置钩子
+func (pm *Manager) RunPostHooks(ctx context.Context, call *types.ToolCallRecord, result interface{}, callErr error) error {
+	pm.mu.RLock()
+	hooks := make([]Hook, len(pm.hooks))
+	copy(hooks, pm.hooks)
+	pm.mu.RUnlock()
+
+	for _, hook := range hooks {
+		if hook.PostToolUse == nil {
+			continue
+		}
+
+		err := hook.PostToolUse(ctx, call, result, callErr)
+		if err != nil {
+			pm.mu.Lock()
+			pm.stats.HookErrorCount++
+			pm.mu.Unlock()
+			return fmt.Errorf("post-hook error: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetStats 获取统计信息
+func (pm *Manager) GetStats() Stats {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	return pm.stats
+}
+
+// SetApprovalFunc 设置审批函数
+func (pm *Manager) SetApprovalFunc(f ApprovalFunc) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.approvalFunc = f
+}
+
+// SetDefaultMode 设置默认模式
+func (pm *Manager) SetDefaultMode(mode types.PermissionMode) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.defaultMode = mode
+}
+
+// GetDefaultMode 获取默认模式
+func (pm *Manager) GetDefaultMode() types.PermissionMode {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	return pm.defaultMode
+}
+
+// AddToAllowList 添加到白名单
+func (pm *Manager) AddToAllowList(toolName string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.allowList[toolName] = true
+	delete(pm.denyList, toolName)
+	delete(pm.askList, toolName)
+}
+
+// AddToDenyList 添加到黑名单
+func (pm *Manager) AddToDenyList(toolName string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.denyList[toolName] = true
+	delete(pm.allowList, toolName)
+	delete(pm.askList, toolName)
+}
+
+// AddToAskList 添加到审批列表
+func (pm *Manager) AddToAskList(toolName string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.askList[toolName] = true
+	delete(pm.allowList, toolName)
+	delete(pm.denyList, toolName)
+}
+
+// RemoveFromLists 从所有列表中移除
+func (pm *Manager) RemoveFromLists(toolName string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	delete(pm.allowList, toolName)
+	delete(pm.denyList, toolName)
+	delete(pm.askList, toolName)
+}
+
+// IsInAllowList 检查是否在白名单
+func (pm *Manager) IsInAllowList(toolName string) bool {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	return pm.allowList[toolName]
+}
+
+// IsInDenyList 检查是否在黑名单
+func (pm *Manager) IsInDenyList(toolName string) bool {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	return pm.denyList[toolName]
+}
+
+// IsInAskList 检查是否在审批列表
+func (pm *Manager) IsInAskList(toolName string) bool {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	return pm.askList[toolName]
+}
+
+// ClearStats 清空统计
+func (pm *Manager) ClearStats() {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.stats = Stats{}
+}
@@ -80,7 +80,7 @@ func (ap *AnthropicProvider) Complete(ctx context.Context, messages []types.Mess
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		log.Printf("[AnthropicProvider] API error response: %s", string(body))
-		return nil, fmt.Errorf("anthropic api error: %d - %s", resp.StatusCode, string(body))
+		return nil, newAPIError("anthropic", resp, body)
 	}
 
 	// 解析完整响应
@@ -96,14 +96,14 @@ func (ap *AnthropicProvider) Complete(ctx context.Context, messages []types.Mess
 	if err != nil {
 		return nil, fmt.Errorf("parse response: %w", err)
 	}
+	if opts != nil && opts.ResponseFormat != nil {
+		structuredToolToText(&message, opts.ResponseFormat.name())
+	}
 
 	// 解析Token使用情况
 	var usage *TokenUsage
 	if usageData, ok := apiResp["usage"].(map[string]interface{}); ok {
-		usage = &TokenUsage{
-			InputTokens:  int64(usageData["input_tokens"].(float64)),
-			OutputTokens: int64(usageData["output_tokens"].(float64)),
-		}
+		usage = parseAnthropicUsage(usageData)
 	}
 
 	return &CompleteResponse{
@@ -158,13 +158,21 @@ func (ap *AnthropicProvider) Stream(ctx context.Context, messages []types.Messag
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
-		return nil, fmt.Errorf("anthropic api error: %d - %s", resp.StatusCode, string(body))
+		return nil, newAPIError("anthropic", resp, body)
 	}
 
 	// 创建流式响应channel
 	chunkCh := make(chan StreamChunk, 10)
 
-	go ap.processStream(resp.Body, chunkCh)
+	var structured *anthropicStructuredStream
+	if opts != nil && opts.ResponseFormat != nil {
+		structured = &anthropicStructuredStream{
+			tool:   opts.ResponseFormat.name(),
+			blocks: make(map[int]bool),
+		}
+	}
+
+	go ap.processStream(resp.Body, chunkCh, structured)
 
 	return chunkCh, nil
 }
@@ -221,10 +229,16 @@ func (ap *AnthropicProvider) buildRequest(messages []types.Message, opts *Stream
 			req["system"] = ap.systemPrompt
 		}
 
-		if len(opts.Tools) > 0 {
+		toolSchemas := opts.Tools
+		if opts.ResponseFormat != nil {
+			// 结构化输出: 追加输出工具,模型通过调用它返回结果
+			toolSchemas = append(append([]ToolSchema(nil), opts.Tools...), anthropicOutputTool(opts.ResponseFormat))
+		}
+
+		if len(toolSchemas) > 0 {
 			// 转换工具格式为 Anthropic API 格式
-			tools := make([]map[string]interface{}, 0, len(opts.Tools))
-			for _, tool := range opts.Tools {
+			tools := make([]map[string]interface{}, 0, len(toolSchemas))
+			for _, tool := range toolSchemas {
 				toolMap := map[string]interface{}{
 					"name":         tool.Name,
 					"description":  tool.Description,
@@ -232,6 +246,10 @@ func (ap *AnthropicProvider) buildRequest(messages []types.Message, opts *Stream
 				}
 				tools = append(tools, toolMap)
 			}
+			if cache := opts.PromptCache; cache != nil && cache.Enabled && cache.Tools {
+				// 工具定义位于请求前缀最前面,在最后一个工具上打断点即可缓存全部工具
+				tools[len(tools)-1]["cache_control"] = anthropicCacheControl(cache)
+			}
 			req["tools"] = tools
 			toolNames := make([]string, len(tools))
 			for i, t := range tools {
@@ -247,6 +265,10 @@ func (ap *AnthropicProvider) buildRequest(messages []types.Message, opts *Stream
 				}
 			}
 		}
+
+		if cache := opts.PromptCache; cache != nil && cache.Enabled {
+			applyAnthropicCacheControl(req, cache)
+		}
 	} else {
 		req["max_tokens"] = 4096
 		if ap.systemPrompt != "" {
@@ -254,12 +276,184 @@ func (ap *AnthropicProvider) buildRequest(messages []types.Message, opts *Stream
 		}
 	}
 
+	if budget := ap.config.ThinkingBudgetTokens; budget > 0 {
+		// 扩展思考: max_tokens 必须大于思考预算,且不能设置 temperature
+		req["thinking"] = map[string]interface{}{
+			"type":          "enabled",
+			"budget_tokens": budget,
+		}
+		if maxTokens, _ := req["max_tokens"].(int); maxTokens <= budget {
+			req["max_tokens"] = budget + 4096
+		}
+		delete(req, "temperature")
+	}
+
+	if opts != nil && opts.ResponseFormat != nil {
+		name := opts.ResponseFormat.name()
+		switch {
+		case ap.config.ThinkingBudgetTokens > 0:
+			// 扩展思考不支持强制调用工具,改为在系统提示词中要求调用输出工具
+			instruction := fmt.Sprintf("When you have the final answer, call the %s tool exactly once with it instead of replying in text.", name)
+			if system, ok := req["system"].(string); ok && system != "" {
+				req["system"] = system + "\n\n" + instruction
+			} else if blocks, ok := req["system"].([]map[string]interface{}); ok {
+				req["system"] = append(blocks, map[string]interface{}{"type": "text", "text": instruction})
+			} else {
+				req["system"] = instruction
+			}
+		case len(opts.Tools) == 0:
+			req["tool_choice"] = map[string]interface{}{"type": "tool", "name": name}
+		default:
+			// 还有其他工具可用: 要求每一步都调用工具,由模型决定何时调用输出工具
+			req["tool_choice"] = map[string]interface{}{"type": "any"}
+		}
+	}
+
 	return req
 }
 
+// anthropicOutputTool 结构化输出使用的工具定义
+func anthropicOutputTool(rf *ResponseFormat) ToolSchema {
+	schema := rf.Schema
+	if rf.Type != ResponseFormatJSONSchema || schema == nil {
+		schema = map[string]interface{}{"type": "object", "additionalProperties": true}
+	}
+	return ToolSchema{
+		Name:        rf.name(),
+		Description: "Return the final answer as structured data. Call this tool exactly once, with the complete answer, when you are done.",
+		InputSchema: schema,
+	}
+}
+
+// anthropicStructuredStream 把输出工具的调用转换为 JSON 文本块
+type anthropicStructuredStream struct {
+	tool       string       // 输出工具名称
+	blocks     map[int]bool // 属于输出工具的内容块索引
+	otherTools bool         // 是否调用了其他工具
+}
+
+// convert 原地转换流式块
+func (s *anthropicStructuredStream) convert(chunk *StreamChunk) {
+	delta, _ := chunk.Delta.(map[string]interface{})
+
+	switch chunk.Type {
+	case "content_block_start":
+		if delta["type"] != "tool_use" {
+			return
+		}
+		if delta["name"] != s.tool {
+			s.otherTools = true
+			return
+		}
+		s.blocks[chunk.Index] = true
+		chunk.Delta = map[string]interface{}{"type": "text", "text": ""}
+
+	case "content_block_delta":
+		if s.blocks[chunk.Index] && delta["type"] == "input_json_delta" {
+			chunk.Delta = map[string]interface{}{"type": "text_delta", "text": delta["partial_json"]}
+		}
+
+	case "message_delta":
+		// 只调用了输出工具时视为正常结束,避免 Agent 继续执行工具
+		if len(s.blocks) > 0 && !s.otherTools && delta["stop_reason"] == "tool_use" {
+			converted := make(map[string]interface{}, len(delta))
+			for k, v := range delta {
+				converted[k] = v
+			}
+			converted["stop_reason"] = "end_turn"
+			chunk.Delta = converted
+		}
+	}
+}
+
+// structuredToolToText 把完整响应中输出工具的调用转换为 JSON 文本块
+func structuredToolToText(message *types.Message, tool string) {
+	for i, block := range message.Content {
+		if tu, ok := block.(*types.ToolUseBlock); ok && tu.Name == tool {
+			data, _ := json.Marshal(tu.Input)
+			message.Content[i] = &types.TextBlock{Text: string(data)}
+		}
+	}
+}
+
+// anthropicMaxCacheBreakpoints Anthropic 单个请求最多允许的 cache_control 断点数
+const anthropicMaxCacheBreakpoints = 4
+
+// anthropicCacheControl 构建 cache_control 标记
+func anthropicCacheControl(cache *types.PromptCacheConfig) map[string]interface{} {
+	control := map[string]interface{}{"type": "ephemeral"}
+	if cache.TTL != "" {
+		control["ttl"] = cache.TTL
+	}
+	return control
+}
+
+// applyAnthropicCacheControl 为系统提示词和对话前缀添加缓存断点
+// 缓存按 tools -> system -> messages 的前缀顺序生效:
+// 系统提示词(含工具手册)整体缓存;对话在最近两条用户消息末尾打断点,
+// 上一步写入的前缀在下一步命中,新一步的内容再写入缓存,形成滚动缓存
+func applyAnthropicCacheControl(req map[string]interface{}, cache *types.PromptCacheConfig) {
+	used := 0
+	if tools, ok := req["tools"].([]map[string]interface{}); ok && len(tools) > 0 {
+		if _, ok := tools[len(tools)-1]["cache_control"]; ok {
+			used++
+		}
+	}
+
+	if system, ok := req["system"].(string); ok && system != "" && cache.System {
+		req["system"] = []map[string]interface{}{
+			{
+				"type":          "text",
+				"text":          system,
+				"cache_control": anthropicCacheControl(cache),
+			},
+		}
+		used++
+	}
+
+	if !cache.Messages {
+		return
+	}
+
+	messages, _ := req["messages"].([]map[string]interface{})
+	remaining := anthropicMaxCacheBreakpoints - used
+	if remaining > 2 {
+		remaining = 2
+	}
+	for i := len(messages) - 1; i >= 0 && remaining > 0; i-- {
+		if messages[i]["role"] != string(types.MessageRoleUser) {
+			continue
+		}
+		content, _ := messages[i]["content"].([]interface{})
+		for j := len(content) - 1; j >= 0; j-- {
+			block, ok := content[j].(map[string]interface{})
+			if !ok || !cacheableAnthropicBlock(block) {
+				continue
+			}
+			block["cache_control"] = anthropicCacheControl(cache)
+			remaining--
+			break
+		}
+	}
+}
+
+// cacheableAnthropicBlock 判断内容块能否携带 cache_control(空文本块会被 API 拒绝)
+func cacheableAnthropicBlock(block map[string]interface{}) bool {
+	switch block["type"] {
+	case "text":
+		text, _ := block["text"].(string)
+		return text != ""
+	case "tool_use", "tool_result", "image", "document":
+		return true
+	default:
+		return false
+	}
+}
+
 // convertMessages 转换消息格式
 func (ap *AnthropicProvider) convertMessages(messages []types.Message) []map[string]interface{} {
 	result := make([]map[string]interface{}, 0, len(messages))
+	vision := ap.Capabilities().SupportVision
 
 	for _, msg := range messages {
 		// 跳过system消息(system在opts中单独传递)
@@ -275,6 +469,8 @@ func (ap *AnthropicProvider) convertMessages(messages []types.Message) []map[str
 					"type": "text",
 					"text": b.Text,
 				})
+			case *types.ImageBlock, *types.DocumentBlock:
+				content = append(content, anthropicMediaBlock(block, vision))
 			case *types.ToolUseBlock:
 				content = append(content, map[string]interface{}{
 					"type":  "tool_use",
@@ -283,12 +479,40 @@ func (ap *AnthropicProvider) convertMessages(messages []types.Message) []map[str
 					"input": b.Input,
 				})
 			case *types.ToolResultBlock:
+				var resultContent interface{} = b.Content
+				if blocks := toolResultBlocks(b.Content); blocks != nil {
+					// tool_result 可以直接包含文本、图片和文档块
+					converted := make([]interface{}, 0, len(blocks))
+					for _, rb := range blocks {
+						if tb, ok := rb.(*types.TextBlock); ok && tb.Text != "" {
+							converted = append(converted, map[string]interface{}{"type": "text", "text": tb.Text})
+						} else if isMediaBlock(rb) {
+							converted = append(converted, anthropicMediaBlock(rb, vision))
+						}
+					}
+					resultContent = converted
+				}
 				content = append(content, map[string]interface{}{
 					"type":        "tool_result",
 					"tool_use_id": b.ToolUseID,
-					"content":     b.Content,
+					"content":     resultContent,
 					"is_error":    b.IsError,
 				})
+			case *types.ThinkingBlock:
+				// 工具调用续写时必须原样回传带签名的思考块;
+				// 没有签名的推理内容(来自其他提供商)无法通过校验,直接跳过
+				if b.Redacted() {
+					content = append(content, map[string]interface{}{
+						"type": "redacted_thinking",
+						"data": b.Data,
+					})
+				} else if b.Signature != "" {
+					content = append(content, map[string]interface{}{
+						"type":      "thinking",
+						"thinking":  b.Thinking,
+						"signature": b.Signature,
+					})
+				}
 			}
 		}
 
@@ -301,11 +525,52 @@ func (ap *AnthropicProvider) convertMessages(messages []types.Message) []map[str
 	return result
 }
 
+// anthropicMediaBlock 转换图片/文档块
+// 纯文本文档不依赖视觉能力;模型不支持视觉时其他媒体替换为文本说明
+func anthropicMediaBlock(block types.ContentBlock, vision bool) map[string]interface{} {
+	var source map[string]interface{}
+	blockType := "image"
+
+	switch b := block.(type) {
+	case *types.ImageBlock:
+		if b.Data != "" {
+			source = map[string]interface{}{"type": "base64", "media_type": b.MediaType, "data": b.Data}
+		} else if b.URL != "" {
+			source = map[string]interface{}{"type": "url", "url": b.URL}
+		}
+	case *types.DocumentBlock:
+		blockType = "document"
+		if text, ok := documentText(b); ok {
+			return map[string]interface{}{
+				"type":   "document",
+				"source": map[string]interface{}{"type": "text", "media_type": "text/plain", "data": text},
+				"title":  documentName(b),
+			}
+		}
+		if b.MediaType == "application/pdf" && b.Data != "" {
+			source = map[string]interface{}{"type": "base64", "media_type": b.MediaType, "data": b.Data}
+		} else if b.MediaType == "application/pdf" && b.URL != "" {
+			source = map[string]interface{}{"type": "url", "url": b.URL}
+		}
+	}
+
+	if !vision || source == nil {
+		return map[string]interface{}{
+			"type": "text",
+			"text": renderContentText([]types.ContentBlock{block}, nil),
+		}
+	}
+	return map[string]interface{}{"type": blockType, "source": source}
+}
+
 // processStream 处理流式响应
-func (ap *AnthropicProvider) processStream(body io.ReadCloser, chunkCh chan<- StreamChunk) {
+func (ap *AnthropicProvider) processStream(body io.ReadCloser, chunkCh chan<- StreamChunk, structured *anthropicStructuredStream) {
 	defer close(chunkCh)
 	defer body.Close()
 
+	// message_start 携带输入侧用量(含缓存),message_delta 只携带输出侧用量,需要合并
+	var startUsage *TokenUsage
+
 	scanner := bufio.NewScanner(body)
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -328,8 +593,20 @@ func (ap *AnthropicProvider) processStream(body io.ReadCloser, chunkCh chan<- St
 			continue
 		}
 
+		if message, ok := event["message"].(map[string]interface{}); ok && event["type"] == "message_start" {
+			if usage, ok := message["usage"].(map[string]interface{}); ok {
+				startUsage = parseAnthropicUsage(usage)
+			}
+		}
+
 		chunk := ap.parseStreamEvent(event)
 		if chunk != nil {
+			if chunk.Type == "message_delta" && chunk.Usage != nil && startUsage != nil {
+				mergeAnthropicUsage(chunk.Usage, startUsage)
+			}
+			if structured != nil {
+				structured.convert(chunk)
+			}
 			chunkCh <- *chunk
 		}
 	}
@@ -381,16 +658,44 @@ func (ap *AnthropicProvider) parseStreamEvent(event map[string]interface{}) *Str
 			chunk.Delta = delta
 		}
 		if usage, ok := event["usage"].(map[string]interface{}); ok {
-			chunk.Usage = &TokenUsage{
-				InputTokens:  int64(usage["input_tokens"].(float64)),
-				OutputTokens: int64(usage["output_tokens"].(float64)),
-			}
+			chunk.Usage = parseAnthropicUsage(usage)
 		}
 	}
 
 	return chunk
 }
 
+// parseAnthropicUsage 解析 usage 字段,缺失的字段按 0 处理
+func parseAnthropicUsage(usage map[string]interface{}) *TokenUsage {
+	inputTokens, _ := usage["input_tokens"].(float64)
+	outputTokens, _ := usage["output_tokens"].(float64)
+	cacheCreation, _ := usage["cache_creation_input_tokens"].(float64)
+	cacheRead, _ := usage["cache_read_input_tokens"].(float64)
+
+	return &TokenUsage{
+		InputTokens:              int64(inputTokens),
+		OutputTokens:             int64(outputTokens),
+		CacheCreationInputTokens: int64(cacheCreation),
+		CacheReadInputTokens:     int64(cacheRead),
+	}
+}
+
+// mergeAnthropicUsage 用 message_start 的输入侧用量补全 message_delta 中缺失的字段
+func mergeAnthropicUsage(usage, start *TokenUsage) {
+	if usage.InputTokens == 0 {
+		usage.InputTokens = start.InputTokens
+	}
+	if usage.CacheCreationInputTokens == 0 {
+		usage.CacheCreationInputTokens = start.CacheCreationInputTokens
+	}
+	if usage.CacheReadInputTokens == 0 {
+		usage.CacheReadInputTokens = start.CacheReadInputTokens
+	}
+	if usage.OutputTokens == 0 {
+		usage.OutputTokens = start.OutputTokens
+	}
+}
+
 // Config 返回配置
 func (ap *AnthropicProvider) Config() *types.ModelConfig {
 	return ap.config
@@ -402,9 +707,10 @@ func (ap *AnthropicProvider) Capabilities() ProviderCapabilities {
 		SupportToolCalling:  true,
 		SupportSystemPrompt: true,
 		SupportStreaming:    true,
-		SupportVision:       false, // 根据模型决定
+		SupportVision:       supportsVision(ap.config, anthropicModelSupportsVision(ap.config.Model)),
 		MaxTokens:           200000,
 		MaxToolsPerCall:     0, // 无限制
+		ContextWindow:       200000,
 		ToolCallingFormat:   "anthropic",
 	}
 }
@@ -446,6 +752,17 @@ func (ap *AnthropicProvider) parseCompleteResponse(apiResp map[string]interface{
 				assistantContent = append(assistantContent, &types.TextBlock{Text: text})
 			}
 
+		case "thinking", "redacted_thinking":
+			// 思考块
+			thinking, _ := block["thinking"].(string)
+			signature, _ := block["signature"].(string)
+			data, _ := block["data"].(string)
+			assistantContent = append(assistantContent, &types.ThinkingBlock{
+				Thinking:  thinking,
+				Signature: signature,
+				Data:      data,
+			})
+
 		case "tool_use":
 			// 工具调用块
 			toolID, _ := block["id"].(string)
@@ -0,0 +1,286 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// anthropicCacheTestMessages 多轮工具调用对话
+func anthropicCacheTestMessages() []types.Message {
+	return []types.Message{
+		{Role: types.MessageRoleUser, Content: []types.ContentBlock{&types.TextBlock{Text: "read a.txt"}}},
+		{Role: types.MessageRoleAssistant, Content: []types.ContentBlock{
+			&types.ToolUseBlock{ID: "toolu_1", Name: "Read", Input: map[string]interface{}{"path": "a.txt"}},
+		}},
+		{Role: types.MessageRoleUser, Content: []types.ContentBlock{
+			&types.ToolResultBlock{ToolUseID: "toolu_1", Content: "hello"},
+		}},
+		{Role: types.MessageRoleAssistant, Content: []types.ContentBlock{&types.TextBlock{Text: "It says hello."}}},
+		{Role: types.MessageRoleUser, Content: []types.ContentBlock{
+			&types.TextBlock{Text: "thanks"},
+			&types.TextBlock{Text: ""},
+		}},
+	}
+}
+
+// cacheControlOf 返回块上的 cache_control 标记
+func cacheControlOf(block interface{}) map[string]interface{} {
+	m, _ := block.(map[string]interface{})
+	control, _ := m["cache_control"].(map[string]interface{})
+	return control
+}
+
+func TestAnthropicProvider_PromptCacheBreakpoints(t *testing.T) {
+	p, err := NewAnthropicProvider(&types.ModelConfig{Provider: "anthropic", Model: "claude-test", APIKey: "k"})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	req := p.buildRequest(anthropicCacheTestMessages(), &StreamOptions{
+		System:      "You are helpful.\n\n### Tools Manual\n...",
+		Tools:       testToolSchemas,
+		PromptCache: &types.PromptCacheConfig{Enabled: true, System: true, Tools: true, Messages: true, TTL: "1h"},
+	})
+
+	// 序列化后再检查,与实际发送的内容一致
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatalf("Failed to unmarshal request: %v", err)
+	}
+
+	breakpoints := 0
+
+	tools := body["tools"].([]interface{})
+	for i, tool := range tools {
+		control := cacheControlOf(tool)
+		if i < len(tools)-1 && control != nil {
+			t.Errorf("Expected no cache_control on tool %d", i)
+		}
+		if control != nil {
+			breakpoints++
+		}
+	}
+	if control := cacheControlOf(tools[len(tools)-1]); control == nil || control["type"] != "ephemeral" || control["ttl"] != "1h" {
+		t.Errorf("Expected ephemeral 1h cache_control on last tool, got %v", control)
+	}
+
+	system, ok := body["system"].([]interface{})
+	if !ok || len(system) != 1 {
+		t.Fatalf("Expected system as a single text block, got %v", body["system"])
+	}
+	if cacheControlOf(system[0]) == nil {
+		t.Errorf("Expected cache_control on system block")
+	} else {
+		breakpoints++
+	}
+
+	messages := body["messages"].([]interface{})
+	marked := make(map[int]int)
+	for i, raw := range messages {
+		content := raw.(map[string]interface{})["content"].([]interface{})
+		for j, block := range content {
+			if cacheControlOf(block) != nil {
+				marked[i] = j
+				breakpoints++
+			}
+		}
+	}
+	// 最近两条用户消息: 第 4 条的非空文本块和第 2 条的工具结果
+	if j, ok := marked[4]; !ok || j != 0 {
+		t.Errorf("Expected breakpoint on last non-empty block of last user message, got %v", marked)
+	}
+	if j, ok := marked[2]; !ok || j != 0 {
+		t.Errorf("Expected breakpoint on previous tool_result message, got %v", marked)
+	}
+	if _, ok := marked[0]; ok {
+		t.Errorf("Expected no breakpoint on first user message, got %v", marked)
+	}
+
+	if breakpoints != anthropicMaxCacheBreakpoints {
+		t.Errorf("Expected %d breakpoints, got %d", anthropicMaxCacheBreakpoints, breakpoints)
+	}
+}
+
+func TestAnthropicProvider_PromptCacheDisabled(t *testing.T) {
+	p, err := NewAnthropicProvider(&types.ModelConfig{Provider: "anthropic", Model: "claude-test", APIKey: "k"})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	for _, cache := range []*types.PromptCacheConfig{nil, {Enabled: false, System: true, Tools: true, Messages: true}} {
+		req := p.buildRequest(anthropicCacheTestMessages(), &StreamOptions{
+			System:      "You are helpful.",
+			Tools:       testToolSchemas,
+			PromptCache: cache,
+		})
+
+		if _, ok := req["system"].(string); !ok {
+			t.Errorf("Expected plain string system prompt, got %T", req["system"])
+		}
+		data, _ := json.Marshal(req)
+		var body map[string]interface{}
+		json.Unmarshal(data, &body)
+		for _, tool := range body["tools"].([]interface{}) {
+			if cacheControlOf(tool) != nil {
+				t.Errorf("Expected no cache_control on tools when caching is disabled")
+			}
+		}
+		for _, raw := range body["messages"].([]interface{}) {
+			for _, block := range raw.(map[string]interface{})["content"].([]interface{}) {
+				if cacheControlOf(block) != nil {
+					t.Errorf("Expected no cache_control on messages when caching is disabled")
+				}
+			}
+		}
+	}
+}
+
+func TestAnthropicProvider_StreamCacheUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeSSE(w,
+			`{"type":"message_start","message":{"id":"msg_1","role":"assistant","usage":{"input_tokens":12,"cache_creation_input_tokens":300,"cache_read_input_tokens":5000,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hi"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":7}}`,
+			`{"type":"message_stop"}`,
+		)
+	}))
+	defer server.Close()
+
+	p, err := NewAnthropicProvider(&types.ModelConfig{
+		Provider: "anthropic",
+		Model:    "claude-test",
+		APIKey:   "k",
+		BaseURL:  server.URL,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	ch, err := p.Stream(context.Background(), testMessages, &StreamOptions{})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+
+	var usage *TokenUsage
+	for _, chunk := range collectChunks(t, ch) {
+		if chunk.Type == "message_delta" {
+			usage = chunk.Usage
+		}
+	}
+	if usage == nil {
+		t.Fatalf("Expected usage on message_delta")
+	}
+
+	want := TokenUsage{InputTokens: 12, OutputTokens: 7, CacheCreationInputTokens: 300, CacheReadInputTokens: 5000}
+	if *usage != want {
+		t.Errorf("Expected usage %+v, got %+v", want, *usage)
+	}
+	if usage.TotalTokens() != 5319 {
+		t.Errorf("Expected total 5319, got %d", usage.TotalTokens())
+	}
+}
+
+func TestAnthropicProvider_CompleteCacheUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"content":[{"type":"text","text":"ok"}],"usage":{"input_tokens":3,"output_tokens":2,"cache_read_input_tokens":900}}`))
+	}))
+	defer server.Close()
+
+	p, err := NewAnthropicProvider(&types.ModelConfig{
+		Provider: "anthropic",
+		Model:    "claude-test",
+		APIKey:   "k",
+		BaseURL:  server.URL,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	resp, err := p.Complete(context.Background(), testMessages, &StreamOptions{})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if resp.Usage == nil || resp.Usage.CacheReadInputTokens != 900 || resp.Usage.CacheCreationInputTokens != 0 {
+		t.Errorf("Expected cache read usage, got %+v", resp.Usage)
+	}
+}
+
+func TestParseOpenAIUsage_CachedTokens(t *testing.T) {
+	usage := parseOpenAIUsage(map[string]interface{}{
+		"prompt_tokens":         float64(1000),
+		"completion_tokens":     float64(10),
+		"prompt_tokens_details": map[string]interface{}{"cached_tokens": float64(800)},
+	})
+	if usage.InputTokens != 200 || usage.CacheReadInputTokens != 800 || usage.TotalTokens() != 1010 {
+		t.Errorf("Unexpected OpenAI usage: %+v", usage)
+	}
+
+	usage = parseOpenAIUsage(map[string]interface{}{
+		"prompt_tokens":            float64(500),
+		"completion_tokens":        float64(5),
+		"prompt_cache_hit_tokens":  float64(300),
+		"prompt_cache_miss_tokens": float64(200),
+	})
+	if usage.InputTokens != 200 || usage.CacheReadInputTokens != 300 {
+		t.Errorf("Unexpected Deepseek usage: %+v", usage)
+	}
+}
+
+func TestAnthropicProvider_ThinkingRoundTrip(t *testing.T) {
+	p, err := NewAnthropicProvider(&types.ModelConfig{
+		Provider:             "anthropic",
+		Model:                "claude-test",
+		APIKey:               "k",
+		ThinkingBudgetTokens: 8000,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	req := p.buildRequest([]types.Message{
+		{Role: types.MessageRoleUser, Content: []types.ContentBlock{&types.TextBlock{Text: "read a.txt"}}},
+		{Role: types.MessageRoleAssistant, Content: []types.ContentBlock{
+			&types.ThinkingBlock{Thinking: "need to read", Signature: "sig"},
+			&types.ThinkingBlock{Data: "encrypted"},
+			&types.ThinkingBlock{Thinking: "unsigned reasoning from another provider"},
+			&types.ToolUseBlock{ID: "toolu_1", Name: "Read", Input: map[string]interface{}{"path": "a.txt"}},
+		}},
+	}, &StreamOptions{MaxTokens: 4096, Temperature: 0.5})
+
+	thinking, ok := req["thinking"].(map[string]interface{})
+	if !ok || thinking["type"] != "enabled" || thinking["budget_tokens"] != 8000 {
+		t.Errorf("Expected thinking enabled with budget 8000, got %v", req["thinking"])
+	}
+	if maxTokens, _ := req["max_tokens"].(int); maxTokens <= 8000 {
+		t.Errorf("Expected max_tokens above thinking budget, got %v", req["max_tokens"])
+	}
+	if _, ok := req["temperature"]; ok {
+		t.Errorf("temperature must not be sent with extended thinking")
+	}
+
+	messages := req["messages"].([]map[string]interface{})
+	content := messages[1]["content"].([]interface{})
+	if len(content) != 3 {
+		t.Fatalf("Expected unsigned thinking to be dropped, got %d blocks", len(content))
+	}
+	first := content[0].(map[string]interface{})
+	if first["type"] != "thinking" || first["signature"] != "sig" || first["thinking"] != "need to read" {
+		t.Errorf("Unexpected thinking block: %v", first)
+	}
+	second := content[1].(map[string]interface{})
+	if second["type"] != "redacted_thinking" || second["data"] != "encrypted" {
+		t.Errorf("Unexpected redacted thinking block: %v", second)
+	}
+}
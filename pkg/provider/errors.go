@@ -0,0 +1,30 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// APIError 提供商 API 返回的非 200 响应
+// 保留状态码和响应头,供重试逻辑判断是否可重试以及等待多久
+type APIError struct {
+	Provider   string      // 提供商名称,如 "anthropic"
+	StatusCode int         // HTTP 状态码
+	Body       string      // 响应体
+	Header     http.Header // 响应头(Retry-After、限流信息等)
+}
+
+// Error 实现 error 接口
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s api error: %d - %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// newAPIError 根据 HTTP 响应创建 APIError
+func newAPIError(provider string, resp *http.Response, body []byte) *APIError {
+	return &APIError{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		Header:     resp.Header,
+	}
+}
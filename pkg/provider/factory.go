@@ -21,6 +21,11 @@ func NewMultiProviderFactory() *MultiProviderFactory {
 
 // Create 根据配置创建相应的提供商
 func (f *MultiProviderFactory) Create(config *types.ModelConfig) (Provider, error) {
+	// 配置了备用模型或多个 API Key 时创建组合提供商
+	if len(config.Fallbacks) > 0 || len(config.APIKeys) > 0 {
+		return NewFailoverProvider(config, f)
+	}
+
 	providerType := config.Provider
 	if providerType == "" {
 		// 默认使用 anthropic
@@ -34,6 +39,10 @@ func (f *MultiProviderFactory) Create(config *types.ModelConfig) (Provider, erro
 		return NewGLMProvider(config)
 	case "deepseek":
 		return NewDeepseekProvider(config)
+	case "openai":
+		return NewOpenAIProvider(config)
+	case "ollama":
+		return NewOllamaProvider(config)
 	default:
 		return nil, fmt.Errorf("unsupported provider: %s", providerType)
 	}
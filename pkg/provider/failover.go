@@ -0,0 +1,398 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+const (
+	// KeyStrategyRoundRobin 轮询使用各个 API Key
+	KeyStrategyRoundRobin = "round_robin"
+	// KeyStrategyLeastLoaded 优先使用进行中请求最少的 API Key
+	KeyStrategyLeastLoaded = "least_loaded"
+
+	// defaultKeyCooldown Key 被限流且服务端未给出重置时间时的冷却时间
+	defaultKeyCooldown = 30 * time.Second
+)
+
+// Metadata 中的后端信息字段
+const (
+	MetadataProvider = "provider"
+	MetadataModel    = "model"
+	MetadataBackend  = "backend"
+)
+
+// failoverMember 一个具体的后端(某个模型的某个 API Key)
+type failoverMember struct {
+	name     string
+	provider Provider
+	inflight int64 // 进行中的请求数
+
+	mu            sync.Mutex
+	cooldownUntil time.Time // 被限流后的冷却截止时间
+}
+
+func (m *failoverMember) coolingDown(now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return now.Before(m.cooldownUntil)
+}
+
+func (m *failoverMember) setCooldown(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cooldownUntil = time.Now().Add(d)
+}
+
+// failoverGroup 故障转移链中的一项: 同一个模型的多个 API Key
+type failoverGroup struct {
+	config   *types.ModelConfig
+	members  []*failoverMember
+	strategy string
+	next     uint64 // 轮询计数
+}
+
+// order 返回本次调用尝试成员的顺序
+// 冷却中的成员排在最后,只在其他成员都失败时才会使用
+func (g *failoverGroup) order() []*failoverMember {
+	n := len(g.members)
+	start := int(atomic.AddUint64(&g.next, 1)-1) % n
+
+	ordered := make([]*failoverMember, 0, n)
+	for i := 0; i < n; i++ {
+		ordered = append(ordered, g.members[(start+i)%n])
+	}
+
+	if g.strategy == KeyStrategyLeastLoaded {
+		// 稳定的插入排序: 负载相同时保持轮询顺序
+		for i := 1; i < n; i++ {
+			for j := i; j > 0 && atomic.LoadInt64(&ordered[j].inflight) < atomic.LoadInt64(&ordered[j-1].inflight); j-- {
+				ordered[j], ordered[j-1] = ordered[j-1], ordered[j]
+			}
+		}
+	}
+
+	now := time.Now()
+	ready := make([]*failoverMember, 0, n)
+	cooling := make([]*failoverMember, 0)
+	for _, m := range ordered {
+		if m.coolingDown(now) {
+			cooling = append(cooling, m)
+		} else {
+			ready = append(ready, m)
+		}
+	}
+	return append(ready, cooling...)
+}
+
+// FailoverProvider 故障转移 + 多 Key 负载均衡的组合提供商
+// 按 ModelConfig 及其 Fallbacks 的顺序尝试各个模型;同一模型配置多个 API Key 时,
+// 按 KeyStrategy 分摊请求,某个 Key 被限流或鉴权失败时先换 Key,其他错误或超时则切换到下一个模型
+type FailoverProvider struct {
+	config       *types.ModelConfig
+	groups       []*failoverGroup
+	timeout      time.Duration
+	systemPrompt string
+}
+
+// NewFailoverProvider 根据配置创建组合提供商,factory 用于创建每个具体后端
+func NewFailoverProvider(config *types.ModelConfig, factory Factory) (*FailoverProvider, error) {
+	entries := make([]types.ModelConfig, 0, len(config.Fallbacks)+1)
+	entries = append(entries, *config)
+	for _, fb := range config.Fallbacks {
+		entries = append(entries, inheritModelConfig(fb, config))
+	}
+
+	fp := &FailoverProvider{
+		config:  config,
+		timeout: time.Duration(config.TimeoutMs) * time.Millisecond,
+	}
+
+	for _, entry := range entries {
+		group, err := newFailoverGroup(entry, factory)
+		if err != nil {
+			fp.Close()
+			return nil, err
+		}
+		fp.groups = append(fp.groups, group)
+	}
+
+	return fp, nil
+}
+
+// inheritModelConfig 备用配置中未填写的字段继承主配置
+func inheritModelConfig(fb types.ModelConfig, primary *types.ModelConfig) types.ModelConfig {
+	if fb.Provider == "" {
+		fb.Provider = primary.Provider
+		if fb.BaseURL == "" {
+			fb.BaseURL = primary.BaseURL
+		}
+		if fb.APIKey == "" && len(fb.APIKeys) == 0 {
+			fb.APIKey = primary.APIKey
+			fb.APIKeys = primary.APIKeys
+		}
+	}
+	if fb.KeyStrategy == "" {
+		fb.KeyStrategy = primary.KeyStrategy
+	}
+	return fb
+}
+
+// newFailoverGroup 为一个模型配置的每个 API Key 创建后端
+func newFailoverGroup(entry types.ModelConfig, factory Factory) (*failoverGroup, error) {
+	keys := entry.APIKeys
+	if len(keys) == 0 {
+		keys = []string{entry.APIKey}
+	}
+
+	providerName := entry.Provider
+	if providerName == "" {
+		providerName = "anthropic"
+	}
+
+	group := &failoverGroup{
+		config:   &entry,
+		strategy: entry.KeyStrategy,
+	}
+
+	for i, key := range keys {
+		// 单个后端的配置: 去掉组合相关的字段,避免工厂再次创建组合提供商
+		single := entry
+		single.APIKey = key
+		single.APIKeys = nil
+		single.Fallbacks = nil
+
+		prov, err := factory.Create(&single)
+		if err != nil {
+			return nil, fmt.Errorf("create backend %s/%s: %w", providerName, entry.Model, err)
+		}
+
+		name := fmt.Sprintf("%s/%s", providerName, entry.Model)
+		if len(keys) > 1 {
+			name = fmt.Sprintf("%s#%d", name, i+1)
+		}
+		group.members = append(group.members, &failoverMember{name: name, provider: prov})
+	}
+
+	return group, nil
+}
+
+// Stream 流式对话,依次尝试各个后端直到建立流
+func (fp *FailoverProvider) Stream(ctx context.Context, messages []types.Message, opts *StreamOptions) (<-chan StreamChunk, error) {
+	var errs []error
+
+	for _, group := range fp.groups {
+		for _, member := range group.order() {
+			ch, err := fp.streamOnce(ctx, group, member, messages, opts)
+			if err == nil {
+				return ch, nil
+			}
+			if ctx.Err() != nil {
+				return nil, err
+			}
+
+			errs = append(errs, fmt.Errorf("%s: %w", member.name, err))
+			log.Printf("[FailoverProvider] Backend %s failed: %v", member.name, err)
+			if !fp.handleMemberError(member, err) {
+				break // 换下一个模型
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("all backends failed: %w", errors.Join(errs...))
+}
+
+// streamOnce 在一个后端上建立流,成功后转发数据块并附加后端信息
+func (fp *FailoverProvider) streamOnce(ctx context.Context, group *failoverGroup, member *failoverMember, messages []types.Message, opts *StreamOptions) (<-chan StreamChunk, error) {
+	callCtx, cancel := context.WithCancel(ctx)
+
+	// 超时只限制建立流的时间,流建立后不再限制
+	var timedOut int32
+	var timer *time.Timer
+	if fp.timeout > 0 {
+		timer = time.AfterFunc(fp.timeout, func() {
+			atomic.StoreInt32(&timedOut, 1)
+			cancel()
+		})
+	}
+
+	atomic.AddInt64(&member.inflight, 1)
+	ch, err := member.provider.Stream(callCtx, messages, opts)
+	if timer != nil && !timer.Stop() && atomic.LoadInt32(&timedOut) == 1 {
+		err = fmt.Errorf("timed out after %v", fp.timeout)
+	}
+	if err != nil {
+		atomic.AddInt64(&member.inflight, -1)
+		cancel()
+		if ch != nil {
+			// 超时与建流同时发生: 丢弃已建立的流
+			go func() {
+				for range ch {
+				}
+			}()
+		}
+		return nil, err
+	}
+
+	metadata := backendMetadata(group, member)
+	out := make(chan StreamChunk, 10)
+	go func() {
+		defer close(out)
+		defer cancel()
+		defer atomic.AddInt64(&member.inflight, -1)
+
+		for chunk := range ch {
+			if chunk.Type == "message_delta" {
+				chunk.Metadata = mergeMetadata(chunk.Metadata, metadata)
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				// 调用方已放弃,继续读完上游以便其退出
+				for range ch {
+				}
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// Complete 非流式对话,依次尝试各个后端直到成功
+func (fp *FailoverProvider) Complete(ctx context.Context, messages []types.Message, opts *StreamOptions) (*CompleteResponse, error) {
+	var errs []error
+
+	for _, group := range fp.groups {
+		for _, member := range group.order() {
+			resp, err := fp.completeOnce(ctx, member, messages, opts)
+			if err == nil {
+				resp.Metadata = mergeMetadata(resp.Metadata, backendMetadata(group, member))
+				return resp, nil
+			}
+			if ctx.Err() != nil {
+				return nil, err
+			}
+
+			errs = append(errs, fmt.Errorf("%s: %w", member.name, err))
+			log.Printf("[FailoverProvider] Backend %s failed: %v", member.name, err)
+			if !fp.handleMemberError(member, err) {
+				break
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("all backends failed: %w", errors.Join(errs...))
+}
+
+func (fp *FailoverProvider) completeOnce(ctx context.Context, member *failoverMember, messages []types.Message, opts *StreamOptions) (*CompleteResponse, error) {
+	callCtx := ctx
+	if fp.timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, fp.timeout)
+		defer cancel()
+	}
+
+	atomic.AddInt64(&member.inflight, 1)
+	defer atomic.AddInt64(&member.inflight, -1)
+
+	return member.provider.Complete(callCtx, messages, opts)
+}
+
+// handleMemberError 处理单个后端的错误,返回是否应该继续尝试同组的其他 Key
+// 限流和鉴权错误只与 Key 有关,换 Key 可能成功;其他错误换 Key 无济于事,直接切换模型
+func (fp *FailoverProvider) handleMemberError(member *failoverMember, err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests:
+		cooldown := defaultKeyCooldown
+		if hint, ok := retryAfterHint(apiErr.Header); ok {
+			cooldown = hint
+		}
+		member.setCooldown(cooldown)
+		return true
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusPaymentRequired:
+		return true
+	default:
+		return false
+	}
+}
+
+// backendMetadata 构建后端信息
+func backendMetadata(group *failoverGroup, member *failoverMember) map[string]interface{} {
+	providerName := group.config.Provider
+	if providerName == "" {
+		providerName = "anthropic"
+	}
+	return map[string]interface{}{
+		MetadataProvider: providerName,
+		MetadataModel:    group.config.Model,
+		MetadataBackend:  member.name,
+	}
+}
+
+// mergeMetadata 合并附加信息,不覆盖已有字段
+func mergeMetadata(dst, src map[string]interface{}) map[string]interface{} {
+	if dst == nil {
+		dst = make(map[string]interface{}, len(src))
+	}
+	for k, v := range src {
+		if _, exists := dst[k]; !exists {
+			dst[k] = v
+		}
+	}
+	return dst
+}
+
+// Config 返回配置(主配置)
+func (fp *FailoverProvider) Config() *types.ModelConfig {
+	return fp.config
+}
+
+// Capabilities 返回模型能力(以主模型为准)
+func (fp *FailoverProvider) Capabilities() ProviderCapabilities {
+	return fp.groups[0].members[0].provider.Capabilities()
+}
+
+// SetSystemPrompt 设置系统提示词(同步到所有后端)
+func (fp *FailoverProvider) SetSystemPrompt(prompt string) error {
+	fp.systemPrompt = prompt
+	for _, group := range fp.groups {
+		for _, member := range group.members {
+			if err := member.provider.SetSystemPrompt(prompt); err != nil {
+				return fmt.Errorf("set system prompt on %s: %w", member.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// GetSystemPrompt 获取系统提示词
+func (fp *FailoverProvider) GetSystemPrompt() string {
+	return fp.systemPrompt
+}
+
+// Close 关闭所有后端
+func (fp *FailoverProvider) Close() error {
+	var errs []error
+	for _, group := range fp.groups {
+		for _, member := range group.members {
+			if err := member.provider.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,218 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// keyRecorder 记录每个 API Key 收到的请求数
+type keyRecorder struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (r *keyRecorder) record(req *http.Request) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.calls == nil {
+		r.calls = make(map[string]int)
+	}
+	key := req.Header.Get("Authorization")
+	r.calls[key]++
+	return key
+}
+
+func okCompletion(w http.ResponseWriter) {
+	w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}],"usage":{"prompt_tokens":1,"completion_tokens":2}}`))
+}
+
+func TestFailoverProvider_FallsBackToNextModel(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer primary.Close()
+
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeSSE(w,
+			`{"choices":[{"index":0,"delta":{"content":"from backup"}}]}`,
+			`{"choices":[{"index":0,"delta":{},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":4}}`,
+		)
+	}))
+	defer backup.Close()
+
+	p, err := NewMultiProviderFactory().Create(&types.ModelConfig{
+		Provider: "deepseek",
+		Model:    "deepseek-chat",
+		APIKey:   "sk-primary",
+		BaseURL:  primary.URL,
+		Fallbacks: []types.ModelConfig{
+			{Provider: "glm", Model: "glm-4.6", APIKey: "sk-backup", BaseURL: backup.URL},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	if _, ok := p.(*FailoverProvider); !ok {
+		t.Fatalf("Expected *FailoverProvider, got %T", p)
+	}
+
+	ch, err := p.Stream(context.Background(), testMessages, nil)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+
+	var metadata map[string]interface{}
+	for chunk := range ch {
+		if chunk.Type == "message_delta" && chunk.Usage != nil {
+			metadata = chunk.Metadata
+		}
+	}
+
+	if metadata[MetadataProvider] != "glm" || metadata[MetadataModel] != "glm-4.6" || metadata[MetadataBackend] != "glm/glm-4.6" {
+		t.Errorf("Unexpected backend metadata: %v", metadata)
+	}
+}
+
+func TestFailoverProvider_RoundRobinKeys(t *testing.T) {
+	var rec keyRecorder
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec.record(r)
+		okCompletion(w)
+	}))
+	defer server.Close()
+
+	p, err := NewFailoverProvider(&types.ModelConfig{
+		Provider: "openai",
+		Model:    "gpt-4o",
+		BaseURL:  server.URL,
+		APIKeys:  []string{"k1", "k2", "k3"},
+	}, NewMultiProviderFactory())
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	backends := make(map[string]bool)
+	for i := 0; i < 6; i++ {
+		resp, err := p.Complete(context.Background(), testMessages, nil)
+		if err != nil {
+			t.Fatalf("Complete failed: %v", err)
+		}
+		backends[resp.Metadata[MetadataBackend].(string)] = true
+	}
+
+	for _, key := range []string{"Bearer k1", "Bearer k2", "Bearer k3"} {
+		if rec.calls[key] != 2 {
+			t.Errorf("Expected 2 calls for %s, got %d", key, rec.calls[key])
+		}
+	}
+	if len(backends) != 3 || !backends["openai/gpt-4o#1"] {
+		t.Errorf("Unexpected backends: %v", backends)
+	}
+}
+
+func TestFailoverProvider_RateLimitedKeyCoolsDown(t *testing.T) {
+	var rec keyRecorder
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rec.record(r) == "Bearer limited" {
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		okCompletion(w)
+	}))
+	defer server.Close()
+
+	p, err := NewFailoverProvider(&types.ModelConfig{
+		Provider: "openai",
+		Model:    "gpt-4o",
+		BaseURL:  server.URL,
+		APIKeys:  []string{"limited", "healthy"},
+	}, NewMultiProviderFactory())
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		resp, err := p.Complete(context.Background(), testMessages, nil)
+		if err != nil {
+			t.Fatalf("Complete failed: %v", err)
+		}
+		if resp.Metadata[MetadataBackend] != "openai/gpt-4o#2" {
+			t.Errorf("Expected healthy key to serve, got %v", resp.Metadata[MetadataBackend])
+		}
+	}
+
+	// 被限流的 Key 只尝试一次,之后进入冷却
+	if rec.calls["Bearer limited"] != 1 {
+		t.Errorf("Expected limited key to be tried once, got %d", rec.calls["Bearer limited"])
+	}
+}
+
+func TestFailoverProvider_TimeoutFailsOver(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(500 * time.Millisecond):
+		}
+	}))
+	defer slow.Close()
+
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		okCompletion(w)
+	}))
+	defer fast.Close()
+
+	p, err := NewFailoverProvider(&types.ModelConfig{
+		Provider:  "openai",
+		Model:     "slow-model",
+		APIKey:    "sk",
+		BaseURL:   slow.URL,
+		TimeoutMs: 50,
+		Fallbacks: []types.ModelConfig{{Model: "fast-model", BaseURL: fast.URL}},
+	}, NewMultiProviderFactory())
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	resp, err := p.Complete(context.Background(), testMessages, nil)
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	// 备用配置继承了主配置的 Provider
+	if resp.Metadata[MetadataBackend] != "openai/fast-model" {
+		t.Errorf("Expected fast-model to serve, got %v", resp.Metadata)
+	}
+}
+
+func TestFailoverProvider_AllBackendsFail(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	p, err := NewFailoverProvider(&types.ModelConfig{
+		Provider:  "openai",
+		Model:     "a",
+		APIKey:    "sk",
+		BaseURL:   server.URL,
+		Fallbacks: []types.ModelConfig{{Model: "b"}},
+	}, NewMultiProviderFactory())
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	_, err = p.Complete(context.Background(), testMessages, nil)
+	if err == nil {
+		t.Fatal("Expected error when all backends fail")
+	}
+	// 组合错误仍然可以被重试装饰器识别
+	if !IsRetryableError(err) {
+		t.Errorf("Expected aggregated 503 error to be retryable: %v", err)
+	}
+}
@@ -2,22 +2,32 @@ package provider
 
 import (
 	"context"
+	"encoding/json"
 
 	"github.com/wordflowlab/agentsdk/pkg/types"
 )
 
 // StreamChunk 流式响应块
 type StreamChunk struct {
-	Type  string      // "content_block_start", "content_block_delta", "content_block_stop", "message_delta"
-	Index int         // 内容块索引
-	Delta interface{} // 增量数据
-	Usage *TokenUsage // Token使用情况
+	Type     string                 // "content_block_start", "content_block_delta", "content_block_stop", "message_delta"
+	Index    int                    // 内容块索引
+	Delta    interface{}            // 增量数据
+	Usage    *TokenUsage            // Token使用情况
+	Metadata map[string]interface{} // 附加信息(如实际处理请求的后端)
 }
 
 // TokenUsage Token使用统计
+// InputTokens 不含命中或写入缓存的部分,输入总量为三者之和
 type TokenUsage struct {
-	InputTokens  int64
-	OutputTokens int64
+	InputTokens              int64
+	OutputTokens             int64
+	CacheCreationInputTokens int64 // 写入提示词缓存的输入 Token
+	CacheReadInputTokens     int64 // 从提示词缓存读取的输入 Token
+}
+
+// TotalTokens 返回输入(含缓存)与输出 Token 总数
+func (u *TokenUsage) TotalTokens() int64 {
+	return u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens + u.OutputTokens
 }
 
 // StreamOptions 流式请求选项
@@ -26,12 +36,55 @@ type StreamOptions struct {
 	MaxTokens   int
 	Temperature float64
 	System      string
+	PromptCache *types.PromptCacheConfig // 提示词缓存断点,目前仅 Anthropic 支持
+
+	// ResponseFormat 结构化输出格式,为空时返回普通文本
+	ResponseFormat *ResponseFormat
+}
+
+// 结构化输出类型
+const (
+	ResponseFormatJSONSchema = "json_schema" // 按 JSON Schema 输出
+	ResponseFormatJSONObject = "json_object" // 输出任意 JSON 对象
+)
+
+// DefaultResponseFormatName 未指定名称时使用的 schema 名称
+const DefaultResponseFormatName = "structured_output"
+
+// ResponseFormat 结构化输出格式
+// OpenAI 使用 response_format,Ollama 使用 format;
+// Anthropic 没有原生支持,通过只提供一个输出工具并强制调用来实现,
+// 模型的工具参数会被转换为 JSON 文本返回,调用方看到的与其他提供商一致
+type ResponseFormat struct {
+	Type   string                 // "json_schema" | "json_object"
+	Name   string                 // schema 名称,默认 "structured_output"
+	Schema map[string]interface{} // JSON Schema,根节点必须为 object
+	Strict bool                   // 是否要求严格遵守 schema(OpenAI strict 模式)
+}
+
+// name 返回 schema 名称
+func (rf *ResponseFormat) name() string {
+	if rf.Name != "" {
+		return rf.Name
+	}
+	return DefaultResponseFormatName
+}
+
+// instructions 返回描述输出格式的提示词,用于不支持 schema 的提供商
+func (rf *ResponseFormat) instructions() string {
+	if rf.Type != ResponseFormatJSONSchema || rf.Schema == nil {
+		return "Respond with a single valid JSON object and nothing else."
+	}
+	schemaJSON, _ := json.MarshalIndent(rf.Schema, "", "  ")
+	return "Respond with a single valid JSON object and nothing else. " +
+		"The JSON must conform to this JSON Schema:\n" + string(schemaJSON)
 }
 
 // CompleteResponse 完整响应
 type CompleteResponse struct {
-	Message types.Message
-	Usage   *TokenUsage
+	Message  types.Message
+	Usage    *TokenUsage
+	Metadata map[string]interface{} // 附加信息(如实际处理请求的后端)
 }
 
 // ToolSchema 工具Schema
@@ -52,6 +105,7 @@ type ProviderCapabilities struct {
 	// 限制
 	MaxTokens       int // 最大 token 数
 	MaxToolsPerCall int // 单次最多调用工具数
+	ContextWindow   int // 上下文窗口大小(提示词与输出的 token 总数),0 表示未知
 
 	// Tool Calling 格式
 	ToolCallingFormat string // "anthropic" | "openai" | "qwen" | "custom"
@@ -0,0 +1,399 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// MockToolCall 脚本中的一次工具调用
+type MockToolCall struct {
+	ID    string                 // 调用 ID,为空时自动生成
+	Name  string                 // 工具名称
+	Input map[string]interface{} // 工具参数
+
+	// PartialJSON 以 input_json_delta 分片发送的原始 JSON,设置后忽略 Input
+	// 用于测试参数被拆分甚至不合法时的解析行为
+	PartialJSON []string
+}
+
+// MockStep 一次模型调用的脚本
+type MockStep struct {
+	Thinking   string         // 思考内容,以带签名的 thinking 块在文本之前输出
+	Text       string         // 文本回复
+	TextChunks []string       // 分片发送的文本,设置后忽略 Text
+	ToolCalls  []MockToolCall // 工具调用
+	Usage      *TokenUsage    // Token 使用情况
+	Err        error          // 非空时 Stream/Complete 直接返回该错误
+	Delay      time.Duration  // 返回响应前的等待时间
+	ChunkDelay time.Duration  // 流式数据块之间的等待时间
+}
+
+// MockTextStep 返回文本的脚本步骤
+func MockTextStep(text string) MockStep {
+	return MockStep{Text: text}
+}
+
+// MockToolStep 返回工具调用的脚本步骤
+func MockToolStep(calls ...MockToolCall) MockStep {
+	return MockStep{ToolCalls: calls}
+}
+
+// MockErrorStep 返回错误的脚本步骤
+func MockErrorStep(err error) MockStep {
+	return MockStep{Err: err}
+}
+
+// MockRequest 模拟提供商收到的一次请求
+type MockRequest struct {
+	Messages []types.Message
+	Options  StreamOptions
+	System   string // 实际生效的系统提示词
+	Stream   bool   // 是否为流式调用
+}
+
+// MockProvider 可编排的模拟提供商(用于测试)
+// 每次 Stream/Complete 按顺序消费一个脚本步骤,并记录收到的请求;
+// 流式输出使用与 AnthropicProvider 相同的内容块格式
+type MockProvider struct {
+	mu           sync.Mutex
+	steps        []MockStep
+	next         int
+	requests     []MockRequest
+	systemPrompt string
+	config       *types.ModelConfig
+	capabilities ProviderCapabilities
+}
+
+// NewMockProvider 创建模拟提供商
+func NewMockProvider(steps ...MockStep) *MockProvider {
+	return &MockProvider{
+		steps: steps,
+		config: &types.ModelConfig{
+			Provider: "mock",
+			Model:    "mock-model",
+		},
+		capabilities: ProviderCapabilities{
+			SupportToolCalling:  true,
+			SupportSystemPrompt: true,
+			SupportStreaming:    true,
+			SupportVision:       false,
+			MaxTokens:           200000,
+			MaxToolsPerCall:     0,
+			ContextWindow:       200000,
+			ToolCallingFormat:   "anthropic",
+		},
+	}
+}
+
+// AddSteps 追加脚本步骤
+func (mp *MockProvider) AddSteps(steps ...MockStep) *MockProvider {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	mp.steps = append(mp.steps, steps...)
+	return mp
+}
+
+// SetCapabilities 设置模型能力
+func (mp *MockProvider) SetCapabilities(caps ProviderCapabilities) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	mp.capabilities = caps
+}
+
+// Requests 返回收到的所有请求
+func (mp *MockProvider) Requests() []MockRequest {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	return append([]MockRequest(nil), mp.requests...)
+}
+
+// Request 返回第 i 次请求(从 0 开始)
+func (mp *MockProvider) Request(i int) (MockRequest, bool) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	if i < 0 || i >= len(mp.requests) {
+		return MockRequest{}, false
+	}
+	return mp.requests[i], true
+}
+
+// Remaining 返回尚未消费的脚本步骤数
+func (mp *MockProvider) Remaining() int {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	return len(mp.steps) - mp.next
+}
+
+// nextStep 记录请求并取出下一个脚本步骤
+func (mp *MockProvider) nextStep(messages []types.Message, opts *StreamOptions, stream bool) (MockStep, error) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	req := MockRequest{
+		Messages: append([]types.Message(nil), messages...),
+		System:   mp.systemPrompt,
+		Stream:   stream,
+	}
+	if opts != nil {
+		req.Options = *opts
+		req.Options.Tools = append([]ToolSchema(nil), opts.Tools...)
+		if opts.System != "" {
+			req.System = opts.System
+		}
+	}
+	mp.requests = append(mp.requests, req)
+
+	if mp.next >= len(mp.steps) {
+		return MockStep{}, fmt.Errorf("mock provider: no scripted step for call %d", len(mp.requests))
+	}
+
+	step := mp.steps[mp.next]
+	mp.next++
+	return step, nil
+}
+
+// Stream 流式对话,按脚本输出数据块
+func (mp *MockProvider) Stream(ctx context.Context, messages []types.Message, opts *StreamOptions) (<-chan StreamChunk, error) {
+	step, err := mp.nextStep(messages, opts, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sleepContext(ctx, step.Delay); err != nil {
+		return nil, err
+	}
+	if step.Err != nil {
+		return nil, step.Err
+	}
+
+	chunks := mockStreamChunks(step)
+	out := make(chan StreamChunk, len(chunks))
+
+	go func() {
+		defer close(out)
+		for i, chunk := range chunks {
+			if i > 0 && step.ChunkDelay > 0 {
+				if err := sleepContext(ctx, step.ChunkDelay); err != nil {
+					return
+				}
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// Complete 非流式对话,按脚本返回完整消息
+func (mp *MockProvider) Complete(ctx context.Context, messages []types.Message, opts *StreamOptions) (*CompleteResponse, error) {
+	step, err := mp.nextStep(messages, opts, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sleepContext(ctx, step.Delay); err != nil {
+		return nil, err
+	}
+	if step.Err != nil {
+		return nil, step.Err
+	}
+
+	content := make([]types.ContentBlock, 0, len(step.ToolCalls)+2)
+	if step.Thinking != "" {
+		content = append(content, &types.ThinkingBlock{Thinking: step.Thinking, Signature: mockSignature})
+	}
+	if text := step.text(); text != "" {
+		content = append(content, &types.TextBlock{Text: text})
+	}
+	for i, call := range step.ToolCalls {
+		input := call.Input
+		if len(call.PartialJSON) > 0 {
+			input = make(map[string]interface{})
+			joined := ""
+			for _, part := range call.PartialJSON {
+				joined += part
+			}
+			if err := json.Unmarshal([]byte(joined), &input); err != nil {
+				input = make(map[string]interface{})
+			}
+		}
+		if input == nil {
+			input = make(map[string]interface{})
+		}
+		content = append(content, &types.ToolUseBlock{
+			ID:    call.id(i),
+			Name:  call.Name,
+			Input: input,
+		})
+	}
+
+	return &CompleteResponse{
+		Message: types.Message{
+			Role:    types.MessageRoleAssistant,
+			Content: content,
+		},
+		Usage: step.Usage,
+	}, nil
+}
+
+// mockSignature 模拟思考块的签名
+const mockSignature = "mock-signature"
+
+// text 返回完整文本
+func (s MockStep) text() string {
+	if len(s.TextChunks) == 0 {
+		return s.Text
+	}
+	text := ""
+	for _, chunk := range s.TextChunks {
+		text += chunk
+	}
+	return text
+}
+
+// id 返回调用 ID,未指定时按位置生成
+func (c MockToolCall) id(i int) string {
+	if c.ID != "" {
+		return c.ID
+	}
+	return fmt.Sprintf("toolu_mock_%d", i+1)
+}
+
+// mockStreamChunks 把脚本步骤转换为流式数据块
+func mockStreamChunks(step MockStep) []StreamChunk {
+	chunks := make([]StreamChunk, 0)
+	index := 0
+
+	if step.Thinking != "" {
+		chunks = append(chunks,
+			StreamChunk{
+				Type:  "content_block_start",
+				Index: index,
+				Delta: map[string]interface{}{"type": "thinking", "thinking": ""},
+			},
+			StreamChunk{
+				Type:  "content_block_delta",
+				Index: index,
+				Delta: map[string]interface{}{"type": "thinking_delta", "thinking": step.Thinking},
+			},
+			StreamChunk{
+				Type:  "content_block_delta",
+				Index: index,
+				Delta: map[string]interface{}{"type": "signature_delta", "signature": mockSignature},
+			},
+			StreamChunk{Type: "content_block_stop", Index: index},
+		)
+		index++
+	}
+
+	textChunks := step.TextChunks
+	if len(textChunks) == 0 && step.Text != "" {
+		textChunks = []string{step.Text}
+	}
+	if len(textChunks) > 0 {
+		chunks = append(chunks, StreamChunk{
+			Type:  "content_block_start",
+			Index: index,
+			Delta: map[string]interface{}{"type": "text", "text": ""},
+		})
+		for _, text := range textChunks {
+			chunks = append(chunks, StreamChunk{
+				Type:  "content_block_delta",
+				Index: index,
+				Delta: map[string]interface{}{"type": "text_delta", "text": text},
+			})
+		}
+		chunks = append(chunks, StreamChunk{Type: "content_block_stop", Index: index})
+		index++
+	}
+
+	for i, call := range step.ToolCalls {
+		chunks = append(chunks, StreamChunk{
+			Type:  "content_block_start",
+			Index: index,
+			Delta: map[string]interface{}{
+				"type": "tool_use",
+				"id":   call.id(i),
+				"name": call.Name,
+			},
+		})
+
+		parts := call.PartialJSON
+		if len(parts) == 0 {
+			input := call.Input
+			if input == nil {
+				input = make(map[string]interface{})
+			}
+			data, _ := json.Marshal(input)
+			parts = []string{string(data)}
+		}
+		for _, part := range parts {
+			chunks = append(chunks, StreamChunk{
+				Type:  "content_block_delta",
+				Index: index,
+				Delta: map[string]interface{}{"type": "input_json_delta", "partial_json": part},
+			})
+		}
+		chunks = append(chunks, StreamChunk{Type: "content_block_stop", Index: index})
+		index++
+	}
+
+	stopReason := "end_turn"
+	if len(step.ToolCalls) > 0 {
+		stopReason = "tool_use"
+	}
+	chunks = append(chunks, StreamChunk{
+		Type:  "message_delta",
+		Delta: map[string]interface{}{"stop_reason": stopReason},
+		Usage: step.Usage,
+	})
+
+	return chunks
+}
+
+// Config 返回配置
+func (mp *MockProvider) Config() *types.ModelConfig {
+	return mp.config
+}
+
+// Capabilities 返回模型能力
+func (mp *MockProvider) Capabilities() ProviderCapabilities {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	return mp.capabilities
+}
+
+// SetSystemPrompt 设置系统提示词
+func (mp *MockProvider) SetSystemPrompt(prompt string) error {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	mp.systemPrompt = prompt
+	return nil
+}
+
+// GetSystemPrompt 获取系统提示词
+func (mp *MockProvider) GetSystemPrompt() string {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	return mp.systemPrompt
+}
+
+// Close 关闭连接
+func (mp *MockProvider) Close() error {
+	return nil
+}
+
+// Create 实现 Factory 接口,总是返回自身
+// 便于直接作为 agent.Dependencies.ProviderFactory 使用
+func (mp *MockProvider) Create(config *types.ModelConfig) (Provider, error) {
+	return mp, nil
+}
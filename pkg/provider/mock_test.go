@@ -0,0 +1,103 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+func TestMockProvider_ScriptedSteps(t *testing.T) {
+	mp := NewMockProvider(
+		MockStep{
+			TextChunks: []string{"Let me ", "look."},
+			ToolCalls: []MockToolCall{
+				{Name: "Read", PartialJSON: []string{`{"pa`, `th":"a.txt"}`}},
+			},
+			Usage: &TokenUsage{InputTokens: 10, OutputTokens: 5},
+		},
+		MockErrorStep(errors.New("overloaded")),
+		MockTextStep("done"),
+	)
+
+	ch, err := mp.Stream(context.Background(), testMessages, &StreamOptions{System: "sys", Tools: testToolSchemas})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+
+	var text, args string
+	var toolStart map[string]interface{}
+	var usage *TokenUsage
+	for _, chunk := range collectChunks(t, ch) {
+		delta, _ := chunk.Delta.(map[string]interface{})
+		switch chunk.Type {
+		case "content_block_start":
+			if delta["type"] == "tool_use" {
+				toolStart = delta
+			}
+		case "content_block_delta":
+			if delta["type"] == "text_delta" {
+				text += delta["text"].(string)
+			} else {
+				args += delta["partial_json"].(string)
+			}
+		case "message_delta":
+			usage = chunk.Usage
+		}
+	}
+
+	if text != "Let me look." || args != `{"path":"a.txt"}` {
+		t.Errorf("Unexpected stream content: text=%q args=%q", text, args)
+	}
+	if toolStart["id"] != "toolu_mock_1" || toolStart["name"] != "Read" {
+		t.Errorf("Unexpected tool start: %v", toolStart)
+	}
+	if usage == nil || usage.InputTokens != 10 {
+		t.Errorf("Unexpected usage: %+v", usage)
+	}
+
+	if _, err := mp.Complete(context.Background(), testMessages, nil); err == nil || err.Error() != "overloaded" {
+		t.Errorf("Expected scripted error, got %v", err)
+	}
+
+	resp, err := mp.Complete(context.Background(), testMessages, nil)
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if tb, ok := resp.Message.Content[0].(*types.TextBlock); !ok || tb.Text != "done" {
+		t.Errorf("Unexpected response: %v", resp.Message.Content)
+	}
+
+	// 脚本用完后返回错误
+	if _, err := mp.Complete(context.Background(), testMessages, nil); err == nil {
+		t.Error("Expected error when script is exhausted")
+	}
+
+	requests := mp.Requests()
+	if len(requests) != 4 {
+		t.Fatalf("Expected 4 recorded requests, got %d", len(requests))
+	}
+	if !requests[0].Stream || requests[0].System != "sys" || len(requests[0].Options.Tools) != 1 {
+		t.Errorf("Unexpected first request: %+v", requests[0])
+	}
+	if requests[1].Stream {
+		t.Errorf("Expected second request to be non-streaming")
+	}
+}
+
+func TestMockProvider_DelayHonoursContext(t *testing.T) {
+	mp := NewMockProvider(MockStep{Text: "slow", Delay: time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := mp.Stream(ctx, testMessages, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Delay did not honour context cancellation")
+	}
+}
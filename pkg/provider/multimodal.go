@@ -0,0 +1,177 @@
+package provider
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// supportsVision 配置中显式声明时以配置为准,否则使用按模型推断的结果
+func supportsVision(config *types.ModelConfig, inferred bool) bool {
+	if config != nil && config.Vision != nil {
+		return *config.Vision
+	}
+	return inferred
+}
+
+// anthropicModelSupportsVision Claude 3 及之后的模型都支持图片和 PDF 输入
+func anthropicModelSupportsVision(model string) bool {
+	m := strings.ToLower(model)
+	return !strings.HasPrefix(m, "claude-2") && !strings.HasPrefix(m, "claude-instant")
+}
+
+// openAIVisionModels 支持图片输入的 OpenAI 模型前缀
+var openAIVisionModels = []string{"gpt-4o", "chatgpt-4o", "gpt-4.1", "gpt-4-turbo", "gpt-4-vision", "gpt-5", "o1", "o3", "o4"}
+
+// openAIModelSupportsVision 按模型名称推断 OpenAI 模型是否支持图片输入
+func openAIModelSupportsVision(model string) bool {
+	m := strings.ToLower(model)
+	if m == "o1-mini" || strings.HasPrefix(m, "o1-mini-") || m == "o3-mini" || strings.HasPrefix(m, "o3-mini-") {
+		return false
+	}
+	for _, prefix := range openAIVisionModels {
+		if strings.HasPrefix(m, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// glmVisionModel GLM 视觉模型名称以 v 结尾,如 glm-4v、glm-4.5v
+var glmVisionModel = regexp.MustCompile(`^glm-[0-9.]+v`)
+
+// glmModelSupportsVision 按模型名称推断 GLM 模型是否支持图片输入
+func glmModelSupportsVision(model string) bool {
+	return glmVisionModel.MatchString(strings.ToLower(model))
+}
+
+// isMediaBlock 是否为图片或文档块
+func isMediaBlock(block types.ContentBlock) bool {
+	switch block.(type) {
+	case *types.ImageBlock, *types.DocumentBlock:
+		return true
+	}
+	return false
+}
+
+// containsMedia 内容块中是否有图片或文档
+func containsMedia(blocks []types.ContentBlock) bool {
+	for _, block := range blocks {
+		if isMediaBlock(block) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasMedia 消息(含工具结果)中是否包含图片或文档
+func hasMedia(messages []types.Message) bool {
+	for _, msg := range messages {
+		if containsMedia(msg.Content) {
+			return true
+		}
+		for _, block := range msg.Content {
+			if tr, ok := block.(*types.ToolResultBlock); ok && containsMedia(toolResultBlocks(tr.Content)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// toolResultBlocks 工具结果为内容块列表时(如 fs_read 读取图片)返回这些块,否则返回 nil
+func toolResultBlocks(content interface{}) []types.ContentBlock {
+	blocks, _ := content.([]types.ContentBlock)
+	return blocks
+}
+
+// dataURL 构建 base64 data URL
+func dataURL(mediaType, data string) string {
+	return fmt.Sprintf("data:%s;base64,%s", mediaType, data)
+}
+
+// documentText 解码纯文本文档的内容
+func documentText(b *types.DocumentBlock) (string, bool) {
+	if !types.IsTextMediaType(b.MediaType) || b.Data == "" {
+		return "", false
+	}
+	data, err := base64.StdEncoding.DecodeString(b.Data)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// documentName 文档的文件名
+func documentName(b *types.DocumentBlock) string {
+	switch {
+	case b.Title != "":
+		return b.Title
+	case b.Path != "":
+		return path.Base(b.Path)
+	case b.MediaType == "application/pdf":
+		return "document.pdf"
+	default:
+		return "document"
+	}
+}
+
+// mediaLabel 媒体块的简短描述,如 "image screenshot.png (image/png)"
+func mediaLabel(block types.ContentBlock) string {
+	kind, source, mediaType := "", "", ""
+	switch b := block.(type) {
+	case *types.ImageBlock:
+		kind, mediaType = "image", b.MediaType
+		source = b.Path
+		if source == "" {
+			source = b.URL
+		}
+	case *types.DocumentBlock:
+		kind, mediaType = "document", b.MediaType
+		source = b.Path
+		if source == "" {
+			source = b.Title
+		}
+	}
+
+	label := kind
+	if source != "" {
+		label += " " + source
+	}
+	if mediaType != "" {
+		label += " (" + mediaType + ")"
+	}
+	return label
+}
+
+// renderContentText 把内容块渲染为纯文本
+// 纯文本文档直接展开;attachable 为 nil 或返回 false 的媒体块替换为占位说明,
+// 其余媒体块会以多模态形式另行发送,这里只保留引用
+func renderContentText(blocks []types.ContentBlock, attachable func(types.ContentBlock) bool) string {
+	parts := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		switch b := block.(type) {
+		case *types.TextBlock:
+			if b.Text != "" {
+				parts = append(parts, b.Text)
+			}
+		case *types.ImageBlock, *types.DocumentBlock:
+			if doc, ok := b.(*types.DocumentBlock); ok {
+				if text, ok := documentText(doc); ok {
+					parts = append(parts, fmt.Sprintf("[%s]\n%s", mediaLabel(doc), text))
+					continue
+				}
+			}
+			if attachable != nil && attachable(block) {
+				parts = append(parts, fmt.Sprintf("[%s attached]", mediaLabel(block)))
+			} else {
+				parts = append(parts, fmt.Sprintf("[%s omitted: the model does not accept this input]", mediaLabel(block)))
+			}
+		}
+	}
+	return strings.Join(parts, "\n")
+}
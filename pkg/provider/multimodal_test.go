@@ -0,0 +1,183 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+var testImageData = base64.StdEncoding.EncodeToString([]byte("\x89PNG fake"))
+
+// multimodalTestMessages 用户发送截图,随后 fs_read 返回一张图片
+func multimodalTestMessages() []types.Message {
+	return []types.Message{
+		{Role: types.MessageRoleUser, Content: []types.ContentBlock{
+			&types.TextBlock{Text: "what is wrong in this screenshot?"},
+			&types.ImageBlock{MediaType: "image/png", Data: testImageData, Path: "shot.png"},
+			&types.DocumentBlock{MediaType: "application/pdf", Data: testImageData, Path: "spec.pdf"},
+		}},
+		{Role: types.MessageRoleAssistant, Content: []types.ContentBlock{
+			&types.ToolUseBlock{ID: "call_1", Name: "fs_read", Input: map[string]interface{}{"path": "diagram.png"}},
+		}},
+		{Role: types.MessageRoleUser, Content: []types.ContentBlock{
+			&types.ToolResultBlock{ToolUseID: "call_1", Content: []types.ContentBlock{
+				&types.TextBlock{Text: "Read diagram.png"},
+				&types.ImageBlock{MediaType: "image/png", Data: testImageData, Path: "diagram.png"},
+			}},
+		}},
+	}
+}
+
+func TestAnthropicProvider_ConvertImagesAndDocuments(t *testing.T) {
+	p, err := NewAnthropicProvider(&types.ModelConfig{Provider: "anthropic", Model: "claude-sonnet-4", APIKey: "k"})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	if !p.Capabilities().SupportVision {
+		t.Fatalf("Expected Claude to support vision")
+	}
+
+	messages := p.convertMessages(multimodalTestMessages())
+
+	content := messages[0]["content"].([]interface{})
+	image := content[1].(map[string]interface{})
+	source := image["source"].(map[string]interface{})
+	if image["type"] != "image" || source["type"] != "base64" || source["media_type"] != "image/png" || source["data"] != testImageData {
+		t.Errorf("Unexpected image block: %v", image)
+	}
+	if doc := content[2].(map[string]interface{}); doc["type"] != "document" {
+		t.Errorf("Expected document block, got %v", doc)
+	}
+
+	result := messages[2]["content"].([]interface{})[0].(map[string]interface{})
+	inner := result["content"].([]interface{})
+	if len(inner) != 2 || inner[1].(map[string]interface{})["type"] != "image" {
+		t.Errorf("Expected image inside tool_result, got %v", result["content"])
+	}
+}
+
+func TestAnthropicProvider_ConvertMediaWithoutVision(t *testing.T) {
+	vision := false
+	p, err := NewAnthropicProvider(&types.ModelConfig{Provider: "anthropic", Model: "claude-test", APIKey: "k", Vision: &vision})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	messages := p.convertMessages(multimodalTestMessages())
+	image := messages[0]["content"].([]interface{})[1].(map[string]interface{})
+	if image["type"] != "text" || !strings.Contains(image["text"].(string), "shot.png") {
+		t.Errorf("Expected text placeholder for image, got %v", image)
+	}
+}
+
+func TestOpenAIProvider_ConvertImagesAndDocuments(t *testing.T) {
+	p, err := NewOpenAIProvider(&types.ModelConfig{Provider: "openai", Model: "gpt-4o", APIKey: "k"})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	messages := p.convertMessages(multimodalTestMessages(), "")
+	if len(messages) != 4 {
+		t.Fatalf("Expected user, assistant, tool and attachment messages, got %d", len(messages))
+	}
+
+	parts := messages[0]["content"].([]interface{})
+	if len(parts) != 3 {
+		t.Fatalf("Expected 3 content parts, got %v", parts)
+	}
+	image := parts[1].(map[string]interface{})
+	if image["type"] != "image_url" || image["image_url"].(map[string]interface{})["url"] != "data:image/png;base64,"+testImageData {
+		t.Errorf("Unexpected image part: %v", image)
+	}
+	file := parts[2].(map[string]interface{})
+	if file["type"] != "file" || file["file"].(map[string]interface{})["filename"] != "spec.pdf" {
+		t.Errorf("Unexpected file part: %v", file)
+	}
+
+	// tool 消息只能是文本,图片随后以用户消息发送
+	tool := messages[2]
+	if tool["role"] != "tool" || !strings.Contains(tool["content"].(string), "diagram.png (image/png) attached") {
+		t.Errorf("Unexpected tool message: %v", tool)
+	}
+	follow := messages[3]
+	followParts, _ := follow["content"].([]interface{})
+	if follow["role"] != "user" || len(followParts) != 2 || followParts[1].(map[string]interface{})["type"] != "image_url" {
+		t.Errorf("Expected follow-up user message with tool image, got %v", follow)
+	}
+}
+
+func TestDeepseekProvider_ConvertImagesWithoutVision(t *testing.T) {
+	p, err := NewDeepseekProvider(&types.ModelConfig{Provider: "deepseek", Model: "deepseek-chat", APIKey: "k"})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	messages := p.convertMessages(multimodalTestMessages(), "")
+	if len(messages) != 3 {
+		t.Fatalf("Expected no attachment message without vision, got %d messages", len(messages))
+	}
+	for _, part := range messages[0]["content"].([]interface{}) {
+		if part.(map[string]interface{})["type"] != "text" {
+			t.Errorf("Expected only text parts without vision, got %v", part)
+		}
+	}
+	if tool := messages[2]["content"].(string); !strings.Contains(tool, "diagram.png (image/png) omitted") {
+		t.Errorf("Expected placeholder in tool message, got %q", tool)
+	}
+}
+
+func TestOllamaProvider_Images(t *testing.T) {
+	var reqBody map[string]interface{}
+	server := newOllamaServer(t, []string{"completion", "vision"}, []string{
+		`{"message":{"role":"assistant","content":"a diagram"},"done":true,"done_reason":"stop"}`,
+	}, &reqBody)
+	defer server.Close()
+
+	p, err := NewOllamaProvider(&types.ModelConfig{Provider: "ollama", Model: "llava", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	ch, err := p.Stream(context.Background(), multimodalTestMessages(), nil)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	collectChunks(t, ch)
+
+	messages := reqBody["messages"].([]interface{})
+	first := messages[0].(map[string]interface{})
+	if images, _ := first["images"].([]interface{}); len(images) != 1 || images[0] != testImageData {
+		t.Errorf("Expected user image, got %v", first["images"])
+	}
+	if !strings.Contains(first["content"].(string), "spec.pdf (application/pdf) omitted") {
+		t.Errorf("Expected PDF placeholder, got %q", first["content"])
+	}
+
+	last := messages[len(messages)-1].(map[string]interface{})
+	if images, _ := last["images"].([]interface{}); last["role"] != "user" || len(images) != 1 {
+		t.Errorf("Expected tool image in follow-up user message, got %v", last)
+	}
+}
+
+func TestModelSupportsVision(t *testing.T) {
+	cases := map[string]bool{
+		"gpt-4o-mini": true,
+		"gpt-4.1":     true,
+		"o3-mini":     false,
+		"gpt-3.5":     false,
+	}
+	for model, want := range cases {
+		if got := openAIModelSupportsVision(model); got != want {
+			t.Errorf("openAIModelSupportsVision(%q) = %v, want %v", model, got, want)
+		}
+	}
+	if !glmModelSupportsVision("glm-4.5v") || glmModelSupportsVision("glm-4.6") {
+		t.Errorf("Unexpected GLM vision detection")
+	}
+	if anthropicModelSupportsVision("claude-2.1") || !anthropicModelSupportsVision("claude-3-5-sonnet") {
+		t.Errorf("Unexpected Claude vision detection")
+	}
+}
@@ -0,0 +1,609 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+const (
+	defaultOllamaBaseURL = "http://localhost:11434"
+
+	// ollamaShowTimeout 查询模型信息的超时时间
+	ollamaShowTimeout = 10 * time.Second
+)
+
+// OllamaProvider Ollama 本地模型提供商
+// 使用 Ollama 原生的 /api/chat 接口(NDJSON 流式格式),
+// 模型能力通过 /api/show 查询,不支持工具调用的模型不会收到工具定义
+type OllamaProvider struct {
+	config       *types.ModelConfig
+	client       *http.Client
+	baseURL      string
+	apiKey       string
+	systemPrompt string
+
+	capsMu sync.Mutex
+	caps   *ProviderCapabilities // 从 /api/show 获取的能力(成功后缓存)
+}
+
+// NewOllamaProvider 创建 Ollama 提供商
+func NewOllamaProvider(config *types.ModelConfig) (*OllamaProvider, error) {
+	if config.Model == "" {
+		return nil, fmt.Errorf("ollama model is required")
+	}
+
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = defaultOllamaBaseURL
+	}
+
+	return &OllamaProvider{
+		config:  config,
+		client:  &http.Client{},
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  config.APIKey,
+	}, nil
+}
+
+// newHTTPRequest 创建 HTTP 请求并设置请求头
+func (op *OllamaProvider) newHTTPRequest(ctx context.Context, path string, reqBody map[string]interface{}) (*http.Request, error) {
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", op.baseURL+path, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if op.apiKey != "" {
+		// 经过反向代理或 Ollama 云服务时需要鉴权
+		req.Header.Set("Authorization", "Bearer "+op.apiKey)
+	}
+
+	return req, nil
+}
+
+// Complete 非流式对话(阻塞式,返回完整响应)
+func (op *OllamaProvider) Complete(ctx context.Context, messages []types.Message, opts *StreamOptions) (*CompleteResponse, error) {
+	reqBody := op.buildRequest(ctx, messages, opts)
+	reqBody["stream"] = false
+
+	req, err := op.newHTTPRequest(ctx, "/api/chat", reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := op.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		log.Printf("[OllamaProvider] API error response: %s", string(body))
+		return nil, newAPIError("ollama", resp, body)
+	}
+
+	var apiResp map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	message, ok := apiResp["message"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("parse response: no message in response")
+	}
+
+	assistantContent := make([]types.ContentBlock, 0)
+	if thinking, ok := message["thinking"].(string); ok && thinking != "" {
+		assistantContent = append(assistantContent, &types.ThinkingBlock{Thinking: thinking})
+	}
+	if content, ok := message["content"].(string); ok && content != "" {
+		assistantContent = append(assistantContent, &types.TextBlock{Text: content})
+	}
+
+	if toolCalls, ok := message["tool_calls"].([]interface{}); ok {
+		for _, tc := range toolCalls {
+			toolCall, ok := tc.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id, name, input := parseOllamaToolCall(toolCall)
+			assistantContent = append(assistantContent, &types.ToolUseBlock{
+				ID:    id,
+				Name:  name,
+				Input: input,
+			})
+		}
+	}
+
+	return &CompleteResponse{
+		Message: types.Message{
+			Role:    types.MessageRoleAssistant,
+			Content: assistantContent,
+		},
+		Usage: parseOllamaUsage(apiResp),
+	}, nil
+}
+
+// Stream 流式对话
+func (op *OllamaProvider) Stream(ctx context.Context, messages []types.Message, opts *StreamOptions) (<-chan StreamChunk, error) {
+	reqBody := op.buildRequest(ctx, messages, opts)
+
+	req, err := op.newHTTPRequest(ctx, "/api/chat", reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := op.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		log.Printf("[OllamaProvider] API error response: %s", string(body))
+		return nil, newAPIError("ollama", resp, body)
+	}
+
+	chunkCh := make(chan StreamChunk, 10)
+
+	go op.processStream(ctx, resp.Body, chunkCh)
+
+	return chunkCh, nil
+}
+
+// buildRequest 构建请求体
+func (op *OllamaProvider) buildRequest(ctx context.Context, messages []types.Message, opts *StreamOptions) map[string]interface{} {
+	system := op.systemPrompt
+	if opts != nil && opts.System != "" {
+		system = opts.System
+	}
+
+	// 只有消息中带图片时才需要查询模型能力
+	vision := hasMedia(messages) && op.capabilities(ctx).SupportVision
+
+	req := map[string]interface{}{
+		"model":    op.config.Model,
+		"messages": op.convertMessages(messages, system, vision),
+		"stream":   true,
+	}
+
+	if op.config.ThinkingBudgetTokens > 0 {
+		req["think"] = true
+	}
+
+	if opts != nil && opts.ResponseFormat != nil {
+		// format 可以是 "json" 或完整的 JSON Schema
+		if rf := opts.ResponseFormat; rf.Type == ResponseFormatJSONSchema && rf.Schema != nil {
+			req["format"] = rf.Schema
+		} else {
+			req["format"] = "json"
+		}
+	}
+
+	if opts == nil {
+		return req
+	}
+
+	options := make(map[string]interface{})
+	if opts.MaxTokens > 0 {
+		options["num_predict"] = opts.MaxTokens
+	}
+	if opts.Temperature > 0 {
+		options["temperature"] = opts.Temperature
+	}
+	if len(options) > 0 {
+		req["options"] = options
+	}
+
+	if len(opts.Tools) > 0 {
+		if !op.capabilities(ctx).SupportToolCalling {
+			// 模型不支持工具调用,发送工具定义会导致请求失败
+			log.Printf("[OllamaProvider] Model %s does not support tools, dropping %d tool schemas", op.config.Model, len(opts.Tools))
+			return req
+		}
+
+		tools := make([]map[string]interface{}, 0, len(opts.Tools))
+		for _, tool := range opts.Tools {
+			tools = append(tools, map[string]interface{}{
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":        tool.Name,
+					"description": tool.Description,
+					"parameters":  tool.InputSchema,
+				},
+			})
+		}
+		req["tools"] = tools
+	}
+
+	return req
+}
+
+// convertMessages 转换消息格式(Ollama /api/chat 格式)
+// vision 为 false 时图片替换为文本说明
+func (op *OllamaProvider) convertMessages(messages []types.Message, system string, vision bool) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(messages)+1)
+
+	if system != "" {
+		result = append(result, map[string]interface{}{
+			"role":    "system",
+			"content": system,
+		})
+	}
+
+	// 工具结果需要带上工具名称,记录 tool_use ID 到名称的映射
+	toolNames := make(map[string]string)
+	turnStart := lastUserTurn(messages)
+
+	for i, msg := range messages {
+		switch msg.Role {
+		case types.MessageRoleSystem:
+			if text := joinTextBlocks(msg.Content); text != "" {
+				result = append(result, map[string]interface{}{
+					"role":    "system",
+					"content": text,
+				})
+			}
+
+		case types.MessageRoleAssistant:
+			toolCalls := make([]map[string]interface{}, 0)
+			for _, block := range msg.Content {
+				if b, ok := block.(*types.ToolUseBlock); ok {
+					toolNames[b.ID] = b.Name
+					// Ollama 的 arguments 是 JSON 对象而不是字符串
+					toolCalls = append(toolCalls, map[string]interface{}{
+						"function": map[string]interface{}{
+							"name":      b.Name,
+							"arguments": b.Input,
+						},
+					})
+				}
+			}
+
+			msgMap := map[string]interface{}{
+				"role":    "assistant",
+				"content": joinTextBlocks(msg.Content),
+			}
+			if len(toolCalls) > 0 {
+				msgMap["tool_calls"] = toolCalls
+			}
+			// 当前轮工具调用续写时回传思考内容,让模型接着之前的推理继续
+			if i > turnStart {
+				if thinking := joinThinkingBlocks(msg.Content); thinking != "" {
+					msgMap["thinking"] = thinking
+				}
+			}
+			result = append(result, msgMap)
+
+		default:
+			// 图片以 base64 放在 images 字段;工具返回的图片随后面的用户消息发送
+			attachable := func(block types.ContentBlock) bool {
+				image, ok := block.(*types.ImageBlock)
+				return ok && vision && image.Data != ""
+			}
+			images := make([]string, 0)
+			userBlocks := make([]types.ContentBlock, 0, len(msg.Content))
+			for _, block := range msg.Content {
+				tr, ok := block.(*types.ToolResultBlock)
+				if !ok {
+					userBlocks = append(userBlocks, block)
+					if attachable(block) {
+						images = append(images, block.(*types.ImageBlock).Data)
+					}
+					continue
+				}
+
+				content := toolResultText(tr.Content)
+				if blocks := toolResultBlocks(tr.Content); blocks != nil {
+					content = renderContentText(blocks, attachable)
+					for _, b := range blocks {
+						if attachable(b) {
+							images = append(images, b.(*types.ImageBlock).Data)
+						}
+					}
+				}
+				toolMsg := map[string]interface{}{
+					"role":    "tool",
+					"content": content,
+				}
+				if name, ok := toolNames[tr.ToolUseID]; ok {
+					toolMsg["tool_name"] = name
+				}
+				result = append(result, toolMsg)
+			}
+
+			text := renderContentText(userBlocks, attachable)
+			if text != "" || len(images) > 0 {
+				userMsg := map[string]interface{}{
+					"role":    "user",
+					"content": text,
+				}
+				if len(images) > 0 {
+					userMsg["images"] = images
+				}
+				result = append(result, userMsg)
+			}
+		}
+	}
+
+	return result
+}
+
+// processStream 处理 NDJSON 流式响应
+// 每行是一个完整的 JSON 对象,最后一行 done=true 并携带用量统计
+func (op *OllamaProvider) processStream(ctx context.Context, body io.ReadCloser, chunkCh chan<- StreamChunk) {
+	defer close(chunkCh)
+	defer body.Close()
+
+	state := newOpenAIStreamState()
+	toolCount := 0
+	emit := func(chunks []StreamChunk) bool {
+		for _, chunk := range chunks {
+			select {
+			case chunkCh <- chunk:
+			case <-ctx.Done():
+				return false
+			}
+		}
+		return true
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSSELineSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			log.Printf("[OllamaProvider] Failed to parse JSON: %v, data: %s", err, line)
+			continue
+		}
+
+		if errMsg, ok := event["error"].(string); ok {
+			log.Printf("[OllamaProvider] Stream error: %s", errMsg)
+			emit(state.finish())
+			return
+		}
+
+		var chunks []StreamChunk
+		if message, ok := event["message"].(map[string]interface{}); ok {
+			if thinking, ok := message["thinking"].(string); ok && thinking != "" {
+				chunks = append(chunks, state.thinkingDelta(thinking)...)
+			}
+
+			if content, ok := message["content"].(string); ok && content != "" {
+				chunks = append(chunks, state.textDelta(content)...)
+			}
+
+			if toolCalls, ok := message["tool_calls"].([]interface{}); ok {
+				for _, tc := range toolCalls {
+					toolCall, ok := tc.(map[string]interface{})
+					if !ok {
+						continue
+					}
+
+					// Ollama 一次返回完整的工具调用,转换为 OpenAI 增量格式复用同一套状态机
+					id, name, input := parseOllamaToolCall(toolCall)
+					argsJSON, _ := json.Marshal(input)
+					chunks = append(chunks, state.toolCallDelta(toolCount, map[string]interface{}{
+						"index": float64(toolCount),
+						"id":    id,
+						"function": map[string]interface{}{
+							"name":      name,
+							"arguments": string(argsJSON),
+						},
+					})...)
+					toolCount++
+				}
+			}
+		}
+
+		if done, _ := event["done"].(bool); done {
+			stopReason := "end_turn"
+			if toolCount > 0 {
+				stopReason = "tool_use"
+			} else if reason, _ := event["done_reason"].(string); reason == "length" {
+				stopReason = "max_tokens"
+			}
+
+			chunks = append(chunks, state.closeBlocks()...)
+			state.finished = true
+			chunks = append(chunks, StreamChunk{
+				Type: "message_delta",
+				Delta: map[string]interface{}{
+					"stop_reason": stopReason,
+				},
+				Usage: parseOllamaUsage(event),
+			})
+		}
+
+		if !emit(chunks) {
+			return
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Printf("[OllamaProvider] Scanner error: %v", err)
+	}
+
+	emit(state.finish())
+}
+
+// capabilities 查询模型能力
+// 成功后缓存结果;Ollama 不可达时返回保守的默认值并在下次调用时重试
+func (op *OllamaProvider) capabilities(ctx context.Context) ProviderCapabilities {
+	op.capsMu.Lock()
+	defer op.capsMu.Unlock()
+
+	if op.caps != nil {
+		return *op.caps
+	}
+
+	caps := ProviderCapabilities{
+		SupportToolCalling:  true,
+		SupportSystemPrompt: true,
+		SupportStreaming:    true,
+		SupportVision:       supportsVision(op.config, false),
+		MaxTokens:           8192,
+		MaxToolsPerCall:     0,
+		ToolCallingFormat:   "openai", // Ollama 使用 OpenAI 风格的工具定义
+	}
+
+	showCtx, cancel := context.WithTimeout(ctx, ollamaShowTimeout)
+	defer cancel()
+
+	info, err := op.show(showCtx)
+	if err != nil {
+		log.Printf("[OllamaProvider] Failed to query model info: %v", err)
+		return caps
+	}
+
+	// 旧版本 Ollama 不返回 capabilities,此时按默认值处理
+	if reported, ok := info["capabilities"].([]interface{}); ok {
+		features := make(map[string]bool, len(reported))
+		for _, c := range reported {
+			if s, ok := c.(string); ok {
+				features[s] = true
+			}
+		}
+		caps.SupportToolCalling = features["tools"]
+		caps.SupportVision = supportsVision(op.config, features["vision"])
+	}
+
+	if modelInfo, ok := info["model_info"].(map[string]interface{}); ok {
+		for key, value := range modelInfo {
+			if strings.HasSuffix(key, ".context_length") {
+				if n, ok := value.(float64); ok && n > 0 {
+					caps.MaxTokens = int(n)
+					caps.ContextWindow = int(n)
+				}
+			}
+		}
+	}
+
+	op.caps = &caps
+	return caps
+}
+
+// show 调用 /api/show 获取模型信息
+func (op *OllamaProvider) show(ctx context.Context) (map[string]interface{}, error) {
+	req, err := op.newHTTPRequest(ctx, "/api/show", map[string]interface{}{
+		"model": op.config.Model,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := op.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, newAPIError("ollama", resp, body)
+	}
+
+	var info map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	return info, nil
+}
+
+// Config 返回配置
+func (op *OllamaProvider) Config() *types.ModelConfig {
+	return op.config
+}
+
+// Capabilities 返回模型能力(由模型上报的特性决定)
+func (op *OllamaProvider) Capabilities() ProviderCapabilities {
+	return op.capabilities(context.Background())
+}
+
+// SetSystemPrompt 设置系统提示词
+func (op *OllamaProvider) SetSystemPrompt(prompt string) error {
+	op.systemPrompt = prompt
+	return nil
+}
+
+// GetSystemPrompt 获取系统提示词
+func (op *OllamaProvider) GetSystemPrompt() string {
+	return op.systemPrompt
+}
+
+// Close 关闭连接
+func (op *OllamaProvider) Close() error {
+	return nil
+}
+
+// parseOllamaToolCall 解析工具调用
+// Ollama 通常不返回调用 ID,此时生成一个以便和工具结果配对
+func parseOllamaToolCall(toolCall map[string]interface{}) (string, string, map[string]interface{}) {
+	id, _ := toolCall["id"].(string)
+	if id == "" {
+		id = "call_" + uuid.New().String()
+	}
+
+	fn, _ := toolCall["function"].(map[string]interface{})
+	name, _ := fn["name"].(string)
+
+	input := make(map[string]interface{})
+	switch args := fn["arguments"].(type) {
+	case map[string]interface{}:
+		input = args
+	case string:
+		// 部分兼容实现返回 JSON 字符串
+		if err := json.Unmarshal([]byte(args), &input); err != nil {
+			log.Printf("[OllamaProvider] Failed to parse tool arguments: %v", err)
+			input = make(map[string]interface{})
+		}
+	}
+
+	return id, name, input
+}
+
+// parseOllamaUsage 解析用量统计
+func parseOllamaUsage(event map[string]interface{}) *TokenUsage {
+	promptTokens, hasPrompt := event["prompt_eval_count"].(float64)
+	evalTokens, hasEval := event["eval_count"].(float64)
+	if !hasPrompt && !hasEval {
+		return nil
+	}
+
+	return &TokenUsage{
+		InputTokens:  int64(promptTokens),
+		OutputTokens: int64(evalTokens),
+	}
+}
+
+// OllamaFactory Ollama 工厂
+type OllamaFactory struct{}
+
+// Create 创建 Ollama 提供商
+func (f *OllamaFactory) Create(config *types.ModelConfig) (Provider, error) {
+	return NewOllamaProvider(config)
+}
@@ -0,0 +1,246 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// newOllamaServer 创建模拟的 Ollama 服务器
+func newOllamaServer(t *testing.T, capabilities []string, chatLines []string, chatReq *map[string]interface{}) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/show":
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"capabilities": capabilities,
+				"model_info": map[string]interface{}{
+					"llama.context_length": 32768,
+				},
+			})
+		case "/api/chat":
+			if chatReq != nil {
+				json.NewDecoder(r.Body).Decode(chatReq)
+			}
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			for _, line := range chatLines {
+				fmt.Fprintln(w, line)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+var testToolSchemas = []ToolSchema{{
+	Name:        "Read",
+	Description: "read a file",
+	InputSchema: map[string]interface{}{"type": "object"},
+}}
+
+func TestOllamaProvider_StreamWithToolCalls(t *testing.T) {
+	var reqBody map[string]interface{}
+	server := newOllamaServer(t, []string{"completion", "tools"}, []string{
+		`{"message":{"role":"assistant","content":"Reading "},"done":false}`,
+		`{"message":{"role":"assistant","content":"files."},"done":false}`,
+		`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"Read","arguments":{"path":"a.txt"}}},{"function":{"name":"Read","arguments":{"path":"b.txt"}}}]},"done":false}`,
+		`{"message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":20,"eval_count":8}`,
+	}, &reqBody)
+	defer server.Close()
+
+	p, err := NewOllamaProvider(&types.ModelConfig{Provider: "ollama", Model: "qwen3", BaseURL: server.URL + "/"})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	caps := p.Capabilities()
+	if !caps.SupportToolCalling || caps.SupportVision || caps.MaxTokens != 32768 || caps.ContextWindow != 32768 {
+		t.Errorf("Unexpected capabilities: %+v", caps)
+	}
+
+	ch, err := p.Stream(context.Background(), []types.Message{
+		{Role: types.MessageRoleUser, Content: []types.ContentBlock{&types.TextBlock{Text: "read both"}}},
+	}, &StreamOptions{System: "sys", Tools: testToolSchemas, MaxTokens: 100})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	chunks := collectChunks(t, ch)
+
+	if tools, _ := reqBody["tools"].([]interface{}); len(tools) != 1 {
+		t.Errorf("Expected 1 tool in request, got %v", reqBody["tools"])
+	}
+	if options, _ := reqBody["options"].(map[string]interface{}); options["num_predict"] != float64(100) {
+		t.Errorf("Expected num_predict=100, got %v", reqBody["options"])
+	}
+
+	var text string
+	toolStarts := 0
+	args := make(map[int]string)
+	var usage *TokenUsage
+	var stopReason string
+	for _, chunk := range chunks {
+		delta, _ := chunk.Delta.(map[string]interface{})
+		switch chunk.Type {
+		case "content_block_start":
+			if delta["type"] == "tool_use" {
+				toolStarts++
+				if id, _ := delta["id"].(string); id == "" || delta["name"] != "Read" {
+					t.Errorf("Unexpected tool start: %v", delta)
+				}
+			}
+		case "content_block_delta":
+			if delta["type"] == "text_delta" {
+				text += delta["text"].(string)
+			} else if delta["type"] == "arguments" {
+				args[chunk.Index] += delta["arguments"].(string)
+			}
+		case "message_delta":
+			stopReason, _ = delta["stop_reason"].(string)
+			usage = chunk.Usage
+		}
+	}
+
+	if text != "Reading files." {
+		t.Errorf("Expected text 'Reading files.', got '%s'", text)
+	}
+	if toolStarts != 2 {
+		t.Errorf("Expected 2 tool calls, got %d", toolStarts)
+	}
+	if args[1] != `{"path":"a.txt"}` || args[2] != `{"path":"b.txt"}` {
+		t.Errorf("Unexpected tool arguments: %v", args)
+	}
+	if stopReason != "tool_use" {
+		t.Errorf("Expected stop_reason tool_use, got %s", stopReason)
+	}
+	if usage == nil || usage.InputTokens != 20 || usage.OutputTokens != 8 {
+		t.Errorf("Unexpected usage: %+v", usage)
+	}
+}
+
+func TestOllamaProvider_NoToolSupport(t *testing.T) {
+	var reqBody map[string]interface{}
+	server := newOllamaServer(t, []string{"completion", "vision"}, []string{
+		`{"message":{"role":"assistant","content":"hi"},"done":true,"done_reason":"stop"}`,
+	}, &reqBody)
+	defer server.Close()
+
+	factory := NewMultiProviderFactory()
+	p, err := factory.Create(&types.ModelConfig{Provider: "ollama", Model: "gemma", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	caps := p.Capabilities()
+	if caps.SupportToolCalling || !caps.SupportVision {
+		t.Errorf("Unexpected capabilities: %+v", caps)
+	}
+
+	ch, err := p.Stream(context.Background(), []types.Message{
+		{Role: types.MessageRoleUser, Content: []types.ContentBlock{&types.TextBlock{Text: "hello"}}},
+	}, &StreamOptions{Tools: testToolSchemas})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	collectChunks(t, ch)
+
+	if _, ok := reqBody["tools"]; ok {
+		t.Errorf("Tools should not be sent to a model without tool support")
+	}
+}
+
+func TestOllamaProvider_Complete(t *testing.T) {
+	var reqBody map[string]interface{}
+	server := newOllamaServer(t, []string{"completion", "tools"}, []string{
+		`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"Write","arguments":{"path":"c.txt"}}}]},"done":true,"prompt_eval_count":3,"eval_count":4}`,
+	}, &reqBody)
+	defer server.Close()
+
+	p, err := NewOllamaProvider(&types.ModelConfig{Model: "qwen3", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	resp, err := p.Complete(context.Background(), []types.Message{
+		{Role: types.MessageRoleAssistant, Content: []types.ContentBlock{
+			&types.ToolUseBlock{ID: "call_0", Name: "Read", Input: map[string]interface{}{"path": "a.txt"}},
+		}},
+		{Role: types.MessageRoleUser, Content: []types.ContentBlock{
+			&types.ToolResultBlock{ToolUseID: "call_0", Content: "hello"},
+		}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	if reqBody["stream"] != false {
+		t.Errorf("Expected stream=false, got %v", reqBody["stream"])
+	}
+
+	// 工具调用参数以对象发送,工具结果带上工具名称
+	messages, _ := reqBody["messages"].([]interface{})
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+	assistant := messages[0].(map[string]interface{})
+	toolCalls, _ := assistant["tool_calls"].([]interface{})
+	if len(toolCalls) != 1 {
+		t.Fatalf("Expected 1 tool call, got %v", assistant)
+	}
+	fn := toolCalls[0].(map[string]interface{})["function"].(map[string]interface{})
+	if _, ok := fn["arguments"].(map[string]interface{}); !ok {
+		t.Errorf("Expected object arguments, got %T", fn["arguments"])
+	}
+	if toolMsg := messages[1].(map[string]interface{}); toolMsg["role"] != "tool" || toolMsg["tool_name"] != "Read" {
+		t.Errorf("Unexpected tool message: %v", toolMsg)
+	}
+
+	if len(resp.Message.Content) != 1 {
+		t.Fatalf("Expected 1 content block, got %d", len(resp.Message.Content))
+	}
+	tu, ok := resp.Message.Content[0].(*types.ToolUseBlock)
+	if !ok || tu.ID == "" || tu.Name != "Write" || tu.Input["path"] != "c.txt" {
+		t.Errorf("Unexpected tool use block: %v", resp.Message.Content[0])
+	}
+	if resp.Usage == nil || resp.Usage.InputTokens != 3 || resp.Usage.OutputTokens != 4 {
+		t.Errorf("Unexpected usage: %+v", resp.Usage)
+	}
+}
+
+func TestOllamaProvider_StreamThinking(t *testing.T) {
+	var reqBody map[string]interface{}
+	server := newOllamaServer(t, []string{"completion", "thinking"}, []string{
+		`{"message":{"role":"assistant","content":"","thinking":"Let me think."},"done":false}`,
+		`{"message":{"role":"assistant","content":"Hi!"},"done":false}`,
+		`{"message":{"role":"assistant","content":""},"done":true,"done_reason":"stop"}`,
+	}, &reqBody)
+	defer server.Close()
+
+	p, err := NewOllamaProvider(&types.ModelConfig{Model: "qwen3", BaseURL: server.URL, ThinkingBudgetTokens: 1})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	ch, err := p.Stream(context.Background(), testMessages, &StreamOptions{})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	chunks := collectChunks(t, ch)
+
+	if reqBody["think"] != true {
+		t.Errorf("Expected think=true in request, got %v", reqBody["think"])
+	}
+	start, _ := chunks[0].Delta.(map[string]interface{})
+	if chunks[0].Type != "content_block_start" || start["type"] != "thinking" {
+		t.Fatalf("Expected stream to open with a thinking block, got %+v", chunks[0])
+	}
+	delta, _ := chunks[1].Delta.(map[string]interface{})
+	if delta["thinking"] != "Let me think." {
+		t.Errorf("Unexpected thinking delta: %v", chunks[1].Delta)
+	}
+}
@@ -0,0 +1,932 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+const (
+	defaultOpenAIBaseURL = "https://api.openai.com"
+
+	// maxSSELineSize 单行 SSE 数据的最大长度(工具参数可能很长)
+	maxSSELineSize = 4 * 1024 * 1024
+)
+
+// openAIDialect OpenAI 兼容协议的方言配置
+// 不同厂商(OpenAI/Deepseek/GLM/vLLM...)共用同一套协议实现,仅在这些细节上有差异
+type openAIDialect struct {
+	name         string               // 厂商名称,用于错误信息
+	logPrefix    string               // 日志前缀
+	apiVersion   string               // URL 中的版本段,如 "v1"、"v4"
+	includeUsage bool                 // 流式请求是否携带 stream_options.include_usage
+	capabilities ProviderCapabilities // 模型能力
+
+	// jsonSchema 是否支持 response_format: json_schema,不支持时退化为 json_object 并在提示词中描述 schema
+	jsonSchema bool
+
+	// fileInput 是否支持 type: file 的 PDF 输入(需同时支持视觉)
+	fileInput bool
+
+	// reasoningRoundTrip 工具调用续写时是否回传 reasoning_content
+	// Deepseek/GLM 的思考模式要求同一轮对话内的推理内容随工具结果一起发回
+	reasoningRoundTrip bool
+}
+
+// OpenAIProvider OpenAI Chat Completions 兼容提供商
+// 适用于 OpenAI、vLLM、LM Studio、OpenRouter 以及任何兼容 /v1/chat/completions 的网关
+type OpenAIProvider struct {
+	config       *types.ModelConfig
+	client       *http.Client
+	baseURL      string
+	apiKey       string
+	dialect      openAIDialect
+	systemPrompt string
+}
+
+// NewOpenAIProvider 创建 OpenAI 兼容提供商
+// 未配置 BaseURL 时指向官方 API,此时必须提供 APIKey;
+// 自建网关或本地推理服务(vLLM、LM Studio 等)可以不提供 APIKey
+func NewOpenAIProvider(config *types.ModelConfig) (*OpenAIProvider, error) {
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		if config.APIKey == "" {
+			return nil, fmt.Errorf("openai api key is required")
+		}
+		baseURL = defaultOpenAIBaseURL
+	}
+
+	return newOpenAICompatibleProvider(config, baseURL, openAIDialect{
+		name:         "openai",
+		logPrefix:    "[OpenAIProvider]",
+		apiVersion:   "v1",
+		includeUsage: true,
+		capabilities: ProviderCapabilities{
+			SupportToolCalling:  true,
+			SupportSystemPrompt: true,
+			SupportStreaming:    true,
+			SupportVision:       openAIModelSupportsVision(config.Model),
+			MaxTokens:           128000,
+			MaxToolsPerCall:     0,
+			ContextWindow:       128000,
+			ToolCallingFormat:   "openai",
+		},
+		jsonSchema: true,
+		fileInput:  true,
+	}), nil
+}
+
+// newOpenAICompatibleProvider 按方言创建 OpenAI 兼容提供商
+func newOpenAICompatibleProvider(config *types.ModelConfig, baseURL string, dialect openAIDialect) *OpenAIProvider {
+	dialect.capabilities.SupportVision = supportsVision(config, dialect.capabilities.SupportVision)
+	return &OpenAIProvider{
+		config:  config,
+		client:  &http.Client{},
+		baseURL: baseURL,
+		apiKey:  config.APIKey,
+		dialect: dialect,
+	}
+}
+
+// endpoint 返回 chat/completions 的完整地址
+// baseURL 可以带或不带版本段,如 "https://api.deepseek.com" 或 "http://localhost:8000/v1"
+func (op *OpenAIProvider) endpoint() string {
+	base := strings.TrimSuffix(op.baseURL, "/")
+	if op.dialect.apiVersion != "" && !strings.HasSuffix(base, "/"+op.dialect.apiVersion) {
+		base += "/" + op.dialect.apiVersion
+	}
+	return base + "/chat/completions"
+}
+
+// newHTTPRequest 创建 HTTP 请求并设置请求头
+func (op *OpenAIProvider) newHTTPRequest(ctx context.Context, reqBody map[string]interface{}) (*http.Request, error) {
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", op.endpoint(), bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if op.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+op.apiKey)
+	}
+
+	return req, nil
+}
+
+// Complete 非流式对话(阻塞式,返回完整响应)
+func (op *OpenAIProvider) Complete(ctx context.Context, messages []types.Message, opts *StreamOptions) (*CompleteResponse, error) {
+	// 构建请求体(非流式)
+	reqBody := op.buildRequest(messages, opts)
+	reqBody["stream"] = false
+	delete(reqBody, "stream_options")
+
+	req, err := op.newHTTPRequest(ctx, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := op.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		log.Printf("%s API error response: %s", op.dialect.logPrefix, string(body))
+		return nil, newAPIError(op.dialect.name, resp, body)
+	}
+
+	var apiResp map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	message, err := op.parseCompleteResponse(apiResp)
+	if err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+
+	return &CompleteResponse{
+		Message: message,
+		Usage:   parseOpenAIUsage(apiResp["usage"]),
+	}, nil
+}
+
+// Stream 流式对话
+func (op *OpenAIProvider) Stream(ctx context.Context, messages []types.Message, opts *StreamOptions) (<-chan StreamChunk, error) {
+	reqBody := op.buildRequest(messages, opts)
+
+	if tools, ok := reqBody["tools"].([]map[string]interface{}); ok && len(tools) > 0 {
+		log.Printf("%s Request body includes %d tools", op.dialect.logPrefix, len(tools))
+	}
+
+	req, err := op.newHTTPRequest(ctx, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := op.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		log.Printf("%s API error response: %s", op.dialect.logPrefix, string(body))
+		return nil, newAPIError(op.dialect.name, resp, body)
+	}
+
+	chunkCh := make(chan StreamChunk, 10)
+
+	go op.processStream(ctx, resp.Body, chunkCh)
+
+	return chunkCh, nil
+}
+
+// buildRequest 构建请求体
+func (op *OpenAIProvider) buildRequest(messages []types.Message, opts *StreamOptions) map[string]interface{} {
+	system := op.systemPrompt
+	if opts != nil && opts.System != "" {
+		system = opts.System
+	}
+
+	var responseFormat map[string]interface{}
+	if opts != nil && opts.ResponseFormat != nil {
+		rf := opts.ResponseFormat
+		if rf.Type == ResponseFormatJSONSchema && rf.Schema != nil && op.dialect.jsonSchema {
+			responseFormat = map[string]interface{}{
+				"type": "json_schema",
+				"json_schema": map[string]interface{}{
+					"name":   rf.name(),
+					"schema": rf.Schema,
+					"strict": rf.Strict,
+				},
+			}
+		} else {
+			// json_object 模式要求提示词中出现 "json",同时把 schema 写进系统提示词
+			responseFormat = map[string]interface{}{"type": "json_object"}
+			system = strings.TrimSpace(system + "\n\n" + rf.instructions())
+		}
+	}
+
+	req := map[string]interface{}{
+		"model":    op.config.Model,
+		"messages": op.convertMessages(messages, system),
+		"stream":   true,
+	}
+	if responseFormat != nil {
+		req["response_format"] = responseFormat
+	}
+
+	if op.dialect.includeUsage {
+		req["stream_options"] = map[string]interface{}{
+			"include_usage": true,
+		}
+	}
+
+	req["max_tokens"] = 4096
+	if opts == nil {
+		return req
+	}
+
+	if opts.MaxTokens > 0 {
+		req["max_tokens"] = opts.MaxTokens
+	}
+
+	if opts.Temperature > 0 {
+		req["temperature"] = opts.Temperature
+	}
+
+	if len(opts.Tools) > 0 {
+		tools := make([]map[string]interface{}, 0, len(opts.Tools))
+		toolNames := make([]string, 0, len(opts.Tools))
+		for _, tool := range opts.Tools {
+			tools = append(tools, map[string]interface{}{
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":        tool.Name,
+					"description": tool.Description,
+					"parameters":  tool.InputSchema,
+				},
+			})
+			toolNames = append(toolNames, tool.Name)
+		}
+		req["tools"] = tools
+		log.Printf("%s Sending %d tools to API: %v", op.dialect.logPrefix, len(tools), toolNames)
+	}
+
+	return req
+}
+
+// convertMessages 转换消息格式(OpenAI Chat Completions 格式)
+// system 提示词作为第一条 system 消息发送
+func (op *OpenAIProvider) convertMessages(messages []types.Message, system string) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(messages)+1)
+
+	if system != "" {
+		result = append(result, map[string]interface{}{
+			"role":    "system",
+			"content": system,
+		})
+	}
+
+	// 最后一条用户文本消息之后的 assistant 消息属于当前轮的工具调用续写
+	turnStart := lastUserTurn(messages)
+
+	for i, msg := range messages {
+		switch msg.Role {
+		case types.MessageRoleSystem:
+			// 历史中的 system 消息(如上下文总结)原样保留
+			if text := joinTextBlocks(msg.Content); text != "" {
+				result = append(result, map[string]interface{}{
+					"role":    "system",
+					"content": text,
+				})
+			}
+
+		case types.MessageRoleAssistant:
+			// Assistant 消息: 文本 + tool_calls
+			toolCalls := make([]map[string]interface{}, 0)
+			for _, block := range msg.Content {
+				if b, ok := block.(*types.ToolUseBlock); ok {
+					argsJSON, _ := json.Marshal(b.Input)
+					toolCalls = append(toolCalls, map[string]interface{}{
+						"id":   b.ID,
+						"type": "function",
+						"function": map[string]interface{}{
+							"name":      b.Name,
+							"arguments": string(argsJSON),
+						},
+					})
+				}
+			}
+
+			msgMap := map[string]interface{}{
+				"role": "assistant",
+			}
+			if text := joinTextBlocks(msg.Content); text != "" {
+				msgMap["content"] = text
+			} else if len(toolCalls) == 0 {
+				msgMap["content"] = ""
+			}
+			if len(toolCalls) > 0 {
+				msgMap["tool_calls"] = toolCalls
+			}
+			if op.dialect.reasoningRoundTrip && i > turnStart {
+				if reasoning := joinThinkingBlocks(msg.Content); reasoning != "" {
+					msgMap["reasoning_content"] = reasoning
+				}
+			}
+			result = append(result, msgMap)
+
+		default:
+			// User 消息: 工具结果必须作为独立的 role: "tool" 消息,
+			// 并且紧跟在带 tool_calls 的 assistant 消息之后,所以先于文本发送
+			// tool 消息只能是文本,工具返回的图片随后以用户消息发送
+			var attachments []interface{}
+			userBlocks := make([]types.ContentBlock, 0, len(msg.Content))
+			for _, block := range msg.Content {
+				tr, ok := block.(*types.ToolResultBlock)
+				if !ok {
+					userBlocks = append(userBlocks, block)
+					continue
+				}
+
+				content := toolResultText(tr.Content)
+				if blocks := toolResultBlocks(tr.Content); blocks != nil {
+					content = renderContentText(blocks, op.canAttach)
+					if parts := op.mediaParts(blocks); len(parts) > 0 {
+						attachments = append(attachments, map[string]interface{}{
+							"type": "text",
+							"text": fmt.Sprintf("Attachments returned by tool call %s:", tr.ToolUseID),
+						})
+						attachments = append(attachments, parts...)
+					}
+				}
+				result = append(result, map[string]interface{}{
+					"role":         "tool",
+					"content":      content,
+					"tool_call_id": tr.ToolUseID,
+				})
+			}
+
+			parts := op.contentParts(userBlocks)
+			if len(attachments) == 0 && !containsMedia(userBlocks) {
+				// 纯文本消息保持字符串格式,兼容不支持 content 数组的服务
+				if text := joinTextBlocks(userBlocks); text != "" {
+					result = append(result, map[string]interface{}{
+						"role":    "user",
+						"content": text,
+					})
+				}
+				continue
+			}
+			if parts = append(attachments, parts...); len(parts) > 0 {
+				result = append(result, map[string]interface{}{
+					"role":    "user",
+					"content": parts,
+				})
+			}
+		}
+	}
+
+	return result
+}
+
+// canAttach 媒体块能否以多模态形式发送
+func (op *OpenAIProvider) canAttach(block types.ContentBlock) bool {
+	if !op.dialect.capabilities.SupportVision {
+		return false
+	}
+	switch b := block.(type) {
+	case *types.ImageBlock:
+		return b.Data != "" || b.URL != ""
+	case *types.DocumentBlock:
+		return op.dialect.fileInput && b.MediaType == "application/pdf" && b.Data != ""
+	}
+	return false
+}
+
+// mediaParts 转换可以发送的媒体块
+func (op *OpenAIProvider) mediaParts(blocks []types.ContentBlock) []interface{} {
+	parts := make([]interface{}, 0)
+	for _, block := range blocks {
+		if isMediaBlock(block) && op.canAttach(block) {
+			parts = append(parts, op.mediaPart(block))
+		}
+	}
+	return parts
+}
+
+// mediaPart 转换单个媒体块: 图片使用 image_url,PDF 使用 file
+func (op *OpenAIProvider) mediaPart(block types.ContentBlock) map[string]interface{} {
+	switch b := block.(type) {
+	case *types.ImageBlock:
+		url := b.URL
+		if b.Data != "" {
+			url = dataURL(b.MediaType, b.Data)
+		}
+		return map[string]interface{}{
+			"type":      "image_url",
+			"image_url": map[string]interface{}{"url": url},
+		}
+	case *types.DocumentBlock:
+		return map[string]interface{}{
+			"type": "file",
+			"file": map[string]interface{}{
+				"filename":  documentName(b),
+				"file_data": dataURL(b.MediaType, b.Data),
+			},
+		}
+	}
+	return nil
+}
+
+// contentParts 把用户消息转换为 content 数组,无法发送的媒体块替换为文本说明
+func (op *OpenAIProvider) contentParts(blocks []types.ContentBlock) []interface{} {
+	parts := make([]interface{}, 0, len(blocks))
+	for _, block := range blocks {
+		switch b := block.(type) {
+		case *types.TextBlock:
+			if b.Text != "" {
+				parts = append(parts, map[string]interface{}{"type": "text", "text": b.Text})
+			}
+		case *types.ImageBlock, *types.DocumentBlock:
+			if op.canAttach(block) {
+				parts = append(parts, op.mediaPart(block))
+			} else {
+				parts = append(parts, map[string]interface{}{
+					"type": "text",
+					"text": renderContentText([]types.ContentBlock{block}, nil),
+				})
+			}
+		}
+	}
+	return parts
+}
+
+// processStream 处理流式响应
+func (op *OpenAIProvider) processStream(ctx context.Context, body io.ReadCloser, chunkCh chan<- StreamChunk) {
+	defer close(chunkCh)
+	defer body.Close()
+
+	state := newOpenAIStreamState()
+	emit := func(chunks []StreamChunk) bool {
+		for _, chunk := range chunks {
+			select {
+			case chunkCh <- chunk:
+			case <-ctx.Done():
+				return false
+			}
+		}
+		return true
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSSELineSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// SSE格式: "data: {...}"
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			log.Printf("%s Failed to parse JSON: %v, data: %s", op.dialect.logPrefix, err, data)
+			continue
+		}
+
+		if !emit(state.handleEvent(event)) {
+			return
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Printf("%s Scanner error: %v", op.dialect.logPrefix, err)
+	}
+
+	emit(state.finish())
+}
+
+// openAIStreamState 流式解析状态
+// 把 OpenAI 的 delta 格式转换为 Anthropic 风格的内容块事件,
+// 以便 Agent 用同一套逻辑处理所有提供商的流式响应
+type openAIStreamState struct {
+	nextIndex     int         // 下一个内容块索引
+	thinkingIndex int         // 当前打开的思考块索引, -1 表示没有
+	textIndex     int         // 当前打开的文本块索引, -1 表示没有
+	toolBlocks    map[int]int // OpenAI tool_calls[].index -> 内容块索引
+	openTools     []int       // 尚未关闭的工具块索引(按打开顺序)
+	finished      bool        // 是否已收到 finish_reason
+}
+
+func newOpenAIStreamState() *openAIStreamState {
+	return &openAIStreamState{
+		thinkingIndex: -1,
+		textIndex:     -1,
+		toolBlocks:    make(map[int]int),
+	}
+}
+
+// handleEvent 处理一个 SSE 数据事件,返回转换后的流式块
+func (s *openAIStreamState) handleEvent(event map[string]interface{}) []StreamChunk {
+	var chunks []StreamChunk
+
+	if choices, ok := event["choices"].([]interface{}); ok && len(choices) > 0 {
+		if choice, ok := choices[0].(map[string]interface{}); ok {
+			if delta, ok := choice["delta"].(map[string]interface{}); ok {
+				if reasoning := openAIReasoning(delta); reasoning != "" {
+					chunks = append(chunks, s.thinkingDelta(reasoning)...)
+				}
+
+				if content, ok := delta["content"].(string); ok && content != "" {
+					chunks = append(chunks, s.textDelta(content)...)
+				}
+
+				if toolCalls, ok := delta["tool_calls"].([]interface{}); ok {
+					for i, tc := range toolCalls {
+						if toolCall, ok := tc.(map[string]interface{}); ok {
+							chunks = append(chunks, s.toolCallDelta(i, toolCall)...)
+						}
+					}
+				}
+			}
+
+			if finishReason, ok := choice["finish_reason"].(string); ok && finishReason != "" {
+				chunks = append(chunks, s.closeBlocks()...)
+				s.finished = true
+				chunks = append(chunks, StreamChunk{
+					Type: "message_delta",
+					Delta: map[string]interface{}{
+						"stop_reason": convertOpenAIFinishReason(finishReason),
+					},
+					Usage: parseOpenAIUsage(event["usage"]),
+				})
+				return chunks
+			}
+		}
+	}
+
+	// include_usage 时,用量在一个 choices 为空的独立事件中返回
+	if usage := parseOpenAIUsage(event["usage"]); usage != nil {
+		chunks = append(chunks, StreamChunk{
+			Type:  "message_delta",
+			Usage: usage,
+		})
+	}
+
+	return chunks
+}
+
+// thinkingDelta 处理推理内容增量
+func (s *openAIStreamState) thinkingDelta(text string) []StreamChunk {
+	var chunks []StreamChunk
+	if s.thinkingIndex < 0 {
+		s.thinkingIndex = s.nextIndex
+		s.nextIndex++
+		chunks = append(chunks, StreamChunk{
+			Type:  "content_block_start",
+			Index: s.thinkingIndex,
+			Delta: map[string]interface{}{
+				"type":     "thinking",
+				"thinking": "",
+			},
+		})
+	}
+
+	return append(chunks, StreamChunk{
+		Type:  "content_block_delta",
+		Index: s.thinkingIndex,
+		Delta: map[string]interface{}{
+			"type":     "thinking_delta",
+			"thinking": text,
+		},
+	})
+}
+
+// closeThinking 关闭正在输出的思考块(推理内容总是在正文和工具调用之前)
+func (s *openAIStreamState) closeThinking() []StreamChunk {
+	if s.thinkingIndex < 0 {
+		return nil
+	}
+	chunk := StreamChunk{Type: "content_block_stop", Index: s.thinkingIndex}
+	s.thinkingIndex = -1
+	return []StreamChunk{chunk}
+}
+
+// textDelta 处理文本增量
+func (s *openAIStreamState) textDelta(text string) []StreamChunk {
+	chunks := s.closeThinking()
+	if s.textIndex < 0 {
+		s.textIndex = s.nextIndex
+		s.nextIndex++
+		chunks = append(chunks, StreamChunk{
+			Type:  "content_block_start",
+			Index: s.textIndex,
+			Delta: map[string]interface{}{
+				"type": "text",
+				"text": "",
+			},
+		})
+	}
+
+	return append(chunks, StreamChunk{
+		Type:  "content_block_delta",
+		Index: s.textIndex,
+		Delta: map[string]interface{}{
+			"type": "text_delta",
+			"text": text,
+		},
+	})
+}
+
+// toolCallDelta 处理工具调用增量
+// 同一个事件中可能包含多个并行的 tool_calls,通过 index 区分
+func (s *openAIStreamState) toolCallDelta(position int, toolCall map[string]interface{}) []StreamChunk {
+	var chunks []StreamChunk
+
+	callIndex := position
+	if idx, ok := toolCall["index"].(float64); ok {
+		callIndex = int(idx)
+	}
+
+	fn, _ := toolCall["function"].(map[string]interface{})
+
+	blockIndex, exists := s.toolBlocks[callIndex]
+	if !exists {
+		// 新的工具调用: 先关闭正在输出的思考块和文本块
+		chunks = append(chunks, s.closeThinking()...)
+		if s.textIndex >= 0 {
+			chunks = append(chunks, StreamChunk{Type: "content_block_stop", Index: s.textIndex})
+			s.textIndex = -1
+		}
+
+		blockIndex = s.nextIndex
+		s.nextIndex++
+		s.toolBlocks[callIndex] = blockIndex
+		s.openTools = append(s.openTools, blockIndex)
+
+		toolInfo := map[string]interface{}{
+			"type": "tool_use",
+		}
+		if id, ok := toolCall["id"].(string); ok {
+			toolInfo["id"] = id
+		}
+		if name, ok := fn["name"].(string); ok {
+			toolInfo["name"] = name
+		}
+
+		chunks = append(chunks, StreamChunk{
+			Type:  "content_block_start",
+			Index: blockIndex,
+			Delta: toolInfo,
+		})
+	}
+
+	if arguments, ok := fn["arguments"].(string); ok && arguments != "" {
+		chunks = append(chunks, StreamChunk{
+			Type:  "content_block_delta",
+			Index: blockIndex,
+			Delta: map[string]interface{}{
+				"type":      "arguments",
+				"arguments": arguments,
+			},
+		})
+	}
+
+	return chunks
+}
+
+// closeBlocks 关闭所有打开的内容块
+func (s *openAIStreamState) closeBlocks() []StreamChunk {
+	chunks := s.closeThinking()
+	if s.textIndex >= 0 {
+		chunks = append(chunks, StreamChunk{Type: "content_block_stop", Index: s.textIndex})
+		s.textIndex = -1
+	}
+	for _, idx := range s.openTools {
+		chunks = append(chunks, StreamChunk{Type: "content_block_stop", Index: idx})
+	}
+	s.openTools = nil
+	return chunks
+}
+
+// finish 流结束时调用,关闭没有收到 finish_reason 的内容块
+func (s *openAIStreamState) finish() []StreamChunk {
+	if s.finished {
+		return nil
+	}
+	return s.closeBlocks()
+}
+
+// parseCompleteResponse 解析完整的非流式响应
+func (op *OpenAIProvider) parseCompleteResponse(apiResp map[string]interface{}) (types.Message, error) {
+	assistantContent := make([]types.ContentBlock, 0)
+
+	choices, ok := apiResp["choices"].([]interface{})
+	if !ok || len(choices) == 0 {
+		return types.Message{}, fmt.Errorf("no choices in response")
+	}
+
+	choice, ok := choices[0].(map[string]interface{})
+	if !ok {
+		return types.Message{}, fmt.Errorf("invalid choice format")
+	}
+
+	message, ok := choice["message"].(map[string]interface{})
+	if !ok {
+		return types.Message{}, fmt.Errorf("no message in choice")
+	}
+
+	if reasoning := openAIReasoning(message); reasoning != "" {
+		assistantContent = append(assistantContent, &types.ThinkingBlock{Thinking: reasoning})
+	}
+
+	if content, ok := message["content"].(string); ok && content != "" {
+		assistantContent = append(assistantContent, &types.TextBlock{Text: content})
+	}
+
+	if toolCalls, ok := message["tool_calls"].([]interface{}); ok {
+		for _, tc := range toolCalls {
+			toolCall, ok := tc.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			fn, ok := toolCall["function"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			toolID, _ := toolCall["id"].(string)
+			toolName, _ := fn["name"].(string)
+			argsJSON, _ := fn["arguments"].(string)
+
+			input := make(map[string]interface{})
+			if argsJSON != "" {
+				if err := json.Unmarshal([]byte(argsJSON), &input); err != nil {
+					log.Printf("%s Failed to parse tool arguments: %v", op.dialect.logPrefix, err)
+					input = make(map[string]interface{})
+				}
+			}
+
+			assistantContent = append(assistantContent, &types.ToolUseBlock{
+				ID:    toolID,
+				Name:  toolName,
+				Input: input,
+			})
+		}
+	}
+
+	return types.Message{
+		Role:    types.MessageRoleAssistant,
+		Content: assistantContent,
+	}, nil
+}
+
+// Config 返回配置
+func (op *OpenAIProvider) Config() *types.ModelConfig {
+	return op.config
+}
+
+// Capabilities 返回模型能力
+func (op *OpenAIProvider) Capabilities() ProviderCapabilities {
+	return op.dialect.capabilities
+}
+
+// SetSystemPrompt 设置系统提示词
+func (op *OpenAIProvider) SetSystemPrompt(prompt string) error {
+	op.systemPrompt = prompt
+	return nil
+}
+
+// GetSystemPrompt 获取系统提示词
+func (op *OpenAIProvider) GetSystemPrompt() string {
+	return op.systemPrompt
+}
+
+// Close 关闭连接
+func (op *OpenAIProvider) Close() error {
+	return nil
+}
+
+// parseOpenAIUsage 解析 OpenAI 格式的 usage 字段
+func parseOpenAIUsage(raw interface{}) *TokenUsage {
+	usage, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	promptTokens, _ := usage["prompt_tokens"].(float64)
+	completionTokens, _ := usage["completion_tokens"].(float64)
+
+	// prompt_tokens 包含命中缓存的部分,拆分出来与 Anthropic 的口径保持一致
+	// OpenAI: prompt_tokens_details.cached_tokens; Deepseek: prompt_cache_hit_tokens
+	cachedTokens, _ := usage["prompt_cache_hit_tokens"].(float64)
+	if details, ok := usage["prompt_tokens_details"].(map[string]interface{}); ok && cachedTokens == 0 {
+		cachedTokens, _ = details["cached_tokens"].(float64)
+	}
+	if cachedTokens > promptTokens {
+		cachedTokens = promptTokens
+	}
+
+	return &TokenUsage{
+		InputTokens:          int64(promptTokens - cachedTokens),
+		OutputTokens:         int64(completionTokens),
+		CacheReadInputTokens: int64(cachedTokens),
+	}
+}
+
+// openAIReasoning 读取推理内容
+// Deepseek/GLM/Qwen 使用 reasoning_content,OpenRouter/vLLM 使用 reasoning
+func openAIReasoning(m map[string]interface{}) string {
+	if reasoning, ok := m["reasoning_content"].(string); ok && reasoning != "" {
+		return reasoning
+	}
+	reasoning, _ := m["reasoning"].(string)
+	return reasoning
+}
+
+// convertOpenAIFinishReason 将 finish_reason 转换为 Anthropic 风格的 stop_reason
+func convertOpenAIFinishReason(reason string) string {
+	switch reason {
+	case "stop":
+		return "end_turn"
+	case "tool_calls", "function_call":
+		return "tool_use"
+	case "length":
+		return "max_tokens"
+	default:
+		return reason
+	}
+}
+
+// joinTextBlocks 拼接消息中的所有文本块
+func joinTextBlocks(blocks []types.ContentBlock) string {
+	parts := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		if tb, ok := block.(*types.TextBlock); ok && tb.Text != "" {
+			parts = append(parts, tb.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// joinThinkingBlocks 拼接所有未加密的思考块内容
+func joinThinkingBlocks(blocks []types.ContentBlock) string {
+	parts := make([]string, 0)
+	for _, block := range blocks {
+		if tb, ok := block.(*types.ThinkingBlock); ok && !tb.Redacted() && tb.Thinking != "" {
+			parts = append(parts, tb.Thinking)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// lastUserTurn 返回最后一条包含用户文本的消息索引,没有时返回 -1
+// 之后的消息(assistant 工具调用与工具结果)属于同一轮对话的续写
+// 带工具结果的用户消息是续写,其中合并的文本(如系统提醒)不开始新的一轮
+func lastUserTurn(messages []types.Message) int {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != types.MessageRoleUser {
+			continue
+		}
+		hasText, hasToolResult := false, false
+		for _, block := range messages[i].Content {
+			switch block.(type) {
+			case *types.TextBlock:
+				hasText = true
+			case *types.ToolResultBlock:
+				hasToolResult = true
+			}
+		}
+		if hasText && !hasToolResult {
+			return i
+		}
+	}
+	return -1
+}
+
+// toolResultText 将工具结果转换为字符串
+func toolResultText(content interface{}) string {
+	if s, ok := content.(string); ok {
+		return s
+	}
+	if blocks := toolResultBlocks(content); blocks != nil {
+		return renderContentText(blocks, nil)
+	}
+	if jsonBytes, err := json.Marshal(content); err == nil {
+		return string(jsonBytes)
+	}
+	return fmt.Sprintf("%v", content)
+}
+
+// OpenAIFactory OpenAI 兼容工厂
+type OpenAIFactory struct{}
+
+// Create 创建 OpenAI 兼容提供商
+func (f *OpenAIFactory) Create(config *types.ModelConfig) (Provider, error) {
+	return NewOpenAIProvider(config)
+}
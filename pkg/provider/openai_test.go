@@ -0,0 +1,395 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// writeSSE 按 SSE 格式写出事件
+func writeSSE(w http.ResponseWriter, events ...string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	for _, event := range events {
+		fmt.Fprintf(w, "data: %s\n\n", event)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+func collectChunks(t *testing.T, ch <-chan StreamChunk) []StreamChunk {
+	t.Helper()
+	var chunks []StreamChunk
+	for chunk := range ch {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+func TestOpenAIProvider_StreamTextAndParallelToolCalls(t *testing.T) {
+	var reqBody map[string]interface{}
+	var path, auth string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&reqBody)
+
+		writeSSE(w,
+			`{"choices":[{"index":0,"delta":{"role":"assistant","content":"Let me "}}]}`,
+			`{"choices":[{"index":0,"delta":{"content":"check."}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_a","type":"function","function":{"name":"Read","arguments":""}},{"index":1,"id":"call_b","type":"function","function":{"name":"Bash","arguments":"{\"cmd\":"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"path\":\"a.txt\"}"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"function":{"arguments":"\"ls\"}"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":34,"total_tokens":46}}`,
+		)
+	}))
+	defer server.Close()
+
+	p, err := NewOpenAIProvider(&types.ModelConfig{
+		Provider: "openai",
+		Model:    "gpt-4o",
+		APIKey:   "sk-test",
+		BaseURL:  server.URL + "/v1",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	ch, err := p.Stream(context.Background(), []types.Message{
+		{Role: types.MessageRoleUser, Content: []types.ContentBlock{&types.TextBlock{Text: "hi"}}},
+	}, &StreamOptions{
+		System: "You are helpful.",
+		Tools: []ToolSchema{{
+			Name:        "Read",
+			Description: "read a file",
+			InputSchema: map[string]interface{}{"type": "object"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	chunks := collectChunks(t, ch)
+
+	if path != "/v1/chat/completions" {
+		t.Errorf("Expected path /v1/chat/completions, got %s", path)
+	}
+	if auth != "Bearer sk-test" {
+		t.Errorf("Unexpected Authorization header: %s", auth)
+	}
+
+	// 系统提示词作为第一条 system 消息
+	messages, _ := reqBody["messages"].([]interface{})
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+	if first, _ := messages[0].(map[string]interface{}); first["role"] != "system" || first["content"] != "You are helpful." {
+		t.Errorf("Expected system message first, got %v", first)
+	}
+
+	// 工具使用 function 格式
+	tools, _ := reqBody["tools"].([]interface{})
+	if len(tools) != 1 {
+		t.Fatalf("Expected 1 tool, got %d", len(tools))
+	}
+	tool, _ := tools[0].(map[string]interface{})
+	fn, _ := tool["function"].(map[string]interface{})
+	if tool["type"] != "function" || fn["name"] != "Read" || fn["parameters"] == nil {
+		t.Errorf("Unexpected tool schema: %v", tool)
+	}
+
+	// 检查块序列: 文本块 0, 工具块 1 和 2
+	var text string
+	starts := make(map[int]map[string]interface{})
+	args := make(map[int]string)
+	var stopReason string
+	var usage *TokenUsage
+	for _, chunk := range chunks {
+		switch chunk.Type {
+		case "content_block_start":
+			starts[chunk.Index], _ = chunk.Delta.(map[string]interface{})
+		case "content_block_delta":
+			delta, _ := chunk.Delta.(map[string]interface{})
+			switch delta["type"] {
+			case "text_delta":
+				if chunk.Index != 0 {
+					t.Errorf("Expected text delta at index 0, got %d", chunk.Index)
+				}
+				text += delta["text"].(string)
+			case "arguments":
+				args[chunk.Index] += delta["arguments"].(string)
+			}
+		case "message_delta":
+			if delta, ok := chunk.Delta.(map[string]interface{}); ok {
+				stopReason, _ = delta["stop_reason"].(string)
+			}
+			if chunk.Usage != nil {
+				usage = chunk.Usage
+			}
+		}
+	}
+
+	if text != "Let me check." {
+		t.Errorf("Expected text 'Let me check.', got '%s'", text)
+	}
+	if starts[0]["type"] != "text" {
+		t.Errorf("Expected text block at index 0, got %v", starts[0])
+	}
+	if starts[1]["id"] != "call_a" || starts[1]["name"] != "Read" {
+		t.Errorf("Unexpected tool block 1: %v", starts[1])
+	}
+	if starts[2]["id"] != "call_b" || starts[2]["name"] != "Bash" {
+		t.Errorf("Unexpected tool block 2: %v", starts[2])
+	}
+	if args[1] != `{"path":"a.txt"}` {
+		t.Errorf("Unexpected arguments for block 1: %s", args[1])
+	}
+	if args[2] != `{"cmd":"ls"}` {
+		t.Errorf("Unexpected arguments for block 2: %s", args[2])
+	}
+	if stopReason != "tool_use" {
+		t.Errorf("Expected stop_reason tool_use, got %s", stopReason)
+	}
+	if usage == nil || usage.InputTokens != 12 || usage.OutputTokens != 34 {
+		t.Errorf("Unexpected usage: %+v", usage)
+	}
+}
+
+func TestOpenAIProvider_Complete(t *testing.T) {
+	var reqBody map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&reqBody)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"choices": [{
+				"index": 0,
+				"message": {
+					"role": "assistant",
+					"content": "done",
+					"tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "Write", "arguments": "{\"path\":\"b.txt\"}"}}]
+				},
+				"finish_reason": "tool_calls"
+			}],
+			"usage": {"prompt_tokens": 5, "completion_tokens": 7}
+		}`))
+	}))
+	defer server.Close()
+
+	// 自建网关无需 APIKey
+	p, err := NewOpenAIProvider(&types.ModelConfig{Model: "local-model", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	resp, err := p.Complete(context.Background(), []types.Message{
+		{Role: types.MessageRoleAssistant, Content: []types.ContentBlock{
+			&types.ToolUseBlock{ID: "call_0", Name: "Read", Input: map[string]interface{}{"path": "a.txt"}},
+		}},
+		{Role: types.MessageRoleUser, Content: []types.ContentBlock{
+			&types.ToolResultBlock{ToolUseID: "call_0", Content: "hello"},
+			&types.TextBlock{Text: "now write"},
+		}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	if reqBody["stream"] != false {
+		t.Errorf("Expected stream=false, got %v", reqBody["stream"])
+	}
+	if _, ok := reqBody["stream_options"]; ok {
+		t.Errorf("stream_options should not be sent for non-streaming requests")
+	}
+
+	// 工具结果紧跟 assistant 消息,用户文本在其后
+	messages, _ := reqBody["messages"].([]interface{})
+	if len(messages) != 3 {
+		t.Fatalf("Expected 3 messages, got %d", len(messages))
+	}
+	roles := make([]interface{}, 0, len(messages))
+	for _, m := range messages {
+		roles = append(roles, m.(map[string]interface{})["role"])
+	}
+	if roles[0] != "assistant" || roles[1] != "tool" || roles[2] != "user" {
+		t.Errorf("Unexpected message order: %v", roles)
+	}
+
+	if len(resp.Message.Content) != 2 {
+		t.Fatalf("Expected 2 content blocks, got %d", len(resp.Message.Content))
+	}
+	if tb, ok := resp.Message.Content[0].(*types.TextBlock); !ok || tb.Text != "done" {
+		t.Errorf("Unexpected text block: %v", resp.Message.Content[0])
+	}
+	tu, ok := resp.Message.Content[1].(*types.ToolUseBlock)
+	if !ok || tu.ID != "call_1" || tu.Name != "Write" || tu.Input["path"] != "b.txt" {
+		t.Errorf("Unexpected tool use block: %v", resp.Message.Content[1])
+	}
+	if resp.Usage == nil || resp.Usage.InputTokens != 5 || resp.Usage.OutputTokens != 7 {
+		t.Errorf("Unexpected usage: %+v", resp.Usage)
+	}
+}
+
+func TestOpenAIProvider_Endpoint(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		version string
+		want    string
+	}{
+		{"https://api.openai.com", "v1", "https://api.openai.com/v1/chat/completions"},
+		{"http://localhost:8000/v1", "v1", "http://localhost:8000/v1/chat/completions"},
+		{"http://localhost:8000/v1/", "v1", "http://localhost:8000/v1/chat/completions"},
+		{"https://open.bigmodel.cn/api/paas/v4", "v4", "https://open.bigmodel.cn/api/paas/v4/chat/completions"},
+	}
+
+	for _, tt := range tests {
+		p := newOpenAICompatibleProvider(&types.ModelConfig{}, tt.baseURL, openAIDialect{apiVersion: tt.version})
+		if got := p.endpoint(); got != tt.want {
+			t.Errorf("endpoint(%s) = %s, want %s", tt.baseURL, got, tt.want)
+		}
+	}
+}
+
+func TestMultiProviderFactory_OpenAI(t *testing.T) {
+	factory := NewMultiProviderFactory()
+
+	p, err := factory.Create(&types.ModelConfig{Provider: "openai", Model: "gpt-4o", APIKey: "sk-test"})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	if _, ok := p.(*OpenAIProvider); !ok {
+		t.Errorf("Expected *OpenAIProvider, got %T", p)
+	}
+
+	if _, err := factory.Create(&types.ModelConfig{Provider: "openai", Model: "gpt-4o"}); err == nil {
+		t.Errorf("Expected error when api key and base url are both missing")
+	}
+}
+
+func TestOpenAIProvider_StreamReasoningContent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeSSE(w,
+			`{"choices":[{"index":0,"delta":{"role":"assistant","reasoning_content":"Think "}}]}`,
+			`{"choices":[{"index":0,"delta":{"reasoning_content":"hard."}}]}`,
+			`{"choices":[{"index":0,"delta":{"content":"Answer."}}]}`,
+			`{"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		)
+	}))
+	defer server.Close()
+
+	p, err := NewDeepseekProvider(&types.ModelConfig{Model: "deepseek-reasoner", APIKey: "k", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	ch, err := p.Stream(context.Background(), testMessages, nil)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+
+	var sequence []string
+	thinking := ""
+	for _, chunk := range collectChunks(t, ch) {
+		delta, _ := chunk.Delta.(map[string]interface{})
+		switch chunk.Type {
+		case "content_block_start":
+			sequence = append(sequence, fmt.Sprintf("start:%v:%d", delta["type"], chunk.Index))
+		case "content_block_stop":
+			sequence = append(sequence, fmt.Sprintf("stop:%d", chunk.Index))
+		case "content_block_delta":
+			if delta["type"] == "thinking_delta" {
+				thinking += delta["thinking"].(string)
+			}
+		}
+	}
+
+	// 思考块在正文开始前关闭
+	want := []string{"start:thinking:0", "stop:0", "start:text:1", "stop:1"}
+	if fmt.Sprint(sequence) != fmt.Sprint(want) {
+		t.Errorf("Expected block sequence %v, got %v", want, sequence)
+	}
+	if thinking != "Think hard." {
+		t.Errorf("Expected thinking 'Think hard.', got %q", thinking)
+	}
+}
+
+func TestOpenAIProvider_ReasoningRoundTrip(t *testing.T) {
+	messages := []types.Message{
+		{Role: types.MessageRoleUser, Content: []types.ContentBlock{&types.TextBlock{Text: "first question"}}},
+		{Role: types.MessageRoleAssistant, Content: []types.ContentBlock{
+			&types.ThinkingBlock{Thinking: "old reasoning"},
+			&types.TextBlock{Text: "first answer"},
+		}},
+		{Role: types.MessageRoleUser, Content: []types.ContentBlock{&types.TextBlock{Text: "read a.txt"}}},
+		{Role: types.MessageRoleAssistant, Content: []types.ContentBlock{
+			&types.ThinkingBlock{Thinking: "need to read"},
+			&types.ToolUseBlock{ID: "call_1", Name: "Read", Input: map[string]interface{}{"path": "a.txt"}},
+		}},
+		{Role: types.MessageRoleUser, Content: []types.ContentBlock{
+			&types.ToolResultBlock{ToolUseID: "call_1", Content: "hello"},
+		}},
+	}
+
+	deepseek, err := NewDeepseekProvider(&types.ModelConfig{Model: "deepseek-reasoner", APIKey: "k"})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	converted := deepseek.convertMessages(messages, "")
+	if _, ok := converted[1]["reasoning_content"]; ok {
+		t.Errorf("Reasoning from a previous turn should not be sent back")
+	}
+	if converted[3]["reasoning_content"] != "need to read" {
+		t.Errorf("Expected reasoning_content on tool-use continuation, got %v", converted[3]["reasoning_content"])
+	}
+
+	openai, err := NewOpenAIProvider(&types.ModelConfig{Model: "gpt-4o", APIKey: "k"})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	for _, msg := range openai.convertMessages(messages, "") {
+		if _, ok := msg["reasoning_content"]; ok {
+			t.Errorf("OpenAI dialect should never send reasoning_content")
+		}
+	}
+}
+
+func TestOpenAIProvider_ReasoningRoundTripWithReminder(t *testing.T) {
+	// 两次工具调用之间合并到工具结果消息中的系统提醒不开始新的一轮
+	messages := []types.Message{
+		{Role: types.MessageRoleUser, Content: []types.ContentBlock{&types.TextBlock{Text: "read a.txt and b.txt"}}},
+		{Role: types.MessageRoleAssistant, Content: []types.ContentBlock{
+			&types.ThinkingBlock{Thinking: "read a first"},
+			&types.ToolUseBlock{ID: "call_1", Name: "Read", Input: map[string]interface{}{"path": "a.txt"}},
+		}},
+		{Role: types.MessageRoleUser, Content: []types.ContentBlock{
+			&types.ToolResultBlock{ToolUseID: "call_1", Content: "hello"},
+			&types.TextBlock{Text: "<system-reminder>\nUse the write_todos tool.\n</system-reminder>"},
+		}},
+		{Role: types.MessageRoleAssistant, Content: []types.ContentBlock{
+			&types.ThinkingBlock{Thinking: "now read b"},
+			&types.ToolUseBlock{ID: "call_2", Name: "Read", Input: map[string]interface{}{"path": "b.txt"}},
+		}},
+		{Role: types.MessageRoleUser, Content: []types.ContentBlock{
+			&types.ToolResultBlock{ToolUseID: "call_2", Content: "world"},
+		}},
+	}
+
+	deepseek, err := NewDeepseekProvider(&types.ModelConfig{Model: "deepseek-reasoner", APIKey: "k"})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	var reasoning []interface{}
+	for _, msg := range deepseek.convertMessages(messages, "") {
+		if msg["role"] == "assistant" {
+			reasoning = append(reasoning, msg["reasoning_content"])
+		}
+	}
+	if len(reasoning) != 2 || reasoning[0] != "read a first" || reasoning[1] != "now read b" {
+		t.Errorf("Expected reasoning_content on both tool-use continuations, got %v", reasoning)
+	}
+}
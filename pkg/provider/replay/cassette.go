@@ -0,0 +1,181 @@
+package replay
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/wordflowlab/agentsdk/pkg/provider"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// cassetteVersion cassette 文件格式版本
+const cassetteVersion = 1
+
+// 调用方式
+const (
+	ModeStream   = "stream"
+	ModeComplete = "complete"
+)
+
+// Request 录制的请求
+type Request struct {
+	Model       string                   `json:"model,omitempty"`
+	System      string                   `json:"system,omitempty"`
+	Messages    []map[string]interface{} `json:"messages"`
+	Tools       []provider.ToolSchema    `json:"tools,omitempty"`
+	MaxTokens   int                      `json:"max_tokens,omitempty"`
+	Temperature float64                  `json:"temperature,omitempty"`
+}
+
+// Chunk 录制的流式响应块
+type Chunk struct {
+	Type     string                 `json:"type"`
+	Index    int                    `json:"index"`
+	Delta    interface{}            `json:"delta,omitempty"`
+	Usage    *provider.TokenUsage   `json:"usage,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// Response 录制的非流式响应
+type Response struct {
+	Message  map[string]interface{} `json:"message"`
+	Usage    *provider.TokenUsage   `json:"usage,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// Interaction 一次模型调用的完整记录
+type Interaction struct {
+	Hash     string    `json:"hash"`
+	Mode     string    `json:"mode"` // "stream" | "complete"
+	Request  *Request  `json:"request"`
+	Chunks   []Chunk   `json:"chunks,omitempty"`
+	Response *Response `json:"response,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Cassette 录制文件
+type Cassette struct {
+	Version      int                           `json:"version"`
+	Provider     string                        `json:"provider,omitempty"`
+	Model        string                        `json:"model,omitempty"`
+	Capabilities provider.ProviderCapabilities `json:"capabilities"`
+	Interactions []*Interaction                `json:"interactions"`
+
+	mu sync.Mutex
+}
+
+// LoadCassette 从文件加载 cassette
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read cassette: %w", err)
+	}
+
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("unmarshal cassette: %w", err)
+	}
+	if c.Version > cassetteVersion {
+		return nil, fmt.Errorf("unsupported cassette version: %d", c.Version)
+	}
+
+	return &c, nil
+}
+
+// Save 保存 cassette 到文件
+func (c *Cassette) Save(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Version = cassetteVersion
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal cassette: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create cassette dir: %w", err)
+	}
+
+	// 先写临时文件再重命名,避免录制中途崩溃留下损坏的文件
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write cassette: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// add 追加一条记录
+func (c *Cassette) add(interaction *Interaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Interactions = append(c.Interactions, interaction)
+}
+
+// HashFunc 计算请求哈希,回放时用哈希匹配录制的响应
+type HashFunc func(req *Request) string
+
+// DefaultHash 对完整请求(模型、系统提示词、消息、工具、参数)计算 SHA-256
+func DefaultHash(req *Request) string {
+	data, _ := json.Marshal(req)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// HashIgnoringSystem 计算哈希时忽略系统提示词
+// 系统提示词包含工作目录、时间等环境信息时使用,避免不同机器上哈希不一致
+func HashIgnoringSystem(req *Request) string {
+	withoutSystem := *req
+	withoutSystem.System = ""
+	return DefaultHash(&withoutSystem)
+}
+
+// newRequest 根据调用参数构建请求记录
+func newRequest(model, system string, messages []types.Message, opts *provider.StreamOptions) *Request {
+	req := &Request{
+		Model:    model,
+		System:   system,
+		Messages: types.EncodeMessages(messages),
+	}
+
+	if opts != nil {
+		if opts.System != "" {
+			req.System = opts.System
+		}
+		req.Tools = opts.Tools
+		req.MaxTokens = opts.MaxTokens
+		req.Temperature = opts.Temperature
+	}
+
+	return req
+}
+
+// normalize 将值转换为 JSON 往返后的形式,保证录制和回放时看到的数据一致
+func normalize(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+// normalizeMap 同 normalize,结果为 map
+func normalizeMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	out, _ := normalize(m).(map[string]interface{})
+	return out
+}
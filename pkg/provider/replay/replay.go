@@ -0,0 +1,340 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/wordflowlab/agentsdk/pkg/provider"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// ErrNoInteraction 回放时找不到匹配的录制记录
+var ErrNoInteraction = errors.New("replay: no recorded interaction for request")
+
+// RecordingProvider 录制提供商
+// 包装真实的 Provider,把每次请求及其响应块写入 cassette 文件
+type RecordingProvider struct {
+	inner        provider.Provider
+	path         string
+	cassette     *Cassette
+	hash         HashFunc
+	systemPrompt string
+}
+
+// NewRecordingProvider 创建录制提供商,每次调用结束后立即写入 path
+func NewRecordingProvider(inner provider.Provider, path string) *RecordingProvider {
+	cassette := &Cassette{
+		Capabilities: inner.Capabilities(),
+		Interactions: make([]*Interaction, 0),
+	}
+	if cfg := inner.Config(); cfg != nil {
+		cassette.Provider = cfg.Provider
+		cassette.Model = cfg.Model
+	}
+
+	return &RecordingProvider{
+		inner:    inner,
+		path:     path,
+		cassette: cassette,
+		hash:     DefaultHash,
+	}
+}
+
+// SetHashFunc 设置请求哈希函数(需与回放时一致)
+func (rp *RecordingProvider) SetHashFunc(hash HashFunc) {
+	rp.hash = hash
+}
+
+// Cassette 返回录制内容
+func (rp *RecordingProvider) Cassette() *Cassette {
+	return rp.cassette
+}
+
+// Stream 流式对话,转发响应块的同时录制
+func (rp *RecordingProvider) Stream(ctx context.Context, messages []types.Message, opts *provider.StreamOptions) (<-chan provider.StreamChunk, error) {
+	interaction := rp.newInteraction(ModeStream, messages, opts)
+
+	stream, err := rp.inner.Stream(ctx, messages, opts)
+	if err != nil {
+		interaction.Error = err.Error()
+		rp.save(interaction)
+		return nil, err
+	}
+
+	out := make(chan provider.StreamChunk, 10)
+	go func() {
+		defer close(out)
+		defer rp.save(interaction)
+
+		for chunk := range stream {
+			interaction.Chunks = append(interaction.Chunks, Chunk{
+				Type:     chunk.Type,
+				Index:    chunk.Index,
+				Delta:    normalize(chunk.Delta),
+				Usage:    chunk.Usage,
+				Metadata: normalizeMap(chunk.Metadata),
+			})
+			out <- chunk
+		}
+	}()
+
+	return out, nil
+}
+
+// Complete 非流式对话,返回响应的同时录制
+func (rp *RecordingProvider) Complete(ctx context.Context, messages []types.Message, opts *provider.StreamOptions) (*provider.CompleteResponse, error) {
+	interaction := rp.newInteraction(ModeComplete, messages, opts)
+
+	resp, err := rp.inner.Complete(ctx, messages, opts)
+	if err != nil {
+		interaction.Error = err.Error()
+		rp.save(interaction)
+		return nil, err
+	}
+
+	interaction.Response = &Response{
+		Message:  normalizeMap(types.EncodeMessage(resp.Message)),
+		Usage:    resp.Usage,
+		Metadata: normalizeMap(resp.Metadata),
+	}
+	rp.save(interaction)
+
+	return resp, nil
+}
+
+// newInteraction 创建一条记录
+func (rp *RecordingProvider) newInteraction(mode string, messages []types.Message, opts *provider.StreamOptions) *Interaction {
+	req := newRequest(rp.cassette.Model, rp.systemPrompt, messages, opts)
+	return &Interaction{
+		Hash:    rp.hash(req),
+		Mode:    mode,
+		Request: req,
+	}
+}
+
+// save 追加记录并写入文件
+func (rp *RecordingProvider) save(interaction *Interaction) {
+	rp.cassette.add(interaction)
+	if err := rp.cassette.Save(rp.path); err != nil {
+		log.Printf("[RecordingProvider] Failed to save cassette %s: %v", rp.path, err)
+	}
+}
+
+// Config 返回配置
+func (rp *RecordingProvider) Config() *types.ModelConfig {
+	return rp.inner.Config()
+}
+
+// Capabilities 返回模型能力
+func (rp *RecordingProvider) Capabilities() provider.ProviderCapabilities {
+	return rp.inner.Capabilities()
+}
+
+// SetSystemPrompt 设置系统提示词
+func (rp *RecordingProvider) SetSystemPrompt(prompt string) error {
+	rp.systemPrompt = prompt
+	return rp.inner.SetSystemPrompt(prompt)
+}
+
+// GetSystemPrompt 获取系统提示词
+func (rp *RecordingProvider) GetSystemPrompt() string {
+	return rp.inner.GetSystemPrompt()
+}
+
+// Close 关闭连接
+func (rp *RecordingProvider) Close() error {
+	return rp.inner.Close()
+}
+
+// ReplayProvider 回放提供商
+// 按请求哈希返回 cassette 中录制的响应,不访问网络;
+// 同一哈希录制了多次时按录制顺序依次返回,用完后重复最后一次
+type ReplayProvider struct {
+	cassette     *Cassette
+	hash         HashFunc
+	systemPrompt string
+
+	mu      sync.Mutex
+	byHash  map[string][]*Interaction
+	served  map[string]int
+	history []*Request // 收到的请求(用于断言)
+}
+
+// NewReplayProvider 从 cassette 文件创建回放提供商
+func NewReplayProvider(path string) (*ReplayProvider, error) {
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayProviderFromCassette(cassette), nil
+}
+
+// NewReplayProviderFromCassette 从已加载的 cassette 创建回放提供商
+func NewReplayProviderFromCassette(cassette *Cassette) *ReplayProvider {
+	rp := &ReplayProvider{
+		cassette: cassette,
+		hash:     DefaultHash,
+	}
+	rp.index()
+	return rp
+}
+
+// SetHashFunc 设置请求哈希函数(需与录制时一致)
+// 录制时保存的哈希会被忽略,改为用新的哈希函数对录制的请求重新计算
+func (rp *ReplayProvider) SetHashFunc(hash HashFunc) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	rp.hash = hash
+	rp.reindex()
+}
+
+// index 按录制时保存的哈希建立索引
+func (rp *ReplayProvider) index() {
+	rp.byHash = make(map[string][]*Interaction)
+	rp.served = make(map[string]int)
+	for _, interaction := range rp.cassette.Interactions {
+		rp.byHash[interaction.Hash] = append(rp.byHash[interaction.Hash], interaction)
+	}
+}
+
+// reindex 用当前哈希函数重新计算索引
+func (rp *ReplayProvider) reindex() {
+	rp.byHash = make(map[string][]*Interaction)
+	rp.served = make(map[string]int)
+	for _, interaction := range rp.cassette.Interactions {
+		h := rp.hash(interaction.Request)
+		rp.byHash[h] = append(rp.byHash[h], interaction)
+	}
+}
+
+// Requests 返回回放期间收到的请求
+func (rp *ReplayProvider) Requests() []*Request {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	return append([]*Request(nil), rp.history...)
+}
+
+// lookup 查找与请求匹配的录制记录
+func (rp *ReplayProvider) lookup(mode string, messages []types.Message, opts *provider.StreamOptions) (*Interaction, error) {
+	req := newRequest(rp.cassette.Model, rp.systemPrompt, messages, opts)
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	rp.history = append(rp.history, req)
+
+	h := rp.hash(req)
+	candidates := rp.byHash[h]
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w (hash %s, %d messages)", ErrNoInteraction, h, len(messages))
+	}
+
+	i := rp.served[h]
+	if i >= len(candidates) {
+		i = len(candidates) - 1
+	}
+	rp.served[h]++
+
+	interaction := candidates[i]
+	if interaction.Mode != mode {
+		return nil, fmt.Errorf("replay: interaction %s was recorded as %s, requested as %s", h, interaction.Mode, mode)
+	}
+	return interaction, nil
+}
+
+// Stream 回放录制的流式响应
+func (rp *ReplayProvider) Stream(ctx context.Context, messages []types.Message, opts *provider.StreamOptions) (<-chan provider.StreamChunk, error) {
+	interaction, err := rp.lookup(ModeStream, messages, opts)
+	if err != nil {
+		return nil, err
+	}
+	if interaction.Error != "" {
+		return nil, errors.New(interaction.Error)
+	}
+
+	out := make(chan provider.StreamChunk, len(interaction.Chunks))
+	go func() {
+		defer close(out)
+		for _, chunk := range interaction.Chunks {
+			select {
+			case out <- provider.StreamChunk{
+				Type:     chunk.Type,
+				Index:    chunk.Index,
+				Delta:    normalize(chunk.Delta),
+				Usage:    chunk.Usage,
+				Metadata: normalizeMap(chunk.Metadata),
+			}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// Complete 回放录制的非流式响应
+func (rp *ReplayProvider) Complete(ctx context.Context, messages []types.Message, opts *provider.StreamOptions) (*provider.CompleteResponse, error) {
+	interaction, err := rp.lookup(ModeComplete, messages, opts)
+	if err != nil {
+		return nil, err
+	}
+	if interaction.Error != "" {
+		return nil, errors.New(interaction.Error)
+	}
+	if interaction.Response == nil {
+		return nil, fmt.Errorf("replay: interaction %s has no response", interaction.Hash)
+	}
+
+	return &provider.CompleteResponse{
+		Message:  types.DecodeMessage(normalizeMap(interaction.Response.Message)),
+		Usage:    interaction.Response.Usage,
+		Metadata: normalizeMap(interaction.Response.Metadata),
+	}, nil
+}
+
+// Config 返回录制时的模型配置(不含 API Key)
+func (rp *ReplayProvider) Config() *types.ModelConfig {
+	return &types.ModelConfig{
+		Provider: rp.cassette.Provider,
+		Model:    rp.cassette.Model,
+	}
+}
+
+// Capabilities 返回录制时的模型能力
+func (rp *ReplayProvider) Capabilities() provider.ProviderCapabilities {
+	return rp.cassette.Capabilities
+}
+
+// SetSystemPrompt 设置系统提示词
+func (rp *ReplayProvider) SetSystemPrompt(prompt string) error {
+	rp.systemPrompt = prompt
+	return nil
+}
+
+// GetSystemPrompt 获取系统提示词
+func (rp *ReplayProvider) GetSystemPrompt() string {
+	return rp.systemPrompt
+}
+
+// Close 关闭连接
+func (rp *ReplayProvider) Close() error {
+	return nil
+}
+
+// Factory 总是返回同一个提供商的工厂
+// 用于把录制/回放提供商注入 agent.Dependencies.ProviderFactory
+type Factory struct {
+	Provider provider.Provider
+}
+
+// Create 返回预先创建的提供商
+func (f *Factory) Create(config *types.ModelConfig) (provider.Provider, error) {
+	if f.Provider == nil {
+		return nil, fmt.Errorf("replay factory: provider is nil")
+	}
+	return f.Provider, nil
+}
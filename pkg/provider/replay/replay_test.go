@@ -0,0 +1,263 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/agent"
+	"github.com/wordflowlab/agentsdk/pkg/provider"
+	"github.com/wordflowlab/agentsdk/pkg/sandbox"
+	"github.com/wordflowlab/agentsdk/pkg/store"
+	"github.com/wordflowlab/agentsdk/pkg/tools"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// newFakeOpenAIServer 模拟 OpenAI 兼容接口: 第一次调用返回工具调用,之后返回文本
+func newFakeOpenAIServer(t *testing.T, calls *int32) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(calls, 1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			`{"choices":[{"index":0,"delta":{"content":"The answer is 42."}}]}`,
+			`{"choices":[{"index":0,"delta":{},"finish_reason":"stop"}],"usage":{"prompt_tokens":20,"completion_tokens":5}}`,
+		}
+		if n == 1 {
+			events = []string{
+				`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"echo","arguments":"{\"text\":\"42\"}"}}]}}]}`,
+				`{"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":10,"completion_tokens":3}}`,
+			}
+		}
+		for _, event := range events {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+}
+
+func collect(ch <-chan provider.StreamChunk) []provider.StreamChunk {
+	var chunks []provider.StreamChunk
+	for chunk := range ch {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+var userMessages = []types.Message{
+	{Role: types.MessageRoleUser, Content: []types.ContentBlock{&types.TextBlock{Text: "what is the answer?"}}},
+}
+
+func TestRecordAndReplay_Stream(t *testing.T) {
+	var calls int32
+	server := newFakeOpenAIServer(t, &calls)
+	defer server.Close()
+
+	inner, err := provider.NewOpenAIProvider(&types.ModelConfig{Provider: "openai", Model: "gpt-4o", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "cassettes", "stream.json")
+	recorder := NewRecordingProvider(inner, path)
+
+	opts := &provider.StreamOptions{
+		System: "You are helpful.",
+		Tools:  []provider.ToolSchema{{Name: "echo", InputSchema: map[string]interface{}{"type": "object"}}},
+	}
+
+	ch, err := recorder.Stream(context.Background(), userMessages, opts)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	recorded := collect(ch)
+
+	replayer, err := NewReplayProvider(path)
+	if err != nil {
+		t.Fatalf("Failed to load cassette: %v", err)
+	}
+	if replayer.Config().Model != "gpt-4o" {
+		t.Errorf("Expected model gpt-4o, got %s", replayer.Config().Model)
+	}
+
+	ch, err = replayer.Stream(context.Background(), userMessages, opts)
+	if err != nil {
+		t.Fatalf("Replay stream failed: %v", err)
+	}
+	replayed := collect(ch)
+
+	if calls != 1 {
+		t.Errorf("Expected replay not to hit the server, got %d calls", calls)
+	}
+	if len(replayed) != len(recorded) {
+		t.Fatalf("Expected %d chunks, got %d", len(recorded), len(replayed))
+	}
+	for i := range recorded {
+		if recorded[i].Type != replayed[i].Type || recorded[i].Index != replayed[i].Index {
+			t.Errorf("Chunk %d mismatch: recorded %+v, replayed %+v", i, recorded[i], replayed[i])
+		}
+	}
+
+	// 不同的请求找不到录制记录
+	_, err = replayer.Stream(context.Background(), userMessages, &provider.StreamOptions{System: "different"})
+	if !errors.Is(err, ErrNoInteraction) {
+		t.Errorf("Expected ErrNoInteraction, got %v", err)
+	}
+
+	// 忽略系统提示词后可以匹配
+	replayer.SetHashFunc(HashIgnoringSystem)
+	if _, err := replayer.Stream(context.Background(), userMessages, &provider.StreamOptions{System: "different", Tools: opts.Tools}); err != nil {
+		t.Errorf("Expected match when ignoring system prompt, got %v", err)
+	}
+}
+
+func TestRecordAndReplay_CompleteAndErrors(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"hi","tool_calls":[{"id":"c1","type":"function","function":{"name":"echo","arguments":"{\"n\":1}"}}]}}],"usage":{"prompt_tokens":1,"completion_tokens":2}}`))
+	}))
+	defer server.Close()
+
+	inner, _ := provider.NewOpenAIProvider(&types.ModelConfig{Model: "m", BaseURL: server.URL})
+	path := filepath.Join(t.TempDir(), "complete.json")
+	recorder := NewRecordingProvider(inner, path)
+
+	if _, err := recorder.Complete(context.Background(), userMessages, nil); err == nil {
+		t.Fatal("Expected first call to fail")
+	}
+	if _, err := recorder.Complete(context.Background(), userMessages, nil); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	replayer, err := NewReplayProvider(path)
+	if err != nil {
+		t.Fatalf("Failed to load cassette: %v", err)
+	}
+
+	// 同一请求录制了两次,按顺序回放: 先错误,后成功
+	if _, err := replayer.Complete(context.Background(), userMessages, nil); err == nil {
+		t.Error("Expected recorded error to be replayed")
+	}
+	resp, err := replayer.Complete(context.Background(), userMessages, nil)
+	if err != nil {
+		t.Fatalf("Replay complete failed: %v", err)
+	}
+	if len(resp.Message.Content) != 2 {
+		t.Fatalf("Expected 2 content blocks, got %d", len(resp.Message.Content))
+	}
+	tu, ok := resp.Message.Content[1].(*types.ToolUseBlock)
+	if !ok || tu.ID != "c1" || tu.Input["n"] != float64(1) {
+		t.Errorf("Unexpected tool use: %+v", resp.Message.Content[1])
+	}
+	if resp.Usage == nil || resp.Usage.OutputTokens != 2 {
+		t.Errorf("Unexpected usage: %+v", resp.Usage)
+	}
+	if len(replayer.Requests()) != 2 {
+		t.Errorf("Expected 2 recorded requests, got %d", len(replayer.Requests()))
+	}
+}
+
+// echoTool 原样返回输入的测试工具
+type echoTool struct{}
+
+func (e *echoTool) Name() string        { return "echo" }
+func (e *echoTool) Description() string { return "Echo the input text" }
+func (e *echoTool) Prompt() string      { return "" }
+func (e *echoTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"text": map[string]interface{}{"type": "string"}},
+	}
+}
+func (e *echoTool) Execute(ctx context.Context, input map[string]interface{}, tc *tools.ToolContext) (interface{}, error) {
+	return input["text"], nil
+}
+
+// chatWith 使用指定提供商创建 Agent 并完成一次对话
+func chatWith(t *testing.T, prov provider.Provider) string {
+	t.Helper()
+
+	toolRegistry := tools.NewRegistry()
+	toolRegistry.Register("echo", func(config map[string]interface{}) (tools.Tool, error) {
+		return &echoTool{}, nil
+	})
+
+	jsonStore, err := store.NewJSONStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	templates := agent.NewTemplateRegistry()
+	templates.Register(&types.AgentTemplateDefinition{
+		ID:           "replay-test",
+		SystemPrompt: "You are a test assistant.",
+		Tools:        []interface{}{"echo"},
+	})
+
+	ag, err := agent.Create(context.Background(), &types.AgentConfig{
+		TemplateID:  "replay-test",
+		ModelConfig: &types.ModelConfig{Provider: "openai", Model: "gpt-4o"},
+		Sandbox:     &types.SandboxConfig{Kind: types.SandboxKindMock, WorkDir: "/tmp/test"},
+	}, &agent.Dependencies{
+		Store:            jsonStore,
+		SandboxFactory:   sandbox.NewFactory(),
+		ToolRegistry:     toolRegistry,
+		ProviderFactory:  &Factory{Provider: prov},
+		TemplateRegistry: templates,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create agent: %v", err)
+	}
+	defer ag.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := ag.Chat(ctx, "what is the answer?")
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	return result.Text
+}
+
+func TestReplay_AgentChatToolLoop(t *testing.T) {
+	var calls int32
+	server := newFakeOpenAIServer(t, &calls)
+	defer server.Close()
+
+	inner, err := provider.NewOpenAIProvider(&types.ModelConfig{Provider: "openai", Model: "gpt-4o", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "agent.json")
+	if text := chatWith(t, NewRecordingProvider(inner, path)); text != "The answer is 42." {
+		t.Fatalf("Unexpected recorded answer: %q", text)
+	}
+	if calls != 2 {
+		t.Fatalf("Expected 2 model calls while recording, got %d", calls)
+	}
+
+	// 离线回放整个工具调用循环
+	server.Close()
+	replayer, err := NewReplayProvider(path)
+	if err != nil {
+		t.Fatalf("Failed to load cassette: %v", err)
+	}
+	if text := chatWith(t, replayer); text != "The answer is 42." {
+		t.Errorf("Unexpected replayed answer: %q", text)
+	}
+	if len(replayer.Requests()) != 2 {
+		t.Errorf("Expected 2 replayed model calls, got %d", len(replayer.Requests()))
+	}
+}
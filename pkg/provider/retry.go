@@ -0,0 +1,292 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+const (
+	defaultRetryMaxAttempts    = 3
+	defaultRetryMaxElapsed     = 120 * time.Second
+	defaultRetryInitialBackoff = 1 * time.Second
+	defaultRetryMaxBackoff     = 30 * time.Second
+)
+
+// RetryInfo 一次重试的信息
+type RetryInfo struct {
+	Attempt     int           // 失败的尝试序号(从 1 开始)
+	MaxAttempts int           // 最大尝试次数
+	Delay       time.Duration // 下次尝试前的等待时间
+	StatusCode  int           // HTTP 状态码,连接错误时为 0
+	Err         error         // 本次失败的错误
+}
+
+// RetryHandler 重试回调,每次重试前调用
+type RetryHandler func(info RetryInfo)
+
+// RetryProvider 重试装饰器
+// 对 Stream/Complete 在限流(429)、服务端错误(5xx)和连接中断时按指数退避重试,
+// 优先使用服务端返回的 Retry-After 和限流重置时间
+// 注意: Stream 只在建立流之前重试,流开始后的中断不会重试
+type RetryProvider struct {
+	Provider
+
+	maxAttempts    int
+	maxElapsed     time.Duration
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	onRetry        RetryHandler
+
+	// sleep 等待函数,测试时可替换
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewRetryProvider 创建重试装饰器,config 为空时使用默认配置
+func NewRetryProvider(inner Provider, config *types.RetryConfig) *RetryProvider {
+	rp := &RetryProvider{
+		Provider:       inner,
+		maxAttempts:    defaultRetryMaxAttempts,
+		maxElapsed:     defaultRetryMaxElapsed,
+		initialBackoff: defaultRetryInitialBackoff,
+		maxBackoff:     defaultRetryMaxBackoff,
+		sleep:          sleepContext,
+	}
+
+	if config != nil {
+		if config.MaxAttempts > 0 {
+			rp.maxAttempts = config.MaxAttempts
+		}
+		if config.MaxElapsedMs > 0 {
+			rp.maxElapsed = time.Duration(config.MaxElapsedMs) * time.Millisecond
+		}
+		if config.InitialBackoffMs > 0 {
+			rp.initialBackoff = time.Duration(config.InitialBackoffMs) * time.Millisecond
+		}
+		if config.MaxBackoffMs > 0 {
+			rp.maxBackoff = time.Duration(config.MaxBackoffMs) * time.Millisecond
+		}
+	}
+
+	return rp
+}
+
+// SetRetryHandler 设置重试回调
+func (rp *RetryProvider) SetRetryHandler(handler RetryHandler) {
+	rp.onRetry = handler
+}
+
+// Unwrap 返回被装饰的提供商
+func (rp *RetryProvider) Unwrap() Provider {
+	return rp.Provider
+}
+
+// Stream 流式对话(建立流失败时重试)
+func (rp *RetryProvider) Stream(ctx context.Context, messages []types.Message, opts *StreamOptions) (<-chan StreamChunk, error) {
+	var ch <-chan StreamChunk
+	err := rp.do(ctx, func() error {
+		var err error
+		ch, err = rp.Provider.Stream(ctx, messages, opts)
+		return err
+	})
+	return ch, err
+}
+
+// Complete 非流式对话(失败时重试)
+func (rp *RetryProvider) Complete(ctx context.Context, messages []types.Message, opts *StreamOptions) (*CompleteResponse, error) {
+	var resp *CompleteResponse
+	err := rp.do(ctx, func() error {
+		var err error
+		resp, err = rp.Provider.Complete(ctx, messages, opts)
+		return err
+	})
+	return resp, err
+}
+
+// do 执行调用并在可重试错误时重试
+func (rp *RetryProvider) do(ctx context.Context, call func() error) error {
+	start := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= rp.maxAttempts || ctx.Err() != nil || !IsRetryableError(err) {
+			return err
+		}
+
+		delay := rp.retryDelay(attempt, err)
+		if time.Since(start)+delay > rp.maxElapsed {
+			log.Printf("[RetryProvider] Giving up after %d attempts, next delay %v exceeds max elapsed %v: %v",
+				attempt, delay, rp.maxElapsed, err)
+			return err
+		}
+
+		info := RetryInfo{
+			Attempt:     attempt,
+			MaxAttempts: rp.maxAttempts,
+			Delay:       delay,
+			Err:         err,
+		}
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			info.StatusCode = apiErr.StatusCode
+		}
+
+		log.Printf("[RetryProvider] Attempt %d/%d failed: %v, retrying in %v", attempt, rp.maxAttempts, err, delay)
+		if rp.onRetry != nil {
+			rp.onRetry(info)
+		}
+
+		if err := rp.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// retryDelay 计算下次重试前的等待时间
+// 服务端给出等待时间时直接使用,否则按指数退避并加上随机抖动
+func (rp *RetryProvider) retryDelay(attempt int, err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if hint, ok := retryAfterHint(apiErr.Header); ok {
+			return hint
+		}
+	}
+
+	backoff := rp.initialBackoff << (attempt - 1)
+	if backoff <= 0 || backoff > rp.maxBackoff {
+		backoff = rp.maxBackoff
+	}
+
+	// 抖动: 在 [backoff/2, backoff] 之间随机取值,避免多个客户端同时重试
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// IsRetryableError 判断错误是否值得重试
+// 可重试: 408/409/429/5xx(含 Anthropic 的 529 overloaded)、连接重置、超时
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.StatusCode == http.StatusRequestTimeout,
+			apiErr.StatusCode == http.StatusConflict,
+			apiErr.StatusCode == http.StatusTooManyRequests:
+			return true
+		case apiErr.StatusCode >= 500:
+			return true
+		default:
+			return false
+		}
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// retryAfterHint 从响应头解析服务端建议的等待时间
+// 支持 Retry-After、retry-after-ms(OpenAI),
+// 以及额度耗尽时的 x-ratelimit-reset-*(OpenAI)和 anthropic-ratelimit-*-reset(Anthropic)
+func retryAfterHint(header http.Header) (time.Duration, bool) {
+	if header == nil {
+		return 0, false
+	}
+
+	if v := header.Get("retry-after-ms"); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms >= 0 {
+			return time.Duration(ms * float64(time.Millisecond)), true
+		}
+	}
+
+	if v := header.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.ParseFloat(v, 64); err == nil && seconds >= 0 {
+			return time.Duration(seconds * float64(time.Second)), true
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return nonNegative(time.Until(t)), true
+		}
+	}
+
+	var hint time.Duration
+	found := false
+
+	// OpenAI: x-ratelimit-remaining-requests: 0, x-ratelimit-reset-requests: 6m0s
+	for _, kind := range []string{"requests", "tokens"} {
+		if header.Get("x-ratelimit-remaining-"+kind) != "0" {
+			continue
+		}
+		if d, err := time.ParseDuration(header.Get("x-ratelimit-reset-" + kind)); err == nil {
+			found = true
+			if d > hint {
+				hint = d
+			}
+		}
+	}
+
+	// Anthropic: anthropic-ratelimit-tokens-remaining: 0, anthropic-ratelimit-tokens-reset: RFC 3339 时间
+	for _, kind := range []string{"requests", "tokens", "input-tokens", "output-tokens"} {
+		if header.Get("anthropic-ratelimit-"+kind+"-remaining") != "0" {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, header.Get("anthropic-ratelimit-"+kind+"-reset")); err == nil {
+			found = true
+			if d := nonNegative(time.Until(t)); d > hint {
+				hint = d
+			}
+		}
+	}
+
+	return hint, found
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// sleepContext 等待指定时间,ctx 取消时提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,221 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// newTestRetryProvider 创建指向测试服务器的重试装饰器,记录等待时间而不真正等待
+func newTestRetryProvider(t *testing.T, serverURL string, config *types.RetryConfig) (*RetryProvider, *[]time.Duration) {
+	t.Helper()
+	inner, err := NewOpenAIProvider(&types.ModelConfig{Model: "test", BaseURL: serverURL})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	delays := make([]time.Duration, 0)
+	rp := NewRetryProvider(inner, config)
+	rp.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return ctx.Err()
+	}
+	return rp, &delays
+}
+
+var testMessages = []types.Message{
+	{Role: types.MessageRoleUser, Content: []types.ContentBlock{&types.TextBlock{Text: "hi"}}},
+}
+
+func TestRetryProvider_RetriesOverloadedThenSucceeds(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			w.WriteHeader(529)
+			w.Write([]byte(`{"type":"error","error":{"type":"overloaded_error"}}`))
+			return
+		}
+		writeSSE(w,
+			`{"choices":[{"index":0,"delta":{"content":"ok"}}]}`,
+			`{"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		)
+	}))
+	defer server.Close()
+
+	rp, delays := newTestRetryProvider(t, server.URL, &types.RetryConfig{
+		MaxAttempts:      3,
+		InitialBackoffMs: 100,
+		MaxBackoffMs:     1000,
+	})
+
+	var retries []RetryInfo
+	rp.SetRetryHandler(func(info RetryInfo) {
+		retries = append(retries, info)
+	})
+
+	ch, err := rp.Stream(context.Background(), testMessages, nil)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	collectChunks(t, ch)
+
+	if calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", calls)
+	}
+	if len(retries) != 2 {
+		t.Fatalf("Expected 2 retries, got %d", len(retries))
+	}
+	if retries[0].StatusCode != 529 || retries[0].Attempt != 1 || retries[1].Attempt != 2 {
+		t.Errorf("Unexpected retry info: %+v", retries)
+	}
+
+	// 指数退避 + 抖动: 第一次 [50ms,100ms], 第二次 [100ms,200ms]
+	if d := (*delays)[0]; d < 50*time.Millisecond || d > 100*time.Millisecond {
+		t.Errorf("Unexpected first delay: %v", d)
+	}
+	if d := (*delays)[1]; d < 100*time.Millisecond || d > 200*time.Millisecond {
+		t.Errorf("Unexpected second delay: %v", d)
+	}
+}
+
+func TestRetryProvider_HonoursRetryAfter(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	rp, delays := newTestRetryProvider(t, server.URL, nil)
+
+	resp, err := rp.Complete(context.Background(), testMessages, nil)
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if tb, ok := resp.Message.Content[0].(*types.TextBlock); !ok || tb.Text != "ok" {
+		t.Errorf("Unexpected response: %v", resp.Message.Content)
+	}
+	if len(*delays) != 1 || (*delays)[0] != 7*time.Second {
+		t.Errorf("Expected a single 7s delay, got %v", *delays)
+	}
+}
+
+func TestRetryProvider_DoesNotRetryClientErrors(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	rp, _ := newTestRetryProvider(t, server.URL, nil)
+
+	_, err := rp.Complete(context.Background(), testMessages, nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected 400 APIError, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %d", calls)
+	}
+}
+
+func TestRetryProvider_ConnectionReset(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// 直接断开连接
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	rp, _ := newTestRetryProvider(t, server.URL, nil)
+
+	if _, err := rp.Complete(context.Background(), testMessages, nil); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 calls, got %d", calls)
+	}
+}
+
+func TestRetryProvider_StopsAtMaxAttemptsAndElapsed(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	rp, _ := newTestRetryProvider(t, server.URL, &types.RetryConfig{MaxAttempts: 4, InitialBackoffMs: 1})
+	if _, err := rp.Complete(context.Background(), testMessages, nil); err == nil {
+		t.Fatal("Expected error after exhausting retries")
+	}
+	if calls != 4 {
+		t.Errorf("Expected 4 calls, got %d", calls)
+	}
+
+	// 服务端要求的等待时间超过总耗时上限时直接放弃
+	atomic.StoreInt32(&calls, 0)
+	limited := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", strconv.Itoa(60))
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer limited.Close()
+
+	rp, delays := newTestRetryProvider(t, limited.URL, &types.RetryConfig{MaxAttempts: 5, MaxElapsedMs: 1000})
+	if _, err := rp.Complete(context.Background(), testMessages, nil); err == nil {
+		t.Fatal("Expected error when retry-after exceeds max elapsed")
+	}
+	if calls != 1 || len(*delays) != 0 {
+		t.Errorf("Expected 1 call without waiting, got %d calls, delays %v", calls, *delays)
+	}
+}
+
+func TestRetryAfterHint_RateLimitHeaders(t *testing.T) {
+	// OpenAI: 仅在额度耗尽时使用对应的重置时间
+	header := http.Header{}
+	header.Set("x-ratelimit-remaining-requests", "10")
+	header.Set("x-ratelimit-reset-requests", "1m0s")
+	header.Set("x-ratelimit-remaining-tokens", "0")
+	header.Set("x-ratelimit-reset-tokens", "6s")
+	if d, ok := retryAfterHint(header); !ok || d != 6*time.Second {
+		t.Errorf("Expected 6s from openai headers, got %v (%v)", d, ok)
+	}
+
+	// Anthropic: RFC 3339 重置时间
+	header = http.Header{}
+	header.Set("anthropic-ratelimit-requests-remaining", "0")
+	header.Set("anthropic-ratelimit-requests-reset", time.Now().Add(30*time.Second).UTC().Format(time.RFC3339))
+	if d, ok := retryAfterHint(header); !ok || d < 28*time.Second || d > 30*time.Second {
+		t.Errorf("Expected ~30s from anthropic headers, got %v (%v)", d, ok)
+	}
+
+	header = http.Header{}
+	header.Set("retry-after-ms", "250")
+	if d, ok := retryAfterHint(header); !ok || d != 250*time.Millisecond {
+		t.Errorf("Expected 250ms from retry-after-ms, got %v (%v)", d, ok)
+	}
+
+	if _, ok := retryAfterHint(http.Header{}); ok {
+		t.Errorf("Expected no hint for empty headers")
+	}
+}
@@ -0,0 +1,201 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+// testResponseFormat 结构化输出测试使用的 schema
+var testResponseFormat = &ResponseFormat{
+	Type: ResponseFormatJSONSchema,
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"city": map[string]interface{}{"type": "string"},
+		},
+		"required": []interface{}{"city"},
+	},
+	Strict: true,
+}
+
+func TestOpenAIProvider_ResponseFormatJSONSchema(t *testing.T) {
+	p, err := NewOpenAIProvider(&types.ModelConfig{Provider: "openai", Model: "gpt-test", APIKey: "k"})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	req := p.buildRequest(testMessages, &StreamOptions{System: "sys", ResponseFormat: testResponseFormat})
+
+	rf, ok := req["response_format"].(map[string]interface{})
+	if !ok || rf["type"] != "json_schema" {
+		t.Fatalf("Expected json_schema response_format, got %v", req["response_format"])
+	}
+	js := rf["json_schema"].(map[string]interface{})
+	if js["name"] != DefaultResponseFormatName || js["strict"] != true || js["schema"] == nil {
+		t.Errorf("Unexpected json_schema: %v", js)
+	}
+
+	system := req["messages"].([]map[string]interface{})[0]
+	if system["content"] != "sys" {
+		t.Errorf("Expected system prompt untouched, got %v", system["content"])
+	}
+}
+
+func TestDeepseekProvider_ResponseFormatFallsBackToJSONObject(t *testing.T) {
+	p, err := NewDeepseekProvider(&types.ModelConfig{Provider: "deepseek", Model: "deepseek-chat", APIKey: "k"})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	req := p.buildRequest(testMessages, &StreamOptions{System: "sys", ResponseFormat: testResponseFormat})
+
+	rf, ok := req["response_format"].(map[string]interface{})
+	if !ok || rf["type"] != "json_object" {
+		t.Fatalf("Expected json_object response_format, got %v", req["response_format"])
+	}
+
+	system, _ := req["messages"].([]map[string]interface{})[0]["content"].(string)
+	if !strings.HasPrefix(system, "sys") || !strings.Contains(system, `"city"`) {
+		t.Errorf("Expected schema instructions in system prompt, got %q", system)
+	}
+}
+
+func TestOllamaProvider_ResponseFormat(t *testing.T) {
+	var reqBody map[string]interface{}
+	server := newOllamaServer(t, []string{"completion"}, []string{
+		`{"message":{"role":"assistant","content":"{\"city\":\"Paris\"}"},"done":true,"done_reason":"stop"}`,
+	}, &reqBody)
+	defer server.Close()
+
+	p, err := NewOllamaProvider(&types.ModelConfig{Provider: "ollama", Model: "llama3", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	ch, err := p.Stream(context.Background(), testMessages, &StreamOptions{ResponseFormat: testResponseFormat})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	collectChunks(t, ch)
+
+	format, ok := reqBody["format"].(map[string]interface{})
+	if !ok || format["type"] != "object" {
+		t.Errorf("Expected schema as format, got %v", reqBody["format"])
+	}
+
+	req := p.buildRequest(context.Background(), testMessages, &StreamOptions{
+		ResponseFormat: &ResponseFormat{Type: ResponseFormatJSONObject},
+	})
+	if req["format"] != "json" {
+		t.Errorf("Expected format json, got %v", req["format"])
+	}
+}
+
+func TestAnthropicProvider_ResponseFormatToolChoice(t *testing.T) {
+	p, err := NewAnthropicProvider(&types.ModelConfig{Provider: "anthropic", Model: "claude-test", APIKey: "k"})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	// 没有其他工具: 强制调用输出工具
+	req := p.buildRequest(testMessages, &StreamOptions{ResponseFormat: testResponseFormat})
+	choice, _ := req["tool_choice"].(map[string]interface{})
+	if choice["type"] != "tool" || choice["name"] != DefaultResponseFormatName {
+		t.Errorf("Expected forced output tool, got %v", req["tool_choice"])
+	}
+	tools := req["tools"].([]map[string]interface{})
+	if len(tools) != 1 || tools[0]["name"] != DefaultResponseFormatName {
+		t.Errorf("Expected output tool in request, got %v", tools)
+	}
+
+	// 还有其他工具: 要求调用任一工具
+	req = p.buildRequest(testMessages, &StreamOptions{Tools: testToolSchemas, ResponseFormat: testResponseFormat})
+	choice, _ = req["tool_choice"].(map[string]interface{})
+	if choice["type"] != "any" {
+		t.Errorf("Expected tool_choice any, got %v", req["tool_choice"])
+	}
+	if tools := req["tools"].([]map[string]interface{}); len(tools) != len(testToolSchemas)+1 {
+		t.Errorf("Expected output tool appended, got %d tools", len(tools))
+	}
+}
+
+func TestAnthropicProvider_StreamStructuredOutputAsText(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeSSE(w,
+			`{"type":"message_start","message":{"id":"msg_1","role":"assistant","usage":{"input_tokens":5,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"structured_output","input":{}}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":9}}`,
+			`{"type":"message_stop"}`,
+		)
+	}))
+	defer server.Close()
+
+	p, err := NewAnthropicProvider(&types.ModelConfig{Provider: "anthropic", Model: "claude-test", APIKey: "k", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	ch, err := p.Stream(context.Background(), testMessages, &StreamOptions{ResponseFormat: testResponseFormat})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+
+	var text strings.Builder
+	var stopReason interface{}
+	for _, chunk := range collectChunks(t, ch) {
+		delta, _ := chunk.Delta.(map[string]interface{})
+		switch chunk.Type {
+		case "content_block_start":
+			if delta["type"] != "text" {
+				t.Errorf("Expected output tool block converted to text, got %v", delta)
+			}
+		case "content_block_delta":
+			if delta["type"] != "text_delta" {
+				t.Errorf("Expected text_delta, got %v", delta)
+			}
+			text.WriteString(delta["text"].(string))
+		case "message_delta":
+			stopReason = delta["stop_reason"]
+		}
+	}
+
+	if text.String() != `{"city":"Paris"}` {
+		t.Errorf("Unexpected structured text: %q", text.String())
+	}
+	if stopReason != "end_turn" {
+		t.Errorf("Expected stop_reason end_turn, got %v", stopReason)
+	}
+}
+
+func TestAnthropicProvider_CompleteStructuredOutputAsText(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"content":[{"type":"tool_use","id":"toolu_1","name":"structured_output","input":{"city":"Paris"}}],"usage":{"input_tokens":3,"output_tokens":2}}`))
+	}))
+	defer server.Close()
+
+	p, err := NewAnthropicProvider(&types.ModelConfig{Provider: "anthropic", Model: "claude-test", APIKey: "k", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	resp, err := p.Complete(context.Background(), testMessages, &StreamOptions{ResponseFormat: testResponseFormat})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if len(resp.Message.Content) != 1 {
+		t.Fatalf("Expected one block, got %d", len(resp.Message.Content))
+	}
+	text, ok := resp.Message.Content[0].(*types.TextBlock)
+	if !ok || text.Text != `{"city":"Paris"}` {
+		t.Errorf("Expected JSON text block, got %#v", resp.Message.Content[0])
+	}
+}
@@ -0,0 +1,181 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// For 根据 Go 类型生成 JSON Schema
+func For[T any]() map[string]interface{} {
+	return FromType(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+// FromType 根据 Go 类型生成 JSON Schema
+// 字段名取自 json tag;没有 omitempty 的字段视为必填;
+// 支持 `jsonschema:"description=...,enum=a|b"` tag 补充描述和枚举值
+func FromType(t reflect.Type) map[string]interface{} {
+	return fromType(t, make(map[reflect.Type]bool))
+}
+
+func fromType(t reflect.Type, visiting map[reflect.Type]bool) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return map[string]interface{}{}
+	}
+	if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+		// 自定义序列化的类型无法推断结构
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// []byte 按 base64 字符串序列化
+			return map[string]interface{}{"type": "string"}
+		}
+		return map[string]interface{}{
+			"type":  "array",
+			"items": fromType(t.Elem(), visiting),
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": fromType(t.Elem(), visiting),
+		}
+	case reflect.Struct:
+		if visiting[t] {
+			// 递归类型: 不再展开
+			return map[string]interface{}{"type": "object"}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+		return structSchema(t, visiting)
+	}
+
+	return map[string]interface{}{}
+}
+
+func structSchema(t reflect.Type, visiting map[reflect.Type]bool) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := make([]interface{}, 0)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue // 未导出字段
+		}
+
+		name, omitEmpty, skip := jsonFieldName(field)
+		if skip {
+			continue
+		}
+
+		// 匿名嵌入的结构体: 字段提升到外层
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			for embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				sub := fromType(embedded, visiting)
+				if props, ok := sub["properties"].(map[string]interface{}); ok {
+					for k, v := range props {
+						properties[k] = v
+					}
+				}
+				if req, ok := sub["required"].([]interface{}); ok {
+					required = append(required, req...)
+				}
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop := fromType(field.Type, visiting)
+		applyFieldTag(prop, field.Tag.Get("jsonschema"))
+		properties[name] = prop
+
+		if field.Type.Kind() == reflect.Ptr {
+			// 指针字段可以为 null
+			if t, ok := prop["type"].(string); ok {
+				prop["type"] = []interface{}{t, "null"}
+			}
+		} else if !omitEmpty {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// jsonFieldName 解析 json tag
+func jsonFieldName(field reflect.StructField) (name string, omitEmpty, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" || opt == "omitzero" {
+			omitEmpty = true
+		}
+	}
+	return parts[0], omitEmpty, false
+}
+
+// applyFieldTag 解析 jsonschema tag: description=...,enum=a|b
+func applyFieldTag(prop map[string]interface{}, tag string) {
+	if tag == "" {
+		return
+	}
+	for _, part := range strings.Split(tag, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "description":
+			prop["description"] = value
+		case "enum":
+			values := strings.Split(value, "|")
+			enum := make([]interface{}, len(values))
+			for i, v := range values {
+				enum[i] = v
+			}
+			prop["enum"] = enum
+		}
+	}
+}
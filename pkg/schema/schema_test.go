@@ -0,0 +1,156 @@
+package schema
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+var invoiceSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"number": map[string]interface{}{"type": "string", "pattern": "^INV-[0-9]+$"},
+		"total":  map[string]interface{}{"type": "number", "minimum": 0},
+		"status": map[string]interface{}{"enum": []interface{}{"paid", "open"}},
+		"items": map[string]interface{}{
+			"type":     "array",
+			"minItems": 1,
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"sku": map[string]interface{}{"type": "string"},
+					"qty": map[string]interface{}{"type": "integer"},
+				},
+				"required": []interface{}{"sku", "qty"},
+			},
+		},
+	},
+	"required":             []interface{}{"number", "total", "items"},
+	"additionalProperties": false,
+}
+
+func TestValidateJSON_Valid(t *testing.T) {
+	value, err := ValidateJSON(invoiceSchema, []byte(`{"number":"INV-42","total":12.5,"status":"paid","items":[{"sku":"a","qty":2}]}`))
+	if err != nil {
+		t.Fatalf("Expected valid document, got %v", err)
+	}
+	if value.(map[string]interface{})["number"] != "INV-42" {
+		t.Errorf("Unexpected decoded value: %v", value)
+	}
+}
+
+func TestValidateJSON_CollectsAllErrors(t *testing.T) {
+	_, err := ValidateJSON(invoiceSchema, []byte(`{"number":"42","total":-1,"status":"void","items":[{"sku":"a","qty":1.5}],"extra":true}`))
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected ValidationError, got %v", err)
+	}
+
+	want := []string{
+		`$.items[0].qty: expected integer, got number`,
+		`$.number: value "42" does not match pattern`,
+		`$.status: value "void" is not one of`,
+		`$.total: expected >= 0, got -1`,
+		`$: unexpected property "extra"`,
+	}
+	joined := validationErr.Error()
+	for _, w := range want {
+		if !strings.Contains(joined, w) {
+			t.Errorf("Expected error containing %q, got %q", w, joined)
+		}
+	}
+}
+
+func TestValidateJSON_InvalidJSON(t *testing.T) {
+	if _, err := ValidateJSON(invoiceSchema, []byte(`{"number":`)); err == nil || !strings.Contains(err.Error(), "invalid json") {
+		t.Errorf("Expected invalid json error, got %v", err)
+	}
+}
+
+func TestValidate_Combinators(t *testing.T) {
+	s := map[string]interface{}{
+		"anyOf": []interface{}{
+			map[string]interface{}{"type": "string"},
+			map[string]interface{}{"type": "null"},
+		},
+	}
+	if err := Validate(s, nil); err != nil {
+		t.Errorf("Expected null to match anyOf, got %v", err)
+	}
+	if err := Validate(s, float64(1)); err == nil {
+		t.Errorf("Expected number to fail anyOf")
+	}
+
+	oneOf := map[string]interface{}{
+		"oneOf": []interface{}{
+			map[string]interface{}{"type": "integer"},
+			map[string]interface{}{"type": "number"},
+		},
+	}
+	if err := Validate(oneOf, float64(1)); err == nil {
+		t.Errorf("Expected integer to match both oneOf branches and fail")
+	}
+	if err := Validate(oneOf, 1.5); err != nil {
+		t.Errorf("Expected 1.5 to match exactly one branch, got %v", err)
+	}
+}
+
+type lineItem struct {
+	SKU string `json:"sku" jsonschema:"description=Stock keeping unit"`
+	Qty int    `json:"qty"`
+}
+
+type invoice struct {
+	Number string     `json:"number"`
+	Total  float64    `json:"total"`
+	Status string     `json:"status,omitempty" jsonschema:"enum=paid|open"`
+	Items  []lineItem `json:"items"`
+	Note   *string    `json:"note"`
+	Parent *invoice   `json:"parent,omitempty"`
+	hidden string
+}
+
+func TestFor_Struct(t *testing.T) {
+	s := For[invoice]()
+
+	if s["type"] != "object" || s["additionalProperties"] != false {
+		t.Fatalf("Unexpected root schema: %v", s)
+	}
+	required := s["required"].([]interface{})
+	if len(required) != 3 || required[0] != "number" || required[1] != "total" || required[2] != "items" {
+		t.Errorf("Expected number/total/items to be required, got %v", required)
+	}
+
+	props := s["properties"].(map[string]interface{})
+	if _, ok := props["hidden"]; ok {
+		t.Errorf("Unexported fields should be skipped")
+	}
+	if props["total"].(map[string]interface{})["type"] != "number" {
+		t.Errorf("Expected total to be a number, got %v", props["total"])
+	}
+	status := props["status"].(map[string]interface{})
+	if enum := status["enum"].([]interface{}); len(enum) != 2 || enum[0] != "paid" {
+		t.Errorf("Expected enum from tag, got %v", status)
+	}
+	items := props["items"].(map[string]interface{})["items"].(map[string]interface{})
+	sku := items["properties"].(map[string]interface{})["sku"].(map[string]interface{})
+	if sku["description"] != "Stock keeping unit" {
+		t.Errorf("Expected description from tag, got %v", sku)
+	}
+	if _, ok := props["parent"].(map[string]interface{})["properties"]; ok {
+		t.Errorf("Expected recursive type not to be expanded, got %v", props["parent"])
+	}
+
+	// 生成的 schema 可以校验对应的 JSON
+	if _, err := ValidateJSON(s, []byte(`{"number":"1","total":2,"items":[{"sku":"a","qty":1}],"note":"x"}`)); err != nil {
+		t.Errorf("Expected generated schema to accept matching JSON, got %v", err)
+	}
+}
+
+func TestFor_PointerFieldsAreNullable(t *testing.T) {
+	s := For[invoice]()
+	if _, err := ValidateJSON(s, []byte(`{"number":"1","total":2,"items":[],"note":null}`)); err != nil {
+		t.Errorf("Expected null to be accepted for pointer field, got %v", err)
+	}
+}
@@ -0,0 +1,366 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidationError Schema 校验错误,包含所有不满足约束的位置
+type ValidationError struct {
+	Errors []string
+}
+
+func (e *ValidationError) Error() string {
+	return strings.Join(e.Errors, "; ")
+}
+
+// Validate 按 JSON Schema 校验 value
+// value 应为 json.Unmarshal 到 interface{} 的结果(map/slice/float64/string/bool/nil);
+// 支持常用关键字: type、properties、required、additionalProperties、items、enum、const、
+// minimum/maximum、exclusiveMinimum/exclusiveMaximum、multipleOf、minLength/maxLength、pattern、
+// minItems/maxItems、uniqueItems、minProperties/maxProperties、allOf/anyOf/oneOf/not
+// 不支持 $ref 和 format
+func Validate(schema map[string]interface{}, value interface{}) error {
+	v := &validator{}
+	v.validate("$", schema, value)
+	if len(v.errors) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: v.errors}
+}
+
+// ValidateJSON 解析 JSON 并校验
+func ValidateJSON(schema map[string]interface{}, data []byte) (interface{}, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+	if err := Validate(schema, value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+type validator struct {
+	errors []string
+}
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+	v.errors = append(v.errors, path+": "+fmt.Sprintf(format, args...))
+}
+
+// validate 校验单个值,错误追加到 v.errors
+func (v *validator) validate(path string, schema map[string]interface{}, value interface{}) {
+	if schema == nil {
+		return
+	}
+
+	if types, ok := schemaTypes(schema["type"]); ok {
+		matched := false
+		for _, t := range types {
+			if matchesType(t, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(path, "expected %s, got %s", strings.Join(types, " or "), typeName(value))
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, candidate := range enum {
+			if equal(candidate, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(path, "value %s is not one of %s", compact(value), compact(enum))
+		}
+	}
+
+	if c, ok := schema["const"]; ok && !equal(c, value) {
+		v.fail(path, "expected constant %s, got %s", compact(c), compact(value))
+	}
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		v.validateObject(path, schema, val)
+	case []interface{}:
+		v.validateArray(path, schema, val)
+	case string:
+		v.validateString(path, schema, val)
+	case float64:
+		v.validateNumber(path, schema, val)
+	}
+
+	v.validateCombinators(path, schema, value)
+}
+
+func (v *validator) validateObject(path string, schema map[string]interface{}, obj map[string]interface{}) {
+	for _, name := range stringList(schema["required"]) {
+		if _, ok := obj[name]; !ok {
+			v.fail(path, "missing required property %q", name)
+		}
+	}
+
+	if n, ok := number(schema["minProperties"]); ok && float64(len(obj)) < n {
+		v.fail(path, "expected at least %v properties, got %d", n, len(obj))
+	}
+	if n, ok := number(schema["maxProperties"]); ok && float64(len(obj)) > n {
+		v.fail(path, "expected at most %v properties, got %d", n, len(obj))
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	// 按键名排序,保证错误顺序稳定
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		childPath := path + "." + key
+		if propSchema, ok := properties[key].(map[string]interface{}); ok {
+			v.validate(childPath, propSchema, obj[key])
+			continue
+		}
+		if _, ok := properties[key]; ok {
+			continue
+		}
+
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.fail(path, "unexpected property %q", key)
+			}
+		case map[string]interface{}:
+			v.validate(childPath, additional, obj[key])
+		}
+	}
+}
+
+func (v *validator) validateArray(path string, schema map[string]interface{}, arr []interface{}) {
+	if n, ok := number(schema["minItems"]); ok && float64(len(arr)) < n {
+		v.fail(path, "expected at least %v items, got %d", n, len(arr))
+	}
+	if n, ok := number(schema["maxItems"]); ok && float64(len(arr)) > n {
+		v.fail(path, "expected at most %v items, got %d", n, len(arr))
+	}
+
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		for i, item := range arr {
+			v.validate(fmt.Sprintf("%s[%d]", path, i), items, item)
+		}
+	}
+
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := 0; i < len(arr); i++ {
+			for j := i + 1; j < len(arr); j++ {
+				if equal(arr[i], arr[j]) {
+					v.fail(path, "items %d and %d are equal", i, j)
+				}
+			}
+		}
+	}
+}
+
+func (v *validator) validateString(path string, schema map[string]interface{}, s string) {
+	length := utf8.RuneCountInString(s)
+	if n, ok := number(schema["minLength"]); ok && float64(length) < n {
+		v.fail(path, "expected at least %v characters, got %d", n, length)
+	}
+	if n, ok := number(schema["maxLength"]); ok && float64(length) > n {
+		v.fail(path, "expected at most %v characters, got %d", n, length)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			v.fail(path, "invalid pattern %q in schema: %v", pattern, err)
+		} else if !re.MatchString(s) {
+			v.fail(path, "value %q does not match pattern %q", s, pattern)
+		}
+	}
+}
+
+func (v *validator) validateNumber(path string, schema map[string]interface{}, n float64) {
+	if min, ok := number(schema["minimum"]); ok && n < min {
+		v.fail(path, "expected >= %v, got %v", min, n)
+	}
+	if max, ok := number(schema["maximum"]); ok && n > max {
+		v.fail(path, "expected <= %v, got %v", max, n)
+	}
+	if min, ok := number(schema["exclusiveMinimum"]); ok && n <= min {
+		v.fail(path, "expected > %v, got %v", min, n)
+	}
+	if max, ok := number(schema["exclusiveMaximum"]); ok && n >= max {
+		v.fail(path, "expected < %v, got %v", max, n)
+	}
+	if m, ok := number(schema["multipleOf"]); ok && m > 0 {
+		if q := n / m; math.Abs(q-math.Round(q)) > 1e-9 {
+			v.fail(path, "expected a multiple of %v, got %v", m, n)
+		}
+	}
+}
+
+func (v *validator) validateCombinators(path string, schema map[string]interface{}, value interface{}) {
+	for _, sub := range schemaList(schema["allOf"]) {
+		v.validate(path, sub, value)
+	}
+
+	if anyOf := schemaList(schema["anyOf"]); len(anyOf) > 0 {
+		if countMatches(anyOf, value) == 0 {
+			v.fail(path, "value does not match any of the allowed schemas")
+		}
+	}
+
+	if oneOf := schemaList(schema["oneOf"]); len(oneOf) > 0 {
+		if n := countMatches(oneOf, value); n != 1 {
+			v.fail(path, "value must match exactly one schema, matched %d", n)
+		}
+	}
+
+	if not, ok := schema["not"].(map[string]interface{}); ok {
+		if Validate(not, value) == nil {
+			v.fail(path, "value must not match schema %s", compact(not))
+		}
+	}
+}
+
+// countMatches 统计 value 满足的子 schema 数量
+func countMatches(schemas []map[string]interface{}, value interface{}) int {
+	n := 0
+	for _, sub := range schemas {
+		if Validate(sub, value) == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// schemaTypes 读取 type 关键字(字符串或字符串数组)
+func schemaTypes(raw interface{}) ([]string, bool) {
+	switch t := raw.(type) {
+	case string:
+		return []string{t}, true
+	case []interface{}:
+		return stringList(t), len(t) > 0
+	case []string:
+		return t, len(t) > 0
+	}
+	return nil, false
+}
+
+func matchesType(t string, value interface{}) bool {
+	switch t {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+func typeName(value interface{}) string {
+	switch val := value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if val == math.Trunc(val) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// number 读取数值关键字,兼容 Go 代码里直接写的 int
+func number(raw interface{}) (float64, bool) {
+	switch n := raw.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func stringList(raw interface{}) []string {
+	switch list := raw.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		result := make([]string, 0, len(list))
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+func schemaList(raw interface{}) []map[string]interface{} {
+	switch list := raw.(type) {
+	case []map[string]interface{}:
+		return list
+	case []interface{}:
+		result := make([]map[string]interface{}, 0, len(list))
+		for _, item := range list {
+			if m, ok := item.(map[string]interface{}); ok {
+				result = append(result, m)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// equal 比较两个 JSON 值(schema 中的值可能是 Go 原生类型,先统一为 JSON 形式)
+func equal(a, b interface{}) bool {
+	return compact(a) == compact(b)
+}
+
+func compact(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
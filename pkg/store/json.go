@@ -88,8 +88,9 @@ func (js *JSONStore) SaveMessages(ctx context.Context, agentID string, messages
 		return err
 	}
 
+	// ContentBlock 是接口,按类型标记编码后再保存
 	path := filepath.Join(js.agentDir(agentID), "messages.json")
-	return js.saveJSON(path, messages)
+	return js.saveJSON(path, types.EncodeMessages(messages))
 }
 
 // LoadMessages 加载消息列表
@@ -97,17 +98,13 @@ func (js *JSONStore) LoadMessages(ctx context.Context, agentID string) ([]types.
 	js.mu.RLock()
 	defer js.mu.RUnlock()
 
-	var messages []types.Message
+	var encoded []map[string]interface{}
 	path := filepath.Join(js.agentDir(agentID), "messages.json")
-	if err := js.loadJSON(path, &messages); err != nil {
+	if err := js.loadJSON(path, &encoded); err != nil {
 		return nil, err
 	}
 
-	if messages == nil {
-		messages = []types.Message{}
-	}
-
-	return messages, nil
+	return types.DecodeMessages(encoded), nil
 }
 
 // SaveToolCallRecords 保存工具调用记录
@@ -157,7 +154,23 @@ func (js *JSONStore) SaveSnapshot(ctx context.Context, agentID string, snapshot
 	}
 
 	path := filepath.Join(snapshotsDir, snapshot.ID+".json")
-	return js.saveJSON(path, snapshot)
+	return js.saveJSON(path, snapshotFile{
+		Snapshot: snapshot,
+		Messages: types.EncodeMessages(snapshot.Messages),
+	})
+}
+
+// snapshotFile 快照的存储格式,消息按类型标记编码(覆盖 Snapshot.Messages)
+type snapshotFile struct {
+	types.Snapshot
+	Messages []map[string]interface{} `json:"messages"`
+}
+
+// decode 还原为 types.Snapshot
+func (f *snapshotFile) decode() *types.Snapshot {
+	snapshot := f.Snapshot
+	snapshot.Messages = types.DecodeMessages(f.Messages)
+	return &snapshot
 }
 
 // LoadSnapshot 加载快照
@@ -165,13 +178,13 @@ func (js *JSONStore) LoadSnapshot(ctx context.Context, agentID string, snapshotI
 	js.mu.RLock()
 	defer js.mu.RUnlock()
 
-	var snapshot types.Snapshot
+	var file snapshotFile
 	path := filepath.Join(js.agentDir(agentID), "snapshots", snapshotID+".json")
-	if err := js.loadJSON(path, &snapshot); err != nil {
+	if err := js.loadJSON(path, &file); err != nil {
 		return nil, err
 	}
 
-	return &snapshot, nil
+	return file.decode(), nil
 }
 
 // ListSnapshots 列出快照
@@ -194,13 +207,13 @@ func (js *JSONStore) ListSnapshots(ctx context.Context, agentID string) ([]types
 			continue
 		}
 
-		var snapshot types.Snapshot
+		var file snapshotFile
 		path := filepath.Join(snapshotsDir, entry.Name())
-		if err := js.loadJSON(path, &snapshot); err != nil {
+		if err := js.loadJSON(path, &file); err != nil {
 			continue // 忽略损坏的文件
 		}
 
-		snapshots = append(snapshots, snapshot)
+		snapshots = append(snapshots, *file.decode())
 	}
 
 	return snapshots, nil
@@ -40,6 +40,11 @@ func (t *BashRunTool) InputSchema() map[string]interface{} {
 	}
 }
 
+// ConcurrencyKey 命令可能读写任意文件,独占执行
+func (t *BashRunTool) ConcurrencyKey(input map[string]interface{}) string {
+	return tools.ExclusiveConcurrencyKey
+}
+
 func (t *BashRunTool) Execute(ctx context.Context, input map[string]interface{}, tc *tools.ToolContext) (interface{}, error) {
 	// 获取参数
 	cmd, ok := input["cmd"].(string)
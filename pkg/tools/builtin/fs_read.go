@@ -2,12 +2,17 @@ package builtin
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"strings"
 
 	"github.com/wordflowlab/agentsdk/pkg/tools"
+	"github.com/wordflowlab/agentsdk/pkg/types"
 )
 
+// maxMediaFileBytes 以图片/PDF 形式返回的文件大小上限(与 Anthropic 单张图片上限一致)
+const maxMediaFileBytes = 5 * 1024 * 1024
+
 // FsReadTool 文件读取工具
 type FsReadTool struct{}
 
@@ -45,6 +50,11 @@ func (t *FsReadTool) InputSchema() map[string]interface{} {
 	}
 }
 
+// ConcurrencyKey 同一路径的读写按顺序执行
+func (t *FsReadTool) ConcurrencyKey(input map[string]interface{}) string {
+	return tools.PathConcurrencyKey(input)
+}
+
 func (t *FsReadTool) Execute(ctx context.Context, input map[string]interface{}, tc *tools.ToolContext) (interface{}, error) {
 	// 获取参数
 	path, ok := input["path"].(string)
@@ -76,6 +86,11 @@ func (t *FsReadTool) Execute(ctx context.Context, input map[string]interface{},
 		}, nil
 	}
 
+	// 图片和 PDF 以内容块返回,由支持视觉的模型直接查看
+	if mediaType := types.MediaTypeFromPath(path); types.IsImageMediaType(mediaType) || mediaType == "application/pdf" {
+		return readMediaFile(path, mediaType, content), nil
+	}
+
 	// 分割成行
 	lines := strings.Split(content, "\n")
 	totalLines := len(lines)
@@ -123,6 +138,31 @@ func (t *FsReadTool) Execute(ctx context.Context, input map[string]interface{},
 	}, nil
 }
 
+// readMediaFile 把图片/PDF 文件转换为内容块
+func readMediaFile(path, mediaType, content string) interface{} {
+	if len(content) > maxMediaFileBytes {
+		return map[string]interface{}{
+			"ok":    false,
+			"error": fmt.Sprintf("file too large to inline: %d bytes (limit %d)", len(content), maxMediaFileBytes),
+			"recommendations": []string{
+				"压缩或缩小图片后重试",
+				"使用 bash_run 提取需要的信息",
+			},
+		}
+	}
+
+	data := base64.StdEncoding.EncodeToString([]byte(content))
+	var media types.ContentBlock = &types.ImageBlock{MediaType: mediaType, Data: data, Path: path}
+	if !types.IsImageMediaType(mediaType) {
+		media = &types.DocumentBlock{MediaType: mediaType, Data: data, Path: path}
+	}
+
+	return []types.ContentBlock{
+		&types.TextBlock{Text: fmt.Sprintf("Read %s (%s, %d bytes)", path, mediaType, len(content))},
+		media,
+	}
+}
+
 func (t *FsReadTool) Prompt() string {
 	return `Use this tool to inspect files within the sandboxed workspace.
 
@@ -130,6 +170,7 @@ Usage guidance:
 - Always pass paths relative to the sandbox working directory.
 - You may optionally provide "offset" and "limit" to control the slice of lines to inspect.
 - Large files will be truncated to keep responses compact; request additional ranges if needed.
+- Image files (png, jpg, gif, webp) and PDFs are returned as attachments you can view directly when the model supports vision.
 - Prefer batching adjacent reads in a single turn to minimize context churn.
 
 Safety/Limitations:
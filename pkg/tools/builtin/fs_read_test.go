@@ -0,0 +1,68 @@
+package builtin
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/wordflowlab/agentsdk/pkg/sandbox"
+	"github.com/wordflowlab/agentsdk/pkg/tools"
+	"github.com/wordflowlab/agentsdk/pkg/types"
+)
+
+func TestFsReadTool_ImageFile(t *testing.T) {
+	sb := sandbox.NewMockSandbox()
+	png := "\x89PNG\r\n\x1a\nfake"
+	if err := sb.FS().Write(context.Background(), "screens/login.PNG", png); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	tool, err := NewFsReadTool(nil)
+	if err != nil {
+		t.Fatalf("Failed to create tool: %v", err)
+	}
+
+	result, err := tool.Execute(context.Background(), map[string]interface{}{
+		"path": "screens/login.PNG",
+	}, &tools.ToolContext{Sandbox: sb})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	blocks, ok := result.([]types.ContentBlock)
+	if !ok || len(blocks) != 2 {
+		t.Fatalf("Expected text and image blocks, got %#v", result)
+	}
+	image, ok := blocks[1].(*types.ImageBlock)
+	if !ok {
+		t.Fatalf("Expected image block, got %T", blocks[1])
+	}
+	if image.MediaType != "image/png" || image.Path != "screens/login.PNG" {
+		t.Errorf("Unexpected image block: %+v", image)
+	}
+	if image.Data != base64.StdEncoding.EncodeToString([]byte(png)) {
+		t.Errorf("Unexpected image data: %s", image.Data)
+	}
+}
+
+func TestFsReadTool_TextFileUnchanged(t *testing.T) {
+	sb := sandbox.NewMockSandbox()
+	sb.FS().Write(context.Background(), "notes.txt", "a\nb\nc")
+
+	tool, _ := NewFsReadTool(nil)
+	result, err := tool.Execute(context.Background(), map[string]interface{}{
+		"path":  "notes.txt",
+		"limit": float64(2),
+	}, &tools.ToolContext{Sandbox: sb})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	resultMap, ok := result.(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected map result for text file, got %T", result)
+	}
+	if resultMap["content"] != "a\nb" {
+		t.Errorf("Expected first two lines, got %q", resultMap["content"])
+	}
+}
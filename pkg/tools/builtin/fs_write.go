@@ -40,6 +40,11 @@ func (t *FsWriteTool) InputSchema() map[string]interface{} {
 	}
 }
 
+// ConcurrencyKey 同一路径的读写按顺序执行
+func (t *FsWriteTool) ConcurrencyKey(input map[string]interface{}) string {
+	return tools.PathConcurrencyKey(input)
+}
+
 func (t *FsWriteTool) Execute(ctx context.Context, input map[string]interface{}, tc *tools.ToolContext) (interface{}, error) {
 	// 获取参数
 	path, ok := input["path"].(string)
@@ -0,0 +1,66 @@
+package tools
+
+import "path"
+
+// ExclusiveConcurrencyKey 独占执行: 前面的调用全部完成后才开始,完成后才继续后面的调用
+const ExclusiveConcurrencyKey = "*"
+
+// ConcurrencyKeyer 可选接口,声明工具调用之间的并发约束
+// 未实现该接口的工具可以与同一批次的其它调用并发执行
+type ConcurrencyKeyer interface {
+	// ConcurrencyKey 返回本次调用占用的资源键
+	// 空字符串表示可以并发;键相同的调用按模型给出的顺序依次执行;
+	// ExclusiveConcurrencyKey 表示独占执行
+	ConcurrencyKey(input map[string]interface{}) string
+}
+
+// ConcurrencyKeyOf 返回工具调用的资源键
+func ConcurrencyKeyOf(tool Tool, input map[string]interface{}) string {
+	if keyer, ok := tool.(ConcurrencyKeyer); ok {
+		return keyer.ConcurrencyKey(input)
+	}
+	return ""
+}
+
+// PathConcurrencyKey 文件类工具的资源键,同一路径的读写按顺序执行
+func PathConcurrencyKey(input map[string]interface{}) string {
+	p, _ := input["path"].(string)
+	if p == "" {
+		return ExclusiveConcurrencyKey
+	}
+	return "path:" + path.Clean(p)
+}
+
+// PlanBatches 把一组工具调用的资源键划分为可并发执行的批次
+// 批次按顺序执行,批次内的调用互不冲突;返回每个批次包含的调用下标
+func PlanBatches(keys []string) [][]int {
+	var batches [][]int
+	var current []int
+	used := make(map[string]bool)
+
+	flush := func() {
+		if len(current) > 0 {
+			batches = append(batches, current)
+		}
+		current = nil
+		used = make(map[string]bool)
+	}
+
+	for i, key := range keys {
+		switch {
+		case key == ExclusiveConcurrencyKey:
+			flush()
+			batches = append(batches, []int{i})
+			continue
+		case key != "" && used[key]:
+			flush()
+		}
+		current = append(current, i)
+		if key != "" {
+			used[key] = true
+		}
+	}
+	flush()
+
+	return batches
+}
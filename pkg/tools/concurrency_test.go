@@ -0,0 +1,36 @@
+package tools
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanBatches(t *testing.T) {
+	cases := []struct {
+		name string
+		keys []string
+		want [][]int
+	}{
+		{"all parallel", []string{"", "", ""}, [][]int{{0, 1, 2}}},
+		{"same path", []string{"path:a", "path:b", "path:a", ""}, [][]int{{0, 1}, {2, 3}}},
+		{"exclusive", []string{"", "*", "", ""}, [][]int{{0}, {1}, {2, 3}}},
+		{"empty", nil, nil},
+	}
+
+	for _, tc := range cases {
+		if got := PlanBatches(tc.keys); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: PlanBatches(%v) = %v, want %v", tc.name, tc.keys, got, tc.want)
+		}
+	}
+}
+
+func TestPathConcurrencyKey(t *testing.T) {
+	a := PathConcurrencyKey(map[string]interface{}{"path": "./src/../main.go"})
+	b := PathConcurrencyKey(map[string]interface{}{"path": "main.go"})
+	if a != b {
+		t.Errorf("Expected equivalent paths to share a key, got %q and %q", a, b)
+	}
+	if key := PathConcurrencyKey(map[string]interface{}{}); key != ExclusiveConcurrencyKey {
+		t.Errorf("Expected exclusive key without path, got %q", key)
+	}
+}
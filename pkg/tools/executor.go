@@ -11,8 +11,8 @@ import (
 
 // ExecutorConfig 执行器配置
 type ExecutorConfig struct {
-	MaxConcurrency int           // 最大并发数
-	DefaultTimeout time.Duration // 默认超时时间
+	MaxConcurrency int           // 最大并发数,默认 3
+	DefaultTimeout time.Duration // 默认超时时间,默认 60s
 }
 
 // Executor 工具执行器
@@ -37,6 +37,11 @@ func NewExecutor(config ExecutorConfig) *Executor {
 	}
 }
 
+// MaxConcurrency 返回最大并发数
+func (e *Executor) MaxConcurrency() int {
+	return e.config.MaxConcurrency
+}
+
 // ExecuteRequest 执行请求
 type ExecuteRequest struct {
 	Tool    Tool
@@ -0,0 +1,175 @@
+package types
+
+import "fmt"
+
+// EncodeMessages 将消息编码为带类型标记的 JSON 结构
+// ContentBlock 是接口,直接序列化会丢失具体类型,持久化和录制时使用本编码
+func EncodeMessages(messages []Message) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(messages))
+	for _, msg := range messages {
+		result = append(result, EncodeMessage(msg))
+	}
+	return result
+}
+
+// EncodeMessage 将单条消息编码为带类型标记的 JSON 结构
+func EncodeMessage(msg Message) map[string]interface{} {
+	return map[string]interface{}{
+		"role":    string(msg.Role),
+		"content": encodeBlocks(msg.Content),
+	}
+}
+
+// DecodeMessages 将 EncodeMessages 的结果(或其 JSON 往返形式)还原为消息列表
+func DecodeMessages(encoded []map[string]interface{}) []Message {
+	messages := make([]Message, 0, len(encoded))
+	for _, m := range encoded {
+		messages = append(messages, DecodeMessage(m))
+	}
+	return messages
+}
+
+// DecodeMessage 将编码后的消息还原为 Message,无法识别的内容块会被忽略
+func DecodeMessage(encoded map[string]interface{}) Message {
+	role, _ := encoded["role"].(string)
+	return Message{
+		Role:    MessageRole(role),
+		Content: decodeBlocks(encoded["content"]),
+	}
+}
+
+func encodeBlocks(blocks []ContentBlock) []map[string]interface{} {
+	content := make([]map[string]interface{}, 0, len(blocks))
+	for _, block := range blocks {
+		content = append(content, encodeBlock(block))
+	}
+	return content
+}
+
+func encodeBlock(block ContentBlock) map[string]interface{} {
+	switch b := block.(type) {
+	case *TextBlock:
+		return map[string]interface{}{
+			"type": "text",
+			"text": b.Text,
+		}
+	case *ToolUseBlock:
+		return map[string]interface{}{
+			"type":  "tool_use",
+			"id":    b.ID,
+			"name":  b.Name,
+			"input": b.Input,
+		}
+	case *ToolResultBlock:
+		encoded := map[string]interface{}{
+			"type":        "tool_result",
+			"tool_use_id": b.ToolUseID,
+			"is_error":    b.IsError,
+		}
+		// 多模态结果是内容块列表,单独编码以保留块类型
+		if blocks, ok := b.Content.([]ContentBlock); ok {
+			encoded["content_blocks"] = encodeBlocks(blocks)
+		} else {
+			encoded["content"] = b.Content
+		}
+		return encoded
+	case *ThinkingBlock:
+		return map[string]interface{}{
+			"type":      "thinking",
+			"thinking":  b.Thinking,
+			"signature": b.Signature,
+			"data":      b.Data,
+		}
+	case *ImageBlock:
+		return map[string]interface{}{
+			"type":       "image",
+			"media_type": b.MediaType,
+			"data":       b.Data,
+			"url":        b.URL,
+			"path":       b.Path,
+		}
+	case *DocumentBlock:
+		return map[string]interface{}{
+			"type":       "document",
+			"media_type": b.MediaType,
+			"data":       b.Data,
+			"url":        b.URL,
+			"path":       b.Path,
+			"title":      b.Title,
+		}
+	default:
+		return map[string]interface{}{
+			"type":  fmt.Sprintf("%T", block),
+			"block": block,
+		}
+	}
+}
+
+func decodeBlocks(raw interface{}) []ContentBlock {
+	var items []map[string]interface{}
+	switch v := raw.(type) {
+	case []map[string]interface{}:
+		items = v
+	case []interface{}:
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok {
+				items = append(items, m)
+			}
+		}
+	}
+
+	blocks := make([]ContentBlock, 0, len(items))
+	for _, item := range items {
+		if block := decodeBlock(item); block != nil {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+func decodeBlock(block map[string]interface{}) ContentBlock {
+	switch block["type"] {
+	case "text":
+		text, _ := block["text"].(string)
+		return &TextBlock{Text: text}
+	case "tool_use":
+		id, _ := block["id"].(string)
+		name, _ := block["name"].(string)
+		input, _ := block["input"].(map[string]interface{})
+		if input == nil {
+			input = make(map[string]interface{})
+		}
+		return &ToolUseBlock{ID: id, Name: name, Input: input}
+	case "tool_result":
+		toolUseID, _ := block["tool_use_id"].(string)
+		isError, _ := block["is_error"].(bool)
+		result := &ToolResultBlock{ToolUseID: toolUseID, IsError: isError}
+		if nested, ok := block["content_blocks"]; ok {
+			result.Content = decodeBlocks(nested)
+		} else {
+			result.Content = block["content"]
+		}
+		return result
+	case "thinking":
+		thinking, _ := block["thinking"].(string)
+		signature, _ := block["signature"].(string)
+		data, _ := block["data"].(string)
+		return &ThinkingBlock{Thinking: thinking, Signature: signature, Data: data}
+	case "image":
+		image := &ImageBlock{}
+		image.MediaType, _ = block["media_type"].(string)
+		image.Data, _ = block["data"].(string)
+		image.URL, _ = block["url"].(string)
+		image.Path, _ = block["path"].(string)
+		return image
+	case "document":
+		doc := &DocumentBlock{}
+		doc.MediaType, _ = block["media_type"].(string)
+		doc.Data, _ = block["data"].(string)
+		doc.URL, _ = block["url"].(string)
+		doc.Path, _ = block["path"].(string)
+		doc.Title, _ = block["title"].(string)
+		return doc
+	}
+	return nil
+}
@@ -1,6 +1,9 @@
 package types
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // PermissionMode 权限模式
 type PermissionMode string
@@ -56,6 +59,33 @@ type AgentTemplateRuntime struct {
 	Metadata           map[string]interface{} `json:"metadata,omitempty"`
 	ToolTimeoutMs      int                    `json:"tool_timeout_ms,omitempty"`
 	MaxToolConcurrency int                    `json:"max_tool_concurrency,omitempty"`
+	MaxToolResultChars int                    `json:"max_tool_result_chars,omitempty"` // 工具结果超过该字符数时写入沙箱文件,0 使用默认值,负数不限制
+	PromptCache        *PromptCacheConfig     `json:"prompt_cache,omitempty"`
+	Budget             *RunBudget             `json:"budget,omitempty"`
+}
+
+// RunBudget 单轮执行(一次 Send/Chat/Stream)的预算,字段为 0 表示不限制
+// 任一预算耗尽时停止模型与工具循环,ProgressDoneEvent.Reason 给出触发的预算
+type RunBudget struct {
+	MaxSteps        int `json:"max_steps,omitempty"`         // 模型调用次数
+	MaxToolCalls    int `json:"max_tool_calls,omitempty"`    // 工具调用次数,超出的调用直接返回错误结果
+	MaxInputTokens  int `json:"max_input_tokens,omitempty"`  // 累计输入 Token(含缓存读写)
+	MaxOutputTokens int `json:"max_output_tokens,omitempty"` // 累计输出 Token
+	MaxDurationMs   int `json:"max_duration_ms,omitempty"`   // 墙钟时间
+
+	// WrapUp 预算耗尽时再调用一次模型,要求其不再使用工具、基于已有信息给出最终回答
+	WrapUp bool `json:"wrap_up,omitempty"`
+}
+
+// PromptCacheConfig 提示词缓存配置(Anthropic cache_control 断点)
+// 模板未配置时默认缓存系统提示词、工具定义和对话前缀;
+// Enabled 为 true 但未指定任何缓存部分时同样全部缓存
+type PromptCacheConfig struct {
+	Enabled  bool   `json:"enabled"`
+	System   bool   `json:"system,omitempty"`   // 缓存系统提示词(含工具手册)
+	Tools    bool   `json:"tools,omitempty"`    // 缓存工具定义
+	Messages bool   `json:"messages,omitempty"` // 缓存滚动的对话前缀
+	TTL      string `json:"ttl,omitempty"`      // 缓存有效期: "5m"(默认) | "1h"
 }
 
 // AgentTemplateDefinition Agent模板定义
@@ -65,16 +95,39 @@ type AgentTemplateDefinition struct {
 	SystemPrompt string                `json:"system_prompt"`
 	Model        string                `json:"model,omitempty"`
 	Tools        interface{}           `json:"tools"` // []string or "*"
+	Middlewares  []string              `json:"middlewares,omitempty"`
 	Permission   *PermissionConfig     `json:"permission,omitempty"`
 	Runtime      *AgentTemplateRuntime `json:"runtime,omitempty"`
 }
 
 // ModelConfig 模型配置
 type ModelConfig struct {
-	Provider string `json:"provider"` // "anthropic", "openai", etc.
-	Model    string `json:"model"`
-	APIKey   string `json:"api_key,omitempty"`
-	BaseURL  string `json:"base_url,omitempty"`
+	Provider string       `json:"provider"` // "anthropic", "openai", etc.
+	Model    string       `json:"model"`
+	APIKey   string       `json:"api_key,omitempty"`
+	BaseURL  string       `json:"base_url,omitempty"`
+	Retry    *RetryConfig `json:"retry,omitempty"` // 重试配置,为空时使用默认值
+
+	// ThinkingBudgetTokens 扩展思考的 Token 预算(Anthropic),Ollama 大于 0 时开启 think;0 表示不开启
+	ThinkingBudgetTokens int `json:"thinking_budget_tokens,omitempty"`
+
+	// Vision 显式声明模型是否支持图片/文档输入,为空时按模型名称推断(Ollama 查询模型能力)
+	Vision *bool `json:"vision,omitempty"`
+
+	// 多 Key 负载均衡与故障转移
+	APIKeys     []string      `json:"api_keys,omitempty"`     // 多个 API Key,按 KeyStrategy 分摊请求
+	KeyStrategy string        `json:"key_strategy,omitempty"` // "round_robin"(默认) | "least_loaded"
+	TimeoutMs   int           `json:"timeout_ms,omitempty"`   // 单个后端的响应超时,超时后切换到下一个
+	Fallbacks   []ModelConfig `json:"fallbacks,omitempty"`    // 按顺序尝试的备用模型,未填写的 Provider/BaseURL/Key 继承主配置
+}
+
+// RetryConfig 模型调用重试配置
+// 仅对限流(429)、服务端错误(5xx)和连接中断重试
+type RetryConfig struct {
+	MaxAttempts      int `json:"max_attempts,omitempty"`       // 最大尝试次数(含首次),默认 3,设为 1 关闭重试
+	MaxElapsedMs     int `json:"max_elapsed_ms,omitempty"`     // 重试总耗时上限,默认 120000
+	InitialBackoffMs int `json:"initial_backoff_ms,omitempty"` // 首次退避时间,默认 1000
+	MaxBackoffMs     int `json:"max_backoff_ms,omitempty"`     // 单次退避上限,默认 30000
 }
 
 // SandboxKind 沙箱类型
@@ -145,6 +198,7 @@ type AgentConfig struct {
 	AgentID         string                 `json:"agent_id,omitempty"`
 	TemplateID      string                 `json:"template_id"`
 	TemplateVersion string                 `json:"template_version,omitempty"`
+	SystemPrompt    string                 `json:"system_prompt,omitempty"` // 非空时替换模板的系统提示词(工具手册仍会追加)
 	ModelConfig     *ModelConfig           `json:"model_config,omitempty"`
 	Sandbox         *SandboxConfig         `json:"sandbox,omitempty"`
 	Tools           []string               `json:"tools,omitempty"`
@@ -199,8 +253,16 @@ type SubscribeOptions struct {
 
 // CompleteResult 完成结果
 type CompleteResult struct {
-	Status        string    `json:"status"` // "ok" or "paused"
+	Status        string    `json:"status"`           // "ok" or "paused"
+	Reason        string    `json:"reason,omitempty"` // 结束原因,同 ProgressDoneEvent.Reason
 	Text          string    `json:"text,omitempty"`
 	Last          *Bookmark `json:"last,omitempty"`
 	PermissionIDs []string  `json:"permission_ids,omitempty"`
 }
+
+// StructuredResult 结构化输出结果
+type StructuredResult struct {
+	CompleteResult
+	Data     json.RawMessage `json:"data,omitempty"` // 通过 schema 校验的 JSON
+	Attempts int             `json:"attempts"`       // 模型作答次数(含修复)
+}
@@ -107,12 +107,24 @@ func (e *ProgressToolErrorEvent) EventType() string     { return "tool:error" }
 // ProgressDoneEvent 单轮完成事件
 type ProgressDoneEvent struct {
 	Step   int    `json:"step"`
-	Reason string `json:"reason"` // "completed" or "interrupted"
+	Reason string `json:"reason"` // DoneReason 常量之一
 }
 
 func (e *ProgressDoneEvent) Channel() AgentChannel { return ChannelProgress }
 func (e *ProgressDoneEvent) EventType() string     { return "done" }
 
+// ProgressDoneEvent.Reason 取值
+const (
+	DoneReasonCompleted       = "completed"         // 模型给出最终回复
+	DoneReasonInterrupted     = "interrupted"       // 被取消或中断
+	DoneReasonError           = "error"             // 模型调用或持久化失败
+	DoneReasonMaxSteps        = "max_steps"         // 模型调用次数预算耗尽
+	DoneReasonMaxToolCalls    = "max_tool_calls"    // 工具调用次数预算耗尽
+	DoneReasonMaxInputTokens  = "max_input_tokens"  // 输入 Token 预算耗尽
+	DoneReasonMaxOutputTokens = "max_output_tokens" // 输出 Token 预算耗尽
+	DoneReasonMaxDuration     = "max_duration"      // 墙钟时间预算耗尽
+)
+
 // ===================
 // Control Channel Events
 // ===================
@@ -146,9 +158,16 @@ func (e *ControlPermissionDecidedEvent) EventType() string     { return "permiss
 
 // MonitorStateChangedEvent 状态变更事件
 type MonitorStateChangedEvent struct {
-	State AgentRuntimeState `json:"state"`
+	State  AgentRuntimeState `json:"state"`
+	Reason string            `json:"reason,omitempty"` // 暂停原因,见 PauseReason*
 }
 
+// 暂停原因
+const (
+	PauseReasonPermission = "permission" // 等待工具调用审批
+	PauseReasonUser       = "user"       // 调用 Pause
+)
+
 func (e *MonitorStateChangedEvent) Channel() AgentChannel { return ChannelMonitor }
 func (e *MonitorStateChangedEvent) EventType() string     { return "state_changed" }
 
@@ -174,9 +193,14 @@ func (e *MonitorErrorEvent) EventType() string     { return "error" }
 
 // MonitorTokenUsageEvent Token使用统计事件
 type MonitorTokenUsageEvent struct {
-	InputTokens  int64 `json:"input_tokens"`
-	OutputTokens int64 `json:"output_tokens"`
-	TotalTokens  int64 `json:"total_tokens"`
+	InputTokens              int64  `json:"input_tokens"` // 未命中缓存的输入 Token
+	OutputTokens             int64  `json:"output_tokens"`
+	CacheCreationInputTokens int64  `json:"cache_creation_input_tokens,omitempty"` // 写入缓存的输入 Token
+	CacheReadInputTokens     int64  `json:"cache_read_input_tokens,omitempty"`     // 从缓存读取的输入 Token
+	TotalTokens              int64  `json:"total_tokens"`
+	Provider                 string `json:"provider,omitempty"` // 实际处理请求的提供商
+	Model                    string `json:"model,omitempty"`    // 实际处理请求的模型
+	Backend                  string `json:"backend,omitempty"`  // 实际处理请求的后端标识(故障转移/多 Key 时区分)
 }
 
 func (e *MonitorTokenUsageEvent) Channel() AgentChannel { return ChannelMonitor }
@@ -229,9 +253,13 @@ func (e *MonitorReminderSentEvent) EventType() string     { return "reminder_sen
 
 // MonitorContextCompressionEvent 上下文压缩事件
 type MonitorContextCompressionEvent struct {
-	Phase   string  `json:"phase"` // "start" or "end"
-	Summary string  `json:"summary,omitempty"`
-	Ratio   float64 `json:"ratio,omitempty"`
+	Phase          string  `json:"phase"` // "start" or "end"
+	Summary        string  `json:"summary,omitempty"`
+	Ratio          float64 `json:"ratio,omitempty"` // 压缩后与压缩前的估算 token 数之比
+	TokensBefore   int     `json:"tokens_before,omitempty"`
+	TokensAfter    int     `json:"tokens_after,omitempty"`
+	MessagesBefore int     `json:"messages_before,omitempty"`
+	MessagesAfter  int     `json:"messages_after,omitempty"`
 }
 
 func (e *MonitorContextCompressionEvent) Channel() AgentChannel { return ChannelMonitor }
@@ -256,3 +284,23 @@ type MonitorToolManualUpdatedEvent struct {
 
 func (e *MonitorToolManualUpdatedEvent) Channel() AgentChannel { return ChannelMonitor }
 func (e *MonitorToolManualUpdatedEvent) EventType() string     { return "tool_manual_updated" }
+
+// MonitorMessageQueuedEvent 消息进入收件箱事件
+type MonitorMessageQueuedEvent struct {
+	MessageID string `json:"message_id"`
+	Kind      string `json:"kind"`    // "queued": 当前轮结束后处理; "steer": 在当前轮的下一步前注入
+	Pending   int    `json:"pending"` // 同类待处理消息数(含本条)
+}
+
+func (e *MonitorMessageQueuedEvent) Channel() AgentChannel { return ChannelMonitor }
+func (e *MonitorMessageQueuedEvent) EventType() string     { return "message_queued" }
+
+// MonitorMessageConsumedEvent 收件箱消息加入对话事件
+type MonitorMessageConsumedEvent struct {
+	MessageID string `json:"message_id"`
+	Kind      string `json:"kind"` // "queued" or "steer"
+	Step      int    `json:"step"`
+}
+
+func (e *MonitorMessageConsumedEvent) Channel() AgentChannel { return ChannelMonitor }
+func (e *MonitorMessageConsumedEvent) EventType() string     { return "message_consumed" }
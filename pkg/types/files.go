@@ -0,0 +1,13 @@
+package types
+
+import "time"
+
+// FileActivity Agent 通过工具访问过的文件
+type FileActivity struct {
+	Path         string    `json:"path"`
+	Reads        int       `json:"reads"`
+	Writes       int       `json:"writes"` // 写入与编辑
+	LastReadAt   time.Time `json:"last_read_at,omitempty"`
+	LastWriteAt  time.Time `json:"last_write_at,omitempty"`
+	LastAccessAt time.Time `json:"last_access_at"`
+}
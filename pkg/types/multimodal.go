@@ -0,0 +1,57 @@
+package types
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// ImageBlock 图片内容块
+// 图片来源三选一: Data(base64 编码)、URL、Path(沙箱内的文件,发送前由 Agent 读取并填充 Data)
+type ImageBlock struct {
+	MediaType string `json:"media_type,omitempty"` // image/png、image/jpeg、image/gif、image/webp
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Path      string `json:"path,omitempty"`
+}
+
+func (b *ImageBlock) IsContentBlock() {}
+
+// DocumentBlock 文档内容块(PDF 或纯文本)
+// 来源与 ImageBlock 相同;纯文本文档的 Data 同样为 base64 编码
+type DocumentBlock struct {
+	MediaType string `json:"media_type,omitempty"` // application/pdf、text/plain 等
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Path      string `json:"path,omitempty"`
+	Title     string `json:"title,omitempty"`
+}
+
+func (b *DocumentBlock) IsContentBlock() {}
+
+// mediaTypes 支持的附件扩展名
+var mediaTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".pdf":  "application/pdf",
+	".txt":  "text/plain",
+	".md":   "text/markdown",
+	".csv":  "text/csv",
+}
+
+// MediaTypeFromPath 根据扩展名推断附件的媒体类型,不支持时返回空字符串
+func MediaTypeFromPath(path string) string {
+	return mediaTypes[strings.ToLower(filepath.Ext(path))]
+}
+
+// IsImageMediaType 是否为图片类型
+func IsImageMediaType(mediaType string) bool {
+	return strings.HasPrefix(mediaType, "image/")
+}
+
+// IsTextMediaType 是否为纯文本类型
+func IsTextMediaType(mediaType string) bool {
+	return strings.HasPrefix(mediaType, "text/")
+}
@@ -0,0 +1,13 @@
+package types
+
+// AgentStatus Agent 运行状态
+type AgentStatus struct {
+	AgentID      string            `json:"agent_id"`
+	State        AgentRuntimeState `json:"state"`
+	StepCount    int               `json:"step_count"`
+	LastSfpIndex int               `json:"last_sfp_index"`
+	LastBookmark *Bookmark         `json:"last_bookmark,omitempty"`
+	Cursor       int64             `json:"cursor"`
+	Breakpoint   BreakpointState   `json:"breakpoint"`
+	RecentFiles  []string          `json:"recent_files,omitempty"` // 最近读写的文件,最近访问的在前
+}
@@ -0,0 +1,16 @@
+package types
+
+// ThinkingBlock 模型的思考/推理内容块
+// 来源: Anthropic thinking/redacted_thinking、Deepseek/GLM reasoning_content、Ollama thinking
+type ThinkingBlock struct {
+	Thinking  string `json:"thinking"`
+	Signature string `json:"signature,omitempty"` // Anthropic 签名,回传时必须原样携带
+	Data      string `json:"data,omitempty"`      // Anthropic redacted_thinking 的加密内容
+}
+
+func (b *ThinkingBlock) IsContentBlock() {}
+
+// Redacted 是否为被加密的思考内容
+func (b *ThinkingBlock) Redacted() bool {
+	return b.Data != ""
+}